@@ -74,7 +74,7 @@ func (l *LibraryManager) SearchIterWithFilter(query string, filter func(*subsoni
 }
 
 func (l *LibraryManager) GetAlbum(id string) (*subsonic.AlbumID3, error) {
-	a, err := l.s.Server.GetAlbum(id)
+	a, err := l.s.Server().GetAlbum(id)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +85,6 @@ type baseIter struct {
 	listType      string
 	pos           int
 	l             *LibraryManager
-	s             *subsonic.Client
 	opts          map[string]string
 	prefetched    []*subsonic.AlbumID3
 	prefetchedPos int
@@ -96,7 +95,6 @@ func (l *LibraryManager) newBaseIter(listType string, opts map[string]string) *b
 	return &baseIter{
 		listType: listType,
 		l:        l,
-		s:        l.s.Server,
 		opts:     opts,
 	}
 }
@@ -117,8 +115,13 @@ func (r *baseIter) Next() *subsonic.AlbumID3 {
 
 		return a
 	}
+	cli := r.l.s.Server()
+	if cli == nil {
+		r.done = true
+		return nil
+	}
 	r.opts["offset"] = strconv.Itoa(r.pos)
-	albums, err := r.s.GetAlbumList2(r.listType, r.opts)
+	albums, err := cli.GetAlbumList2(r.listType, r.opts)
 	if err != nil {
 		log.Println(err)
 		albums = nil
@@ -156,7 +159,7 @@ func (l *LibraryManager) newSearchIter(query string, filter func(*subsonic.Album
 	return &searchIter{
 		searchIterBase: searchIterBase{
 			query: query,
-			s:     l.s.Server,
+			sm:    l.s,
 		},
 		l:          l,
 		filter:     filter,
@@ -171,7 +174,7 @@ func (s *searchIter) Next() *subsonic.AlbumID3 {
 
 	// prefetch more search results from server
 	if s.prefetched == nil {
-		results := s.searchIterBase.fetchResults()
+		results, cli := s.searchIterBase.fetchResults()
 		if results == nil {
 			s.done = true
 			s.albumIDset = nil
@@ -184,7 +187,7 @@ func (s *searchIter) Next() *subsonic.AlbumID3 {
 
 		// add results from artists search
 		for _, artist := range results.Artist {
-			artist, err := s.s.GetArtist(artist.ID)
+			artist, err := cli.GetArtist(artist.ID)
 			if err != nil || artist == nil {
 				log.Printf("error fetching artist: %s", err.Error())
 			} else {
@@ -198,7 +201,7 @@ func (s *searchIter) Next() *subsonic.AlbumID3 {
 			if song.AlbumID == "" {
 				continue
 			}
-			album, err := s.s.GetAlbum(song.AlbumID)
+			album, err := cli.GetAlbum(song.AlbumID)
 			if err != nil || album == nil {
 				log.Printf("error fetching album: %s", err.Error())
 			} else {
@@ -242,7 +245,6 @@ func (s *searchIter) addNewAlbums(al []*subsonic.AlbumID3) {
 type randomIter struct {
 	albumIDSet    map[string]bool
 	l             *LibraryManager
-	s             *subsonic.Client
 	prefetched    []*subsonic.AlbumID3
 	prefetchedPos int
 	// Random iter works in two phases - phase 1 by requesting random
@@ -259,7 +261,6 @@ type randomIter struct {
 func (l *LibraryManager) newRandomIter() *randomIter {
 	return &randomIter{
 		l:          l,
-		s:          l.s.Server,
 		albumIDSet: make(map[string]bool),
 	}
 }
@@ -270,9 +271,15 @@ func (r *randomIter) Next() *subsonic.AlbumID3 {
 	}
 
 	if r.prefetched == nil {
+		cli := r.l.s.Server()
+		if cli == nil {
+			r.done = true
+			r.albumIDSet = nil
+			return nil
+		}
 		if r.phaseTwo {
 			for len(r.prefetched) == 0 {
-				albums, err := r.s.GetAlbumList2("newest", map[string]string{"size": "20", "offset": strconv.Itoa(r.offset)})
+				albums, err := cli.GetAlbumList2("newest", map[string]string{"size": "20", "offset": strconv.Itoa(r.offset)})
 				if err != nil {
 					log.Println(err)
 					albums = nil
@@ -295,7 +302,7 @@ func (r *randomIter) Next() *subsonic.AlbumID3 {
 			}
 			r.prefetchedPos = 0
 		} else {
-			albums, err := r.s.GetAlbumList2("random", map[string]string{"size": "25"})
+			albums, err := cli.GetAlbumList2("random", map[string]string{"size": "25"})
 			if err != nil {
 				log.Println(err)
 				r.done = true
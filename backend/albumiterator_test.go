@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+func newTestLibraryManager(t *testing.T) (*LibraryManager, *fakeServer) {
+	t.Helper()
+	srv := newFakeServer(t)
+	return NewLibraryManager(&ServerManager{server: srv.newClient(t)}, &SmartPlaylistsConfig{}), srv
+}
+
+func albumIDs(iter AlbumIterator) []string {
+	var ids []string
+	for al := iter.Next(); al != nil; al = iter.Next() {
+		ids = append(ids, al.ID)
+	}
+	return ids
+}
+
+func trackIDs(iter TrackIterator) []string {
+	var ids []string
+	for tr := iter.Next(); tr != nil; tr = iter.Next() {
+		ids = append(ids, tr.ID)
+	}
+	return ids
+}
+
+func TestAlbumsIter(t *testing.T) {
+	for _, tt := range []struct {
+		sort AlbumSortOrder
+		want string
+	}{
+		{AlbumSortRecentlyAdded, "al-9 al-3 al-8 al-6 al-2 al-7 al-12 al-1 al-5 al-11 al-4 al-10"},
+		{AlbumSortRecentlyPlayed, ""},
+		{AlbumSortTitleAZ, "al-8 al-2 al-9 al-5 al-12 al-10 al-1 al-7 al-11 al-6 al-3 al-4"},
+		{AlbumSortArtistAZ, "al-1 al-2 al-3 al-4 al-5 al-6 al-7 al-8 al-9 al-10 al-11 al-12"},
+		{AlbumSortYearAscending, "al-10 al-4 al-11 al-5 al-1 al-12 al-7 al-2 al-6 al-8 al-3 al-9"},
+		{AlbumSortYearDescending, "al-9 al-3 al-8 al-6 al-2 al-7 al-12 al-1 al-5 al-11 al-4 al-10"},
+	} {
+		t.Run(string(tt.sort), func(t *testing.T) {
+			lm, _ := newTestLibraryManager(t)
+			if got := strings.Join(albumIDs(lm.AlbumsIter(tt.sort)), " "); got != tt.want {
+				t.Errorf("albums = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlbumsIterClientSwitch(t *testing.T) {
+	lm, srv := newTestLibraryManager(t)
+	iter := lm.AlbumsIter(AlbumSortArtistAZ)
+	for i := 0; i < 10; i++ { // first page
+		iter.Next()
+	}
+	// e.g. the connection switched to the alternate hostname
+	alt := newFakeServer(t)
+	lm.s.server = alt.newClient(t)
+	if got := albumIDs(iter); len(got) != 2 {
+		t.Errorf("got %d remaining albums, want 2", len(got))
+	}
+	if srv.Requests("getAlbumList2") != 1 || alt.Requests("getAlbumList2") == 0 {
+		t.Errorf("next page not fetched with the current client")
+	}
+}
+
+func TestRandomAlbumsIter(t *testing.T) {
+	lm, srv := newTestLibraryManager(t)
+	ids := albumIDs(lm.AlbumsIter(AlbumSortRandom))
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("album %s returned twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(srv.albums) {
+		t.Errorf("got %d albums, want %d", len(seen), len(srv.albums))
+	}
+}
+
+func TestGenreIter(t *testing.T) {
+	lm, _ := newTestLibraryManager(t)
+	if got := strings.Join(albumIDs(lm.GenreIter("Rock")), " "); got != "al-4 al-5 al-6" {
+		t.Errorf("albums = %q, want %q", got, "al-4 al-5 al-6")
+	}
+}
+
+func TestSearchIter(t *testing.T) {
+	after2011 := func(al *subsonic.AlbumID3) bool { return al.Year > 2011 }
+	for _, tt := range []struct {
+		name   string
+		query  string
+		filter func(*subsonic.AlbumID3) bool
+		want   string
+	}{
+		// album name matches come first, then the albums of matching
+		// artists, then the albums of matching tracks
+		{"album, artist and track", "harbor", nil, "al-12 al-4 al-5 al-6 al-1"},
+		{"albums only", "night", nil, "al-7 al-10"},
+		{"track on already returned album", "lights", nil, "al-1"},
+		{"artist and tracks", "blue", nil, "al-4 al-5 al-6 al-10 al-11"},
+		{"no results", "zzz", nil, ""},
+		{"filtered", "harbor", after2011, "al-12 al-6 al-1"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lm, _ := newTestLibraryManager(t)
+			iter := lm.SearchIter(tt.query)
+			if tt.filter != nil {
+				iter = lm.SearchIterWithFilter(tt.query, tt.filter)
+			}
+			if got := strings.Join(albumIDs(iter), " "); got != tt.want {
+				t.Errorf("albums = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrackIterators(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		iter func(*LibraryManager) TrackIterator
+		want string
+	}{
+		{"search", func(lm *LibraryManager) TrackIterator { return lm.SearchTracksIterator("light") },
+			// matching tracks, then the tracks of matching albums
+			"tr-2 tr-16 tr-1 tr-9 tr-10"},
+		{"search no results", func(lm *LibraryManager) TrackIterator { return lm.SearchTracksIterator("zzz") },
+			""},
+		{"all tracks", func(lm *LibraryManager) TrackIterator { return lm.AllTracksIterator() },
+			"tr-1 tr-2 tr-3 tr-4 tr-5 tr-6 tr-7 tr-8 tr-9 tr-10 tr-11 tr-12 " +
+				"tr-13 tr-14 tr-15 tr-16 tr-17 tr-18 tr-19 tr-20 tr-21 tr-22 tr-23 tr-24"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lm, _ := newTestLibraryManager(t)
+			if got := strings.Join(trackIDs(tt.iter(lm)), " "); got != tt.want {
+				t.Errorf("tracks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchingIterator(t *testing.T) {
+	lm, _ := newTestLibraryManager(t)
+	b := NewBatchingIterator(lm.AlbumsIter(AlbumSortArtistAZ))
+	for i, want := range []int{5, 5, 2, 0} {
+		if got := len(b.NextN(5)); got != want {
+			t.Errorf("batch %d has %d albums, want %d", i, got, want)
+		}
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/20after4/configdir"
-	"github.com/zalando/go-keyring"
 )
 
 var (
@@ -21,13 +20,18 @@ var (
 )
 
 type App struct {
-	Config          *Config
-	ServerManager   *ServerManager
-	ImageManager    *ImageManager
-	LibraryManager  *LibraryManager
-	PlaybackManager *PlaybackManager
-	Player          *player.Player
-	UpdateChecker   UpdateChecker
+	Config           *Config
+	ServerManager    *ServerManager
+	ImageManager     *ImageManager
+	LibraryManager   *LibraryManager
+	DownloadManager  *DownloadManager
+	EqualizerManager *EqualizerManager
+	Scheduler        *Scheduler
+	PinnedItems      *PinnedItemManager
+	PlaybackManager  *PlaybackManager
+	PlayHistory      *PlayHistory
+	Player           *player.Player
+	UpdateChecker    UpdateChecker
 
 	appName       string
 	appVersionTag string
@@ -60,9 +64,18 @@ func StartupApp(appName, appVersionTag, configFile, latestReleaseURL string) (*A
 		return nil, err
 	}
 
-	a.ServerManager = NewServerManager(appName)
-	a.PlaybackManager = NewPlaybackManager(a.bgrndCtx, a.ServerManager, a.Player, &a.Config.Scrobbling)
-	a.LibraryManager = NewLibraryManager(a.ServerManager)
+	a.ServerManager = NewServerManager(a.bgrndCtx, appName)
+	a.initCredentialStore()
+	a.PlaybackManager = NewPlaybackManager(a.bgrndCtx, a.ServerManager, a.Player,
+		&a.Config.LocalPlayback, &a.Config.Scrobbling, &a.Config.Streaming, &a.Config.AutoDJ)
+	a.PlaybackManager.SetTransitionOptions(a.Config.LocalPlayback)
+	a.LibraryManager = NewLibraryManager(a.ServerManager, &a.Config.SmartPlaylists)
+	a.initPlayHistory()
+	a.Scheduler = NewScheduler(a.PlaybackManager, a.ServerManager, a.LibraryManager, a.Player,
+		&a.Config.Scheduler)
+	a.Scheduler.Start(a.bgrndCtx)
+	a.DownloadManager = NewDownloadManager(a.ServerManager, &a.Config.Streaming)
+	a.PinnedItems = NewPinnedItemManager(a.ServerManager, &a.Config.Sidebar)
 	a.ImageManager = NewImageManager(a.bgrndCtx, a.ServerManager, configdir.LocalCache(a.appName))
 	a.LibraryManager.PreCacheCoverFn = func(coverID string) {
 		_, _ = a.ImageManager.GetCoverThumbnail(coverID)
@@ -87,6 +100,16 @@ func (a *App) readConfig() {
 	a.Config = cfg
 }
 
+func (a *App) initPlayHistory() {
+	h, err := NewPlayHistory(path.Join(configdir.LocalConfig(a.appName), "playhistory.json"))
+	if err != nil {
+		log.Printf("error reading play history: %s", err.Error())
+	}
+	a.PlayHistory = h
+	a.PlaybackManager.PlayHistory = h
+	a.LibraryManager.PlayHistory = h
+}
+
 func (a *App) initMPV() error {
 	p := player.NewWithClientName(a.appName)
 	c := a.Config.LocalPlayback
@@ -101,6 +124,10 @@ func (a *App) initMPV() error {
 func (a *App) setupMPV() error {
 	a.Config.LocalPlayback.Volume = clamp(a.Config.LocalPlayback.Volume, 0, 100)
 	a.Player.SetVolume(a.Config.LocalPlayback.Volume)
+	if a.Config.LocalPlayback.PlaybackSpeed <= 0 {
+		a.Config.LocalPlayback.PlaybackSpeed = 1
+	}
+	a.Player.SetSpeed(a.Config.LocalPlayback.PlaybackSpeed)
 
 	devs, err := a.Player.ListAudioDevices()
 	if err != nil {
@@ -122,7 +149,8 @@ func (a *App) setupMPV() error {
 		// (e.g. a USB audio device that is currently unplugged)
 		desiredDevice = "auto"
 	}
-	a.Player.SetAudioDevice(desiredDevice)
+	a.EqualizerManager = NewEqualizerManager(a.Player, &a.Config.Equalizer)
+	a.EqualizerManager.SetAudioDevice(desiredDevice)
 
 	rgainOpts := []string{ReplayGainNone, ReplayGainAlbum, ReplayGainTrack}
 	if !sharedutil.SliceContains(rgainOpts, a.Config.ReplayGain.Mode) {
@@ -134,6 +162,9 @@ func (a *App) setupMPV() error {
 		PreampGain:      a.Config.ReplayGain.PreampGainDB,
 	})
 	a.Player.SetAudioExclusive(a.Config.LocalPlayback.AudioExclusive)
+	if err := a.Player.SetAudioFilters(a.Config.AudioFilters.PlayerFilters()); err != nil {
+		log.Printf("error setting audio filters: %s", err.Error())
+	}
 
 	return nil
 }
@@ -143,19 +174,127 @@ func (a *App) LoginToDefaultServer(string) error {
 	if serverCfg == nil {
 		return ErrNoServers
 	}
-	pass, err := keyring.Get(a.appName, serverCfg.ID.String())
+	pass, err := a.ServerManager.GetServerPassword(serverCfg)
 	if err != nil {
-		return fmt.Errorf("error reading keyring credentials: %v", err)
+		return fmt.Errorf("error reading credentials: %v", err)
 	}
 	return a.ServerManager.ConnectToServer(serverCfg, pass)
 }
 
+func (a *App) initCredentialStore() {
+	c := &a.Config.Credentials
+	if c.Store == CredentialStoreFile {
+		store, err := NewFileCredentialStore(a.credentialFilePath(), c.UseMasterPassphrase)
+		if err == nil {
+			a.ServerManager.SetCredentialStore(store)
+			return
+		}
+		log.Printf("error opening credential file: %s", err.Error())
+		c.Store = CredentialStoreKeyring
+	}
+	keyringStore := NewKeyringCredentialStore(a.appName)
+	if keyringStore.Available() {
+		a.ServerManager.SetCredentialStore(keyringStore)
+		return
+	}
+	// Fall back to the encrypted file store for this session, but leave
+	// the setting unchanged in case the keyring becomes available later
+	log.Println("OS keyring not available; falling back to encrypted credential file")
+	store, err := NewFileCredentialStore(a.credentialFilePath(), false)
+	if err != nil {
+		log.Printf("error opening credential file: %s", err.Error())
+		return
+	}
+	a.ServerManager.SetCredentialStore(store)
+}
+
+// Returns true if the credential store needs to be unlocked
+// with the master passphrase before server passwords can be read.
+func (a *App) CredentialStoreLocked() bool {
+	f, ok := a.ServerManager.CredentialStore().(*FileCredentialStore)
+	return ok && f.Locked()
+}
+
+// Unlocks the credential store with the master passphrase.
+func (a *App) UnlockCredentialStore(passphrase string) error {
+	if f, ok := a.ServerManager.CredentialStore().(*FileCredentialStore); ok {
+		return f.Unlock(passphrase)
+	}
+	return nil
+}
+
+// Switches the credential store used to save server passwords,
+// migrating the passwords of all configured servers to the new store.
+// passphrase is only used if storeType is CredentialStoreFile and usePassphrase is true.
+func (a *App) ChangeCredentialStore(storeType string, usePassphrase bool, passphrase string) error {
+	oldStore := a.ServerManager.CredentialStore()
+	if f, ok := oldStore.(*FileCredentialStore); ok && f.Locked() {
+		return ErrCredentialStoreLocked
+	}
+	passwords := make(map[string]string)
+	for _, server := range a.Config.Servers {
+		if pass, err := oldStore.Get(server.ID.String()); err == nil {
+			passwords[server.ID.String()] = pass
+		} else if err != ErrCredentialNotFound {
+			log.Printf("error reading credentials for %s: %s", server.Nickname, err.Error())
+		}
+	}
+
+	// migrate the passwords into the new store before
+	// removing anything from the old one
+	var newStore CredentialStore
+	if storeType == CredentialStoreFile {
+		// re-create the file, since the key it is encrypted with may be changing
+		f, err := createFileCredentialStore(a.credentialFilePath(), usePassphrase, passphrase, passwords)
+		if err != nil {
+			return err
+		}
+		newStore = f
+	} else {
+		k := NewKeyringCredentialStore(a.appName)
+		if !k.Available() {
+			return ErrKeyringUnavailable
+		}
+		for key, pass := range passwords {
+			if err := k.Set(key, pass); err != nil {
+				return err
+			}
+		}
+		newStore = k
+	}
+
+	if _, ok := oldStore.(*KeyringCredentialStore); ok {
+		if _, ok := newStore.(*KeyringCredentialStore); !ok {
+			for key := range passwords {
+				oldStore.Delete(key)
+			}
+		}
+	} else if storeType == CredentialStoreKeyring {
+		os.Remove(a.credentialFilePath())
+	}
+
+	a.ServerManager.SetCredentialStore(newStore)
+	a.Config.Credentials.Store = storeType
+	a.Config.Credentials.UseMasterPassphrase = usePassphrase && storeType == CredentialStoreFile
+	return nil
+}
+
+func (a *App) credentialFilePath() string {
+	return path.Join(configdir.LocalConfig(a.appName), "credentials.json")
+}
+
 func (a *App) Shutdown() {
 	a.PlaybackManager.DisableCallbacks()
 	a.Player.Stop() // will trigger scrobble check
 	a.Config.LocalPlayback.Volume = a.Player.GetVolume()
 	a.cancel()
 	a.Player.Destroy()
+	if err := a.PlayHistory.Save(); err != nil {
+		log.Printf("error saving play history: %s", err.Error())
+	}
+	// a smart playlist may still be being saved to the server in the background
+	a.LibraryManager.smartPlaylistsLock.Lock()
+	defer a.LibraryManager.smartPlaylistsLock.Unlock()
 	a.Config.WriteConfigFile(a.configPath())
 }
 
@@ -0,0 +1,35 @@
+package backend
+
+import "supersonic/player"
+
+const (
+	NormalizationNone    = "none"
+	NormalizationEBUR128 = string(player.NormalizationEBUR128)
+	NormalizationDynamic = string(player.NormalizationDynamic)
+)
+
+// Returns the player audio filter chain for the configured filters.
+func (c AudioFiltersConfig) PlayerFilters() []player.AudioFilter {
+	var filters []player.AudioFilter
+	if c.TrimSilence {
+		filters = append(filters, player.SilenceTrim{})
+	}
+	if c.Mono {
+		filters = append(filters, player.MonoDownmix{})
+	}
+	if c.Balance != 0 {
+		filters = append(filters, player.Balance{Balance: c.Balance})
+	}
+	if c.Crossfeed {
+		filters = append(filters, player.Crossfeed{})
+	}
+	// normalize last so that the result of the other filters is normalized
+	switch c.Normalization {
+	case NormalizationEBUR128, NormalizationDynamic:
+		filters = append(filters, player.LoudnessNormalization{
+			Mode:       player.NormalizationMode(c.Normalization),
+			TargetLUFS: c.NormalizationTargetLUFS,
+		})
+	}
+	return filters
+}
@@ -0,0 +1,254 @@
+package backend
+
+import (
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+const (
+	// Number of tracks added to the play queue each time Auto-DJ tops it up
+	autoDJBatchSize = 10
+	// Number of recently played tracks used to seed the similar songs
+	autoDJSeedTracks = 5
+	// Number of similar songs requested per seed artist
+	autoDJSimilarCount = 20
+)
+
+// Enables or disables Auto-DJ, which keeps adding similar music
+// to the play queue as it runs out.
+func (p *PlaybackManager) SetAutoDJEnabled(enabled bool) {
+	p.lock()
+	defer p.unlock()
+	p.autoDJCfg.Enabled = enabled
+	p.checkAutoDJ()
+	p.invokeOnAutoDJChangedCallbacks()
+}
+
+func (p *PlaybackManager) AutoDJEnabled() bool {
+	p.lock()
+	defer p.unlock()
+	return p.autoDJCfg.Enabled
+}
+
+// Should be called whenever the Auto-DJ config has been changed elsewhere in the app.
+func (p *PlaybackManager) OnAutoDJSettingsChanged() {
+	p.lock()
+	defer p.unlock()
+	p.checkAutoDJ()
+	p.invokeOnAutoDJChangedCallbacks()
+}
+
+// Registers a callback that is notified when Auto-DJ is enabled or disabled.
+func (p *PlaybackManager) OnAutoDJChanged(cb func(enabled bool)) {
+	p.lock()
+	defer p.unlock()
+	p.onAutoDJChanged = append(p.onAutoDJChanged, cb)
+}
+
+// Returns true if the play queue item at idx was added by Auto-DJ.
+func (p *PlaybackManager) IsAutoDJTrack(idx int) bool {
+	p.lock()
+	defer p.unlock()
+	if idx < 0 || idx >= len(p.playQueue) {
+		return false
+	}
+	return p.autoDJTracks[p.playQueue[idx]]
+}
+
+// Records the now playing track for seeding Auto-DJ and avoiding repeats.
+func (p *PlaybackManager) recordPlayed(tr *subsonic.Child) {
+	if isRadioStation(tr) {
+		return
+	}
+	p.PlayHistory.Record(p.sm.ServerID(), tr.ID, time.Now())
+	p.recentTracks = append(p.recentTracks, tr)
+	if len(p.recentTracks) > autoDJSeedTracks {
+		p.recentTracks = p.recentTracks[len(p.recentTracks)-autoDJSeedTracks:]
+	}
+}
+
+// Starts topping up the play queue in the background if Auto-DJ is enabled
+// and fewer than the configured number of tracks remain after the playing one.
+func (p *PlaybackManager) checkAutoDJ() {
+	if !p.autoDJCfg.Enabled || p.autoDJBusy || p.callbacksDisabled || len(p.playQueue) == 0 {
+		return
+	}
+	// the user asked for playback to end with the queue
+	if p.sleepMode == SleepTimerEndOfQueue {
+		return
+	}
+	if isRadioStation(p.playQueue[p.nowPlayingIdx]) {
+		return
+	}
+	upcoming := len(p.playQueue) - 1 - int(p.nowPlayingIdx)
+	if upcoming >= p.autoDJCfg.MinUpcomingTracks && upcoming > 0 {
+		return
+	}
+
+	seeds := append([]*subsonic.Child(nil), p.recentTracks...)
+	if len(seeds) == 0 {
+		// nothing played yet; seed from the end of the queue
+		seeds = append(seeds, p.playQueue[len(p.playQueue)-1])
+	}
+	p.autoDJBusy = true
+	go p.topUpQueue(seeds, p.autoDJExclusions(), p.autoDJQueueGen)
+}
+
+// Returns the IDs of the tracks Auto-DJ must not add: those already in the
+// play queue, and those played within the configured no-repeat window.
+func (p *PlaybackManager) autoDJExclusions() map[string]bool {
+	exclude := make(map[string]bool)
+	for _, tr := range p.playQueue {
+		exclude[tr.ID] = true
+	}
+	window := time.Duration(p.autoDJCfg.NoRepeatHours) * time.Hour
+	for _, id := range p.PlayHistory.PlayedSince(p.sm.ServerID(), time.Now().Add(-window)) {
+		exclude[id] = true
+	}
+	return exclude
+}
+
+// Called when the play queue is replaced.
+func (p *PlaybackManager) resetAutoDJQueue() {
+	p.autoDJTracks = nil
+	p.autoDJResume = false
+	p.autoDJQueueGen++
+}
+
+// Fetches tracks for Auto-DJ and appends them to the play queue.
+// Must be called without the lock held.
+func (p *PlaybackManager) topUpQueue(seeds []*subsonic.Child, exclude map[string]bool, queueGen int) {
+	tracks := p.fetchAutoDJTracks(seeds, exclude)
+
+	p.lock()
+	p.autoDJBusy = false
+	resume := p.autoDJResume
+	p.autoDJResume = false
+	first := len(p.playQueue)
+	if queueGen != p.autoDJQueueGen {
+		// the queue was replaced while fetching; it may need a top-up of its own
+		tracks = nil
+		p.checkAutoDJ()
+	} else if !p.autoDJCfg.Enabled {
+		tracks = nil
+	}
+	for _, tr := range tracks {
+		url, err := p.streamURL(tr.ID)
+		if err != nil {
+			log.Printf("error getting stream URL: %s", err.Error())
+			break
+		}
+		if err := p.player.AppendFile(url); err != nil {
+			log.Printf("error adding track: %s", err.Error())
+			break
+		}
+		c := *tr
+		if p.autoDJTracks == nil {
+			p.autoDJTracks = make(map[*subsonic.Child]bool)
+		}
+		p.autoDJTracks[&c] = true
+		p.playQueue = append(p.playQueue, &c)
+	}
+	added := len(p.playQueue) > first
+	if added {
+		p.invokeOnPlayQueueChangedCallbacks()
+	}
+	p.unlock()
+
+	if resume && added {
+		// the queue ran out before it could be topped up
+		if err := p.player.PlayTrackAt(first); err != nil {
+			log.Printf("error resuming playback: %s", err.Error())
+		}
+	}
+}
+
+// Returns up to autoDJBatchSize tracks similar to the seed tracks that are not
+// excluded, falling back to a random mix of the seeds' genres, then of any genre.
+func (p *PlaybackManager) fetchAutoDJTracks(seeds []*subsonic.Child, exclude map[string]bool) []*subsonic.Child {
+	server := p.sm.Server()
+	if server == nil {
+		return nil
+	}
+	var tracks []*subsonic.Child
+	add := func(candidates []*subsonic.Child) {
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+		for _, tr := range candidates {
+			if len(tracks) < autoDJBatchSize && !exclude[tr.ID] {
+				exclude[tr.ID] = true
+				tracks = append(tracks, tr)
+			}
+		}
+	}
+
+	var similar []*subsonic.Child
+	artists := make(map[string]bool)
+	for _, tr := range seeds {
+		if tr.ArtistID == "" || artists[tr.ArtistID] {
+			continue
+		}
+		artists[tr.ArtistID] = true
+		songs, err := server.GetSimilarSongs2(tr.ArtistID, map[string]string{"count": strconv.Itoa(autoDJSimilarCount)})
+		if err != nil {
+			log.Printf("error getting similar songs: %s", err.Error())
+			continue
+		}
+		similar = append(similar, songs...)
+	}
+	add(similar)
+
+	var genres []string
+	for _, tr := range seeds {
+		if tr.Genre != "" {
+			genres = append(genres, tr.Genre)
+		}
+	}
+	if len(genres) > 0 {
+		genres = []string{genres[rand.Intn(len(genres))]}
+	}
+	// no genre means a random mix of everything
+	for _, genre := range append(genres, "") {
+		if len(tracks) >= autoDJBatchSize {
+			break
+		}
+		params := map[string]string{"size": strconv.Itoa(autoDJBatchSize * 3)}
+		if genre != "" {
+			params["genre"] = genre
+		}
+		songs, err := server.GetRandomSongs(params)
+		if err != nil {
+			log.Printf("error getting random songs: %s", err.Error())
+			continue
+		}
+		add(songs)
+	}
+	return tracks
+}
+
+// Called when playback stops. If the play queue simply ran out,
+// Auto-DJ resumes playback once it has topped up the queue.
+func (p *PlaybackManager) onStoppedForAutoDJ() {
+	// playback was stopped on purpose
+	if p.stopping || p.stopAfterCurrent || (p.sleepMode != SleepTimerOff && p.sleepMode != SleepTimerDuration) {
+		return
+	}
+	if len(p.playQueue) == 0 || p.nowPlayingIdx != int64(len(p.playQueue)-1) {
+		return
+	}
+	if p.autoDJCfg.Enabled && !p.callbacksDisabled {
+		p.autoDJResume = true
+		p.checkAutoDJ()
+	}
+}
+
+func (p *PlaybackManager) invokeOnAutoDJChangedCallbacks() {
+	enabled := p.autoDJCfg.Enabled
+	for _, cb := range p.onAutoDJChanged {
+		cb := cb
+		p.queueCallback(func() { cb(enabled) })
+	}
+}
@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+	"github.com/google/uuid"
+)
+
+// Waits for Auto-DJ to grow the play queue beyond n tracks.
+func waitForQueueLen(pm *PlaybackManager, n int) []*subsonic.Child {
+	deadline := time.Now().Add(2 * time.Second)
+	queue := pm.GetPlayQueue()
+	for len(queue) <= n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		queue = pm.GetPlayQueue()
+	}
+	return queue
+}
+
+func TestAutoDJTopUp(t *testing.T) {
+	pm, p, srv := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	pm.SetAutoDJEnabled(true)
+	// the two tracks of Aurora Lane's first album
+	pm.LoadTracks(srv.songs[:2], false, ShuffleNone)
+	pm.PlayFromBeginning()
+	p.flush()
+
+	queue := waitForQueueLen(pm, 2)
+	if len(queue) != 2+autoDJBatchSize {
+		t.Fatalf("play queue has %d tracks, want %d", len(queue), 2+autoDJBatchSize)
+	}
+	if got := len(p.Playlist()); got != len(queue) {
+		t.Errorf("player playlist has %d entries, want %d", got, len(queue))
+	}
+	seen := make(map[string]bool)
+	for i, tr := range queue {
+		if seen[tr.ID] {
+			t.Errorf("track %s added twice", tr.ID)
+		}
+		seen[tr.ID] = true
+		if want := i >= 2; pm.IsAutoDJTrack(i) != want {
+			t.Errorf("IsAutoDJTrack(%d) = %t, want %t", i, !want, want)
+		}
+		if i >= 2 && tr.ArtistID == "ar-1" {
+			t.Errorf("added track %s is not from a similar artist", tr.ID)
+		}
+	}
+	if n := srv.Requests("getSimilarSongs2"); n != 1 {
+		t.Errorf("got %d similar songs requests, want 1", n)
+	}
+
+	// enough tracks remain after the next one, so no further top-up
+	p.endTrack()
+	p.flush()
+	time.Sleep(50 * time.Millisecond)
+	if got := len(pm.GetPlayQueue()); got != len(queue) {
+		t.Errorf("play queue has %d tracks after track change, want %d", got, len(queue))
+	}
+}
+
+func TestAutoDJFallback(t *testing.T) {
+	pm, _, srv := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	// an unknown artist has no similar songs
+	seeds := []*subsonic.Child{{ID: "x", ArtistID: "ar-x", Genre: "Jazz"}}
+	tracks := pm.fetchAutoDJTracks(seeds, map[string]bool{"tr-19": true})
+
+	if len(tracks) != autoDJBatchSize {
+		t.Fatalf("got %d tracks, want %d", len(tracks), autoDJBatchSize)
+	}
+	// the five remaining Jazz tracks come first, then the random mix
+	for i, tr := range tracks {
+		if tr.ID == "tr-19" {
+			t.Error("excluded track added")
+		}
+		if i < 5 && tr.Genre != "Jazz" {
+			t.Errorf("track %d has genre %q, want Jazz", i, tr.Genre)
+		}
+	}
+	if n := srv.Requests("getRandomSongs"); n != 2 {
+		t.Errorf("got %d random songs requests, want 2", n)
+	}
+}
+
+func TestAutoDJExclusions(t *testing.T) {
+	pm, _, _ := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	pm.LoadTracks(testTracks(2), false, ShuffleNone)
+	pm.lock()
+	defer pm.unlock()
+	pm.autoDJCfg.NoRepeatHours = 4
+	pm.PlayHistory.Record(uuid.Nil, "recent", time.Now().Add(-time.Hour))
+	pm.PlayHistory.Record(uuid.Nil, "old", time.Now().Add(-5*time.Hour))
+
+	exclude := pm.autoDJExclusions()
+	for id, want := range map[string]bool{"0": true, "1": true, "recent": true, "old": false} {
+		if exclude[id] != want {
+			t.Errorf("%s excluded = %t, want %t", id, exclude[id], want)
+		}
+	}
+	// the history is kept for smart playlists
+	if pm.PlayHistory.LastPlayed(uuid.Nil, "old").IsZero() {
+		t.Error("play history outside the no-repeat window was dropped")
+	}
+}
+
+func TestAutoDJEndOfQueueSleepTimer(t *testing.T) {
+	pm, p, srv := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	pm.SetAutoDJEnabled(true)
+	pm.SetSleepTimer(SleepTimerEndOfQueue, 0)
+	pm.LoadTracks(srv.songs[:2], false, ShuffleNone)
+	pm.PlayFromBeginning()
+	p.flush()
+
+	pm.lock()
+	busy := pm.autoDJBusy
+	pm.unlock()
+	if busy {
+		t.Error("Auto-DJ topping up the queue with the end of queue sleep timer set")
+	}
+}
+
+// Resumes playback when the queue ran out before Auto-DJ topped it up.
+func TestAutoDJResume(t *testing.T) {
+	pm, p, srv := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	pm.LoadTracks(srv.songs[:1], false, ShuffleNone)
+	pm.PlayFromBeginning()
+	p.flush()
+	pm.lock()
+	pm.autoDJCfg.Enabled = true
+	pm.unlock()
+
+	p.endTrack()
+	p.flush()
+	waitForQueueLen(pm, 1)
+	deadline := time.Now().Add(2 * time.Second)
+	for p.GetStatus().PlaylistPos != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	p.flush()
+
+	if np := pm.NowPlaying(); np == nil || !pm.IsAutoDJTrack(1) || np.ID != pm.GetPlayQueue()[1].ID {
+		t.Errorf("now playing = %v, want the first track added by Auto-DJ", np)
+	}
+}
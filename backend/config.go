@@ -2,6 +2,7 @@ package backend
 
 import (
 	"os"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pelletier/go-toml"
@@ -12,6 +13,19 @@ type ServerConnection struct {
 	AltHostname string
 	Username    string
 	LegacyAuth  bool
+	// Authenticate with an OpenSubsonic API key.
+	// If set, the stored password is the API key.
+	APIKeyAuth bool
+
+	// HTTP options, applied both to API requests and to audio streams.
+	// RequestTimeoutSeconds <= 0 means no timeout.
+	RequestTimeoutSeconds int
+	ProxyURL              string // http://, https:// or socks5:// (socks5 not supported for streams)
+	CACertFile            string
+	ClientCertFile        string
+	ClientKeyFile         string
+	SkipTLSVerify         bool
+	ExtraHeaders          map[string]string
 }
 
 type ServerConfig struct {
@@ -34,6 +48,23 @@ type AppConfig struct {
 	FontBoldTTF   string
 }
 
+type CredentialsConfig struct {
+	// CredentialStoreKeyring or CredentialStoreFile
+	Store string
+	// Protect the encrypted credential file with a master passphrase
+	// instead of a machine-derived key
+	UseMasterPassphrase bool
+}
+
+type StreamingConfig struct {
+	Profiles []*StreamingProfile
+	// Names of the profiles used when connected via the primary
+	// and alternate hostnames, and for downloads
+	PrimaryProfile  string
+	AltProfile      string
+	DownloadProfile string
+}
+
 type AlbumPageConfig struct {
 	TracklistColumns []string
 }
@@ -73,6 +104,126 @@ type LocalPlaybackConfig struct {
 	AudioExclusive      bool
 	InMemoryCacheSizeMB int
 	Volume              int
+	// Crossfade duration between tracks of different albums. 0 disables.
+	CrossfadeSeconds int
+	// Short fades on pause, resume, stop and skipping tracks
+	FadeOnPause bool
+	// Playback speed (1 = normal)
+	PlaybackSpeed float64
+	// Remember the playback speed for each genre, e.g. to
+	// automatically speed up audiobooks but not music
+	RememberSpeedPerGenre bool
+	GenreSpeeds           map[string]float64
+}
+
+type AutoDJConfig struct {
+	// Keep playing similar music when the play queue runs out
+	Enabled bool
+	// Top up the queue when fewer than this many tracks remain after the playing one
+	MinUpcomingTracks int
+	// Don't add tracks that were played within this many hours. 0 disables.
+	NoRepeatHours int
+}
+
+type EqualizerConfig struct {
+	Enabled bool
+	// Name of the preset the current curve was loaded from, if any
+	Preset   string
+	PreampDB float64
+	GainsDB  []float64
+	// Presets saved by the user, in addition to the built-in presets
+	UserPresets []*EqualizerPreset
+	// Remember a separate curve for each audio device
+	PerDevice bool
+	// Curve for each audio device, keyed by AudioDeviceName
+	DeviceCurves map[string]*EqualizerPreset
+}
+
+type AudioFiltersConfig struct {
+	// One of "none", "loudnorm" or "dynaudnorm"
+	Normalization           string
+	NormalizationTargetLUFS float64
+	Mono                    bool
+	// -1 (left) to 1 (right)
+	Balance     float64
+	Crossfeed   bool
+	TrimSilence bool
+}
+
+type ScheduledPlayback struct {
+	ID      uuid.UUID
+	Enabled bool
+	// The server the source belongs to
+	ServerID uuid.UUID
+	// Time of day to start playback
+	Hour   int
+	Minute int
+	// Days of the week to start playback (0 = Sunday)
+	Weekdays []int
+	// One of the ScheduleSource* constants
+	SourceType string
+	// Playlist or album ID, genre name, or radio station stream URL
+	SourceID   string
+	SourceName string
+	Shuffle    bool
+	// Gradually raise the volume from 0 to Volume over this many minutes
+	RampMinutes int
+	// Volume to play at. 0 keeps the current volume.
+	Volume int
+}
+
+type SchedulerConfig struct {
+	Schedules []*ScheduledPlayback
+}
+
+// A playlist defined by rules, evaluated locally against the library.
+// Zero values of the rule fields match any track unless noted otherwise.
+type SmartPlaylist struct {
+	ID uuid.UUID
+	// The server whose library the playlist is evaluated against
+	ServerID uuid.UUID
+	Name     string
+
+	Genres []string
+	// Release year range
+	MinYear int
+	MaxYear int
+	// Minimum user rating (1-5)
+	MinRating   int
+	StarredOnly bool
+	// Play count range. MaxPlayCount only applies if HasMaxPlayCount is set,
+	// so that 0 can select tracks that were never played.
+	MinPlayCount    int
+	MaxPlayCount    int
+	HasMaxPlayCount bool
+	// Only tracks last played more than this many days ago, or never
+	NotPlayedForDays int
+	// Only tracks played within this many days
+	PlayedWithinDays int
+	// Case-insensitive substring of the artist name
+	Artist     string
+	MinBitRate int
+	// File types, e.g. "flac"
+	Suffixes []string
+	// Duration range in seconds
+	MinDuration int
+	MaxDuration int
+
+	// One of the SmartPlaylistSort* constants
+	SortOrder string
+	// Maximum number of tracks. 0 is unlimited.
+	Limit int
+
+	// Periodically save the tracks to a playlist on the server
+	Materialize      bool
+	MaterializeHours int
+	// ID of the server playlist the tracks were last saved to
+	MaterializedPlaylistID string
+	LastMaterialized       time.Time
+}
+
+type SmartPlaylistsConfig struct {
+	Playlists []*SmartPlaylist
 }
 
 type ScrobbleConfig struct {
@@ -87,12 +238,31 @@ type ReplayGainConfig struct {
 	PreventClipping bool
 }
 
+// An album, artist or genre pinned to the sidebar.
+type PinnedItem struct {
+	// The server the item belongs to
+	ServerID uuid.UUID
+	// One of the PinnedItem* constants
+	Type string
+	// Album or artist ID, or genre name
+	ID   string
+	Name string
+}
+
+type SidebarConfig struct {
+	// Hide the sidebar, showing only the navigation buttons
+	Collapsed bool
+	Width     int
+	Pinned    []*PinnedItem
+}
+
 type ThemeConfig struct {
 	Appearance string
 }
 
 type Config struct {
 	Application    AppConfig
+	Credentials    CredentialsConfig
 	Servers        []*ServerConfig
 	AlbumPage      AlbumPageConfig
 	AlbumsPage     AlbumsPageConfig
@@ -103,8 +273,15 @@ type Config struct {
 	PlaylistsPage  PlaylistsPageConfig
 	TracksPage     TracksPageConfig
 	LocalPlayback  LocalPlaybackConfig
+	AutoDJ         AutoDJConfig
+	Equalizer      EqualizerConfig
+	AudioFilters   AudioFiltersConfig
+	Scheduler      SchedulerConfig
+	SmartPlaylists SmartPlaylistsConfig
+	Streaming      StreamingConfig
 	Scrobbling     ScrobbleConfig
 	ReplayGain     ReplayGainConfig
+	Sidebar        SidebarConfig
 	Theme          ThemeConfig
 }
 
@@ -120,6 +297,9 @@ func DefaultConfig(appVersionTag string) *Config {
 			CloseToSystemTray:  false,
 			StartupPage:        "Albums",
 		},
+		Credentials: CredentialsConfig{
+			Store: CredentialStoreKeyring,
+		},
 		AlbumPage: AlbumPageConfig{
 			TracklistColumns: []string{"Artist", "Time", "Plays", "Favorite", "Rating"},
 		},
@@ -152,6 +332,25 @@ func DefaultConfig(appVersionTag string) *Config {
 			AudioExclusive:      false,
 			InMemoryCacheSizeMB: 30,
 			Volume:              100,
+			PlaybackSpeed:       1,
+		},
+		AutoDJ: AutoDJConfig{
+			Enabled:           false,
+			MinUpcomingTracks: 3,
+			NoRepeatHours:     4,
+		},
+		Equalizer: EqualizerConfig{
+			Preset: "Flat",
+		},
+		AudioFilters: AudioFiltersConfig{
+			Normalization:           NormalizationNone,
+			NormalizationTargetLUFS: -16,
+		},
+		Streaming: StreamingConfig{
+			Profiles:        DefaultStreamingProfiles(),
+			PrimaryProfile:  "Original",
+			AltProfile:      "Original",
+			DownloadProfile: "Original",
 		},
 		Scrobbling: ScrobbleConfig{
 			Enabled:              true,
@@ -163,6 +362,9 @@ func DefaultConfig(appVersionTag string) *Config {
 			PreampGainDB:    0.0,
 			PreventClipping: true,
 		},
+		Sidebar: SidebarConfig{
+			Width: 200,
+		},
 		Theme: ThemeConfig{
 			Appearance: "Dark",
 		},
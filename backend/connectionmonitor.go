@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// number of consecutive failed requests before re-checking the hostnames
+	monitorFailureThreshold = 3
+	// how often to check for a change in the local network configuration
+	monitorPollInterval = 10 * time.Second
+	// minimum time between two consecutive host re-checks
+	monitorMinRecheckInterval = 5 * time.Second
+)
+
+// connectionMonitor watches the health of the connection to the
+// currently connected server. When several requests in a row fail,
+// or the local network configuration changes (e.g. switching from
+// the home LAN to a VPN), it re-pings both the primary and alternate
+// hostnames and switches to a client for the one that responds.
+type connectionMonitor struct {
+	sm *ServerManager
+
+	failures    int32
+	recheckChan chan struct{}
+
+	recheckLock sync.Mutex
+	lastRecheck time.Time
+
+	networkLock   sync.Mutex
+	lastNetworkID string
+}
+
+func newConnectionMonitor(sm *ServerManager) *connectionMonitor {
+	return &connectionMonitor{
+		sm:            sm,
+		recheckChan:   make(chan struct{}, 1),
+		lastNetworkID: networkID(),
+	}
+}
+
+// Start monitoring in the background.
+// Quits when the given ctx's Done channel returns a value.
+func (c *connectionMonitor) Start(ctx context.Context) {
+	go func() {
+		t := time.NewTicker(monitorPollInterval)
+		for {
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-c.recheckChan:
+				c.recheckHosts()
+			case <-t.C:
+				if c.networkChanged() {
+					log.Println("Network configuration changed; re-checking server hostnames")
+					c.recheckHosts()
+				} else if atomic.LoadInt32(&c.failures) >= monitorFailureThreshold {
+					// previous re-check could not reach either host; try again
+					c.recheckHosts()
+				}
+			}
+		}
+	}()
+}
+
+// Resets the failure count, e.g. after connecting to a new server.
+func (c *connectionMonitor) Reset() {
+	atomic.StoreInt32(&c.failures, 0)
+	c.networkLock.Lock()
+	defer c.networkLock.Unlock()
+	c.lastNetworkID = networkID()
+}
+
+// Returns true if the local network configuration
+// has changed since the last call or Reset.
+func (c *connectionMonitor) networkChanged() bool {
+	id := networkID()
+	c.networkLock.Lock()
+	defer c.networkLock.Unlock()
+	changed := id != c.lastNetworkID
+	c.lastNetworkID = id
+	return changed
+}
+
+// Wraps the given http.RoundTripper so that request
+// successes and failures are reported to the monitor.
+func (c *connectionMonitor) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &monitoredTransport{base: rt, monitor: c}
+}
+
+func (c *connectionMonitor) reportSuccess() {
+	atomic.StoreInt32(&c.failures, 0)
+}
+
+func (c *connectionMonitor) reportFailure() {
+	if atomic.AddInt32(&c.failures, 1) == monitorFailureThreshold {
+		log.Println("Repeated server request failures; re-checking server hostnames")
+		select {
+		case c.recheckChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *connectionMonitor) recheckHosts() {
+	c.recheckLock.Lock()
+	defer c.recheckLock.Unlock()
+	if time.Since(c.lastRecheck) < monitorMinRecheckInterval {
+		return
+	}
+	c.lastRecheck = time.Now()
+
+	server, conn, password := c.sm.connectionState()
+	if server == nil || conn.AltHostname == "" {
+		return
+	}
+	cli, err := c.sm.connect(conn, password)
+	if err != nil {
+		log.Printf("error re-checking server hostnames: %s", err.Error())
+		return
+	}
+	atomic.StoreInt32(&c.failures, 0)
+	if cli.BaseUrl != server.BaseUrl && c.sm.switchBaseURL(server, cli.BaseUrl) {
+		log.Printf("Switching server hostname to %s", cli.BaseUrl)
+		for _, cb := range c.sm.onHostnameChanged {
+			cb()
+		}
+	}
+}
+
+type monitoredTransport struct {
+	base    http.RoundTripper
+	monitor *connectionMonitor
+}
+
+func (m *monitoredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := m.base.RoundTrip(req)
+	if err != nil {
+		// don't count requests canceled by the client as connection failures
+		if req.Context().Err() == nil {
+			m.monitor.reportFailure()
+		}
+	} else {
+		m.monitor.reportSuccess()
+	}
+	return resp, err
+}
+
+// Returns a string identifying the current local network configuration,
+// built from the addresses of all network interfaces that are up.
+func networkID() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	var addrs []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ifAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range ifAddrs {
+			addrs = append(addrs, iface.Name+"="+a.String())
+		}
+	}
+	sort.Strings(addrs)
+	return strings.Join(addrs, ",")
+}
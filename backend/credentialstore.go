@@ -0,0 +1,360 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/user"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	CredentialStoreKeyring = "Keyring"
+	CredentialStoreFile    = "EncryptedFile"
+)
+
+var (
+	ErrCredentialNotFound     = errors.New("credential not found")
+	ErrCredentialStoreLocked  = errors.New("credential store is locked")
+	ErrWrongMasterPassphrase  = errors.New("wrong master passphrase")
+	ErrKeyringUnavailable     = errors.New("no OS keyring available")
+	errCorruptCredentialStore = errors.New("credential store file is corrupt")
+)
+
+// A CredentialStore securely stores the server passwords, keyed by server ID.
+type CredentialStore interface {
+	// Returns ErrCredentialNotFound if there is no password stored for the key.
+	Get(key string) (string, error)
+	Set(key, password string) error
+	Delete(key string) error
+}
+
+// KeyringCredentialStore stores passwords in the OS keyring
+// (Secret Service on Linux, Keychain on macOS, Credential Manager on Windows).
+type KeyringCredentialStore struct {
+	service string
+}
+
+var _ CredentialStore = (*KeyringCredentialStore)(nil)
+
+func NewKeyringCredentialStore(service string) *KeyringCredentialStore {
+	return &KeyringCredentialStore{service: service}
+}
+
+// Returns true if the OS keyring can be accessed.
+func (k *KeyringCredentialStore) Available() bool {
+	_, err := keyring.Get(k.service, "availability-check")
+	return err == nil || err == keyring.ErrNotFound
+}
+
+func (k *KeyringCredentialStore) Get(key string) (string, error) {
+	pass, err := keyring.Get(k.service, key)
+	if err == keyring.ErrNotFound {
+		return "", ErrCredentialNotFound
+	}
+	return pass, err
+}
+
+func (k *KeyringCredentialStore) Set(key, password string) error {
+	return keyring.Set(k.service, key, password)
+}
+
+func (k *KeyringCredentialStore) Delete(key string) error {
+	err := keyring.Delete(k.service, key)
+	if err == keyring.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// FileCredentialStore stores passwords in a file encrypted with AES-GCM.
+// The encryption key is derived either from a master passphrase supplied
+// by the user, or from a machine-specific identifier. The latter protects
+// the passwords from casual inspection and from being usable if the file
+// is copied to another machine, but not from other users of the same machine.
+type FileCredentialStore struct {
+	path          string
+	usePassphrase bool
+
+	mu   sync.Mutex
+	key  []byte
+	file credentialFile
+}
+
+var _ CredentialStore = (*FileCredentialStore)(nil)
+
+type credentialFile struct {
+	Salt string
+	// encrypted known value used to check the derived key
+	Check   string
+	Entries map[string]string
+}
+
+const (
+	pbkdf2Iterations = 200000
+	credentialCheck  = "supersonic"
+)
+
+// Creates a new FileCredentialStore backed by the file at path.
+// If usePassphrase is true, the store is locked until Unlock is called
+// with the master passphrase. Otherwise it is unlocked with a machine-derived key.
+func NewFileCredentialStore(path string, usePassphrase bool) (*FileCredentialStore, error) {
+	f := &FileCredentialStore{path: path, usePassphrase: usePassphrase}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	if !usePassphrase {
+		if err := f.Unlock(machineSecret()); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Creates a FileCredentialStore at path holding the given passwords.
+// The store is written to a temporary file which replaces any existing
+// file at path only once complete, so that a failure (e.g. while unlocking
+// it with the passphrase) leaves the existing store intact.
+func createFileCredentialStore(path string, usePassphrase bool, passphrase string, passwords map[string]string) (*FileCredentialStore, error) {
+	tmpPath := path + ".new"
+	if err := os.Remove(tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	f, err := NewFileCredentialStore(tmpPath, usePassphrase)
+	if err == nil && usePassphrase {
+		err = f.Unlock(passphrase)
+	}
+	for key, pass := range passwords {
+		if err != nil {
+			break
+		}
+		err = f.Set(key, pass)
+	}
+	if err == nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if err = os.Rename(tmpPath, path); err == nil {
+			f.path = path
+			return f, nil
+		}
+	}
+	os.Remove(tmpPath)
+	return nil, err
+}
+
+// Returns true if the store requires the master passphrase to be unlocked.
+func (f *FileCredentialStore) UsesPassphrase() bool {
+	return f.usePassphrase
+}
+
+// Returns true if the store has not yet been unlocked.
+func (f *FileCredentialStore) Locked() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.key == nil
+}
+
+// Unlocks the store with the given passphrase. If the store file
+// does not exist yet, it will be created protected with the passphrase.
+// Returns ErrWrongMasterPassphrase if the passphrase doesn't match.
+func (f *FileCredentialStore) Unlock(passphrase string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	salt, err := base64.StdEncoding.DecodeString(f.file.Salt)
+	if err != nil || len(salt) == 0 {
+		// new store
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		f.file.Salt = base64.StdEncoding.EncodeToString(salt)
+		f.file.Check = ""
+		f.file.Entries = make(map[string]string)
+	}
+	key := pbkdf2SHA256([]byte(passphrase), salt, pbkdf2Iterations, 32)
+	if f.file.Check == "" {
+		check, err := encrypt(key, credentialCheck)
+		if err != nil {
+			return err
+		}
+		f.file.Check = check
+		f.key = key
+		return f.save()
+	}
+	if check, err := decrypt(key, f.file.Check); err != nil || check != credentialCheck {
+		return ErrWrongMasterPassphrase
+	}
+	f.key = key
+	return nil
+}
+
+func (f *FileCredentialStore) Get(key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return "", ErrCredentialStoreLocked
+	}
+	enc, ok := f.file.Entries[key]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return decrypt(f.key, enc)
+}
+
+func (f *FileCredentialStore) Set(key, password string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return ErrCredentialStoreLocked
+	}
+	enc, err := encrypt(f.key, password)
+	if err != nil {
+		return err
+	}
+	f.file.Entries[key] = enc
+	return f.save()
+}
+
+func (f *FileCredentialStore) Delete(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.key == nil {
+		return ErrCredentialStoreLocked
+	}
+	if _, ok := f.file.Entries[key]; !ok {
+		return nil
+	}
+	delete(f.file.Entries, key)
+	return f.save()
+}
+
+func (f *FileCredentialStore) load() error {
+	b, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &f.file); err != nil {
+		return errCorruptCredentialStore
+	}
+	if f.file.Entries == nil {
+		f.file.Entries = make(map[string]string)
+	}
+	return nil
+}
+
+// must be called with f.mu held
+func (f *FileCredentialStore) save() error {
+	b, err := json.MarshalIndent(f.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so that a failed write
+	// doesn't leave a truncated store behind
+	tmpPath := f.path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0600); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, f.path)
+}
+
+func encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decrypt(key []byte, ciphertext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	b, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(b) < gcm.NonceSize() {
+		return "", errCorruptCredentialStore
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PBKDF2 (RFC 8018) with HMAC-SHA256 as the pseudorandom function.
+func pbkdf2SHA256(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
+
+// Returns a string that identifies this machine and user,
+// used to derive the key for a FileCredentialStore without a master passphrase.
+func machineSecret() string {
+	var id string
+	for _, f := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if b, err := os.ReadFile(f); err == nil {
+			id = strings.TrimSpace(string(b))
+			break
+		}
+	}
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+	if u, err := user.Current(); err == nil {
+		id += ":" + u.Uid + ":" + u.HomeDir
+	}
+	return runtime.GOOS + ":" + id
+}
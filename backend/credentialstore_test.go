@@ -0,0 +1,132 @@
+package backend
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCredentialStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	f, err := NewFileCredentialStore(path, true)
+	if err != nil {
+		t.Fatalf("NewFileCredentialStore: %s", err.Error())
+	}
+	if !f.Locked() {
+		t.Fatal("passphrase-protected store not locked")
+	}
+	if err := f.Set("server", "secret"); err != ErrCredentialStoreLocked {
+		t.Errorf("Set on locked store: got %v, want %v", err, ErrCredentialStoreLocked)
+	}
+	if err := f.Unlock("passphrase"); err != nil {
+		t.Fatalf("Unlock: %s", err.Error())
+	}
+	if err := f.Set("server", "secret"); err != nil {
+		t.Fatalf("Set: %s", err.Error())
+	}
+
+	f, err = NewFileCredentialStore(path, true)
+	if err != nil {
+		t.Fatalf("NewFileCredentialStore: %s", err.Error())
+	}
+	if err := f.Unlock("passphrase"); err != nil {
+		t.Fatalf("Unlock: %s", err.Error())
+	}
+	if pass, err := f.Get("server"); err != nil || pass != "secret" {
+		t.Errorf("Get: got %q, %v", pass, err)
+	}
+	if _, err := f.Get("other"); err != ErrCredentialNotFound {
+		t.Errorf("Get missing key: got %v, want %v", err, ErrCredentialNotFound)
+	}
+	if err := f.Delete("server"); err != nil {
+		t.Fatalf("Delete: %s", err.Error())
+	}
+	if _, err := f.Get("server"); err != ErrCredentialNotFound {
+		t.Errorf("Get deleted key: got %v, want %v", err, ErrCredentialNotFound)
+	}
+}
+
+func TestFileCredentialStoreWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	f, err := NewFileCredentialStore(path, true)
+	if err != nil {
+		t.Fatalf("NewFileCredentialStore: %s", err.Error())
+	}
+	if err := f.Unlock("passphrase"); err != nil {
+		t.Fatalf("Unlock: %s", err.Error())
+	}
+
+	f, err = NewFileCredentialStore(path, true)
+	if err != nil {
+		t.Fatalf("NewFileCredentialStore: %s", err.Error())
+	}
+	if err := f.Unlock("wrong"); err != ErrWrongMasterPassphrase {
+		t.Errorf("Unlock: got %v, want %v", err, ErrWrongMasterPassphrase)
+	}
+	if !f.Locked() {
+		t.Error("store unlocked with the wrong passphrase")
+	}
+}
+
+func TestCreateFileCredentialStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.json")
+	old, err := NewFileCredentialStore(path, false)
+	if err != nil {
+		t.Fatalf("NewFileCredentialStore: %s", err.Error())
+	}
+	if err := old.Set("server", "secret"); err != nil {
+		t.Fatalf("Set: %s", err.Error())
+	}
+
+	// migrate to a passphrase-protected store
+	passwords := map[string]string{"server": "secret", "other": "secret2"}
+	f, err := createFileCredentialStore(path, true, "passphrase", passwords)
+	if err != nil {
+		t.Fatalf("createFileCredentialStore: %s", err.Error())
+	}
+	for key, want := range passwords {
+		if pass, err := f.Get(key); err != nil || pass != want {
+			t.Errorf("Get(%s): got %q, %v", key, pass, err)
+		}
+	}
+	// later writes go to the final path
+	if err := f.Set("third", "secret3"); err != nil {
+		t.Fatalf("Set: %s", err.Error())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d files in config dir, want 1", len(entries))
+	}
+
+	reopened, err := NewFileCredentialStore(path, true)
+	if err != nil {
+		t.Fatalf("NewFileCredentialStore: %s", err.Error())
+	}
+	if err := reopened.Unlock("passphrase"); err != nil {
+		t.Fatalf("Unlock migrated store: %s", err.Error())
+	}
+	if pass, err := reopened.Get("third"); err != nil || pass != "secret3" {
+		t.Errorf("Get(third): got %q, %v", pass, err)
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// test vectors from RFC 7914 section 11
+	for _, tt := range []struct {
+		password, salt string
+		iter           int
+		want           string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	} {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iter, 64))
+		if got != tt.want {
+			t.Errorf("pbkdf2SHA256(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iter, got, tt.want)
+		}
+	}
+}
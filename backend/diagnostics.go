@@ -0,0 +1,331 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const diagnosticsStepTimeout = 10 * time.Second
+
+// The result of a single step of the connection diagnostics.
+type DiagnosticStep struct {
+	Name    string
+	OK      bool
+	Skipped bool
+	Latency time.Duration
+	Details []string
+}
+
+// The connection diagnostics for a single hostname.
+type HostDiagnostics struct {
+	Label    string // "Hostname" or "Alt. Hostname"
+	Hostname string
+	Steps    []DiagnosticStep
+}
+
+// The results of RunConnectionDiagnostics.
+type ConnectionDiagnostics struct {
+	Time       time.Time
+	AuthMethod string
+	Hosts      []HostDiagnostics
+}
+
+// Separately tests each layer of the connection (DNS, TCP, TLS, HTTP,
+// Subsonic ping and authentication) for both the primary and alternate
+// hostnames of the server connection.
+func RunConnectionDiagnostics(ctx context.Context, connection ServerConnection, password string) *ConnectionDiagnostics {
+	d := &ConnectionDiagnostics{Time: time.Now(), AuthMethod: authMethodName(connection)}
+	d.Hosts = append(d.Hosts, diagnoseHost(ctx, "Hostname", connection.Hostname, connection, password))
+	if connection.AltHostname != "" {
+		d.Hosts = append(d.Hosts, diagnoseHost(ctx, "Alt. Hostname", connection.AltHostname, connection, password))
+	}
+	return d
+}
+
+// Returns the diagnostics as a human-readable plain text report.
+func (c *ConnectionDiagnostics) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Connection diagnostics - %s\n", c.Time.Format(time.RFC1123))
+	fmt.Fprintf(&sb, "Auth method: %s\n", c.AuthMethod)
+	for _, h := range c.Hosts {
+		fmt.Fprintf(&sb, "\n%s: %s\n", h.Label, h.Hostname)
+		for _, step := range h.Steps {
+			status := "OK"
+			if step.Skipped {
+				status = "SKIPPED"
+			} else if !step.OK {
+				status = "FAILED"
+			}
+			fmt.Fprintf(&sb, "  [%s] %s", status, step.Name)
+			if step.Latency > 0 {
+				fmt.Fprintf(&sb, " (%d ms)", step.Latency.Milliseconds())
+			}
+			sb.WriteString("\n")
+			for _, d := range step.Details {
+				fmt.Fprintf(&sb, "      %s\n", d)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func authMethodName(connection ServerConnection) string {
+	switch {
+	case connection.APIKeyAuth:
+		return "OpenSubsonic API key"
+	case connection.LegacyAuth:
+		return "legacy (plain password)"
+	default:
+		return "token (salted password hash)"
+	}
+}
+
+func diagnoseHost(ctx context.Context, label, hostname string, connection ServerConnection, password string) HostDiagnostics {
+	h := HostDiagnostics{Label: label, Hostname: hostname}
+	failed := false
+	// runs the step unless a previous step has failed
+	run := func(name string, step func() DiagnosticStep) {
+		if failed {
+			h.Steps = append(h.Steps, DiagnosticStep{Name: name, Skipped: true})
+			return
+		}
+		s := step()
+		failed = !s.OK
+		h.Steps = append(h.Steps, s)
+	}
+
+	u, err := url.Parse(hostname)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		detail := "URL must start with http:// or https://"
+		if err != nil {
+			detail = err.Error()
+		}
+		h.Steps = append(h.Steps, DiagnosticStep{Name: "Parse URL", Details: []string{detail}})
+		return h
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	addr := net.JoinHostPort(host, port)
+
+	run("DNS lookup", func() DiagnosticStep { return diagnoseDNS(ctx, host) })
+	run("TCP connect", func() DiagnosticStep { return diagnoseTCP(ctx, addr) })
+	if u.Scheme == "https" {
+		run("TLS handshake", func() DiagnosticStep { return diagnoseTLS(ctx, addr, host, connection) })
+	}
+	run("HTTP request", func() DiagnosticStep { return diagnoseHTTP(ctx, hostname, connection) })
+	run("Subsonic ping and authentication", func() DiagnosticStep { return diagnosePing(hostname, connection, password) })
+	return h
+}
+
+func diagnoseDNS(ctx context.Context, host string) DiagnosticStep {
+	step := DiagnosticStep{Name: "DNS lookup"}
+	if net.ParseIP(host) != nil {
+		step.OK = true
+		step.Details = []string{"host is an IP address"}
+		return step
+	}
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsStepTimeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	step.Latency = time.Since(start)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	step.OK = true
+	step.Details = []string{"resolved to " + strings.Join(addrs, ", ")}
+	return step
+}
+
+func diagnoseTCP(ctx context.Context, addr string) DiagnosticStep {
+	step := DiagnosticStep{Name: "TCP connect to " + addr}
+	dialer := &net.Dialer{Timeout: diagnosticsStepTimeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	step.Latency = time.Since(start)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	step.OK = true
+	step.Details = []string{"connected to " + conn.RemoteAddr().String()}
+	conn.Close()
+	return step
+}
+
+func diagnoseTLS(ctx context.Context, addr, serverName string, connection ServerConnection) DiagnosticStep {
+	step := DiagnosticStep{Name: "TLS handshake"}
+	conf, err := connection.tlsConfig()
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	conf.ServerName = serverName
+
+	dial := func(conf *tls.Config) (*tls.Conn, time.Duration, error) {
+		dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: diagnosticsStepTimeout}, Config: conf}
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		return conn.(*tls.Conn), time.Since(start), nil
+	}
+
+	conn, latency, err := dial(conf)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		// connect again without verification to show the certificate details
+		insecure := conf.Clone()
+		insecure.InsecureSkipVerify = true
+		if conn, _, err2 := dial(insecure); err2 == nil {
+			step.Details = append(step.Details, certificateDetails(conn.ConnectionState())...)
+			conn.Close()
+		}
+		return step
+	}
+	defer conn.Close()
+	step.OK = true
+	step.Latency = latency
+	if conf.InsecureSkipVerify {
+		step.Details = append(step.Details, "WARNING: certificate verification is disabled")
+	}
+	step.Details = append(step.Details, certificateDetails(conn.ConnectionState())...)
+	return step
+}
+
+func certificateDetails(state tls.ConnectionState) []string {
+	details := []string{"protocol: " + tlsVersionName(state.Version)}
+	if len(state.PeerCertificates) == 0 {
+		return details
+	}
+	cert := state.PeerCertificates[0]
+	details = append(details,
+		"subject: "+cert.Subject.String(),
+		"issuer: "+cert.Issuer.String(),
+		fmt.Sprintf("valid: %s to %s", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02")),
+	)
+	if names := certificateNames(cert); len(names) > 0 {
+		details = append(details, "names: "+strings.Join(names, ", "))
+	}
+	if time.Now().After(cert.NotAfter) {
+		details = append(details, "certificate has EXPIRED")
+	}
+	return details
+}
+
+func certificateNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("unknown (0x%04x)", v)
+}
+
+// Makes an unauthenticated request to the ping endpoint to test the HTTP layer
+// (e.g. reverse proxy configuration) independently of authentication.
+func diagnoseHTTP(ctx context.Context, hostname string, connection ServerConnection) DiagnosticStep {
+	step := DiagnosticStep{Name: "HTTP request"}
+	cli, err := newHTTPClient(connection)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	cli.Timeout = diagnosticsStepTimeout
+	u, _ := url.Parse(hostname)
+	u.Path = path.Join(u.Path, "/rest/ping")
+	u.RawQuery = url.Values{"f": {"xml"}, "v": {"1.8.0"}, "c": {"supersonic"}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	start := time.Now()
+	resp, err := cli.Do(req)
+	step.Latency = time.Since(start)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	resp.Body.Close()
+	step.Details = []string{"status: " + resp.Status}
+	if server := resp.Header.Get("Server"); server != "" {
+		step.Details = append(step.Details, "server header: "+server)
+	}
+	if resp.Request.URL.String() != u.String() {
+		step.Details = append(step.Details, "redirected to: "+resp.Request.URL.Redacted())
+	}
+	step.OK = resp.StatusCode == http.StatusOK
+	return step
+}
+
+func diagnosePing(hostname string, connection ServerConnection, password string) DiagnosticStep {
+	step := DiagnosticStep{Name: "Subsonic ping and authentication"}
+	cli, err := newSubsonicClient(connection, hostname, password)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	cli.Client.Timeout = diagnosticsStepTimeout
+	start := time.Now()
+	err = cli.Authenticate(password)
+	step.Latency = time.Since(start)
+	if err != nil {
+		step.Details = []string{err.Error()}
+		return step
+	}
+	info, err := fetchServerInfo(cli)
+	if err != nil {
+		step.Details = []string{"authenticated, but reading server info failed: " + err.Error()}
+		return step
+	}
+	step.OK = true
+	step.Details = []string{"authenticated as " + connection.Username, "API version: " + info.APIVersion}
+	if info.OpenSubsonic {
+		step.Details = append(step.Details,
+			fmt.Sprintf("server: %s %s (OpenSubsonic)", info.Type, info.ServerVersion))
+		exts := make([]string, 0, len(info.Extensions))
+		for name := range info.Extensions {
+			exts = append(exts, name)
+		}
+		sort.Strings(exts)
+		step.Details = append(step.Details, "extensions: "+strings.Join(exts, ", "))
+	} else {
+		step.Details = append(step.Details, "server: Subsonic-compatible (not OpenSubsonic)")
+	}
+	if connection.APIKeyAuth && !info.HasExtension(ExtensionAPIKeyAuth) {
+		step.OK = false
+		step.Details = append(step.Details, ErrAPIKeyAuthUnsupported.Error())
+	}
+	return step
+}
@@ -0,0 +1,161 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// DownloadManager downloads tracks for offline use,
+// transcoded according to the download streaming profile.
+type DownloadManager struct {
+	sm           *ServerManager
+	streamingCfg *StreamingConfig
+}
+
+func NewDownloadManager(sm *ServerManager, streamingCfg *StreamingConfig) *DownloadManager {
+	return &DownloadManager{sm: sm, streamingCfg: streamingCfg}
+}
+
+// Downloads the given tracks into dir. onProgress, if non-nil, is called
+// after each track is downloaded. Returns the first error encountered, after
+// attempting to download all tracks.
+func (d *DownloadManager) DownloadTracks(ctx context.Context, tracks []*subsonic.Child, dir string, onProgress func(done, total int)) error {
+	profile := d.streamingCfg.Profile(d.streamingCfg.DownloadProfile)
+	var firstErr error
+	for i, tr := range tracks {
+		if err := d.downloadTrack(ctx, tr, profile, dir); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if onProgress != nil {
+			onProgress(i+1, len(tracks))
+		}
+	}
+	return firstErr
+}
+
+func (d *DownloadManager) downloadTrack(ctx context.Context, track *subsonic.Child, profile *StreamingProfile, dir string) error {
+	u, err := d.sm.StreamURL(track.ID, profile.Params())
+	if err != nil {
+		return err
+	}
+	cli, conn, _ := d.sm.connectionState()
+	if cli == nil {
+		return ErrUnreachable
+	}
+	// The client's Timeout covers reading the whole response body, which
+	// a large download can exceed. Instead, give up if the server stops
+	// sending data for the connection's request timeout.
+	httpCli := *cli.Client
+	httpCli.Timeout = 0
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stall *time.Timer
+	var stalled atomic.Bool
+	timeout := time.Duration(conn.RequestTimeoutSeconds) * time.Second
+	if timeout > 0 {
+		stall = time.AfterFunc(timeout, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer stall.Stop()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpCli.Do(req)
+	if err != nil {
+		if stalled.Load() {
+			err = fmt.Errorf("error downloading %q: server stopped responding", track.Title)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	var body io.Reader = resp.Body
+	if stall != nil {
+		body = &stallReader{r: resp.Body, timer: stall, timeout: timeout}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error downloading %q: %s", track.Title, resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); strings.HasPrefix(ct, "text/xml") || strings.HasPrefix(ct, "application/xml") {
+		// Subsonic errors are returned as XML with a 200 status
+		return fmt.Errorf("error downloading %q: server returned an error", track.Title)
+	}
+
+	ext := profile.FileExtension()
+	if ext == "" {
+		ext = track.Suffix
+	}
+	dest := filepath.Join(dir, downloadFileName(track, ext))
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		if stalled.Load() {
+			err = fmt.Errorf("error downloading %q: server stopped responding", track.Title)
+		}
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// stallReader resets timer each time data is read from r.
+type stallReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+func downloadFileName(track *subsonic.Child, ext string) string {
+	name := track.Title
+	if track.Artist != "" {
+		name = track.Artist + " - " + name
+	}
+	if track.Track > 0 {
+		name = fmt.Sprintf("%02d %s", track.Track, name)
+	}
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, name)
+	if ext != "" {
+		name += "." + ext
+	}
+	return name
+}
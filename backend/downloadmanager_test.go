@@ -0,0 +1,60 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// Returns a DownloadManager for a server that sends the stream
+// in chunks with the given delay before each one.
+func newTestDownloadManager(t *testing.T, chunks int, delay time.Duration) *DownloadManager {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < chunks; i++ {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			w.Write([]byte("data"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	conn := ServerConnection{Hostname: srv.URL, RequestTimeoutSeconds: 1}
+	cli := &subsonic.Client{Client: srv.Client(), BaseUrl: srv.URL, User: "test", ClientName: "test"}
+	cli.Client.Timeout = time.Second
+	sm := &ServerManager{server: cli, connection: conn}
+	cfg := DefaultConfig("")
+	return NewDownloadManager(sm, &cfg.Streaming)
+}
+
+func TestDownloadLongerThanRequestTimeout(t *testing.T) {
+	d := newTestDownloadManager(t, 4, 400*time.Millisecond)
+	dir := t.TempDir()
+	tr := &subsonic.Child{ID: "1", Title: "Long", Suffix: "mp3"}
+	if err := d.DownloadTracks(context.Background(), []*subsonic.Child{tr}, dir, nil); err != nil {
+		t.Fatalf("DownloadTracks: %s", err.Error())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Long.mp3"))
+	if err != nil || len(data) != 16 {
+		t.Errorf("got %d bytes downloaded, %v", len(data), err)
+	}
+}
+
+func TestDownloadStalled(t *testing.T) {
+	d := newTestDownloadManager(t, 2, 1500*time.Millisecond)
+	tr := &subsonic.Child{ID: "1", Title: "Stalled", Suffix: "mp3"}
+	if err := d.DownloadTracks(context.Background(), []*subsonic.Child{tr}, t.TempDir(), nil); err == nil {
+		t.Error("stalled download succeeded")
+	}
+}
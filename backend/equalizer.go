@@ -0,0 +1,152 @@
+package backend
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"supersonic/player"
+)
+
+var ErrBuiltinPresetName = errors.New("a built-in preset with this name already exists")
+
+// A named equalizer curve.
+type EqualizerPreset struct {
+	Name     string
+	PreampDB float64
+	// Gain of each band in player.EqualizerBands
+	GainsDB []float64
+}
+
+func BuiltinEqualizerPresets() []*EqualizerPreset {
+	return []*EqualizerPreset{
+		{Name: "Flat", GainsDB: make([]float64, player.NumEqualizerBands)},
+		{Name: "Bass Boost", PreampDB: -5, GainsDB: []float64{6, 5, 4, 2, 0, 0, 0, 0, 0, 0}},
+		{Name: "Treble Boost", PreampDB: -5, GainsDB: []float64{0, 0, 0, 0, 0, 1, 2, 4, 5, 6}},
+		{Name: "Vocal", PreampDB: -3, GainsDB: []float64{-2, -2, -1, 0, 2, 4, 4, 2, 0, -1}},
+		{Name: "Rock", PreampDB: -3, GainsDB: []float64{4, 3, 2, 0, -1, -1, 0, 2, 3, 4}},
+		{Name: "Pop", PreampDB: -3, GainsDB: []float64{-1, 0, 2, 3, 4, 3, 1, 0, -1, -1}},
+		{Name: "Jazz", PreampDB: -2, GainsDB: []float64{3, 2, 1, 2, -1, -1, 0, 1, 2, 3}},
+		{Name: "Classical", PreampDB: -3, GainsDB: []float64{4, 3, 2, 1, 0, 0, 0, 1, 2, 3}},
+		{Name: "Electronic", PreampDB: -4, GainsDB: []float64{5, 4, 1, 0, -2, 1, 0, 1, 4, 5}},
+	}
+}
+
+// Returns the built-in presets followed by the user's presets.
+func (c *EqualizerConfig) Presets() []*EqualizerPreset {
+	return append(BuiltinEqualizerPresets(), c.UserPresets...)
+}
+
+// Returns the preset with the given name, or nil if not found.
+func (c *EqualizerConfig) FindPreset(name string) *EqualizerPreset {
+	for _, p := range c.Presets() {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (c *EqualizerConfig) IsBuiltinPreset(name string) bool {
+	for _, p := range BuiltinEqualizerPresets() {
+		if strings.EqualFold(p.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the current curve to that of the given preset.
+func (c *EqualizerConfig) LoadPreset(p *EqualizerPreset) {
+	c.Preset = p.Name
+	c.PreampDB = p.PreampDB
+	c.GainsDB = append([]float64{}, p.GainsDB...)
+}
+
+// Saves the current curve as a user preset with the given name,
+// replacing the user preset of the same name, if any.
+func (c *EqualizerConfig) SaveUserPreset(name string) error {
+	if c.IsBuiltinPreset(name) {
+		return ErrBuiltinPresetName
+	}
+	preset := c.curve()
+	preset.Name = name
+	c.Preset = name
+	for i, p := range c.UserPresets {
+		if p.Name == name {
+			c.UserPresets[i] = preset
+			return nil
+		}
+	}
+	c.UserPresets = append(c.UserPresets, preset)
+	return nil
+}
+
+func (c *EqualizerConfig) DeleteUserPreset(name string) {
+	for i, p := range c.UserPresets {
+		if p.Name == name {
+			c.UserPresets = append(c.UserPresets[:i], c.UserPresets[i+1:]...)
+			break
+		}
+	}
+	if c.Preset == name {
+		c.Preset = ""
+	}
+}
+
+// Returns a copy of the current curve.
+func (c *EqualizerConfig) curve() *EqualizerPreset {
+	return &EqualizerPreset{
+		Name:     c.Preset,
+		PreampDB: c.PreampDB,
+		GainsDB:  append([]float64{}, c.GainsDB...),
+	}
+}
+
+func (c *EqualizerConfig) playerOptions() player.EqualizerOptions {
+	opts := player.EqualizerOptions{Enabled: c.Enabled, PreampDB: c.PreampDB}
+	copy(opts.GainsDB[:], c.GainsDB)
+	return opts
+}
+
+// EqualizerManager applies the equalizer settings to the player and,
+// if per-device curves are enabled, switches the curve when the
+// audio output device changes.
+type EqualizerManager struct {
+	player *player.Player
+	config *EqualizerConfig
+	device string
+}
+
+func NewEqualizerManager(p *player.Player, config *EqualizerConfig) *EqualizerManager {
+	return &EqualizerManager{player: p, config: config}
+}
+
+// Applies the current equalizer settings to the player, and remembers
+// the curve for the current audio device if per-device curves are enabled.
+func (e *EqualizerManager) Apply() error {
+	if e.config.PerDevice && e.device != "" {
+		if e.config.DeviceCurves == nil {
+			e.config.DeviceCurves = make(map[string]*EqualizerPreset)
+		}
+		e.config.DeviceCurves[e.device] = e.config.curve()
+	}
+	return e.player.SetEqualizer(e.config.playerOptions())
+}
+
+// Sets the audio output device of the player, switching to the
+// curve saved for the device if per-device curves are enabled.
+func (e *EqualizerManager) SetAudioDevice(deviceName string) error {
+	e.device = deviceName
+	if err := e.player.SetAudioDevice(deviceName); err != nil {
+		return err
+	}
+	if e.config.PerDevice {
+		if curve, ok := e.config.DeviceCurves[deviceName]; ok {
+			e.config.LoadPreset(curve)
+		}
+	}
+	if err := e.Apply(); err != nil {
+		log.Printf("error setting equalizer: %s", err.Error())
+	}
+	return nil
+}
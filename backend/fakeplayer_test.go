@@ -0,0 +1,278 @@
+package backend
+
+import (
+	"errors"
+	"supersonic/player"
+	"sync"
+)
+
+// fakePlayer is an in-memory Player for PlaybackManager tests.
+// Like the mpv-backed Player, it reports track changes asynchronously:
+// the callbacks are queued and invoked when the test calls flush.
+type fakePlayer struct {
+	mu       sync.Mutex
+	playlist []string
+	status   player.Status
+	speed    float64
+	volume   int
+	stopAC   bool
+	pending  []func()
+
+	onTrackChange      []func(int64)
+	onSeek             []func()
+	onPaused           []func()
+	onPlaying          []func()
+	onStopped          []func()
+	onStopAfterCurrent []func()
+	onTimeUpdate       []func(float64, float64)
+}
+
+var (
+	_ Player          = (*fakePlayer)(nil)
+	_ SchedulerPlayer = (*fakePlayer)(nil)
+)
+
+func newFakePlayer() *fakePlayer {
+	return &fakePlayer{speed: 1, volume: 100, status: player.Status{PlaylistPos: -1}}
+}
+
+// Invokes the callbacks queued since the last flush.
+func (f *fakePlayer) flush() {
+	for {
+		f.mu.Lock()
+		pending := f.pending
+		f.pending = nil
+		f.mu.Unlock()
+		if len(pending) == 0 {
+			return
+		}
+		for _, cb := range pending {
+			cb()
+		}
+	}
+}
+
+// Simulates the end of the current track.
+func (f *fakePlayer) endTrack() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status.PlaylistPos+1 < int64(len(f.playlist)) {
+		f.load(f.status.PlaylistPos + 1)
+	} else {
+		f.stop()
+	}
+}
+
+func (f *fakePlayer) Playlist() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.playlist...)
+}
+
+func (f *fakePlayer) AppendFile(url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.playlist = append(f.playlist, url)
+	return nil
+}
+
+func (f *fakePlayer) RemoveTrackAt(idx int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if idx < 0 || idx >= len(f.playlist) {
+		return errors.New("index out of range")
+	}
+	f.playlist = append(f.playlist[:idx], f.playlist[idx+1:]...)
+	pos := f.status.PlaylistPos
+	switch {
+	case int64(idx) < pos:
+		f.status.PlaylistPos--
+	case int64(idx) == pos && idx < len(f.playlist):
+		// the track after the removed current one starts playing
+		f.load(pos)
+	case int64(idx) == pos:
+		f.stop()
+	}
+	return nil
+}
+
+func (f *fakePlayer) MoveTrack(from, to int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if from < 0 || from >= len(f.playlist) || to < 0 || to > len(f.playlist) {
+		return errors.New("index out of range")
+	}
+	if from == to {
+		return nil
+	}
+	url := f.playlist[from]
+	f.playlist = append(f.playlist[:from], f.playlist[from+1:]...)
+	if from < to {
+		to--
+	}
+	f.playlist = append(f.playlist[:to], append([]string{url}, f.playlist[to:]...)...)
+	switch pos := f.status.PlaylistPos; {
+	case int64(from) == pos:
+		f.status.PlaylistPos = int64(to)
+	case int64(from) < pos && int64(to) >= pos:
+		f.status.PlaylistPos--
+	case int64(from) > pos && int64(to) <= pos:
+		f.status.PlaylistPos++
+	}
+	return nil
+}
+
+func (f *fakePlayer) ClearPlayQueue() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if pos := f.status.PlaylistPos; pos >= 0 {
+		f.playlist = []string{f.playlist[pos]}
+		f.status.PlaylistPos = 0
+	} else {
+		f.playlist = nil
+	}
+	return nil
+}
+
+func (f *fakePlayer) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.playlist = nil
+	f.stop()
+	return nil
+}
+
+func (f *fakePlayer) PlayFromBeginning() error {
+	return f.PlayTrackAt(0)
+}
+
+func (f *fakePlayer) PlayTrackAt(idx int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if idx < 0 || idx >= len(f.playlist) {
+		return errors.New("index out of range")
+	}
+	if f.status.State != player.Playing {
+		f.status.State = player.Playing
+		f.queue(f.onPlaying)
+	}
+	f.load(int64(idx))
+	return nil
+}
+
+func (f *fakePlayer) FadeOutAndPause(_ float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status.State == player.Playing {
+		f.status.State = player.Paused
+		f.queue(f.onPaused)
+	}
+	return nil
+}
+
+func (f *fakePlayer) CancelFadeOutAndPause() {}
+
+func (f *fakePlayer) GetStatus() player.Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+func (f *fakePlayer) GetVolume() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.volume
+}
+
+func (f *fakePlayer) SetVolume(vol int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.volume = clamp(vol, 0, 100)
+	return nil
+}
+
+func (f *fakePlayer) IsSeeking() bool {
+	return false
+}
+
+func (f *fakePlayer) SetSpeed(speed float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.speed = speed
+	return nil
+}
+
+func (f *fakePlayer) GetSpeed() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.speed
+}
+
+func (f *fakePlayer) SetStopAfterCurrent(stop bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopAC = stop
+	return nil
+}
+
+func (f *fakePlayer) StopAfterCurrent() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stopAC
+}
+
+func (f *fakePlayer) SetReplayGainOptions(_ player.ReplayGainOptions) error { return nil }
+
+func (f *fakePlayer) SetHTTPOptions(_ player.HTTPOptions) error { return nil }
+
+func (f *fakePlayer) SetTransitionOptions(_ player.TransitionOptions) {}
+
+func (f *fakePlayer) OnTrackChange(cb func(int64)) {
+	f.onTrackChange = append(f.onTrackChange, cb)
+}
+
+func (f *fakePlayer) OnSeek(cb func()) {
+	f.onSeek = append(f.onSeek, cb)
+}
+
+func (f *fakePlayer) OnPaused(cb func()) {
+	f.onPaused = append(f.onPaused, cb)
+}
+
+func (f *fakePlayer) OnPlaying(cb func()) {
+	f.onPlaying = append(f.onPlaying, cb)
+}
+
+func (f *fakePlayer) OnStopped(cb func()) {
+	f.onStopped = append(f.onStopped, cb)
+}
+
+func (f *fakePlayer) OnStopAfterCurrent(cb func()) {
+	f.onStopAfterCurrent = append(f.onStopAfterCurrent, cb)
+}
+
+func (f *fakePlayer) OnTimeUpdate(cb func(pos, dur float64)) {
+	f.onTimeUpdate = append(f.onTimeUpdate, cb)
+}
+
+func (f *fakePlayer) load(pos int64) {
+	f.status.PlaylistPos = pos
+	f.status.TimePos = 0
+	for _, cb := range f.onTrackChange {
+		cb := cb
+		f.pending = append(f.pending, func() { cb(pos) })
+	}
+}
+
+func (f *fakePlayer) stop() {
+	f.status.PlaylistPos = -1
+	f.status.TimePos = 0
+	if f.status.State != player.Stopped {
+		f.status.State = player.Stopped
+		f.queue(f.onStopped)
+	}
+}
+
+func (f *fakePlayer) queue(callbacks []func()) {
+	f.pending = append(f.pending, callbacks...)
+}
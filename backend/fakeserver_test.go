@@ -0,0 +1,474 @@
+package backend
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+const (
+	fakeServerUser     = "test"
+	fakeServerPassword = "secret"
+)
+
+// The library served by the fake server, loaded from testdata/library.xml.
+type fakeLibrary struct {
+	Artists []*subsonic.ArtistID3 `xml:"http://subsonic.org/restapi artist"`
+}
+
+// Response envelope written by the fake server. go-subsonic's Response
+// cannot be used since some of its fields have unexported types.
+type fakeResponse struct {
+	XMLName       xml.Name                `xml:"http://subsonic.org/restapi subsonic-response"`
+	Status        string                  `xml:"status,attr"`
+	Version       string                  `xml:"version,attr"`
+	Error         *subsonic.Error         `xml:"error,omitempty"`
+	AlbumList2    *fakeAlbumList          `xml:"albumList2,omitempty"`
+	Album         *subsonic.AlbumID3      `xml:"album,omitempty"`
+	Artist        *subsonic.ArtistID3     `xml:"artist,omitempty"`
+	SearchResult3 *subsonic.SearchResult3 `xml:"searchResult3,omitempty"`
+	RandomSongs   *fakeSongList           `xml:"randomSongs,omitempty"`
+	SimilarSongs  *fakeSongList           `xml:"similarSongs,omitempty"`
+	SimilarSongs2 *fakeSongList           `xml:"similarSongs2,omitempty"`
+	Playlists     *fakePlaylists          `xml:"playlists,omitempty"`
+	Playlist      *subsonic.Playlist      `xml:"playlist,omitempty"`
+	Song          *subsonic.Child         `xml:"song,omitempty"`
+}
+
+type fakeAlbumList struct {
+	Album []*subsonic.AlbumID3 `xml:"album"`
+}
+
+type fakePlaylists struct {
+	Playlist []*subsonic.Playlist `xml:"playlist"`
+}
+
+type fakeSongList struct {
+	Song []*subsonic.Child `xml:"song"`
+}
+
+type fakeScrobble struct {
+	ID         string
+	Submission bool
+}
+
+// fakeServer is an in-process Subsonic server for tests. It serves the
+// fixture library over the Subsonic XML API, records scrobbles and
+// keeps the playlists created by the client in memory.
+type fakeServer struct {
+	*httptest.Server
+
+	artists []*subsonic.ArtistID3
+	albums  []*subsonic.AlbumID3
+	songs   []*subsonic.Child
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	requests  map[string]int
+	failing   map[string]bool
+	scrobbles []fakeScrobble
+	playlists []*subsonic.Playlist
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	data, err := os.ReadFile("testdata/library.xml")
+	if err != nil {
+		t.Fatalf("error reading fixture library: %s", err.Error())
+	}
+	var lib fakeLibrary
+	if err := xml.Unmarshal(data, &lib); err != nil {
+		t.Fatalf("error parsing fixture library: %s", err.Error())
+	}
+	f := &fakeServer{
+		artists:  lib.Artists,
+		rand:     rand.New(rand.NewSource(1)),
+		requests: make(map[string]int),
+		failing:  make(map[string]bool),
+	}
+	for _, ar := range lib.Artists {
+		for _, al := range ar.Album {
+			f.albums = append(f.albums, al)
+			f.songs = append(f.songs, al.Song...)
+		}
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// Returns a client for the fake server that has been authenticated.
+func (f *fakeServer) newClient(t *testing.T) *subsonic.Client {
+	t.Helper()
+	cli := &subsonic.Client{
+		Client:     f.Client(),
+		BaseUrl:    f.URL,
+		User:       fakeServerUser,
+		ClientName: "test",
+	}
+	if err := cli.Authenticate(fakeServerPassword); err != nil {
+		t.Fatalf("error authenticating: %s", err.Error())
+	}
+	return cli
+}
+
+// Returns the number of requests made to the given endpoint.
+func (f *fakeServer) Requests(endpoint string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[endpoint]
+}
+
+// Makes requests to the given endpoint fail with a generic error,
+// as opposed to the "not found" error returned for unknown IDs.
+func (f *fakeServer) Fail(endpoint string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing[endpoint] = true
+}
+
+// Returns the scrobbles and now playing notifications received.
+func (f *fakeServer) Scrobbles() []fakeScrobble {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeScrobble(nil), f.scrobbles...)
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimSuffix(path.Base(r.URL.Path), ".view")
+	q := r.URL.Query()
+	f.mu.Lock()
+	f.requests[endpoint]++
+	failing := f.failing[endpoint]
+	f.mu.Unlock()
+
+	resp := &fakeResponse{Status: "ok", Version: "1.16.1"}
+	if !authenticated(q) {
+		resp.Status = "failed"
+		resp.Error = &subsonic.Error{Code: 40, Message: "Wrong username or password"}
+	} else if failing {
+		resp.Status = "failed"
+		resp.Error = &subsonic.Error{Code: 0, Message: "internal error"}
+	} else if err := f.handle(endpoint, q, resp); err != nil {
+		resp.Status = "failed"
+		resp.Error = &subsonic.Error{Code: 70, Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	data, _ := xml.Marshal(resp)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+func authenticated(q map[string][]string) bool {
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	if get("u") != fakeServerUser {
+		return false
+	}
+	if p := get("p"); p != "" {
+		return p == fakeServerPassword
+	}
+	token := fmt.Sprintf("%x", md5.Sum([]byte(fakeServerPassword+get("s"))))
+	return get("t") == token
+}
+
+func (f *fakeServer) handle(endpoint string, q map[string][]string, resp *fakeResponse) error {
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	atoi := func(k string, def int) int {
+		if n, err := strconv.Atoi(get(k)); err == nil {
+			return n
+		}
+		return def
+	}
+
+	switch endpoint {
+	case "ping":
+	case "scrobble":
+		f.mu.Lock()
+		f.scrobbles = append(f.scrobbles, fakeScrobble{ID: get("id"), Submission: get("submission") != "false"})
+		f.mu.Unlock()
+	case "getAlbum":
+		for _, al := range f.albums {
+			if al.ID == get("id") {
+				resp.Album = al
+				return nil
+			}
+		}
+		return fmt.Errorf("album not found")
+	case "getSong":
+		if resp.Song = f.song(get("id")); resp.Song == nil {
+			return fmt.Errorf("song not found")
+		}
+	case "getArtist":
+		for _, ar := range f.artists {
+			if ar.ID == get("id") {
+				artist := *ar
+				artist.Album = withoutSongs(ar.Album)
+				resp.Artist = &artist
+				return nil
+			}
+		}
+		return fmt.Errorf("artist not found")
+	case "getAlbumList2":
+		albums, err := f.albumList(get("type"), get("genre"), atoi("fromYear", 0), atoi("toYear", 0))
+		if err != nil {
+			return err
+		}
+		resp.AlbumList2 = &fakeAlbumList{Album: withoutSongs(page(albums, atoi("offset", 0), atoi("size", 10)))}
+	case "getRandomSongs":
+		var songs []*subsonic.Child
+		for _, s := range f.songs {
+			if g := get("genre"); g == "" || s.Genre == g {
+				songs = append(songs, s)
+			}
+		}
+		f.shuffle(len(songs), func(i, j int) { songs[i], songs[j] = songs[j], songs[i] })
+		resp.RandomSongs = &fakeSongList{Song: page(songs, 0, atoi("size", 10))}
+	case "getSimilarSongs":
+		// the other songs of the same genre are the most similar to a song
+		seed := f.song(get("id"))
+		if seed == nil {
+			return fmt.Errorf("song not found")
+		}
+		var same, other []*subsonic.Child
+		for _, s := range f.songs {
+			if s.ID == seed.ID {
+				continue
+			} else if s.Genre == seed.Genre {
+				same = append(same, s)
+			} else {
+				other = append(other, s)
+			}
+		}
+		resp.SimilarSongs = &fakeSongList{Song: page(append(same, other...), 0, atoi("count", 50))}
+	case "getSimilarSongs2":
+		// the songs of all other artists are considered similar
+		if !f.hasArtist(get("id")) {
+			return fmt.Errorf("artist not found")
+		}
+		var songs []*subsonic.Child
+		for _, s := range f.songs {
+			if s.ArtistID != get("id") {
+				songs = append(songs, s)
+			}
+		}
+		resp.SimilarSongs2 = &fakeSongList{Song: page(songs, 0, atoi("count", 50))}
+	case "getPlaylists":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		resp.Playlists = &fakePlaylists{}
+		for _, pl := range f.playlists {
+			c := *pl
+			c.Entry = nil
+			resp.Playlists.Playlist = append(resp.Playlists.Playlist, &c)
+		}
+	case "getPlaylist":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		pl := f.playlist(get("id"))
+		if pl == nil {
+			return fmt.Errorf("playlist not found")
+		}
+		c := *pl
+		resp.Playlist = &c
+	case "createPlaylist":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		pl := f.playlist(get("playlistId"))
+		if pl == nil {
+			if get("playlistId") != "" {
+				return fmt.Errorf("playlist not found")
+			}
+			pl = &subsonic.Playlist{
+				ID:    fmt.Sprintf("pl-%d", len(f.playlists)+1),
+				Name:  get("name"),
+				Owner: fakeServerUser,
+				// creation order, one second apart
+				Created: time.Unix(int64(len(f.playlists)), 0),
+			}
+			f.playlists = append(f.playlists, pl)
+		}
+		pl.Entry = nil
+		for _, id := range q["songId"] {
+			pl.Entry = append(pl.Entry, f.song(id))
+		}
+		pl.SongCount = len(pl.Entry)
+	case "updatePlaylist":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		pl := f.playlist(get("playlistId"))
+		if pl == nil {
+			return fmt.Errorf("playlist not found")
+		}
+		remove := make(map[int]bool)
+		for _, idx := range q["songIndexToRemove"] {
+			n, _ := strconv.Atoi(idx)
+			remove[n] = true
+		}
+		entries := pl.Entry[:0]
+		for i, tr := range pl.Entry {
+			if !remove[i] {
+				entries = append(entries, tr)
+			}
+		}
+		for _, id := range q["songIdToAdd"] {
+			entries = append(entries, f.song(id))
+		}
+		pl.Entry = entries
+		pl.SongCount = len(pl.Entry)
+	case "search3":
+		query := strings.ToLower(strings.Trim(get("query"), `"`))
+		matches := func(s string) bool { return strings.Contains(strings.ToLower(s), query) }
+		res := &subsonic.SearchResult3{}
+		for _, ar := range f.artists {
+			if matches(ar.Name) {
+				artist := *ar
+				artist.Album = nil
+				res.Artist = append(res.Artist, &artist)
+			}
+		}
+		for _, al := range f.albums {
+			if matches(al.Name) {
+				res.Album = append(res.Album, al)
+			}
+		}
+		for _, s := range f.songs {
+			if matches(s.Title) {
+				res.Song = append(res.Song, s)
+			}
+		}
+		res.Artist = page(res.Artist, atoi("artistOffset", 0), atoi("artistCount", 20))
+		res.Album = withoutSongs(page(res.Album, atoi("albumOffset", 0), atoi("albumCount", 20)))
+		res.Song = page(res.Song, atoi("songOffset", 0), atoi("songCount", 20))
+		resp.SearchResult3 = res
+	default:
+		return fmt.Errorf("%s is not implemented by the fake server", endpoint)
+	}
+	return nil
+}
+
+// Returns the albums for the given getAlbumList2 type.
+// Types that depend on play history return no albums.
+func (f *fakeServer) albumList(listType, genre string, fromYear, toYear int) ([]*subsonic.AlbumID3, error) {
+	albums := append([]*subsonic.AlbumID3(nil), f.albums...)
+	switch listType {
+	case "newest":
+		sort.SliceStable(albums, func(i, j int) bool { return albums[i].Created.After(albums[j].Created) })
+	case "alphabeticalByName":
+		sort.SliceStable(albums, func(i, j int) bool { return albums[i].Name < albums[j].Name })
+	case "alphabeticalByArtist":
+		sort.SliceStable(albums, func(i, j int) bool { return albums[i].Artist < albums[j].Artist })
+	case "byYear":
+		lo, hi := fromYear, toYear
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		filtered := albums[:0]
+		for _, al := range albums {
+			if al.Year >= lo && al.Year <= hi {
+				filtered = append(filtered, al)
+			}
+		}
+		albums = filtered
+		sort.SliceStable(albums, func(i, j int) bool {
+			if fromYear > toYear {
+				return albums[i].Year > albums[j].Year
+			}
+			return albums[i].Year < albums[j].Year
+		})
+	case "byGenre":
+		filtered := albums[:0]
+		for _, al := range albums {
+			if al.Genre == genre {
+				filtered = append(filtered, al)
+			}
+		}
+		albums = filtered
+	case "random":
+		f.shuffle(len(albums), func(i, j int) { albums[i], albums[j] = albums[j], albums[i] })
+	case "recent", "frequent", "starred", "highest":
+		albums = nil
+	default:
+		return nil, fmt.Errorf("unknown list type %s", listType)
+	}
+	return albums, nil
+}
+
+func (f *fakeServer) song(id string) *subsonic.Child {
+	for _, s := range f.songs {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// Must be called with f.mu held.
+func (f *fakeServer) playlist(id string) *subsonic.Playlist {
+	for _, pl := range f.playlists {
+		if pl.ID == id {
+			return pl
+		}
+	}
+	return nil
+}
+
+func (f *fakeServer) hasArtist(id string) bool {
+	for _, ar := range f.artists {
+		if ar.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeServer) shuffle(n int, swap func(i, j int)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rand.Shuffle(n, swap)
+}
+
+// Returns copies of the albums without their tracks,
+// as album lists from a Subsonic server do not include them.
+func withoutSongs(albums []*subsonic.AlbumID3) []*subsonic.AlbumID3 {
+	copies := make([]*subsonic.AlbumID3, len(albums))
+	for i, al := range albums {
+		c := *al
+		c.Song = nil
+		copies[i] = &c
+	}
+	return copies
+}
+
+func page[T any](items []T, offset, size int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if size < len(items) {
+		items = items[:size]
+	}
+	return items
+}
@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"supersonic/player"
+	"time"
+)
+
+// Creates an http.Client configured with the HTTP options
+// (timeout, proxy, TLS and extra headers) of the server connection.
+func newHTTPClient(connection ServerConnection) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if connection.ProxyURL != "" {
+		proxyURL, err := url.Parse(connection.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %s", err.Error())
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := connection.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	var rt http.RoundTripper = transport
+	if len(connection.ExtraHeaders) > 0 {
+		rt = &headerTransport{base: transport, headers: connection.ExtraHeaders}
+	}
+	return &http.Client{
+		Transport: rt,
+		Timeout:   time.Duration(connection.RequestTimeoutSeconds) * time.Second,
+	}, nil
+}
+
+func (c ServerConnection) tlsConfig() (*tls.Config, error) {
+	conf := &tls.Config{}
+	if c.SkipTLSVerify {
+		log.Printf("WARNING: TLS certificate verification is disabled for %s", c.Hostname)
+		conf.InsecureSkipVerify = true
+	}
+	if c.CACertFile != "" {
+		pem, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %s", err.Error())
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no valid certificates found in CA bundle")
+		}
+		conf.RootCAs = pool
+	}
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		keyFile := c.ClientKeyFile
+		if keyFile == "" {
+			// key may be bundled in the same PEM file as the certificate
+			keyFile = c.ClientCertFile
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %s", err.Error())
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// Returns the HTTP options to pass to the Player so that
+// mpv's stream requests use the same settings as the API client.
+func (c ServerConnection) playerHTTPOptions() player.HTTPOptions {
+	opts := player.HTTPOptions{
+		TimeoutSeconds: c.RequestTimeoutSeconds,
+		CACertFile:     c.CACertFile,
+		ClientCertFile: c.ClientCertFile,
+		ClientKeyFile:  c.ClientKeyFile,
+		SkipTLSVerify:  c.SkipTLSVerify,
+		Headers:        c.ExtraHeaders,
+	}
+	if u, err := url.Parse(c.ProxyURL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		opts.ProxyURL = c.ProxyURL
+	} else if c.ProxyURL != "" {
+		log.Printf("proxy %q is not supported for audio streams; streams will connect directly", c.ProxyURL)
+	}
+	return opts
+}
+
+// headerTransport adds a set of extra headers to every request.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (h *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+	return h.base.RoundTrip(req)
+}
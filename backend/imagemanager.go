@@ -72,7 +72,7 @@ func (i *ImageManager) GetFullSizeCoverArt(coverID string) (image.Image, error)
 	if i.cachedFullSizeCoverID == coverID {
 		return i.cachedFullSizeCover, nil
 	}
-	im, err := i.s.Server.GetCoverArt(coverID, nil)
+	im, err := i.s.Server().GetCoverArt(coverID, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -103,13 +103,13 @@ func (i *ImageManager) RefreshCachedArtistImageIfExpired(artistID string, imgURL
 }
 
 func (i *ImageManager) ensureCoverCacheDir() string {
-	path := path.Join(i.baseCacheDir, i.s.ServerID.String(), "covers")
+	path := path.Join(i.baseCacheDir, i.s.ServerID().String(), "covers")
 	configdir.MakePath(path)
 	return path
 }
 
 func (i *ImageManager) ensureArtistCoverCacheDir() string {
-	path := path.Join(i.baseCacheDir, i.s.ServerID.String(), "artistimages")
+	path := path.Join(i.baseCacheDir, i.s.ServerID().String(), "artistimages")
 	configdir.MakePath(path)
 	return path
 }
@@ -143,7 +143,7 @@ func (i *ImageManager) fetchAndCacheCoverFromDiskOrServer(coverID string, ttl ti
 }
 
 func (i *ImageManager) fetchAndCacheCoverFromServer(coverID string, ttl time.Duration) (image.Image, error) {
-	img, err := i.s.Server.GetCoverArt(coverID, map[string]string{"size": "300"})
+	img, err := i.s.Server().GetCoverArt(coverID, map[string]string{"size": "300"})
 	if err != nil {
 		return nil, err
 	}
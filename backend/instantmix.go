@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+const (
+	// Number of tracks in an instant mix
+	instantMixSize = 100
+	// Maximum number of seed tracks queried for similar songs
+	instantMixMaxSeeds = 20
+	// Number of similar songs requested per seed track
+	instantMixSimilarCount = 50
+)
+
+// Plays a mix of songs similar to the seed tracks, replacing the play queue.
+func (p *PlaybackManager) PlayInstantMix(seeds []*subsonic.Child) error {
+	mix, err := p.instantMix(seeds)
+	if err != nil {
+		return err
+	}
+	if err := p.LoadTracks(mix, false, ShuffleNone); err != nil {
+		return err
+	}
+	return p.PlayFromBeginning()
+}
+
+// Plays an instant mix seeded by the tracks of the specified album.
+func (p *PlaybackManager) PlayAlbumInstantMix(albumID string) error {
+	album, err := p.sm.Server().GetAlbum(albumID)
+	if err != nil {
+		return err
+	}
+	return p.PlayInstantMix(album.Song)
+}
+
+// Plays an instant mix seeded by the tracks of the specified playlist.
+func (p *PlaybackManager) PlayPlaylistInstantMix(playlistID string) error {
+	playlist, err := p.sm.Server().GetPlaylist(playlistID)
+	if err != nil {
+		return err
+	}
+	return p.PlayInstantMix(playlist.Entry)
+}
+
+// Queries the songs similar to each seed and merges the results
+// into a mix of up to instantMixSize tracks. The results are interleaved,
+// so that each seed contributes its most similar songs first, and tracks
+// the user has rated highly or starred are moved towards the front.
+func (p *PlaybackManager) instantMix(seeds []*subsonic.Child) ([]*subsonic.Child, error) {
+	seeds = sampleSeeds(seeds, instantMixMaxSeeds)
+	params := map[string]string{"count": strconv.Itoa(instantMixSimilarCount)}
+	var results [][]*subsonic.Child
+	var lastErr error
+	for _, seed := range seeds {
+		songs, err := p.sm.Server().GetSimilarSongs(seed.ID, params)
+		if err != nil {
+			log.Printf("error getting similar songs: %s", err.Error())
+			lastErr = err
+			continue
+		}
+		results = append(results, songs)
+	}
+	if len(results) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	mix := mergeInstantMix(seeds, results)
+	if len(mix) == 0 {
+		return nil, errors.New("no similar songs found")
+	}
+	return mix, nil
+}
+
+// Returns up to max seeds, spread evenly across the selection.
+func sampleSeeds(seeds []*subsonic.Child, max int) []*subsonic.Child {
+	if len(seeds) <= max {
+		return seeds
+	}
+	sampled := make([]*subsonic.Child, max)
+	for i := range sampled {
+		sampled[i] = seeds[i*len(seeds)/max]
+	}
+	return sampled
+}
+
+type instantMixTrack struct {
+	track    *subsonic.Child
+	priority float64
+}
+
+// Interleaves the similar songs results of each seed, dropping duplicates
+// and the seeds themselves, and orders them by similarity rank and weight.
+func mergeInstantMix(seeds []*subsonic.Child, results [][]*subsonic.Child) []*subsonic.Child {
+	seen := make(map[string]bool)
+	for _, s := range seeds {
+		seen[s.ID] = true
+	}
+	var tracks []instantMixTrack
+	for rank := 0; ; rank++ {
+		more := false
+		for _, songs := range results {
+			if rank >= len(songs) {
+				continue
+			}
+			more = true
+			if tr := songs[rank]; !seen[tr.ID] {
+				seen[tr.ID] = true
+				tracks = append(tracks, instantMixTrack{
+					track:    tr,
+					priority: userPreferenceWeight(tr) / float64(rank+1),
+				})
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	sort.SliceStable(tracks, func(i, j int) bool { return tracks[i].priority > tracks[j].priority })
+	if len(tracks) > instantMixSize {
+		tracks = tracks[:instantMixSize]
+	}
+	mix := make([]*subsonic.Child, len(tracks))
+	for i, t := range tracks {
+		mix[i] = t.track
+	}
+	return mix
+}
+
+// Weight of unrated tracks and tracks rated 1 to 5 stars.
+var ratingWeights = []float64{1, 0.25, 0.5, 1, 1.5, 2}
+
+// Returns the weight of a track in an instant mix or weighted shuffle
+// according to the user's rating and favorite status.
+func userPreferenceWeight(tr *subsonic.Child) float64 {
+	w := ratingWeights[clamp(tr.UserRating, 0, 5)]
+	if !tr.Starred.IsZero() {
+		w *= 2
+	}
+	return w
+}
@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+func TestInstantMix(t *testing.T) {
+	pm, p, srv := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	fav := srv.song("tr-20")
+	fav.UserRating = 5
+	fav.Starred = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := pm.PlayInstantMix([]*subsonic.Child{srv.song("tr-1"), srv.song("tr-7")}); err != nil {
+		t.Fatalf("PlayInstantMix: %s", err.Error())
+	}
+	p.flush()
+
+	queue := pm.GetPlayQueue()
+	if len(queue) != len(srv.songs)-2 {
+		t.Errorf("mix has %d tracks, want %d", len(queue), len(srv.songs)-2)
+	}
+	// the most similar songs of each seed alternate, until the
+	// starred 5-star track outranks the less similar songs
+	want := "tr-2 tr-8 tr-3 tr-9 tr-4 tr-10 tr-5 tr-11 tr-20 tr-6 tr-12"
+	if got := queueIDs(queue[:11]); got != want {
+		t.Errorf("mix starts with %q, want %q", got, want)
+	}
+	seen := make(map[string]bool)
+	for _, tr := range queue {
+		if tr.ID == "tr-1" || tr.ID == "tr-7" {
+			t.Errorf("seed track %s in mix", tr.ID)
+		}
+		if seen[tr.ID] {
+			t.Errorf("track %s in mix twice", tr.ID)
+		}
+		seen[tr.ID] = true
+	}
+	if np := pm.NowPlaying(); np == nil || np.ID != "tr-2" {
+		t.Errorf("now playing = %v, want tr-2", np)
+	}
+	if n := srv.Requests("getSimilarSongs"); n != 2 {
+		t.Errorf("got %d similar songs requests, want 2", n)
+	}
+}
+
+func TestInstantMixLimits(t *testing.T) {
+	var seeds []*subsonic.Child
+	var results [][]*subsonic.Child
+	for i := 0; i < 45; i++ {
+		seeds = append(seeds, &subsonic.Child{ID: fmt.Sprintf("seed-%d", i)})
+	}
+	for i := 0; i < 3; i++ {
+		var songs []*subsonic.Child
+		for j := 0; j < 60; j++ {
+			songs = append(songs, &subsonic.Child{ID: fmt.Sprintf("song-%d-%d", i, j)})
+		}
+		results = append(results, songs)
+	}
+
+	sampled := sampleSeeds(seeds, instantMixMaxSeeds)
+	if len(sampled) != instantMixMaxSeeds || sampled[0] != seeds[0] {
+		t.Errorf("got %d seeds starting with %s, want %d starting with %s",
+			len(sampled), sampled[0].ID, instantMixMaxSeeds, seeds[0].ID)
+	}
+	if mix := mergeInstantMix(sampled, results); len(mix) != instantMixSize {
+		t.Errorf("mix has %d tracks, want %d", len(mix), instantMixSize)
+	}
+}
@@ -1,7 +1,25 @@
 package backend
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	subsonic "github.com/dweymouth/go-subsonic/subsonic"
+	"github.com/google/uuid"
+)
+
+const (
+	// How long the list of all tracks in the library is cached
+	allTracksCacheTTL = time.Hour
+	// Maximum number of tracks sent in a single playlist request
+	playlistChunkSize = 200
+	// Subsonic API error code for "the requested data was not found"
+	subsonicErrNotFound = 70
 )
 
 type AlbumIterator interface {
@@ -14,26 +32,228 @@ type TrackIterator interface {
 
 type LibraryManager struct {
 	PreCacheCoverFn func(coverID string)
+	// Local play history used by the smart playlist rules. May be nil.
+	PlayHistory *PlayHistory
 
 	s *ServerManager
+
+	// guards smartPlaylists, which is also read by the scheduler
+	// and the config writer. Callers get copies of the smart playlists.
+	smartPlaylistsLock sync.Mutex
+	smartPlaylists     *SmartPlaylistsConfig
+	// serializes saving smart playlists to the server,
+	// so that a playlist is not created twice
+	materializeLock sync.Mutex
+
+	onPlaylistsChanged []func()
+
+	allTracksLock     sync.Mutex
+	allTracks         []*subsonic.Child
+	allTracksByMBID   map[string]*subsonic.Child
+	allTracksServerID uuid.UUID
+	allTracksFetched  time.Time
 }
 
-func NewLibraryManager(s *ServerManager) *LibraryManager {
+func NewLibraryManager(s *ServerManager, smartPlaylists *SmartPlaylistsConfig) *LibraryManager {
 	return &LibraryManager{
-		s: s,
+		s:              s,
+		smartPlaylists: smartPlaylists,
+	}
+}
+
+// Returns all tracks in the library. The list is fetched album by album
+// and cached for allTracksCacheTTL, so it may not reflect recent changes.
+func (l *LibraryManager) AllTracks() ([]*subsonic.Child, error) {
+	tracks, _, err := l.allTracksWithMBIDs()
+	return tracks, err
+}
+
+// Returns all tracks in the library and the tracks by MusicBrainz ID.
+func (l *LibraryManager) allTracksWithMBIDs() ([]*subsonic.Child, map[string]*subsonic.Child, error) {
+	if l.s.Server() == nil {
+		return nil, nil, ErrUnreachable
+	}
+	l.allTracksLock.Lock()
+	defer l.allTracksLock.Unlock()
+	if l.allTracks != nil && l.allTracksServerID == l.s.ServerID() && time.Since(l.allTracksFetched) < allTracksCacheTTL {
+		return l.allTracks, l.allTracksByMBID, nil
+	}
+	tracks := make([]*subsonic.Child, 0)
+	byMBID := make(map[string]*subsonic.Child)
+	albums := l.AlbumsIter(AlbumSortArtistAZ)
+	for al := albums.Next(); al != nil; al = albums.Next() {
+		// go-subsonic does not parse the MusicBrainz IDs
+		songs, err := getAlbumSongs(l.s.Server(), al.ID)
+		if err != nil {
+			log.Printf("error fetching album: %s", err.Error())
+			continue
+		}
+		for _, s := range songs {
+			tracks = append(tracks, &s.Child)
+			if s.MusicBrainzID != "" {
+				// looked up case-insensitively
+				byMBID[strings.ToLower(s.MusicBrainzID)] = &s.Child
+			}
+		}
+	}
+	l.allTracks = tracks
+	l.allTracksByMBID = byMBID
+	l.allTracksServerID = l.s.ServerID()
+	l.allTracksFetched = time.Now()
+	return tracks, byMBID, nil
+}
+
+// Discards the cached list of all tracks, so it is fetched again on next use.
+func (l *LibraryManager) InvalidateLibraryCache() {
+	l.allTracksLock.Lock()
+	defer l.allTracksLock.Unlock()
+	l.allTracks = nil
+}
+
+// Sets the tracks of the playlist with the given ID, replacing its contents,
+// or creates a new playlist with the given name if playlistID is empty
+// or the playlist no longer exists. Returns the ID of the playlist.
+// Tracks are sent in chunks to keep the request URLs to a reasonable length.
+func (l *LibraryManager) ReplacePlaylistTracks(playlistID, name string, trackIDs []string) (string, error) {
+	if playlistID != "" {
+		if _, err := l.s.Server().GetPlaylist(playlistID); isSubsonicError(err, subsonicErrNotFound) {
+			// deleted on the server; save as a new playlist
+			playlistID = ""
+		} else if err != nil {
+			return "", err
+		}
+	}
+	first := trackIDs
+	if len(first) > playlistChunkSize {
+		first = first[:playlistChunkSize]
+	}
+	if playlistID != "" {
+		if err := l.s.Server().CreatePlaylistWithTracks(first, map[string]string{"playlistId": playlistID}); err != nil {
+			return "", err
+		}
+	} else {
+		if err := l.s.Server().CreatePlaylistWithTracks(first, map[string]string{"name": name}); err != nil {
+			return "", err
+		}
+		var err error
+		if playlistID, err = l.findNewestUserPlaylist(name); err != nil {
+			return "", err
+		}
+		l.notifyPlaylistsChanged()
+	}
+	return playlistID, l.AppendPlaylistTracks(playlistID, trackIDs[len(first):])
+}
+
+// Appends the tracks to the playlist, in chunks.
+func (l *LibraryManager) AppendPlaylistTracks(playlistID string, trackIDs []string) error {
+	for len(trackIDs) > 0 {
+		chunk := trackIDs
+		if len(chunk) > playlistChunkSize {
+			chunk = chunk[:playlistChunkSize]
+		}
+		if err := l.s.Server().UpdatePlaylistTracks(playlistID, chunk, nil); err != nil {
+			return err
+		}
+		trackIDs = trackIDs[len(chunk):]
+	}
+	return nil
+}
+
+// Removes the tracks at the given indexes from the playlist, in chunks.
+// The chunks are removed from the end of the playlist first, so that
+// the remaining indexes stay valid.
+func (l *LibraryManager) RemovePlaylistTracks(playlistID string, idxs []int) error {
+	idxs = append([]int(nil), idxs...)
+	sort.Ints(idxs)
+	for len(idxs) > 0 {
+		start := len(idxs) - playlistChunkSize
+		if start < 0 {
+			start = 0
+		}
+		if err := l.s.Server().UpdatePlaylistTracks(playlistID, nil, idxs[start:]); err != nil {
+			return err
+		}
+		idxs = idxs[:start]
+	}
+	return nil
+}
+
+// Returns the ID of the most recently created playlist of the user
+// with the given name, since createPlaylist does not return it on all servers.
+func (l *LibraryManager) findNewestUserPlaylist(name string) (string, error) {
+	playlists, err := l.GetUserOwnedPlaylists()
+	if err != nil {
+		return "", err
+	}
+	var newest *subsonic.Playlist
+	for _, pl := range playlists {
+		if pl.Name == name && (newest == nil || !pl.Created.Before(newest.Created)) {
+			newest = pl
+		}
+	}
+	if newest == nil {
+		return "", errors.New("created playlist not found")
+	}
+	return newest.ID, nil
+}
+
+// Updates the metadata (e.g. name, comment, public) of the playlist.
+func (l *LibraryManager) UpdatePlaylist(playlistID string, params map[string]string) error {
+	if err := l.s.Server().UpdatePlaylist(playlistID, params); err != nil {
+		return err
+	}
+	l.notifyPlaylistsChanged()
+	return nil
+}
+
+func (l *LibraryManager) DeletePlaylist(playlistID string) error {
+	if err := l.s.Server().DeletePlaylist(playlistID); err != nil {
+		return err
+	}
+	l.notifyPlaylistsChanged()
+	return nil
+}
+
+// Registers a callback that is invoked when a playlist or smart playlist
+// is created, renamed or deleted. It may be invoked from any goroutine.
+func (l *LibraryManager) OnPlaylistsChanged(cb func()) {
+	l.onPlaylistsChanged = append(l.onPlaylistsChanged, cb)
+}
+
+func (l *LibraryManager) notifyPlaylistsChanged() {
+	for _, cb := range l.onPlaylistsChanged {
+		cb()
 	}
 }
 
 func (l *LibraryManager) GetUserOwnedPlaylists() ([]*subsonic.Playlist, error) {
-	pl, err := l.s.Server.GetPlaylists(nil)
+	pl, err := l.s.Server().GetPlaylists(nil)
 	userPl := make([]*subsonic.Playlist, 0)
 	if err != nil {
 		return nil, err
 	}
 	for _, p := range pl {
-		if p.Owner == l.s.Server.User {
+		if p.Owner == l.s.Server().User {
 			userPl = append(userPl, p)
 		}
 	}
 	return userPl, nil
 }
+
+// Returns the internet radio stations configured on the server.
+func (l *LibraryManager) GetRadioStations() ([]*subsonic.InternetRadioStation, error) {
+	resp, err := l.s.Server().Get("getInternetRadioStations", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.InternetRadioStations == nil {
+		return nil, nil
+	}
+	return resp.InternetRadioStations.InternetRadioStation, nil
+}
+
+// Reports whether err is a Subsonic API error response with the given code.
+// go-subsonic returns these as plain errors formatted as "Error #<code>: <message>".
+func isSubsonicError(err error, code int) bool {
+	return err != nil && strings.HasPrefix(err.Error(), fmt.Sprintf("Error #%d:", code))
+}
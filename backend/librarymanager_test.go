@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReplacePlaylistTracksInChunks(t *testing.T) {
+	l, srv := newTestLibraryManager(t)
+	ids := make([]string, 2*playlistChunkSize+50)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("tr-%d", i%24+1)
+	}
+
+	id, err := l.ReplacePlaylistTracks("", "Queue", ids)
+	if err != nil {
+		t.Fatalf("error saving playlist: %s", err.Error())
+	}
+	if pl := srv.playlist(id); pl == nil || len(pl.Entry) != len(ids) {
+		t.Fatalf("playlist %s not saved with %d tracks", id, len(ids))
+	}
+	if c, u := srv.Requests("createPlaylist"), srv.Requests("updatePlaylist"); c != 1 || u != 2 {
+		t.Errorf("got %d create and %d update requests, want 1 and 2", c, u)
+	}
+
+	// replace with fewer tracks, then append
+	if _, err := l.ReplacePlaylistTracks(id, "Queue", ids[:3]); err != nil {
+		t.Fatalf("error replacing tracks: %s", err.Error())
+	}
+	if err := l.AppendPlaylistTracks(id, ids[3:5]); err != nil {
+		t.Fatalf("error appending tracks: %s", err.Error())
+	}
+	pl := srv.playlist(id)
+	if got, want := queueIDs(pl.Entry), "tr-1 tr-2 tr-3 tr-4 tr-5"; got != want {
+		t.Errorf("got tracks %s, want %s", got, want)
+	}
+
+	// remove more than a chunk of tracks
+	if err := l.AppendPlaylistTracks(id, ids); err != nil {
+		t.Fatalf("error appending tracks: %s", err.Error())
+	}
+	rm := make([]int, 0, len(ids))
+	for i := range ids {
+		rm = append(rm, i+5)
+	}
+	if err := l.RemovePlaylistTracks(id, rm); err != nil {
+		t.Fatalf("error removing tracks: %s", err.Error())
+	}
+	if got, want := queueIDs(srv.playlist(id).Entry), "tr-1 tr-2 tr-3 tr-4 tr-5"; got != want {
+		t.Errorf("got tracks %s after removing, want %s", got, want)
+	}
+}
+
+// A playlist deleted on the server is re-created,
+// but other errors must not create a duplicate.
+func TestReplacePlaylistTracksMissingPlaylist(t *testing.T) {
+	l, srv := newTestLibraryManager(t)
+	id, err := l.ReplacePlaylistTracks("deleted", "Queue", []string{"tr-1"})
+	if err != nil {
+		t.Fatalf("error saving playlist: %s", err.Error())
+	}
+	if id == "deleted" || srv.playlist(id) == nil {
+		t.Fatalf("playlist not re-created, got ID %q", id)
+	}
+
+	srv.Fail("getPlaylist")
+	if _, err := l.ReplacePlaylistTracks(id, "Queue", []string{"tr-2"}); err == nil {
+		t.Error("no error when the playlist could not be fetched")
+	}
+	if c := srv.Requests("createPlaylist"); c != 1 {
+		t.Errorf("got %d create requests, want 1", c)
+	}
+}
@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// Names of OpenSubsonic API extensions that the client makes use of.
+// Only API key authentication is used so far; the client does not yet
+// implement the features of other extensions (e.g. songLyrics, transcodeOffset),
+// so the multi-artist and lyrics fields servers report are not parsed.
+const (
+	ExtensionAPIKeyAuth = "apiKeyAuthentication"
+)
+
+var ErrAPIKeyAuthUnsupported = errors.New("server does not support API key authentication")
+
+// Information about the connected server, as reported by the ping
+// and (for OpenSubsonic servers) getOpenSubsonicExtensions endpoints.
+type ServerInfo struct {
+	// The Subsonic API version supported by the server
+	APIVersion string
+	// True if the server implements the OpenSubsonic API
+	OpenSubsonic bool
+	// The server software (e.g. "navidrome"). Empty for non-OpenSubsonic servers.
+	Type string
+	// The version of the server software. Empty for non-OpenSubsonic servers.
+	ServerVersion string
+	// Map of the supported OpenSubsonic extensions to their supported versions
+	Extensions map[string][]int
+}
+
+// Returns true if the server advertises support for the given OpenSubsonic extension.
+func (s ServerInfo) HasExtension(name string) bool {
+	_, ok := s.Extensions[name]
+	return ok
+}
+
+// Error element of a raw Subsonic response.
+type openSubsonicError struct {
+	Error *struct {
+		Code    int    `xml:"code,attr"`
+		Message string `xml:"message,attr"`
+	} `xml:"error"`
+}
+
+func (e *openSubsonicError) responseError() *openSubsonicError {
+	return e
+}
+
+// go-subsonic does not know about the OpenSubsonic response attributes,
+// so we parse the raw responses of ping and getOpenSubsonicExtensions ourselves.
+type openSubsonicResponse struct {
+	openSubsonicError
+	Status        string `xml:"status,attr"`
+	Version       string `xml:"version,attr"`
+	Type          string `xml:"type,attr"`
+	ServerVersion string `xml:"serverVersion,attr"`
+	OpenSubsonic  bool   `xml:"openSubsonic,attr"`
+	Extensions    []struct {
+		Name     string `xml:"name,attr"`
+		Versions []int  `xml:"versions"`
+	} `xml:"openSubsonicExtensions"`
+}
+
+func fetchServerInfo(cli *subsonic.Client) (ServerInfo, error) {
+	ping, err := openSubsonicRequest(cli, "ping")
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	info := ServerInfo{
+		APIVersion:    ping.Version,
+		OpenSubsonic:  ping.OpenSubsonic,
+		Type:          ping.Type,
+		ServerVersion: ping.ServerVersion,
+	}
+	if !info.OpenSubsonic {
+		return info, nil
+	}
+	exts, err := openSubsonicRequest(cli, "getOpenSubsonicExtensions")
+	if err != nil {
+		return info, err
+	}
+	info.Extensions = make(map[string][]int, len(exts.Extensions))
+	for _, ext := range exts.Extensions {
+		info.Extensions[ext.Name] = ext.Versions
+	}
+	return info, nil
+}
+
+func openSubsonicRequest(cli *subsonic.Client, endpoint string) (*openSubsonicResponse, error) {
+	var r openSubsonicResponse
+	if err := rawSubsonicRequest(cli, endpoint, nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// A track with the OpenSubsonic attributes go-subsonic does not parse.
+type openSubsonicSong struct {
+	subsonic.Child
+	MusicBrainzID string `xml:"musicBrainzId,attr"`
+}
+
+type openSubsonicAlbumResponse struct {
+	openSubsonicError
+	Album struct {
+		Song []*openSubsonicSong `xml:"song"`
+	} `xml:"album"`
+}
+
+// Returns the tracks of the album, including their MusicBrainz IDs
+// if the server reports them.
+func getAlbumSongs(cli *subsonic.Client, albumID string) ([]*openSubsonicSong, error) {
+	var r openSubsonicAlbumResponse
+	if err := rawSubsonicRequest(cli, "getAlbum", url.Values{"id": {albumID}}, &r); err != nil {
+		return nil, err
+	}
+	return r.Album.Song, nil
+}
+
+// Makes a request and parses the response XML into r.
+func rawSubsonicRequest(cli *subsonic.Client, endpoint string, params url.Values, r interface{ responseError() *openSubsonicError }) error {
+	resp, err := cli.Request("GET", endpoint, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected HTTP status %s", endpoint, resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(body, r); err != nil {
+		return err
+	}
+	if e := r.responseError().Error; e != nil {
+		return fmt.Errorf("%s: error #%d: %s", endpoint, e.Code, e.Message)
+	}
+	return nil
+}
+
+// apiKeyTransport replaces the username and password/token parameters
+// that go-subsonic adds to every request with the OpenSubsonic apiKey parameter.
+type apiKeyTransport struct {
+	base   http.RoundTripper
+	apiKey string
+}
+
+func (a *apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.RawQuery = replaceAuthParamsWithAPIKey(req.URL.Query(), a.apiKey).Encode()
+	return a.base.RoundTrip(req)
+}
+
+func replaceAuthParamsWithAPIKey(query url.Values, apiKey string) url.Values {
+	for _, param := range []string{"u", "p", "t", "s"} {
+		query.Del(param)
+	}
+	query.Set("apiKey", apiKey)
+	return query
+}
@@ -0,0 +1,76 @@
+package backend
+
+const (
+	PinnedAlbum  = "Album"
+	PinnedArtist = "Artist"
+	PinnedGenre  = "Genre"
+)
+
+// PinnedItemManager manages the albums, artists and genres
+// the user has pinned to the sidebar, which are stored in the config.
+type PinnedItemManager struct {
+	sm     *ServerManager
+	config *SidebarConfig
+
+	onChanged []func()
+}
+
+func NewPinnedItemManager(sm *ServerManager, config *SidebarConfig) *PinnedItemManager {
+	return &PinnedItemManager{sm: sm, config: config}
+}
+
+// Registers a callback that is invoked when an item is pinned or unpinned.
+func (p *PinnedItemManager) OnChanged(cb func()) {
+	p.onChanged = append(p.onChanged, cb)
+}
+
+// Returns the pinned items of the connected server, in the order they were pinned.
+func (p *PinnedItemManager) Items() []*PinnedItem {
+	var items []*PinnedItem
+	for _, item := range p.config.Pinned {
+		if item.ServerID == p.sm.ServerID() {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (p *PinnedItemManager) IsPinned(itemType, id string) bool {
+	return p.indexOf(itemType, id) >= 0
+}
+
+// Pins the item of the connected server, if it is not already pinned.
+func (p *PinnedItemManager) Pin(itemType, id, name string) {
+	if p.IsPinned(itemType, id) {
+		return
+	}
+	p.config.Pinned = append(p.config.Pinned, &PinnedItem{
+		ServerID: p.sm.ServerID(),
+		Type:     itemType,
+		ID:       id,
+		Name:     name,
+	})
+	p.notifyChanged()
+}
+
+func (p *PinnedItemManager) Unpin(itemType, id string) {
+	if i := p.indexOf(itemType, id); i >= 0 {
+		p.config.Pinned = append(p.config.Pinned[:i], p.config.Pinned[i+1:]...)
+		p.notifyChanged()
+	}
+}
+
+func (p *PinnedItemManager) indexOf(itemType, id string) int {
+	for i, item := range p.config.Pinned {
+		if item.ServerID == p.sm.ServerID() && item.Type == itemType && item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *PinnedItemManager) notifyChanged() {
+	for _, cb := range p.onChanged {
+		cb()
+	}
+}
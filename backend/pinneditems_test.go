@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPinnedItems(t *testing.T) {
+	sm := &ServerManager{serverID: uuid.New()}
+	cfg := &SidebarConfig{}
+	p := NewPinnedItemManager(sm, cfg)
+	changed := 0
+	p.OnChanged(func() { changed++ })
+
+	p.Pin(PinnedAlbum, "al-1", "Album 1")
+	p.Pin(PinnedGenre, "Jazz", "Jazz")
+	p.Pin(PinnedAlbum, "al-1", "Album 1")
+	if len(cfg.Pinned) != 2 || changed != 2 {
+		t.Fatalf("expected 2 pinned items and 2 changes, got %d and %d", len(cfg.Pinned), changed)
+	}
+	if !p.IsPinned(PinnedAlbum, "al-1") || p.IsPinned(PinnedArtist, "al-1") {
+		t.Error("IsPinned did not match on type and ID")
+	}
+
+	// items of other servers are kept but not returned
+	otherServer := sm.ServerID()
+	sm.serverID = uuid.New()
+	if items := p.Items(); len(items) != 0 {
+		t.Errorf("expected no items for other server, got %d", len(items))
+	}
+	p.Pin(PinnedArtist, "ar-1", "Artist 1")
+	sm.serverID = otherServer
+
+	p.Unpin(PinnedAlbum, "al-1")
+	p.Unpin(PinnedAlbum, "al-1")
+	items := p.Items()
+	if len(items) != 1 || items[0].ID != "Jazz" {
+		t.Errorf("unexpected items after unpin: %v", items)
+	}
+	if len(cfg.Pinned) != 2 || changed != 4 {
+		t.Errorf("expected 2 pinned items and 4 changes, got %d and %d", len(cfg.Pinned), changed)
+	}
+}
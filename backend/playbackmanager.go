@@ -2,11 +2,13 @@ package backend
 
 import (
 	"context"
+	"errors"
 	"log"
 	"strconv"
 	"supersonic/backend/util"
 	"supersonic/player"
 	"supersonic/sharedutil"
+	"sync"
 	"time"
 
 	"github.com/dweymouth/go-subsonic/subsonic"
@@ -18,17 +20,67 @@ const (
 	ReplayGainTrack = string(player.ReplayGainTrack)
 )
 
+var ErrQueueChanged = errors.New("the play queue has changed")
+
+// The playback engine used by the PlaybackManager.
+// Implemented by *player.Player.
+type Player interface {
+	AppendFile(url string) error
+	RemoveTrackAt(idx int) error
+	MoveTrack(from, to int) error
+	ClearPlayQueue() error
+	Stop() error
+	PlayFromBeginning() error
+	PlayTrackAt(idx int) error
+	FadeOutAndPause(seconds float64) error
+	CancelFadeOutAndPause()
+	GetStatus() player.Status
+	IsSeeking() bool
+	SetSpeed(speed float64) error
+	GetSpeed() float64
+	SetStopAfterCurrent(stop bool) error
+	StopAfterCurrent() bool
+	SetReplayGainOptions(options player.ReplayGainOptions) error
+	SetHTTPOptions(options player.HTTPOptions) error
+	SetTransitionOptions(options player.TransitionOptions)
+
+	OnTrackChange(cb func(int64))
+	OnSeek(cb func())
+	OnPaused(cb func())
+	OnPlaying(cb func())
+	OnStopped(cb func())
+	OnStopAfterCurrent(cb func())
+	OnTimeUpdate(cb func(pos, dur float64))
+}
+
+var _ Player = (*player.Player)(nil)
+
 // A high-level Subsonic-aware playback backend.
 // Manages loading tracks into the Player queue,
 // sending callbacks on play time updates and track changes.
+//
+// PlaybackManager functions are safe to call from any goroutine.
+// Callbacks are invoked without the PlaybackManager lock held,
+// so they may call back into the PlaybackManager.
 type PlaybackManager struct {
-	ctx           context.Context
-	cancelPollPos context.CancelFunc
-	pollingTick   *time.Ticker
-	sm            *ServerManager
-	player        *player.Player
+	ctx    context.Context
+	sm     *ServerManager
+	player Player
+
+	// when tracks were last played, to keep Auto-DJ from repeating them
+	PlayHistory *PlayHistory
+
+	// guards all fields below. Player functions that invoke Player
+	// callbacks synchronously (Stop, PlayTrackAt, PlayFromBeginning, PlayPause)
+	// must not be called with mu held, since the PlaybackManager's
+	// Player callbacks acquire it.
+	mu sync.Mutex
+	// callbacks queued while mu is held, invoked by unlock
+	pendingCallbacks []func()
 
 	playTimeStopwatch util.Stopwatch
+	// content time played before the last speed change
+	contentTimeAccum  time.Duration
 	curTrackTime      float64
 	callbacksDisabled bool
 
@@ -37,64 +89,135 @@ type PlaybackManager struct {
 
 	// to pass to onSongChange listeners; clear once listeners have been called
 	lastScrobbled *subsonic.Child
+	playbackCfg   *LocalPlaybackConfig
 	scrobbleCfg   *ScrobbleConfig
-
-	onSongChange     []func(nowPlaying *subsonic.Child, justScrobbledIfAny *subsonic.Child)
-	onPlayTimeUpdate []func(float64, float64)
+	streamingCfg  *StreamingConfig
+	autoDJCfg     *AutoDJConfig
+
+	// set while the PlaybackManager itself is stopping the player
+	stopping bool
+
+	// play queue items added by Auto-DJ
+	autoDJTracks map[*subsonic.Child]bool
+	autoDJBusy   bool
+	// incremented whenever the play queue is replaced, so a
+	// top-up fetched for the previous queue is discarded
+	autoDJQueueGen int
+	// resume playback once Auto-DJ has topped up the queue
+	autoDJResume bool
+	// most recently played tracks, oldest first, to seed Auto-DJ
+	recentTracks []*subsonic.Child
+
+	onSongChange              []func(nowPlaying *subsonic.Child, justScrobbledIfAny *subsonic.Child)
+	onPlayTimeUpdate          []func(float64, float64)
+	onStreamingProfileChanged []func(string)
+	onPlaybackSpeedChanged    []func(float64)
+	onSleepTimerChanged       []func()
+	onPlayQueueChanged        []func()
+	onAutoDJChanged           []func(bool)
+
+	sleepMode        SleepTimerMode
+	sleepDeadline    time.Time
+	sleepTimer       *time.Timer
+	stopAfterCurrent bool
 }
 
 func NewPlaybackManager(
 	ctx context.Context,
 	s *ServerManager,
-	p *player.Player,
+	p Player,
+	playbackCfg *LocalPlaybackConfig,
 	scrobbleCfg *ScrobbleConfig,
+	streamingCfg *StreamingConfig,
+	autoDJCfg *AutoDJConfig,
 ) *PlaybackManager {
 	// clamp to 99% to avoid any possible rounding issues
 	scrobbleCfg.ThresholdPercent = clamp(scrobbleCfg.ThresholdPercent, 0, 99)
 	pm := &PlaybackManager{
-		ctx:         ctx,
-		sm:          s,
-		player:      p,
-		scrobbleCfg: scrobbleCfg,
+		ctx:          ctx,
+		sm:           s,
+		player:       p,
+		playbackCfg:  playbackCfg,
+		scrobbleCfg:  scrobbleCfg,
+		streamingCfg: streamingCfg,
+		autoDJCfg:    autoDJCfg,
 	}
 	p.OnTrackChange(func(tracknum int64) {
-		if tracknum >= int64(len(pm.playQueue)) {
+		pm.lock()
+		defer pm.unlock()
+		if tracknum < 0 || tracknum >= int64(len(pm.playQueue)) {
 			return
 		}
-		pm.checkScrobble(pm.playTimeStopwatch.Elapsed())
-		pm.playTimeStopwatch.Reset()
+		pm.checkScrobble(pm.contentPlayTime())
+		pm.resetPlayTime()
 		if pm.player.GetStatus().State == player.Playing {
 			pm.playTimeStopwatch.Start()
 		}
 		pm.nowPlayingIdx = tracknum
+		pm.autoDJResume = false
+		pm.recordPlayed(pm.playQueue[tracknum])
+		pm.checkAutoDJ()
+		pm.applyGenreSpeed()
+		pm.updateStopAfterCurrent()
 		pm.curTrackTime = float64(pm.playQueue[pm.nowPlayingIdx].Duration)
 		pm.invokeOnSongChangeCallbacks()
 		pm.doUpdateTimePos()
 		pm.sendNowPlayingScrobble()
 	})
 	p.OnSeek(func() {
+		pm.lock()
+		defer pm.unlock()
 		pm.doUpdateTimePos()
 	})
 	p.OnStopped(func() {
+		pm.lock()
+		defer pm.unlock()
 		pm.playTimeStopwatch.Stop()
-		pm.checkScrobble(pm.playTimeStopwatch.Elapsed())
-		pm.playTimeStopwatch.Reset()
-		pm.stopPollTimePos()
+		pm.checkScrobble(pm.contentPlayTime())
+		pm.resetPlayTime()
 		pm.doUpdateTimePos()
 		pm.invokeOnSongChangeCallbacks()
+		pm.onStoppedForAutoDJ()
+		pm.clearEndOfQueueSleepTimer()
+	})
+	p.OnStopAfterCurrent(func() {
+		pm.lock()
+		defer pm.unlock()
+		pm.onStoppedAfterCurrent()
 	})
 	p.OnPaused(func() {
+		pm.lock()
+		defer pm.unlock()
 		pm.playTimeStopwatch.Stop()
-		pm.stopPollTimePos()
 	})
 	p.OnPlaying(func() {
+		pm.lock()
+		defer pm.unlock()
 		pm.playTimeStopwatch.Start()
-		pm.startPollTimePos()
+	})
+	p.OnTimeUpdate(func(pos, dur float64) {
+		pm.lock()
+		defer pm.unlock()
+		pm.invokeOnPlayTimeUpdateCallbacks(pos, dur)
 	})
 
+	s.OnServerConnected(func() {
+		_, conn, _ := s.connectionState()
+		pm.player.SetHTTPOptions(conn.playerHTTPOptions())
+		pm.lock()
+		defer pm.unlock()
+		pm.invokeOnStreamingProfileChangedCallbacks()
+	})
 	s.OnLogout(func() {
 		pm.StopAndClearPlayQueue()
 	})
+	s.OnHostnameChanged(func() {
+		pm.lock()
+		defer pm.unlock()
+		// the new hostname may use a different streaming profile
+		pm.refreshUpcomingStreamURLs()
+		pm.invokeOnStreamingProfileChangedCallbacks()
+	})
 
 	return pm
 }
@@ -106,11 +229,19 @@ func (p *PlaybackManager) IsSeeking() bool {
 // Should only be called before quitting.
 // Disables playback state callbacks being sent
 func (p *PlaybackManager) DisableCallbacks() {
+	p.lock()
+	defer p.unlock()
 	p.callbacksDisabled = true
 }
 
 // Gets the curently playing song, if any.
 func (p *PlaybackManager) NowPlaying() *subsonic.Child {
+	p.lock()
+	defer p.unlock()
+	return p.nowPlaying()
+}
+
+func (p *PlaybackManager) nowPlaying() *subsonic.Child {
 	if len(p.playQueue) == 0 || p.player.GetStatus().State == player.Stopped {
 		return nil
 	}
@@ -119,17 +250,82 @@ func (p *PlaybackManager) NowPlaying() *subsonic.Child {
 
 // Sets a callback that is notified whenever a new song begins playing.
 func (p *PlaybackManager) OnSongChange(cb func(nowPlaying *subsonic.Child, justScrobbledIfAny *subsonic.Child)) {
+	p.lock()
+	defer p.unlock()
 	p.onSongChange = append(p.onSongChange, cb)
 }
 
+// Registers a callback that is notified whenever tracks are added to
+// or removed from the play queue, including by Auto-DJ.
+func (p *PlaybackManager) OnPlayQueueChanged(cb func()) {
+	p.lock()
+	defer p.unlock()
+	p.onPlayQueueChanged = append(p.onPlayQueueChanged, cb)
+}
+
 // Registers a callback that is notified whenever the play time should be updated.
 func (p *PlaybackManager) OnPlayTimeUpdate(cb func(float64, float64)) {
+	p.lock()
+	defer p.unlock()
 	p.onPlayTimeUpdate = append(p.onPlayTimeUpdate, cb)
 }
 
+// Registers a callback that is notified when the active streaming profile changes,
+// either by the user or by switching between the primary and alternate hostnames.
+func (p *PlaybackManager) OnStreamingProfileChanged(cb func(profileName string)) {
+	p.lock()
+	defer p.unlock()
+	p.onStreamingProfileChanged = append(p.onStreamingProfileChanged, cb)
+}
+
+// Returns the names of all available streaming profiles.
+func (p *PlaybackManager) StreamingProfileNames() []string {
+	p.lock()
+	defer p.unlock()
+	return p.streamingCfg.ProfileNames()
+}
+
+// Returns the name of the streaming profile for the hostname currently in use.
+func (p *PlaybackManager) ActiveStreamingProfile() string {
+	p.lock()
+	defer p.unlock()
+	return p.activeStreamingProfile()
+}
+
+func (p *PlaybackManager) activeStreamingProfile() string {
+	if prof := p.streamingProfile(); prof != nil {
+		return prof.Name
+	}
+	return ""
+}
+
+// Sets the streaming profile for the hostname currently in use.
+// Tracks in the play queue after the currently playing one will be
+// streamed with the new profile.
+func (p *PlaybackManager) SetActiveStreamingProfile(name string) {
+	p.lock()
+	defer p.unlock()
+	if p.sm.UsingAltHostname() {
+		p.streamingCfg.AltProfile = name
+	} else {
+		p.streamingCfg.PrimaryProfile = name
+	}
+	p.refreshUpcomingStreamURLs()
+	p.invokeOnStreamingProfileChangedCallbacks()
+}
+
+// Should be called whenever the streaming config has been changed elsewhere in the app,
+// so that upcoming tracks in the play queue use the updated profile.
+func (p *PlaybackManager) OnStreamingSettingsChanged() {
+	p.lock()
+	defer p.unlock()
+	p.refreshUpcomingStreamURLs()
+	p.invokeOnStreamingProfileChangedCallbacks()
+}
+
 // Loads the specified album into the play queue.
-func (p *PlaybackManager) LoadAlbum(albumID string, appendToQueue bool, shuffle bool) error {
-	album, err := p.sm.Server.GetAlbum(albumID)
+func (p *PlaybackManager) LoadAlbum(albumID string, appendToQueue bool, shuffle ShuffleMode) error {
+	album, err := p.sm.Server().GetAlbum(albumID)
 	if err != nil {
 		return err
 	}
@@ -137,30 +333,33 @@ func (p *PlaybackManager) LoadAlbum(albumID string, appendToQueue bool, shuffle
 }
 
 // Loads the specified playlist into the play queue.
-func (p *PlaybackManager) LoadPlaylist(playlistID string, appendToQueue bool, shuffle bool) error {
-	playlist, err := p.sm.Server.GetPlaylist(playlistID)
+func (p *PlaybackManager) LoadPlaylist(playlistID string, appendToQueue bool, shuffle ShuffleMode) error {
+	playlist, err := p.sm.Server().GetPlaylist(playlistID)
 	if err != nil {
 		return err
 	}
 	return p.LoadTracks(playlist.Entry, appendToQueue, shuffle)
 }
 
-func (p *PlaybackManager) LoadTracks(tracks []*subsonic.Child, appendToQueue, shuffle bool) error {
+// Loads the tracks into the play queue in the order given by the shuffle mode.
+func (p *PlaybackManager) LoadTracks(tracks []*subsonic.Child, appendToQueue bool, shuffle ShuffleMode) error {
+	if !appendToQueue {
+		p.stopPlayer()
+	}
+	p.lock()
+	defer p.unlock()
 	if !appendToQueue {
-		p.player.Stop()
 		p.nowPlayingIdx = 0
 		p.playQueue = nil
+		p.resetAutoDJQueue()
 	}
-	nums := util.Range(len(tracks))
-	if shuffle {
-		util.ShuffleSlice(nums)
-	}
-	for _, i := range nums {
-		url, err := p.sm.Server.GetStreamURL(tracks[i].ID, map[string]string{})
+	defer p.invokeOnPlayQueueChangedCallbacks()
+	for _, i := range shuffleOrder(tracks, shuffle) {
+		url, err := p.streamURL(tracks[i].ID)
 		if err != nil {
 			return err
 		}
-		p.player.AppendFile(url.String())
+		p.player.AppendFile(url)
 		// ensure a deep copy of the track info so that we can maintain our own state
 		// (tracking play count increases, favorite, and rating) without messing up
 		// other views' track models
@@ -170,7 +369,7 @@ func (p *PlaybackManager) LoadTracks(tracks []*subsonic.Child, appendToQueue, sh
 	return nil
 }
 
-func (p *PlaybackManager) PlayAlbum(albumID string, firstTrack int, shuffle bool) error {
+func (p *PlaybackManager) PlayAlbum(albumID string, firstTrack int, shuffle ShuffleMode) error {
 	if err := p.LoadAlbum(albumID, false, shuffle); err != nil {
 		return err
 	}
@@ -180,7 +379,7 @@ func (p *PlaybackManager) PlayAlbum(albumID string, firstTrack int, shuffle bool
 	return p.player.PlayTrackAt(firstTrack)
 }
 
-func (p *PlaybackManager) PlayPlaylist(playlistID string, firstTrack int, shuffle bool) error {
+func (p *PlaybackManager) PlayPlaylist(playlistID string, firstTrack int, shuffle ShuffleMode) error {
 	if err := p.LoadPlaylist(playlistID, false, shuffle); err != nil {
 		return err
 	}
@@ -198,30 +397,61 @@ func (p *PlaybackManager) PlayTrackAt(idx int) error {
 	return p.player.PlayTrackAt(idx)
 }
 
-func (p *PlaybackManager) PlayRandomSongs(genreName string) {
+func (p *PlaybackManager) PlayRandomSongs(genreName string, shuffle ShuffleMode) {
 	params := map[string]string{"size": "100"}
 	if genreName != "" {
 		params["genre"] = genreName
 	}
-	if songs, err := p.sm.Server.GetRandomSongs(params); err != nil {
+	if songs, err := p.sm.Server().GetRandomSongs(params); err != nil {
 		log.Printf("error getting random songs: %s", err.Error())
 	} else {
-		p.LoadTracks(songs, false, false)
+		p.LoadTracks(songs, false, shuffle)
 		p.PlayFromBeginning()
 	}
 }
 
+// Plays an internet radio station, replacing the play queue.
+func (p *PlaybackManager) PlayRadioStation(station *subsonic.InternetRadioStation) error {
+	p.stopPlayer()
+	p.lock()
+	p.nowPlayingIdx = 0
+	p.resetAutoDJQueue()
+	// the station is represented in the play queue by a pseudo-track
+	p.playQueue = []*subsonic.Child{{
+		Title:  station.Name,
+		Artist: "Internet radio",
+		Path:   station.StreamUrl,
+		Type:   radioStationType,
+	}}
+	err := p.player.AppendFile(station.StreamUrl)
+	p.invokeOnPlayQueueChangedCallbacks()
+	p.unlock()
+	if err != nil {
+		return err
+	}
+	return p.player.PlayFromBeginning()
+}
+
+// Type of the play queue items created by PlayRadioStation.
+const radioStationType = "radio"
+
+func isRadioStation(tr *subsonic.Child) bool {
+	return tr.Type == radioStationType
+}
+
 func (p *PlaybackManager) PlaySimilarSongs(id string) {
 	params := map[string]string{"size": "100"}
-	if songs, err := p.sm.Server.GetSimilarSongs2(id, params); err != nil {
+	if songs, err := p.sm.Server().GetSimilarSongs2(id, params); err != nil {
 		log.Printf("error getting similar songs: %s", err.Error())
 	} else {
-		p.LoadTracks(songs, false, false)
+		p.LoadTracks(songs, false, ShuffleNone)
 		p.PlayFromBeginning()
 	}
 }
 
 func (p *PlaybackManager) GetPlayQueue() []*subsonic.Child {
+	p.lock()
+	defer p.unlock()
 	pq := make([]*subsonic.Child, len(p.playQueue))
 	for i, tr := range p.playQueue {
 		copy := *tr
@@ -233,6 +463,8 @@ func (p *PlaybackManager) GetPlayQueue() []*subsonic.Child {
 // Any time the user changes the favorite status of a track elsewhere in the app,
 // this should be called to ensure the in-memory track model is updated.
 func (p *PlaybackManager) OnTrackFavoriteStatusChanged(id string, fav bool) {
+	p.lock()
+	defer p.unlock()
 	if tr := sharedutil.FindTrackByID(id, p.playQueue); tr != nil {
 		if fav {
 			tr.Starred = time.Now()
@@ -245,6 +477,8 @@ func (p *PlaybackManager) OnTrackFavoriteStatusChanged(id string, fav bool) {
 // Any time the user changes the rating of a track elsewhere in the app,
 // this should be called to ensure the in-memory track model is updated.
 func (p *PlaybackManager) OnTrackRatingChanged(id string, rating int) {
+	p.lock()
+	defer p.unlock()
 	if tr := sharedutil.FindTrackByID(id, p.playQueue); tr != nil {
 		tr.UserRating = rating
 	}
@@ -252,9 +486,14 @@ func (p *PlaybackManager) OnTrackRatingChanged(id string, rating int) {
 
 // trackIdxs must be sorted
 func (p *PlaybackManager) RemoveTracksFromQueue(trackIdxs []int) {
-	newQueue := make([]*subsonic.Child, 0, len(p.playQueue)-len(trackIdxs))
+	p.lock()
+	defer p.unlock()
+	newQueue := make([]*subsonic.Child, 0, len(p.playQueue))
 	rmCount := 0
 	rmIdx := 0
+	// the player's status is updated asynchronously, so track the
+	// new index of the playing track (or the one after it, if removed)
+	newNowPlayingIdx := p.nowPlayingIdx
 	for i, tr := range p.playQueue {
 		if rmIdx < len(trackIdxs) && trackIdxs[rmIdx] == i {
 			// removing this track
@@ -263,6 +502,10 @@ func (p *PlaybackManager) RemoveTracksFromQueue(trackIdxs []int) {
 			rmIdx++
 			if err := p.player.RemoveTrackAt(i - rmCount); err == nil {
 				rmCount++
+				delete(p.autoDJTracks, tr)
+				if int64(i) < p.nowPlayingIdx {
+					newNowPlayingIdx--
+				}
 			} else {
 				log.Printf("error removing track: %v", err.Error())
 				// did not remove this track
@@ -274,18 +517,126 @@ func (p *PlaybackManager) RemoveTracksFromQueue(trackIdxs []int) {
 		}
 	}
 	p.playQueue = newQueue
-	p.nowPlayingIdx = p.player.GetStatus().PlaylistPos
+	p.nowPlayingIdx = newNowPlayingIdx
+	if p.nowPlayingIdx < 0 || p.nowPlayingIdx >= int64(len(p.playQueue)) {
+		p.nowPlayingIdx = 0
+	}
 	// fire on song change callbacks in case the playing track was removed
 	// TODO: only call this if the playing track actually was removed
 	p.invokeOnSongChangeCallbacks()
+	p.invokeOnPlayQueueChangedCallbacks()
+}
+
+// Inserts the tracks into the play queue so that tracks[i] ends up at
+// index idxs[i], e.g. to restore removed tracks. idxs must be sorted.
+// Tracks with indexes past the end of the queue are appended.
+func (p *PlaybackManager) InsertTracksIntoQueue(tracks []*subsonic.Child, idxs []int) error {
+	p.lock()
+	defer p.unlock()
+	defer p.invokeOnPlayQueueChangedCallbacks()
+	for i, tr := range tracks {
+		url, err := p.trackURL(tr)
+		if err != nil {
+			return err
+		}
+		if err := p.player.AppendFile(url); err != nil {
+			return err
+		}
+		last := len(p.playQueue)
+		idx := idxs[i]
+		if idx >= last || p.player.MoveTrack(last, idx) != nil {
+			idx = last
+		}
+		// deep copy, as in LoadTracks
+		trCopy := *tr
+		p.playQueue = append(p.playQueue[:idx], append([]*subsonic.Child{&trCopy}, p.playQueue[idx:]...)...)
+		if last > 0 && int64(idx) <= p.nowPlayingIdx {
+			p.nowPlayingIdx++
+		}
+	}
+	return nil
+}
+
+// Reorders the play queue so that the track at index order[i] moves to index i.
+// Returns ErrQueueChanged if order is not a permutation of the queue's indexes,
+// e.g. because tracks were added to or removed from the queue in the meantime.
+func (p *PlaybackManager) ReorderQueue(order []int) error {
+	p.lock()
+	defer p.unlock()
+	if !isPermutation(order, len(p.playQueue)) {
+		return ErrQueueChanged
+	}
+	var nowPlaying *subsonic.Child
+	if p.nowPlayingIdx < int64(len(p.playQueue)) {
+		nowPlaying = p.playQueue[p.nowPlayingIdx]
+	}
+	newQueue := make([]*subsonic.Child, len(order))
+	for i, j := range order {
+		newQueue[i] = p.playQueue[j]
+	}
+	// move the tracks into place one by one, keeping the
+	// play queue in sync with the player if a move fails
+	var err error
+	for i, tr := range newQueue {
+		j := i
+		for p.playQueue[j] != tr {
+			j++
+		}
+		if j == i {
+			continue
+		}
+		if err = p.player.MoveTrack(j, i); err != nil {
+			break
+		}
+		copy(p.playQueue[i+1:j+1], p.playQueue[i:j])
+		p.playQueue[i] = tr
+	}
+	for i, tr := range p.playQueue {
+		if tr == nowPlaying {
+			p.nowPlayingIdx = int64(i)
+		}
+	}
+	p.invokeOnPlayQueueChangedCallbacks()
+	return err
+}
+
+func isPermutation(order []int, n int) bool {
+	if len(order) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for _, i := range order {
+		if i < 0 || i >= n || seen[i] {
+			return false
+		}
+		seen[i] = true
+	}
+	return true
 }
 
 // Stop playback and clear the play queue.
 func (p *PlaybackManager) StopAndClearPlayQueue() {
-	p.player.Stop()
+	p.stopPlayer()
 	p.player.ClearPlayQueue()
+	p.lock()
+	defer p.unlock()
 	p.doUpdateTimePos()
 	p.playQueue = nil
+	p.nowPlayingIdx = 0
+	p.resetAutoDJQueue()
+	p.invokeOnPlayQueueChangedCallbacks()
+}
+
+// Stops the player, without Auto-DJ treating it as the end of the queue.
+// Must be called without the lock held.
+func (p *PlaybackManager) stopPlayer() {
+	p.lock()
+	p.stopping = true
+	p.unlock()
+	p.player.Stop()
+	p.lock()
+	p.stopping = false
+	p.unlock()
 }
 
 func (p *PlaybackManager) SetReplayGainOptions(config ReplayGainConfig) {
@@ -296,6 +647,171 @@ func (p *PlaybackManager) SetReplayGainOptions(config ReplayGainConfig) {
 	})
 }
 
+func (p *PlaybackManager) streamingProfile() *StreamingProfile {
+	if p.sm.UsingAltHostname() {
+		return p.streamingCfg.Profile(p.streamingCfg.AltProfile)
+	}
+	return p.streamingCfg.Profile(p.streamingCfg.PrimaryProfile)
+}
+
+func (p *PlaybackManager) streamURL(trackID string) (string, error) {
+	url, err := p.sm.StreamURL(trackID, p.streamingProfile().Params())
+	if err != nil {
+		return "", err
+	}
+	return url.String(), nil
+}
+
+// Returns the URL to play the given play queue item.
+func (p *PlaybackManager) trackURL(tr *subsonic.Child) (string, error) {
+	if isRadioStation(tr) {
+		return tr.Path, nil
+	}
+	return p.streamURL(tr.ID)
+}
+
+// Re-generates the stream URLs for the tracks in the play queue after
+// the currently playing one, e.g. after the server hostname has changed.
+// The currently playing track is left untouched so playback is not interrupted.
+func (p *PlaybackManager) refreshUpcomingStreamURLs() {
+	if len(p.playQueue) == 0 {
+		return
+	}
+	first := int(p.nowPlayingIdx) + 1
+	if p.player.GetStatus().State == player.Stopped {
+		first = 0
+		if err := p.player.ClearPlayQueue(); err != nil {
+			log.Printf("error clearing play queue: %s", err.Error())
+			return
+		}
+	} else {
+		for i := first; i < len(p.playQueue); i++ {
+			if err := p.player.RemoveTrackAt(first); err != nil {
+				log.Printf("error removing track: %s", err.Error())
+				return
+			}
+		}
+	}
+	for _, tr := range p.playQueue[first:] {
+		url, err := p.trackURL(tr)
+		if err != nil {
+			log.Printf("error getting stream URL: %s", err.Error())
+			return
+		}
+		p.player.AppendFile(url)
+	}
+}
+
+// Duration of the fades on pause, resume, stop and skip, if enabled.
+const pauseFadeSeconds = 0.25
+
+// Sets the crossfade and pause fade options from the config.
+func (p *PlaybackManager) SetTransitionOptions(config LocalPlaybackConfig) {
+	opts := player.TransitionOptions{
+		CrossfadeSeconds: float64(clamp(config.CrossfadeSeconds, 0, 12)),
+		ShouldCrossfade:  p.shouldCrossfade,
+	}
+	if config.FadeOnPause {
+		opts.PauseFadeSeconds = pauseFadeSeconds
+	}
+	p.player.SetTransitionOptions(opts)
+}
+
+// Crossfade between all tracks except for consecutive tracks of the same album,
+// so that gapless albums remain gapless.
+func (p *PlaybackManager) shouldCrossfade(fromIdx, toIdx int64) bool {
+	p.lock()
+	defer p.unlock()
+	if fromIdx < 0 || toIdx < 0 || fromIdx >= int64(len(p.playQueue)) || toIdx >= int64(len(p.playQueue)) {
+		return true
+	}
+	from, to := p.playQueue[fromIdx], p.playQueue[toIdx]
+	if from.AlbumID == "" || from.AlbumID != to.AlbumID {
+		return true
+	}
+	sameDiscNext := to.DiscNumber == from.DiscNumber && to.Track == from.Track+1
+	nextDisc := to.DiscNumber == from.DiscNumber+1 && to.Track == 1
+	return !sameDiscNext && !nextDisc
+}
+
+// Sets the playback speed, remembering it for the genre
+// of the playing track if enabled.
+func (p *PlaybackManager) SetPlaybackSpeed(speed float64) {
+	p.lock()
+	defer p.unlock()
+	p.setSpeed(speed)
+	if !p.playbackCfg.RememberSpeedPerGenre {
+		return
+	}
+	if np := p.nowPlaying(); np != nil && np.Genre != "" {
+		if p.playbackCfg.GenreSpeeds == nil {
+			p.playbackCfg.GenreSpeeds = make(map[string]float64)
+		}
+		if speed == 1 {
+			delete(p.playbackCfg.GenreSpeeds, np.Genre)
+		} else {
+			p.playbackCfg.GenreSpeeds[np.Genre] = speed
+		}
+	}
+}
+
+func (p *PlaybackManager) PlaybackSpeed() float64 {
+	return p.player.GetSpeed()
+}
+
+// Registers a callback that is notified when the playback speed changes.
+func (p *PlaybackManager) OnPlaybackSpeedChanged(cb func(float64)) {
+	p.lock()
+	defer p.unlock()
+	p.onPlaybackSpeedChanged = append(p.onPlaybackSpeedChanged, cb)
+}
+
+func (p *PlaybackManager) setSpeed(speed float64) {
+	if speed == p.player.GetSpeed() {
+		return
+	}
+	// the content time played so far was at the old speed
+	p.contentTimeAccum = p.contentPlayTime()
+	running := p.player.GetStatus().State == player.Playing
+	p.playTimeStopwatch.Reset()
+	if running {
+		p.playTimeStopwatch.Start()
+	}
+	if err := p.player.SetSpeed(speed); err != nil {
+		log.Printf("error setting playback speed: %s", err.Error())
+		return
+	}
+	newSpeed := p.player.GetSpeed()
+	p.playbackCfg.PlaybackSpeed = newSpeed
+	for _, cb := range p.onPlaybackSpeedChanged {
+		cb := cb
+		p.queueCallback(func() { cb(newSpeed) })
+	}
+}
+
+// Sets the speed remembered for the genre of the new track, if enabled.
+func (p *PlaybackManager) applyGenreSpeed() {
+	if !p.playbackCfg.RememberSpeedPerGenre {
+		return
+	}
+	speed, ok := p.playbackCfg.GenreSpeeds[p.playQueue[p.nowPlayingIdx].Genre]
+	if !ok {
+		speed = 1
+	}
+	p.setSpeed(speed)
+}
+
+// Returns the content time of the current track that has been played,
+// which differs from the wall-clock play time if the speed is not 1.
+func (p *PlaybackManager) contentPlayTime() time.Duration {
+	return p.contentTimeAccum + time.Duration(float64(p.playTimeStopwatch.Elapsed())*p.player.GetSpeed())
+}
+
+func (p *PlaybackManager) resetPlayTime() {
+	p.playTimeStopwatch.Reset()
+	p.contentTimeAccum = 0
+}
+
 // call BEFORE updating p.nowPlayingIdx
 func (p *PlaybackManager) checkScrobble(playDur time.Duration) {
 	if !p.scrobbleCfg.Enabled || len(p.playQueue) == 0 || p.nowPlayingIdx < 0 {
@@ -309,10 +825,13 @@ func (p *PlaybackManager) checkScrobble(playDur time.Duration) {
 		playDur.Seconds() >= float64(p.scrobbleCfg.ThresholdTimeSeconds)
 	if timeThresholdMet || pcnt >= float64(p.scrobbleCfg.ThresholdPercent) {
 		song := p.playQueue[p.nowPlayingIdx]
+		if isRadioStation(song) {
+			return
+		}
 		log.Printf("Scrobbling %q", song.Title)
 		song.PlayCount += 1
 		p.lastScrobbled = song
-		go p.sm.Server.Scrobble(song.ID, map[string]string{"time": strconv.FormatInt(time.Now().Unix()*1000, 10)})
+		go p.sm.Server().Scrobble(song.ID, map[string]string{"time": strconv.FormatInt(time.Now().Unix()*1000, 10)})
 	}
 }
 
@@ -321,58 +840,74 @@ func (p *PlaybackManager) sendNowPlayingScrobble() {
 		return
 	}
 	song := p.playQueue[p.nowPlayingIdx]
-	go p.sm.Server.Scrobble(song.ID, map[string]string{
+	if isRadioStation(song) {
+		return
+	}
+	go p.sm.Server().Scrobble(song.ID, map[string]string{
 		"time":       strconv.FormatInt(time.Now().Unix()*1000, 10),
 		"submission": "false",
 	})
 }
 
+func (p *PlaybackManager) lock() {
+	p.mu.Lock()
+}
+
+// Releases the lock and then invokes the callbacks queued while it was held.
+func (p *PlaybackManager) unlock() {
+	callbacks := p.pendingCallbacks
+	p.pendingCallbacks = nil
+	p.mu.Unlock()
+	for _, cb := range callbacks {
+		cb()
+	}
+}
+
+// Queues a callback to be invoked once the lock is released.
+func (p *PlaybackManager) queueCallback(cb func()) {
+	p.pendingCallbacks = append(p.pendingCallbacks, cb)
+}
+
 func (p *PlaybackManager) invokeOnSongChangeCallbacks() {
 	if p.callbacksDisabled {
 		return
 	}
+	nowPlaying, lastScrobbled := p.nowPlaying(), p.lastScrobbled
 	for _, cb := range p.onSongChange {
-		cb(p.NowPlaying(), p.lastScrobbled)
+		cb := cb
+		p.queueCallback(func() { cb(nowPlaying, lastScrobbled) })
 	}
 	p.lastScrobbled = nil
 }
 
-func (p *PlaybackManager) startPollTimePos() {
-	ctx, cancel := context.WithCancel(p.ctx)
-	p.cancelPollPos = cancel
-	p.pollingTick = time.NewTicker(250 * time.Millisecond)
+func (p *PlaybackManager) invokeOnPlayQueueChangedCallbacks() {
+	if p.callbacksDisabled {
+		return
+	}
+	for _, cb := range p.onPlayQueueChanged {
+		p.queueCallback(cb)
+	}
+}
 
-	// TODO: fix occasional nil pointer dereference on app quit
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				p.pollingTick.Stop()
-				p.pollingTick = nil
-				return
-			case <-p.pollingTick.C:
-				p.doUpdateTimePos()
-			}
-		}
-	}()
+func (p *PlaybackManager) invokeOnStreamingProfileChangedCallbacks() {
+	name := p.activeStreamingProfile()
+	for _, cb := range p.onStreamingProfileChanged {
+		cb := cb
+		p.queueCallback(func() { cb(name) })
+	}
 }
 
 func (p *PlaybackManager) doUpdateTimePos() {
-	if p.callbacksDisabled {
-		return
-	}
 	s := p.player.GetStatus()
-	for _, cb := range p.onPlayTimeUpdate {
-		cb(s.TimePos, s.Duration)
-	}
+	p.invokeOnPlayTimeUpdateCallbacks(s.TimePos, s.Duration)
 }
 
-func (p *PlaybackManager) stopPollTimePos() {
-	if p.cancelPollPos != nil {
-		p.cancelPollPos()
-		p.cancelPollPos = nil
+func (p *PlaybackManager) invokeOnPlayTimeUpdateCallbacks(pos, dur float64) {
+	if p.callbacksDisabled {
+		return
 	}
-	if p.pollingTick != nil {
-		p.pollingTick.Stop()
+	for _, cb := range p.onPlayTimeUpdate {
+		cb := cb
+		p.queueCallback(func() { cb(pos, dur) })
 	}
 }
@@ -0,0 +1,324 @@
+package backend
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"supersonic/player"
+	"supersonic/player/playertest"
+	"supersonic/sharedutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+type testPlaybackManager struct {
+	*PlaybackManager
+	fake *playertest.FakeMPV
+	srv  *fakeServer
+}
+
+// Creates a PlaybackManager with an mpv-backed Player using a fake mpv.
+func newTestPlaybackManager(t *testing.T) *testPlaybackManager {
+	t.Helper()
+	tpm := &testPlaybackManager{fake: playertest.NewFakeMPV(), srv: newFakeServer(t)}
+	p := player.NewWithMPV(func() player.MPV { return tpm.fake }, "")
+	if err := p.Init(16); err != nil {
+		t.Fatalf("Init: %s", err.Error())
+	}
+	t.Cleanup(p.Destroy)
+
+	cfg := DefaultConfig("")
+	cfg.Scrobbling.Enabled = true
+	cfg.Scrobbling.ThresholdTimeSeconds = 0
+	sm := &ServerManager{server: tpm.srv.newClient(t)}
+	tpm.PlaybackManager = NewPlaybackManager(context.Background(), sm, p,
+		&cfg.LocalPlayback, &cfg.Scrobbling, &cfg.Streaming, &cfg.AutoDJ)
+	tpm.fake.Flush()
+	return tpm
+}
+
+// Creates a PlaybackManager with a fake Player.
+func newFakePlayerPlaybackManager(t *testing.T, scrobbleCfg ScrobbleConfig) (*PlaybackManager, *fakePlayer, *fakeServer) {
+	t.Helper()
+	srv := newFakeServer(t)
+	p := newFakePlayer()
+	cfg := DefaultConfig("")
+	cfg.Scrobbling = scrobbleCfg
+	sm := &ServerManager{server: srv.newClient(t)}
+	pm := NewPlaybackManager(context.Background(), sm, p,
+		&cfg.LocalPlayback, &cfg.Scrobbling, &cfg.Streaming, &cfg.AutoDJ)
+	pm.PlayHistory, _ = NewPlayHistory(filepath.Join(t.TempDir(), "playhistory.json"))
+	return pm, p, srv
+}
+
+func testTracks(n int) []*subsonic.Child {
+	tracks := make([]*subsonic.Child, n)
+	for i := range tracks {
+		id := strconv.Itoa(i)
+		tracks[i] = &subsonic.Child{ID: id, Title: "Track " + id, Duration: 180}
+	}
+	return tracks
+}
+
+func queueIDs(tracks []*subsonic.Child) string {
+	ids := make([]string, len(tracks))
+	for i, tr := range tracks {
+		ids[i] = tr.ID
+	}
+	return strings.Join(ids, " ")
+}
+
+// Waits for the asynchronous scrobble requests to reach the server and
+// returns the number of scrobble submissions and now playing notifications.
+func waitForScrobbles(srv *fakeServer, want int) (submissions, nowPlaying int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for len(srv.Scrobbles()) < want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, s := range srv.Scrobbles() {
+		if s.Submission {
+			submissions++
+		} else {
+			nowPlaying++
+		}
+	}
+	return submissions, nowPlaying
+}
+
+func TestTrackChangeAndScrobble(t *testing.T) {
+	pm := newTestPlaybackManager(t)
+	var mu sync.Mutex
+	var changes []string
+	pm.OnSongChange(func(np, _ *subsonic.Child) {
+		mu.Lock()
+		defer mu.Unlock()
+		if np != nil {
+			changes = append(changes, np.ID)
+		}
+		// callbacks must be able to call back into the PlaybackManager
+		pm.GetPlayQueue()
+	})
+
+	if err := pm.LoadTracks(testTracks(3), false, ShuffleNone); err != nil {
+		t.Fatalf("LoadTracks: %s", err.Error())
+	}
+	pm.PlayFromBeginning()
+	pm.fake.Flush()
+	for i := 0; i < 2; i++ {
+		// exceed the minimum play time for a scrobble
+		time.Sleep(150 * time.Millisecond)
+		pm.fake.EndFile()
+		pm.fake.Flush()
+	}
+
+	if np := pm.NowPlaying(); np == nil || np.ID != "2" {
+		t.Errorf("now playing = %v, want track 2", np)
+	}
+	mu.Lock()
+	if len(changes) != 3 || changes[0] != "0" || changes[2] != "2" {
+		t.Errorf("song changes = %v, want [0 1 2]", changes)
+	}
+	mu.Unlock()
+	if sub, np := waitForScrobbles(pm.srv, 5); sub != 2 || np != 3 {
+		t.Errorf("got %d scrobbles and %d now playing notifications, want 2 and 3", sub, np)
+	}
+	if pq := pm.GetPlayQueue(); pq[0].PlayCount != 1 || pq[2].PlayCount != 0 {
+		t.Errorf("play counts = %d, %d; want 1, 0", pq[0].PlayCount, pq[2].PlayCount)
+	}
+}
+
+func TestScrobbleThresholds(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		cfg    ScrobbleConfig
+		played time.Duration
+		radio  bool
+		want   bool
+	}{
+		{"disabled", ScrobbleConfig{Enabled: false, ThresholdPercent: 50, ThresholdTimeSeconds: -1}, 150 * time.Second, false, false},
+		{"percent met", ScrobbleConfig{Enabled: true, ThresholdPercent: 50, ThresholdTimeSeconds: -1}, 100 * time.Second, false, true},
+		{"percent not met", ScrobbleConfig{Enabled: true, ThresholdPercent: 50, ThresholdTimeSeconds: -1}, 99 * time.Second, false, false},
+		{"time met", ScrobbleConfig{Enabled: true, ThresholdPercent: 90, ThresholdTimeSeconds: 60}, 60 * time.Second, false, true},
+		{"time not met", ScrobbleConfig{Enabled: true, ThresholdPercent: 90, ThresholdTimeSeconds: 60}, 59 * time.Second, false, false},
+		{"time threshold disabled", ScrobbleConfig{Enabled: true, ThresholdPercent: 90, ThresholdTimeSeconds: -1}, 60 * time.Second, false, false},
+		{"percent clamped to 99", ScrobbleConfig{Enabled: true, ThresholdPercent: 100, ThresholdTimeSeconds: -1}, 199 * time.Second, false, true},
+		{"below minimum play time", ScrobbleConfig{Enabled: true, ThresholdPercent: 0, ThresholdTimeSeconds: 0}, 50 * time.Millisecond, false, false},
+		{"radio station", ScrobbleConfig{Enabled: true, ThresholdPercent: 0, ThresholdTimeSeconds: 0}, 100 * time.Second, true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pm, p, srv := newFakePlayerPlaybackManager(t, tt.cfg)
+			if tt.radio {
+				pm.PlayRadioStation(&subsonic.InternetRadioStation{Name: "Radio", StreamUrl: "http://radio"})
+			} else {
+				tracks := testTracks(1)
+				tracks[0].Duration = 200
+				pm.LoadTracks(tracks, false, ShuffleNone)
+				pm.PlayFromBeginning()
+			}
+			p.flush()
+
+			pm.lock()
+			pm.checkScrobble(tt.played)
+			pm.unlock()
+
+			if got := pm.GetPlayQueue()[0].PlayCount == 1; got != tt.want {
+				t.Errorf("scrobbled = %t, want %t", got, tt.want)
+			}
+			want := 0
+			if tt.want {
+				want = 1
+			}
+			// the now playing notification is sent for tracks only if scrobbling is enabled
+			if tt.cfg.Enabled && !tt.radio {
+				want++
+			}
+			if sub, np := waitForScrobbles(srv, want); sub+np != want {
+				t.Errorf("server received %d scrobble requests, want %d", sub+np, want)
+			}
+		})
+	}
+}
+
+func TestRemoveTracksFromQueue(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		playing     int
+		remove      []int
+		wantQueue   string
+		wantPlaying string
+	}{
+		{"nothing", 1, nil, "0 1 2 3 4", "1"},
+		{"before playing", 3, []int{0, 1}, "2 3 4", "3"},
+		{"after playing", 1, []int{3, 4}, "0 1 2", "1"},
+		{"around playing", 2, []int{0, 4}, "1 2 3", "2"},
+		{"playing", 2, []int{2}, "0 1 3 4", "3"},
+		{"playing and next", 2, []int{2, 3}, "0 1 4", "4"},
+		{"playing last track", 4, []int{4}, "0 1 2 3", ""},
+		{"all", 0, []int{0, 1, 2, 3, 4}, "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pm, p, _ := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+			pm.LoadTracks(testTracks(5), false, ShuffleNone)
+			pm.PlayTrackAt(tt.playing)
+			p.flush()
+
+			pm.RemoveTracksFromQueue(tt.remove)
+			p.flush()
+
+			queue := pm.GetPlayQueue()
+			if got := queueIDs(queue); got != tt.wantQueue {
+				t.Errorf("play queue = %q, want %q", got, tt.wantQueue)
+			}
+			if got := len(p.Playlist()); got != len(queue) {
+				t.Errorf("player playlist has %d entries, want %d", got, len(queue))
+			}
+			var playing string
+			if np := pm.NowPlaying(); np != nil {
+				playing = np.ID
+			}
+			if playing != tt.wantPlaying {
+				t.Errorf("now playing = %q, want %q", playing, tt.wantPlaying)
+			}
+		})
+	}
+}
+
+func TestUndoQueueEdits(t *testing.T) {
+	pm, p, _ := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	pm.LoadTracks(testTracks(5), false, ShuffleNone)
+	pm.PlayTrackAt(2)
+	p.flush()
+	check := func(step, wantQueue, wantPlaying string) {
+		t.Helper()
+		queue := pm.GetPlayQueue()
+		if got := queueIDs(queue); got != wantQueue {
+			t.Errorf("%s: play queue = %q, want %q", step, got, wantQueue)
+		}
+		var ids []string
+		for _, u := range p.Playlist() {
+			parsed, _ := url.Parse(u)
+			ids = append(ids, parsed.Query().Get("id"))
+		}
+		if got := strings.Join(ids, " "); got != wantQueue {
+			t.Errorf("%s: player playlist = %q, want %q", step, got, wantQueue)
+		}
+		if np := pm.NowPlaying(); np == nil || np.ID != wantPlaying {
+			t.Errorf("%s: now playing = %v, want %s", step, np, wantPlaying)
+		}
+	}
+
+	// remove and restore tracks around the playing one
+	removed := pm.GetPlayQueue()
+	pm.RemoveTracksFromQueue([]int{1, 3})
+	p.flush()
+	check("remove", "0 2 4", "2")
+	pm.InsertTracksIntoQueue([]*subsonic.Child{removed[1], removed[3]}, []int{1, 3})
+	check("insert", "0 1 2 3 4", "2")
+
+	// move the playing track to the top and back
+	order := sharedutil.ReorderItems([]int{0, 1, 2, 3, 4}, []int{2, 4}, sharedutil.MoveToTop)
+	if err := pm.ReorderQueue(order); err != nil {
+		t.Fatalf("error reordering queue: %s", err.Error())
+	}
+	check("reorder", "2 4 0 1 3", "2")
+	inverse := make([]int, len(order))
+	for i, j := range order {
+		inverse[j] = i
+	}
+	if err := pm.ReorderQueue(inverse); err != nil {
+		t.Fatalf("error reordering queue: %s", err.Error())
+	}
+	check("undo reorder", "0 1 2 3 4", "2")
+
+	if err := pm.ReorderQueue([]int{0, 1, 2}); err != ErrQueueChanged {
+		t.Errorf("got error %v for stale order, want ErrQueueChanged", err)
+	}
+}
+
+// Edits the play queue from several goroutines while track changes
+// and scrobbles happen. Meant to be run with -race.
+func TestConcurrentQueueEdits(t *testing.T) {
+	pm := newTestPlaybackManager(t)
+	pm.OnSongChange(func(_, _ *subsonic.Child) { pm.NowPlaying() })
+	pm.OnPlayTimeUpdate(func(_, _ float64) { pm.SleepTimerMode() })
+	pm.LoadTracks(testTracks(30), false, ShuffleNone)
+	pm.PlayFromBeginning()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			pm.fake.SetTimePos(float64(i))
+			pm.fake.EndFile()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			pm.RemoveTracksFromQueue([]int{i})
+			pm.LoadTracks(testTracks(2), true, ShuffleNone)
+			pm.OnTrackRatingChanged("5", i%5)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			pm.GetPlayQueue()
+			pm.NowPlaying()
+			pm.SleepTimerRemaining()
+			pm.SetStopAfterCurrent(false)
+		}
+	}()
+	wg.Wait()
+	pm.fake.Flush()
+
+	if got, want := len(pm.GetPlayQueue()), len(pm.fake.Playlist()); got != want {
+		t.Errorf("play queue has %d tracks, player playlist has %d", got, want)
+	}
+}
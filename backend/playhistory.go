@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PlayHistory records when tracks were last played by this client,
+// since the Subsonic API does not report it. It is kept per server
+// and persisted as JSON to the given file. It is shared by Auto-DJ,
+// to avoid repeats, and smart playlists.
+type PlayHistory struct {
+	mu         sync.Mutex
+	path       string
+	lastPlayed map[uuid.UUID]map[string]time.Time
+}
+
+// Creates a PlayHistory, loading the history saved at path if it exists.
+func NewPlayHistory(path string) (*PlayHistory, error) {
+	h := &PlayHistory{path: path, lastPlayed: make(map[uuid.UUID]map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	} else if err != nil {
+		return h, err
+	}
+	return h, json.Unmarshal(data, &h.lastPlayed)
+}
+
+// Records that the track on the given server was played at time t.
+// Safe to call on a nil PlayHistory.
+func (h *PlayHistory) Record(serverID uuid.UUID, trackID string, t time.Time) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	played, ok := h.lastPlayed[serverID]
+	if !ok {
+		played = make(map[string]time.Time)
+		h.lastPlayed[serverID] = played
+	}
+	played[trackID] = t
+}
+
+// Returns the time the track on the given server was last played,
+// or the zero time if it has not been played by this client.
+// Safe to call on a nil PlayHistory.
+func (h *PlayHistory) LastPlayed(serverID uuid.UUID, trackID string) time.Time {
+	if h == nil {
+		return time.Time{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastPlayed[serverID][trackID]
+}
+
+// Returns the IDs of the tracks on the given server last played after time t.
+// Safe to call on a nil PlayHistory.
+func (h *PlayHistory) PlayedSince(serverID uuid.UUID, t time.Time) []string {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var ids []string
+	for id, played := range h.lastPlayed[serverID] {
+		if played.After(t) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Writes the history to its file.
+func (h *PlayHistory) Save() error {
+	h.mu.Lock()
+	data, err := json.Marshal(h.lastPlayed)
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, data, 0600)
+}
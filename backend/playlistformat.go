@@ -0,0 +1,410 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// File formats for playlist import and export.
+type PlaylistFormat string
+
+const (
+	PlaylistFormatM3U8 PlaylistFormat = "M3U8"
+	PlaylistFormatXSPF PlaylistFormat = "XSPF"
+	PlaylistFormatPLS  PlaylistFormat = "PLS"
+	PlaylistFormatCSV  PlaylistFormat = "CSV"
+)
+
+var PlaylistFormats = []PlaylistFormat{
+	PlaylistFormatM3U8,
+	PlaylistFormatXSPF,
+	PlaylistFormatPLS,
+	PlaylistFormatCSV,
+}
+
+var ErrUnknownPlaylistFormat = errors.New("unknown playlist file format")
+
+// Returns the file extension for the format, including the dot.
+func (f PlaylistFormat) Extension() string {
+	return "." + strings.ToLower(string(f))
+}
+
+// Returns the playlist format for a file name, from its extension.
+func PlaylistFormatForFile(name string) (PlaylistFormat, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".m3u8", ".m3u":
+		return PlaylistFormatM3U8, nil
+	case ".xspf":
+		return PlaylistFormatXSPF, nil
+	case ".pls":
+		return PlaylistFormatPLS, nil
+	case ".csv":
+		return PlaylistFormatCSV, nil
+	}
+	return "", ErrUnknownPlaylistFormat
+}
+
+// An entry of a playlist file. Which fields are set depends on the format
+// and the application that wrote the file.
+type PlaylistFileEntry struct {
+	// File path or URL
+	Location      string
+	Title         string
+	Artist        string
+	Album         string
+	MusicBrainzID string
+	// Duration in seconds, or 0 if not known
+	Duration int
+}
+
+// Returns a short description of the entry for display.
+func (e PlaylistFileEntry) String() string {
+	switch {
+	case e.Artist != "" && e.Title != "":
+		return e.Artist + " - " + e.Title
+	case e.Title != "":
+		return e.Title
+	}
+	return e.Location
+}
+
+// Writes the tracks as a playlist file in the given format. location returns
+// the path or URL written for each track in the M3U8, XSPF and PLS formats.
+func WritePlaylistFile(w io.Writer, format PlaylistFormat, name string, tracks []*subsonic.Child, location func(*subsonic.Child) string) error {
+	switch format {
+	case PlaylistFormatM3U8:
+		return writeM3U8(w, name, tracks, location)
+	case PlaylistFormatXSPF:
+		return writeXSPF(w, name, tracks, location)
+	case PlaylistFormatPLS:
+		return writePLS(w, tracks, location)
+	case PlaylistFormatCSV:
+		return writeCSV(w, tracks)
+	}
+	return ErrUnknownPlaylistFormat
+}
+
+// Reads a playlist file in the given format. Returns the name of the
+// playlist, if the format includes it, and the entries.
+func ReadPlaylistFile(r io.Reader, format PlaylistFormat) (string, []PlaylistFileEntry, error) {
+	switch format {
+	case PlaylistFormatM3U8:
+		return readM3U8(r)
+	case PlaylistFormatXSPF:
+		return readXSPF(r)
+	case PlaylistFormatPLS:
+		entries, err := readPLS(r)
+		return "", entries, err
+	case PlaylistFormatCSV:
+		entries, err := readCSV(r)
+		return "", entries, err
+	}
+	return "", nil, ErrUnknownPlaylistFormat
+}
+
+func writeM3U8(w io.Writer, name string, tracks []*subsonic.Child, location func(*subsonic.Child) string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	if name != "" {
+		fmt.Fprintf(bw, "#PLAYLIST:%s\n", name)
+	}
+	for _, tr := range tracks {
+		fmt.Fprintf(bw, "#EXTINF:%d,%s - %s\n", tr.Duration, tr.Artist, tr.Title)
+		fmt.Fprintln(bw, location(tr))
+	}
+	return bw.Flush()
+}
+
+func readM3U8(r io.Reader) (string, []PlaylistFileEntry, error) {
+	var name string
+	var entries []PlaylistFileEntry
+	var cur PlaylistFileEntry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			dur, title, _ := strings.Cut(info, ",")
+			// the duration may be followed by attributes, e.g. #EXTINF:123 tvg-id="",
+			dur, _, _ = strings.Cut(dur, " ")
+			if d, err := strconv.Atoi(dur); err == nil && d > 0 {
+				cur.Duration = d
+			}
+			cur.Artist, cur.Title = splitArtistTitle(title)
+		case strings.HasPrefix(line, "#"):
+		default:
+			cur.Location = line
+			entries = append(entries, cur)
+			cur = PlaylistFileEntry{}
+		}
+	}
+	return name, entries, sc.Err()
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version string      `xml:"version,attr"`
+	Title   string      `xml:"title,omitempty"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location   string   `xml:"location,omitempty"`
+	Identifier []string `xml:"identifier,omitempty"`
+	Title      string   `xml:"title,omitempty"`
+	Creator    string   `xml:"creator,omitempty"`
+	Album      string   `xml:"album,omitempty"`
+	// Duration in milliseconds
+	Duration int `xml:"duration,omitempty"`
+}
+
+const musicBrainzRecordingURL = "https://musicbrainz.org/recording/"
+
+func writeXSPF(w io.Writer, name string, tracks []*subsonic.Child, location func(*subsonic.Child) string) error {
+	pl := xspfPlaylist{Version: "1", Title: name}
+	for _, tr := range tracks {
+		pl.Tracks = append(pl.Tracks, xspfTrack{
+			Location: locationURI(location(tr)),
+			Title:    tr.Title,
+			Creator:  tr.Artist,
+			Album:    tr.Album,
+			Duration: tr.Duration * 1000,
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(pl)
+}
+
+func readXSPF(r io.Reader) (string, []PlaylistFileEntry, error) {
+	var pl xspfPlaylist
+	if err := xml.NewDecoder(r).Decode(&pl); err != nil {
+		return "", nil, err
+	}
+	entries := make([]PlaylistFileEntry, len(pl.Tracks))
+	for i, tr := range pl.Tracks {
+		entries[i] = PlaylistFileEntry{
+			Location: locationFromURI(tr.Location),
+			Title:    tr.Title,
+			Artist:   tr.Creator,
+			Album:    tr.Album,
+			Duration: tr.Duration / 1000,
+		}
+		for _, id := range tr.Identifier {
+			if strings.HasPrefix(id, musicBrainzRecordingURL) {
+				entries[i].MusicBrainzID = strings.TrimPrefix(id, musicBrainzRecordingURL)
+			}
+		}
+	}
+	return pl.Title, entries, nil
+}
+
+func writePLS(w io.Writer, tracks []*subsonic.Child, location func(*subsonic.Child) string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "[playlist]")
+	for i, tr := range tracks {
+		fmt.Fprintf(bw, "File%d=%s\n", i+1, location(tr))
+		fmt.Fprintf(bw, "Title%d=%s - %s\n", i+1, tr.Artist, tr.Title)
+		fmt.Fprintf(bw, "Length%d=%d\n", i+1, tr.Duration)
+	}
+	fmt.Fprintf(bw, "NumberOfEntries=%d\n", len(tracks))
+	fmt.Fprintln(bw, "Version=2")
+	return bw.Flush()
+}
+
+func readPLS(r io.Reader) ([]PlaylistFileEntry, error) {
+	byNum := make(map[int]*PlaylistFileEntry)
+	var nums []int
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		field := strings.TrimRight(strings.ToLower(key), "0123456789")
+		num, err := strconv.Atoi(key[len(field):])
+		if err != nil {
+			continue
+		}
+		e, ok := byNum[num]
+		if !ok {
+			e = &PlaylistFileEntry{}
+			byNum[num] = e
+			nums = append(nums, num)
+		}
+		switch field {
+		case "file":
+			e.Location = value
+		case "title":
+			e.Artist, e.Title = splitArtistTitle(value)
+		case "length":
+			if d, err := strconv.Atoi(value); err == nil && d > 0 {
+				e.Duration = d
+			}
+		}
+	}
+	var entries []PlaylistFileEntry
+	sort.Ints(nums)
+	for _, num := range nums {
+		if e := byNum[num]; e.Location != "" {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, sc.Err()
+}
+
+var csvHeader = []string{"Title", "Artist", "Album", "Duration", "Path", "ID"}
+
+func writeCSV(w io.Writer, tracks []*subsonic.Child) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, tr := range tracks {
+		if err := cw.Write([]string{tr.Title, tr.Artist, tr.Album, strconv.Itoa(tr.Duration), tr.Path, tr.ID}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Reads a CSV file with a header row. The columns are identified by
+// their names, as written by common playlist exporters.
+func readCSV(r io.Reader) ([]PlaylistFileEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := make(map[string]int)
+	durationMillis := false
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		switch h {
+		case "title", "name", "track", "track name":
+			h = "title"
+		case "artist", "artist name", "artist name(s)", "creator":
+			h = "artist"
+		case "album", "album name":
+			h = "album"
+		case "duration", "length", "time":
+		case "duration (ms)", "track duration (ms)":
+			h = "duration"
+			durationMillis = true
+		case "path", "location", "file", "filename":
+			h = "path"
+		case "musicbrainz id", "mbid", "musicbrainz track id", "musicbrainz recording id":
+			h = "mbid"
+		default:
+			continue
+		}
+		if _, ok := cols[h]; !ok {
+			cols[h] = i
+		}
+	}
+	if _, ok := cols["title"]; !ok {
+		if _, ok := cols["path"]; !ok {
+			return nil, errors.New("CSV file has no title or path column")
+		}
+	}
+	var entries []PlaylistFileEntry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		entries = append(entries, PlaylistFileEntry{
+			Location:      field("path"),
+			Title:         field("title"),
+			Artist:        field("artist"),
+			Album:         field("album"),
+			MusicBrainzID: field("mbid"),
+			Duration:      parseDuration(field("duration"), durationMillis),
+		})
+	}
+	return entries, nil
+}
+
+// Parses a duration in seconds, milliseconds or [h:]m:ss format.
+func parseDuration(s string, millis bool) int {
+	if s == "" {
+		return 0
+	}
+	if strings.Contains(s, ":") {
+		secs := 0
+		for _, part := range strings.Split(s, ":") {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0
+			}
+			secs = secs*60 + n
+		}
+		return secs
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+	if millis {
+		n /= 1000
+	}
+	return n
+}
+
+// Splits an "Artist - Title" description.
+func splitArtistTitle(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if artist, title, ok := strings.Cut(s, " - "); ok {
+		return strings.TrimSpace(artist), strings.TrimSpace(title)
+	}
+	return "", s
+}
+
+// Returns the location as a URI for XSPF files. URLs are kept as is,
+// file paths are escaped as relative references.
+func locationURI(loc string) string {
+	if u, err := url.Parse(loc); err == nil && u.Scheme != "" && len(u.Scheme) > 1 {
+		return loc
+	}
+	return (&url.URL{Path: loc}).String()
+}
+
+// Returns the file path for file: and relative URIs, or the URI itself.
+func locationFromURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	if u.Scheme == "file" || u.Scheme == "" {
+		return u.Path
+	}
+	return uri
+}
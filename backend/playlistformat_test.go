@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+var exportTracks = []*subsonic.Child{
+	{ID: "1", Title: "Morning Star", Artist: "Aurora Lane", Album: "Northern Lights", Duration: 187, Path: "Aurora Lane/Northern Lights/01 Morning Star.flac"},
+	{ID: "2", Title: "Rock & Roll, Part 2", Artist: "Delta", Album: "Live", Duration: 201, Path: "Delta/Live/02 Rock & Roll, Part 2.mp3"},
+}
+
+func TestPlaylistFileRoundTrip(t *testing.T) {
+	location := func(tr *subsonic.Child) string { return tr.Path }
+	for _, format := range PlaylistFormats {
+		var buf bytes.Buffer
+		if err := WritePlaylistFile(&buf, format, "Road trip", exportTracks, location); err != nil {
+			t.Fatalf("%s: error writing playlist: %s", format, err.Error())
+		}
+		name, entries, err := ReadPlaylistFile(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: error reading playlist: %s", format, err.Error())
+		}
+		if (format == PlaylistFormatM3U8 || format == PlaylistFormatXSPF) && name != "Road trip" {
+			t.Errorf("%s: got name %q, want Road trip", format, name)
+		}
+		if len(entries) != len(exportTracks) {
+			t.Fatalf("%s: got %d entries, want %d", format, len(entries), len(exportTracks))
+		}
+		for i, e := range entries {
+			tr := exportTracks[i]
+			if e.Location != tr.Path || e.Title != tr.Title || e.Artist != tr.Artist || e.Duration != tr.Duration {
+				t.Errorf("%s: got entry %+v for track %s", format, e, tr.Title)
+			}
+		}
+	}
+}
+
+func TestReadPlaylistFiles(t *testing.T) {
+	for _, tt := range []struct {
+		format PlaylistFormat
+		data   string
+		want   PlaylistFileEntry
+	}{
+		{PlaylistFormatM3U8, "\ufeff#EXTM3U\r\n#EXTINF:187,Aurora Lane - Morning Star\r\nC:\\Music\\Morning Star.flac\r\n",
+			PlaylistFileEntry{Location: `C:\Music\Morning Star.flac`, Title: "Morning Star", Artist: "Aurora Lane", Duration: 187}},
+		{PlaylistFormatM3U8, "/music/a.mp3\n",
+			PlaylistFileEntry{Location: "/music/a.mp3"}},
+		{PlaylistFormatXSPF, `<?xml version="1.0"?><playlist version="1" xmlns="http://xspf.org/ns/0/"><trackList><track>` +
+			`<location>file:///music/Morning%20Star.flac</location>` +
+			`<identifier>https://musicbrainz.org/recording/0b8f3a5e-0000-4000-8000-000000000001</identifier>` +
+			`<title>Morning Star</title><creator>Aurora Lane</creator><duration>187000</duration></track></trackList></playlist>`,
+			PlaylistFileEntry{Location: "/music/Morning Star.flac", Title: "Morning Star", Artist: "Aurora Lane",
+				MusicBrainzID: "0b8f3a5e-0000-4000-8000-000000000001", Duration: 187}},
+		{PlaylistFormatPLS, "[playlist]\nFile1=/music/a.mp3\nTitle1=Aurora Lane - Morning Star\nLength1=187\nNumberOfEntries=1\nVersion=2\n",
+			PlaylistFileEntry{Location: "/music/a.mp3", Title: "Morning Star", Artist: "Aurora Lane", Duration: 187}},
+		{PlaylistFormatCSV, "Track Name,Artist Name(s),Album Name,Track Duration (ms)\nMorning Star,Aurora Lane,Northern Lights,187400\n",
+			PlaylistFileEntry{Title: "Morning Star", Artist: "Aurora Lane", Album: "Northern Lights", Duration: 187}},
+	} {
+		_, entries, err := ReadPlaylistFile(strings.NewReader(tt.data), tt.format)
+		if err != nil {
+			t.Errorf("%s: error reading playlist: %s", tt.format, err.Error())
+			continue
+		}
+		if len(entries) != 1 || entries[0] != tt.want {
+			t.Errorf("%s: got entries %+v, want %+v", tt.format, entries, tt.want)
+		}
+	}
+}
+
+func TestPlaylistFormatForFile(t *testing.T) {
+	for name, want := range map[string]PlaylistFormat{
+		"mix.m3u": PlaylistFormatM3U8, "mix.M3U8": PlaylistFormatM3U8, "mix.xspf": PlaylistFormatXSPF,
+		"mix.pls": PlaylistFormatPLS, "mix.csv": PlaylistFormatCSV,
+	} {
+		if got, err := PlaylistFormatForFile(name); err != nil || got != want {
+			t.Errorf("%s: got format %q, want %q", name, got, want)
+		}
+	}
+	if _, err := PlaylistFormatForFile("mix.txt"); err != ErrUnknownPlaylistFormat {
+		t.Errorf("got error %v for unknown format", err)
+	}
+}
@@ -0,0 +1,346 @@
+package backend
+
+import (
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// How a playlist file entry was resolved to a track in the library.
+type PlaylistMatchStatus int
+
+const (
+	// No track in the library resembles the entry
+	PlaylistMatchNone PlaylistMatchStatus = iota
+	// Several tracks resemble the entry and the user needs to choose
+	PlaylistMatchAmbiguous
+	// Matched by artist, title, album and duration
+	PlaylistMatchFuzzy
+	// Matched by stream URL, file path or MusicBrainz ID
+	PlaylistMatchExact
+)
+
+const (
+	// Minimum score of a fuzzy match that is accepted without review
+	fuzzyMatchAccept = 0.85
+	// Minimum lead over the second best candidate to accept a fuzzy match
+	fuzzyMatchMargin = 0.1
+	// Minimum score of a candidate offered for review
+	fuzzyMatchCandidate = 0.5
+	// Maximum number of candidates offered for review
+	maxMatchCandidates = 5
+	// Number of search results considered for fuzzy matching
+	fuzzyMatchSearchCount = 50
+)
+
+// The result of resolving a playlist file entry against the library.
+type PlaylistEntryMatch struct {
+	Entry  PlaylistFileEntry
+	Status PlaylistMatchStatus
+	// The matched track, or the best candidate of an ambiguous match
+	Track *subsonic.Child
+	// The candidates of an ambiguous match, best first
+	Candidates []*subsonic.Child
+}
+
+// Resolves the entries of a playlist file against the library by stream URL
+// or file path, then by MusicBrainz ID, and finally by fuzzy matching of the
+// artist, title, album and duration against search results.
+// onProgress, if not nil, is called with the number of entries resolved so far.
+func (l *LibraryManager) MatchPlaylistEntries(entries []PlaylistFileEntry, onProgress func(done int)) ([]PlaylistEntryMatch, error) {
+	tracks, byMBID, err := l.allTracksWithMBIDs()
+	if err != nil {
+		return nil, err
+	}
+	m := newPlaylistMatcher(tracks, byMBID, func(query string) ([]*subsonic.Child, error) {
+		res, err := l.s.Server().Search3(query, map[string]string{
+			"artistCount": "0", "albumCount": "0", "songCount": strconv.Itoa(fuzzyMatchSearchCount)})
+		if err != nil {
+			return nil, err
+		}
+		return res.Song, nil
+	})
+	matches := make([]PlaylistEntryMatch, len(entries))
+	for i, e := range entries {
+		matches[i] = m.match(e)
+		if onProgress != nil {
+			onProgress(i + 1)
+		}
+	}
+	return matches, nil
+}
+
+// Writes the tracks as a playlist file. If streamURLs is true, the M3U8, XSPF
+// and PLS formats refer to the tracks by stream URL instead of server path.
+// Stream URLs contain the credentials used to log in to the server.
+func (l *LibraryManager) ExportPlaylist(w io.Writer, format PlaylistFormat, name string, tracks []*subsonic.Child, streamURLs bool) error {
+	location := func(tr *subsonic.Child) string { return tr.Path }
+	if streamURLs {
+		location = func(tr *subsonic.Child) string {
+			u, err := l.s.StreamURL(tr.ID, nil)
+			if err != nil {
+				return tr.Path
+			}
+			return u.String()
+		}
+	}
+	return WritePlaylistFile(w, format, name, tracks, location)
+}
+
+type playlistMatcher struct {
+	byID map[string]*subsonic.Child
+	// Tracks by lower-cased MusicBrainz ID
+	byMBID map[string]*subsonic.Child
+	// Tracks by their normalized full server path.
+	// nil values mark paths shared by several tracks.
+	byPath map[string]*subsonic.Child
+	search func(query string) ([]*subsonic.Child, error)
+}
+
+func newPlaylistMatcher(tracks []*subsonic.Child, byMBID map[string]*subsonic.Child, search func(string) ([]*subsonic.Child, error)) *playlistMatcher {
+	m := &playlistMatcher{
+		byID:   make(map[string]*subsonic.Child, len(tracks)),
+		byMBID: byMBID,
+		byPath: make(map[string]*subsonic.Child),
+		search: search,
+	}
+	for _, tr := range tracks {
+		m.byID[tr.ID] = tr
+		if p := normalizePath(tr.Path); p != "" {
+			if _, ok := m.byPath[p]; ok {
+				m.byPath[p] = nil
+			} else {
+				m.byPath[p] = tr
+			}
+		}
+	}
+	return m
+}
+
+func (m *playlistMatcher) match(e PlaylistFileEntry) PlaylistEntryMatch {
+	result := PlaylistEntryMatch{Entry: e}
+	if tr := m.matchExact(e); tr != nil {
+		result.Status = PlaylistMatchExact
+		result.Track = tr
+		return result
+	}
+	e = withInferredTags(e)
+	if e.Title == "" {
+		return result
+	}
+	candidates := m.searchCandidates(e)
+	if len(candidates) == 0 {
+		return result
+	}
+	best := candidates[0]
+	if best.score >= fuzzyMatchAccept && (len(candidates) == 1 || best.score-candidates[1].score >= fuzzyMatchMargin) {
+		result.Status = PlaylistMatchFuzzy
+		result.Track = best.track
+		return result
+	}
+	result.Status = PlaylistMatchAmbiguous
+	result.Track = best.track
+	for i := 0; i < len(candidates) && i < maxMatchCandidates; i++ {
+		result.Candidates = append(result.Candidates, candidates[i].track)
+	}
+	return result
+}
+
+// Matches by the track ID of a stream URL, by file path or by MusicBrainz ID.
+func (m *playlistMatcher) matchExact(e PlaylistFileEntry) *subsonic.Child {
+	if u, err := url.Parse(e.Location); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if id := u.Query().Get("id"); id != "" && strings.HasPrefix(path.Base(u.Path), "stream") {
+			if tr := m.byID[id]; tr != nil {
+				return tr
+			}
+		}
+	} else {
+		// the whole server path of a track must match the end of the entry's
+		// path, e.g. /mnt/music/<server path>; matches of only the trailing
+		// components are left to fuzzy matching, which the user can review
+		for _, suffix := range pathSuffixes(e.Location) {
+			if tr, ok := m.byPath[suffix]; ok {
+				if tr != nil {
+					return tr
+				}
+				break // ambiguous
+			}
+		}
+	}
+	if e.MusicBrainzID != "" {
+		return m.byMBID[strings.ToLower(e.MusicBrainzID)]
+	}
+	return nil
+}
+
+type scoredTrack struct {
+	track *subsonic.Child
+	score float64
+}
+
+// Returns the search results for the entry scoring at least
+// fuzzyMatchCandidate, best first.
+func (m *playlistMatcher) searchCandidates(e PlaylistFileEntry) []scoredTrack {
+	queries := []string{normalizeTag(e.Title)}
+	if e.Artist != "" {
+		queries = append([]string{normalizeTag(e.Artist) + " " + queries[0]}, queries...)
+	}
+	seen := make(map[string]bool)
+	var candidates []scoredTrack
+	for _, q := range queries {
+		results, err := m.search(q)
+		if err != nil {
+			continue
+		}
+		for _, tr := range results {
+			if seen[tr.ID] {
+				continue
+			}
+			seen[tr.ID] = true
+			if score := fuzzyMatchScore(e, tr); score >= fuzzyMatchCandidate {
+				candidates = append(candidates, scoredTrack{track: tr, score: score})
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	return candidates
+}
+
+// Scores how well a track matches the entry, from 0 up to about 1.1.
+// The title counts most, then the artist and the album, as far as they
+// are known; a similar duration adds to the score and a very different
+// duration subtracts.
+func fuzzyMatchScore(e PlaylistFileEntry, tr *subsonic.Child) float64 {
+	score := 0.6 * similarity(normalizeTag(e.Title), normalizeTag(tr.Title))
+	weight := 0.6
+	if e.Artist != "" {
+		score += 0.3 * similarity(normalizeTag(e.Artist), normalizeTag(tr.Artist))
+		weight += 0.3
+	}
+	if e.Album != "" {
+		score += 0.1 * similarity(normalizeTag(e.Album), normalizeTag(tr.Album))
+		weight += 0.1
+	}
+	score /= weight
+	if e.Duration > 0 && tr.Duration > 0 {
+		diff := e.Duration - tr.Duration
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= 3 {
+			score += 0.1
+		} else if diff > 15 {
+			score -= 0.2
+		}
+	}
+	return score
+}
+
+// Fills in a missing title, album and artist from the file path, assuming
+// the common Artist/Album/01 - Title.ext layout.
+func withInferredTags(e PlaylistFileEntry) PlaylistFileEntry {
+	if e.Title != "" || e.Location == "" {
+		return e
+	}
+	parts := strings.Split(strings.ReplaceAll(e.Location, `\`, "/"), "/")
+	name := strings.TrimSuffix(parts[len(parts)-1], path.Ext(parts[len(parts)-1]))
+	name = strings.TrimLeftFunc(name, func(r rune) bool { return unicode.IsDigit(r) || r == ' ' || r == '.' })
+	name = strings.TrimPrefix(name, "- ")
+	e.Title = strings.TrimSpace(name)
+	if len(parts) >= 2 && e.Album == "" {
+		e.Album = parts[len(parts)-2]
+	}
+	if len(parts) >= 3 && e.Artist == "" {
+		e.Artist = parts[len(parts)-3]
+	}
+	return e
+}
+
+// Returns the path in lower case with forward slashes
+// and without leading, trailing or repeated slashes.
+func normalizePath(p string) string {
+	p = strings.ToLower(strings.ReplaceAll(p, `\`, "/"))
+	return strings.Join(strings.FieldsFunc(p, func(r rune) bool { return r == '/' }), "/")
+}
+
+// Returns the trailing components of the normalized path, longest
+// (the whole path) first, down to the file name alone.
+func pathSuffixes(p string) []string {
+	p = normalizePath(p)
+	if p == "" {
+		return nil
+	}
+	parts := strings.Split(p, "/")
+	suffixes := make([]string, 0, len(parts))
+	for i := range parts {
+		suffixes = append(suffixes, strings.Join(parts[i:], "/"))
+	}
+	return suffixes
+}
+
+// Normalizes a title, artist or album for comparison: lower case, without
+// bracketed remarks such as "(Remastered)", featured artists, a leading
+// "the" and punctuation.
+func normalizeTag(s string) string {
+	s = strings.ToLower(s)
+	for _, feat := range []string{" feat. ", " feat ", " ft. ", " featuring "} {
+		if i := strings.Index(s, feat); i > 0 {
+			s = s[:i]
+		}
+	}
+	var b strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case r == ')' || r == ']':
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case r == '&':
+			b.WriteString(" and ")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	s = strings.Join(strings.Fields(b.String()), " ")
+	return strings.TrimPrefix(s, "the ")
+}
+
+// Returns the similarity of two strings from 0 to 1,
+// as the Dice coefficient of their character bigrams.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < 2 || len(rb) < 2 {
+		return 0
+	}
+	bigrams := make(map[[2]rune]int)
+	for i := 0; i < len(ra)-1; i++ {
+		bigrams[[2]rune{ra[i], ra[i+1]}]++
+	}
+	shared := 0
+	for i := 0; i < len(rb)-1; i++ {
+		bg := [2]rune{rb[i], rb[i+1]}
+		if bigrams[bg] > 0 {
+			bigrams[bg]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(ra)+len(rb)-2)
+}
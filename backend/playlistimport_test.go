@@ -0,0 +1,90 @@
+package backend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+func TestPlaylistMatcher(t *testing.T) {
+	tracks := []*subsonic.Child{
+		{ID: "1", Title: "Morning Star", Artist: "Aurora Lane", Album: "Northern Lights", Duration: 187, Path: "Aurora Lane/Northern Lights/01 Morning Star.flac"},
+		{ID: "2", Title: "Morning Star", Artist: "Aurora Lane", Album: "Live", Duration: 240, Path: "Aurora Lane/Live/01 Morning Star.flac"},
+		{ID: "3", Title: "Harbor Lights", Artist: "Aurora Lane", Album: "Northern Lights", Duration: 194, Path: "Aurora Lane/Northern Lights/02 Harbor Lights.flac"},
+	}
+	byMBID := map[string]*subsonic.Child{"0b8f3a5e-0000-4000-8000-000000000003": tracks[2]}
+	search := func(query string) ([]*subsonic.Child, error) { return tracks, nil }
+	m := newPlaylistMatcher(tracks, byMBID, search)
+
+	for _, tt := range []struct {
+		name   string
+		entry  PlaylistFileEntry
+		status PlaylistMatchStatus
+		id     string
+	}{
+		{"stream URL", PlaylistFileEntry{Location: "https://music.example.com/rest/stream.view?id=2&u=me"}, PlaylistMatchExact, "2"},
+		{"path", PlaylistFileEntry{Location: `D:\Music\Aurora Lane\Live\01 Morning Star.flac`}, PlaylistMatchExact, "2"},
+		{"MBID", PlaylistFileEntry{Title: "x", MusicBrainzID: "0B8F3A5E-0000-4000-8000-000000000003"}, PlaylistMatchExact, "3"},
+		{"web URL with MBID", PlaylistFileEntry{Location: "https://example.com/listen/3", Title: "x",
+			MusicBrainzID: "0b8f3a5e-0000-4000-8000-000000000003"}, PlaylistMatchExact, "3"},
+		{"fuzzy", PlaylistFileEntry{Title: "Harbour Lights (Remastered)", Artist: "Aurora Lane"}, PlaylistMatchFuzzy, "3"},
+		{"fuzzy by duration", PlaylistFileEntry{Title: "Morning Star", Artist: "Aurora Lane", Duration: 186}, PlaylistMatchFuzzy, "1"},
+		{"ambiguous", PlaylistFileEntry{Title: "Morning Star", Artist: "Aurora Lane"}, PlaylistMatchAmbiguous, "1"},
+		{"partial path", PlaylistFileEntry{Location: "/other/Live/01 Morning Star.flac"}, PlaylistMatchAmbiguous, "2"},
+		{"file name", PlaylistFileEntry{Location: "/old/Aurora Lane/Northern Lights/02 - Harbor Lights.mp3"}, PlaylistMatchFuzzy, "3"},
+		{"none", PlaylistFileEntry{Title: "Undertow", Artist: "Blue Harbor"}, PlaylistMatchNone, ""},
+	} {
+		match := m.match(tt.entry)
+		var id string
+		if match.Track != nil {
+			id = match.Track.ID
+		}
+		if match.Status != tt.status || id != tt.id {
+			t.Errorf("%s: got status %d with track %q, want %d with %q", tt.name, match.Status, id, tt.status, tt.id)
+		}
+	}
+}
+
+func TestMatchPlaylistEntries(t *testing.T) {
+	l, _ := newTestLibraryManager(t)
+	entries := []PlaylistFileEntry{
+		{Title: "Harbor Lights", Artist: "Aurora Lane", Duration: 194},
+		{Title: "Sea Glass"},
+		{Title: "Nowhere To Be Found", Artist: "Nobody"},
+	}
+	var progress int
+	matches, err := l.MatchPlaylistEntries(entries, func(done int) { progress = done })
+	if err != nil {
+		t.Fatalf("error matching entries: %s", err.Error())
+	}
+	if progress != len(entries) {
+		t.Errorf("got progress %d, want %d", progress, len(entries))
+	}
+	if m := matches[0]; m.Status != PlaylistMatchFuzzy || m.Track.ID != "tr-2" {
+		t.Errorf("got %+v for Harbor Lights", m)
+	}
+	if m := matches[1]; m.Status != PlaylistMatchFuzzy || m.Track.ID != "tr-12" {
+		t.Errorf("got %+v for Sea Glass", m)
+	}
+	if m := matches[2]; m.Status != PlaylistMatchNone {
+		t.Errorf("got %+v for unknown track", m)
+	}
+}
+
+// Exported stream URLs use the same authentication as playback.
+func TestExportPlaylistStreamURLs(t *testing.T) {
+	l, _ := newTestLibraryManager(t)
+	l.s.connection = ServerConnection{APIKeyAuth: true}
+	l.s.password = "key"
+	tracks := []*subsonic.Child{{ID: "tr-1", Title: "Morning Star", Path: "a/b.flac"}}
+
+	var buf bytes.Buffer
+	if err := l.ExportPlaylist(&buf, PlaylistFormatM3U8, "Test", tracks, true); err != nil {
+		t.Fatalf("error exporting playlist: %s", err.Error())
+	}
+	if out := buf.String(); !strings.Contains(out, "apiKey=key") || strings.Contains(out, "u="+fakeServerUser) {
+		t.Errorf("stream URL not authenticated with API key:\n%s", out)
+	}
+}
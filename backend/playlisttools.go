@@ -0,0 +1,169 @@
+package backend
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// Keys to sort playlist tracks by. Named like the tracklist columns.
+const (
+	PlaylistSortTitle    = "Title"
+	PlaylistSortArtist   = "Artist"
+	PlaylistSortAlbum    = "Album"
+	PlaylistSortTime     = "Time"
+	PlaylistSortYear     = "Year"
+	PlaylistSortFavorite = "Favorite"
+	PlaylistSortRating   = "Rating"
+	PlaylistSortPlays    = "Plays"
+	PlaylistSortBitrate  = "Bitrate"
+	PlaylistSortSize     = "Size"
+	PlaylistSortPath     = "Path"
+)
+
+var PlaylistSortKeys = []string{
+	PlaylistSortTitle,
+	PlaylistSortArtist,
+	PlaylistSortAlbum,
+	PlaylistSortTime,
+	PlaylistSortYear,
+	PlaylistSortFavorite,
+	PlaylistSortRating,
+	PlaylistSortPlays,
+	PlaylistSortBitrate,
+	PlaylistSortSize,
+	PlaylistSortPath,
+}
+
+// Returns the indexes of the tracks that duplicate an earlier track in the list,
+// i.e. have the same ID or, if byArtistTitle, the same artist and title
+// (e.g. the same song from a different release).
+func DuplicateTrackIndexes(tracks []*subsonic.Child, byArtistTitle bool) []int {
+	seen := make(map[string]bool, len(tracks))
+	var dupes []int
+	for i, tr := range tracks {
+		key := tr.ID
+		if byArtistTitle {
+			key = normalizeTag(tr.Artist) + "\x00" + normalizeTag(tr.Title)
+		}
+		if seen[key] {
+			dupes = append(dupes, i)
+		}
+		seen[key] = true
+	}
+	return dupes
+}
+
+// Returns the tracks sorted by one of the PlaylistSortKeys. The sort is stable,
+// so tracks with equal keys keep their order in the playlist.
+func SortTracks(tracks []*subsonic.Child, key string, descending bool) []*subsonic.Child {
+	var less func(a, b *subsonic.Child) bool
+	switch key {
+	case PlaylistSortTitle:
+		less = func(a, b *subsonic.Child) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	case PlaylistSortArtist:
+		less = func(a, b *subsonic.Child) bool { return strings.ToLower(a.Artist) < strings.ToLower(b.Artist) }
+	case PlaylistSortAlbum:
+		less = func(a, b *subsonic.Child) bool {
+			if !strings.EqualFold(a.Album, b.Album) {
+				return strings.ToLower(a.Album) < strings.ToLower(b.Album)
+			}
+			if a.DiscNumber != b.DiscNumber {
+				return a.DiscNumber < b.DiscNumber
+			}
+			return a.Track < b.Track
+		}
+	case PlaylistSortTime:
+		less = func(a, b *subsonic.Child) bool { return a.Duration < b.Duration }
+	case PlaylistSortYear:
+		less = func(a, b *subsonic.Child) bool { return a.Year < b.Year }
+	case PlaylistSortFavorite:
+		less = func(a, b *subsonic.Child) bool { return a.Starred.IsZero() && !b.Starred.IsZero() }
+	case PlaylistSortRating:
+		less = func(a, b *subsonic.Child) bool { return a.UserRating < b.UserRating }
+	case PlaylistSortPlays:
+		less = func(a, b *subsonic.Child) bool { return a.PlayCount < b.PlayCount }
+	case PlaylistSortBitrate:
+		less = func(a, b *subsonic.Child) bool { return a.BitRate < b.BitRate }
+	case PlaylistSortSize:
+		less = func(a, b *subsonic.Child) bool { return a.Size < b.Size }
+	case PlaylistSortPath:
+		less = func(a, b *subsonic.Child) bool { return a.Path < b.Path }
+	default:
+		less = func(a, b *subsonic.Child) bool { return false }
+	}
+	sorted := append([]*subsonic.Child(nil), tracks...)
+	if descending {
+		sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[j], sorted[i]) })
+	} else {
+		sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	}
+	return sorted
+}
+
+// Creates a new playlist with the given name containing the tracks of the
+// playlists, in order. If removeDuplicates is true, tracks already added
+// from an earlier playlist are skipped. Returns the ID of the new playlist.
+func (l *LibraryManager) MergePlaylists(name string, playlistIDs []string, removeDuplicates bool) (string, error) {
+	var tracks []*subsonic.Child
+	for _, id := range playlistIDs {
+		pl, err := l.s.Server().GetPlaylist(id)
+		if err != nil {
+			return "", err
+		}
+		tracks = append(tracks, pl.Entry...)
+	}
+	if removeDuplicates {
+		dupes := DuplicateTrackIndexes(tracks, false)
+		tracks = removeIndexes(tracks, dupes)
+	}
+	ids := make([]string, len(tracks))
+	for i, tr := range tracks {
+		ids[i] = tr.ID
+	}
+	return l.ReplacePlaylistTracks("", name, ids)
+}
+
+// Creates a copy owned by the user of the given playlist, which may be
+// another user's public playlist. Returns the ID of the new playlist.
+func (l *LibraryManager) ClonePlaylist(playlistID, name string) (string, error) {
+	return l.MergePlaylists(name, []string{playlistID}, false)
+}
+
+// Returns the indexes of the playlist entries whose tracks no longer exist
+// in the library, e.g. because the files were deleted or re-imported.
+// Each track is looked up on the server, rather than in the whole library.
+func (l *LibraryManager) MissingPlaylistTracks(entries []*subsonic.Child) ([]int, error) {
+	// a track may appear in the playlist more than once
+	gone := make(map[string]bool)
+	var missing []int
+	for i, tr := range entries {
+		isGone, checked := gone[tr.ID]
+		if !checked {
+			_, err := l.s.Server().GetSong(tr.ID)
+			if err != nil && !isSubsonicError(err, subsonicErrNotFound) {
+				return nil, err
+			}
+			isGone = err != nil
+			gone[tr.ID] = isGone
+		}
+		if isGone {
+			missing = append(missing, i)
+		}
+	}
+	return missing, nil
+}
+
+// Returns the tracks without those at the given (sorted) indexes.
+func removeIndexes(tracks []*subsonic.Child, idxs []int) []*subsonic.Child {
+	kept := make([]*subsonic.Child, 0, len(tracks)-len(idxs))
+	for i, tr := range tracks {
+		if len(idxs) > 0 && idxs[0] == i {
+			idxs = idxs[1:]
+			continue
+		}
+		kept = append(kept, tr)
+	}
+	return kept
+}
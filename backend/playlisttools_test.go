@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+func TestDuplicateTrackIndexes(t *testing.T) {
+	tracks := []*subsonic.Child{
+		{ID: "1", Artist: "Aurora Lane", Title: "Morning Star"},
+		{ID: "2", Artist: "Aurora Lane", Title: "Harbor Lights"},
+		{ID: "1", Artist: "Aurora Lane", Title: "Morning Star"},
+		{ID: "3", Artist: "aurora lane", Title: "Morning Star (Live)"},
+		{ID: "4", Artist: "Delta", Title: "Morning Star"},
+	}
+	if got, want := fmt.Sprint(DuplicateTrackIndexes(tracks, false)), "[2]"; got != want {
+		t.Errorf("duplicates by ID = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(DuplicateTrackIndexes(tracks, true)), "[2 3]"; got != want {
+		t.Errorf("duplicates by artist and title = %s, want %s", got, want)
+	}
+}
+
+func TestSortTracks(t *testing.T) {
+	tracks := []*subsonic.Child{
+		{ID: "1", Title: "b", Year: 2001, Album: "X", Track: 2},
+		{ID: "2", Title: "C", Year: 1999, Album: "x", Track: 1},
+		{ID: "3", Title: "a", Year: 2001, Album: "A", Track: 3},
+	}
+	for _, tt := range []struct {
+		key        string
+		descending bool
+		want       string
+	}{
+		{PlaylistSortTitle, false, "3 1 2"},
+		{PlaylistSortYear, false, "2 1 3"},
+		{PlaylistSortYear, true, "1 3 2"},
+		{PlaylistSortAlbum, false, "3 2 1"},
+	} {
+		if got := queueIDs(SortTracks(tracks, tt.key, tt.descending)); got != tt.want {
+			t.Errorf("sorted by %s (descending %t) = %s, want %s", tt.key, tt.descending, got, tt.want)
+		}
+	}
+	if got := queueIDs(tracks); got != "1 2 3" {
+		t.Errorf("original tracks reordered to %s", got)
+	}
+}
+
+func TestMergeAndClonePlaylists(t *testing.T) {
+	l, srv := newTestLibraryManager(t)
+	a, err := l.ReplacePlaylistTracks("", "A", []string{"tr-1", "tr-2"})
+	if err != nil {
+		t.Fatalf("error creating playlist: %s", err.Error())
+	}
+	b, err := l.ReplacePlaylistTracks("", "B", []string{"tr-2", "tr-3"})
+	if err != nil {
+		t.Fatalf("error creating playlist: %s", err.Error())
+	}
+
+	merged, err := l.MergePlaylists("A+B", []string{a, b}, true)
+	if err != nil {
+		t.Fatalf("error merging playlists: %s", err.Error())
+	}
+	if got, want := queueIDs(srv.playlist(merged).Entry), "tr-1 tr-2 tr-3"; got != want {
+		t.Errorf("merged playlist has tracks %s, want %s", got, want)
+	}
+
+	clone, err := l.ClonePlaylist(b, "B copy")
+	if err != nil {
+		t.Fatalf("error cloning playlist: %s", err.Error())
+	}
+	if pl := srv.playlist(clone); clone == b || pl.Name != "B copy" || queueIDs(pl.Entry) != "tr-2 tr-3" {
+		t.Errorf("got clone %s named %q with tracks %s", clone, pl.Name, queueIDs(pl.Entry))
+	}
+}
+
+func TestMissingPlaylistTracks(t *testing.T) {
+	l, _ := newTestLibraryManager(t)
+	entries := []*subsonic.Child{{ID: "tr-1"}, {ID: "gone"}, {ID: "tr-24"}}
+	missing, err := l.MissingPlaylistTracks(entries)
+	if err != nil {
+		t.Fatalf("error finding missing tracks: %s", err.Error())
+	}
+	if got, want := fmt.Sprint(missing), "[1]"; got != want {
+		t.Errorf("missing tracks = %s, want %s", got, want)
+	}
+}
+
+// Tracks are not reported missing if they could not be checked.
+func TestMissingPlaylistTracksError(t *testing.T) {
+	l, srv := newTestLibraryManager(t)
+	entries := []*subsonic.Child{{ID: "tr-1"}, {ID: "tr-1"}, {ID: "tr-2"}}
+	if _, err := l.MissingPlaylistTracks(entries); err != nil {
+		t.Fatalf("error finding missing tracks: %s", err.Error())
+	}
+	if n := srv.Requests("getSong"); n != 2 {
+		t.Errorf("got %d song requests, want 2", n)
+	}
+	if n := srv.Requests("getAlbum"); n != 0 {
+		t.Errorf("library fetched with %d album requests", n)
+	}
+
+	srv.Fail("getSong")
+	if missing, err := l.MissingPlaylistTracks(entries); err == nil {
+		t.Errorf("no error when the tracks could not be checked, got missing %v", missing)
+	}
+}
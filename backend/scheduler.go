@@ -0,0 +1,378 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"supersonic/player"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScheduleSourcePlaylist = "Playlist"
+	ScheduleSourceAlbum    = "Album"
+	ScheduleSourceGenre    = "Genre mix"
+	ScheduleSourceRadio    = "Radio station"
+)
+
+var ScheduleSourceTypes = []string{
+	ScheduleSourcePlaylist,
+	ScheduleSourceAlbum,
+	ScheduleSourceGenre,
+	ScheduleSourceRadio,
+}
+
+const (
+	schedulerCheckInterval = 15 * time.Second
+	volumeRampInterval     = 2 * time.Second
+)
+
+var ErrScheduleOtherServer = errors.New("the scheduled source is on a different server")
+
+// The player controls the Scheduler uses to set the volume.
+type SchedulerPlayer interface {
+	GetVolume() int
+	SetVolume(vol int) error
+	GetStatus() player.Status
+}
+
+var _ SchedulerPlayer = (*player.Player)(nil)
+
+// Scheduler starts playback of a playlist, album, genre mix or radio
+// station at the times configured in the SchedulerConfig, and saves smart
+// playlists to the server at their configured intervals. It runs in
+// the background, independently of whether the main window is shown.
+type Scheduler struct {
+	pm     *PlaybackManager
+	sm     *ServerManager
+	lm     *LibraryManager
+	player SchedulerPlayer
+	config *SchedulerConfig
+
+	// the clock and volume ramp interval, replaced in tests
+	now          func() time.Time
+	rampInterval time.Duration
+
+	// guards config.Schedules, lastRun and rampCancel, since
+	// schedules are edited from the UI while the ticker checks them
+	lock          sync.Mutex
+	lastRun       map[uuid.UUID]time.Time
+	rampCancel    context.CancelFunc
+	materializing atomic.Bool
+
+	onVolumeChanged []func(int)
+}
+
+func NewScheduler(pm *PlaybackManager, sm *ServerManager, lm *LibraryManager, p SchedulerPlayer, config *SchedulerConfig) *Scheduler {
+	return &Scheduler{
+		pm:           pm,
+		sm:           sm,
+		lm:           lm,
+		player:       p,
+		config:       config,
+		now:          time.Now,
+		rampInterval: volumeRampInterval,
+		lastRun:      make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Registers a callback that is notified when the scheduler
+// changes the volume while ramping it up.
+func (s *Scheduler) OnVolumeChanged(cb func(int)) {
+	s.onVolumeChanged = append(s.onVolumeChanged, cb)
+}
+
+// Starts checking for due schedules in the background until ctx is canceled.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		t := time.NewTicker(schedulerCheckInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C:
+				s.runDueSchedules(now)
+				s.materializeDueSmartPlaylists(now)
+			}
+		}
+	}()
+}
+
+// Returns copies of the configured schedules.
+func (s *Scheduler) Schedules() []*ScheduledPlayback {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	schedules := make([]*ScheduledPlayback, len(s.config.Schedules))
+	for i, sched := range s.config.Schedules {
+		sched := *sched
+		schedules[i] = &sched
+	}
+	return schedules
+}
+
+// Adds a new schedule with a new ID to the config.
+func (s *Scheduler) AddSchedule(sched ScheduledPlayback) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sched.ID = uuid.New()
+	s.config.Schedules = append(s.config.Schedules, &sched)
+}
+
+// Replaces the schedule with the same ID as the given one.
+func (s *Scheduler) UpdateSchedule(updated ScheduledPlayback) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, sched := range s.config.Schedules {
+		if sched.ID == updated.ID {
+			*sched = updated
+			return
+		}
+	}
+}
+
+func (s *Scheduler) DeleteSchedule(id uuid.UUID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, sched := range s.config.Schedules {
+		if sched.ID == id {
+			s.config.Schedules = append(s.config.Schedules[:i], s.config.Schedules[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Scheduler) runDueSchedules(now time.Time) {
+	minute := now.Truncate(time.Minute)
+	var due []ScheduledPlayback
+	s.lock.Lock()
+	for _, sched := range s.config.Schedules {
+		if !sched.Enabled || !sched.IsDue(now) || s.lastRun[sched.ID].Equal(minute) {
+			continue
+		}
+		s.lastRun[sched.ID] = minute
+		due = append(due, *sched)
+	}
+	s.lock.Unlock()
+	for i := range due {
+		if err := s.Run(&due[i]); err != nil {
+			log.Printf("error starting scheduled playback: %s", err.Error())
+		}
+	}
+}
+
+// Starts playback of the schedule's source now.
+func (s *Scheduler) Run(sched *ScheduledPlayback) error {
+	if s.sm.Server() == nil {
+		return ErrUnreachable
+	}
+	if sched.ServerID != uuid.Nil && sched.ServerID != s.sm.ServerID() {
+		return ErrScheduleOtherServer
+	}
+	s.cancelRamp()
+	target := s.player.GetVolume()
+	if sched.Volume > 0 {
+		target = clamp(sched.Volume, 0, 100)
+	}
+	if sched.RampMinutes > 0 {
+		s.setVolume(0)
+	} else {
+		s.setVolume(target)
+	}
+
+	var err error
+	switch sched.SourceType {
+	case ScheduleSourcePlaylist:
+		err = s.pm.PlayPlaylist(sched.SourceID, 0, ShuffleModeFromBool(sched.Shuffle))
+	case ScheduleSourceAlbum:
+		err = s.pm.PlayAlbum(sched.SourceID, 0, ShuffleModeFromBool(sched.Shuffle))
+	case ScheduleSourceGenre:
+		err = s.playGenreMix(sched.SourceID)
+	case ScheduleSourceRadio:
+		err = s.playRadioStation(sched.SourceID)
+	default:
+		err = fmt.Errorf("unknown schedule source type %q", sched.SourceType)
+	}
+	if err != nil {
+		s.setVolume(target)
+		return err
+	}
+	if sched.RampMinutes > 0 {
+		s.startRamp(target, time.Duration(sched.RampMinutes)*time.Minute)
+	}
+	return nil
+}
+
+func (s *Scheduler) playGenreMix(genre string) error {
+	songs, err := s.sm.Server().GetRandomSongs(map[string]string{"size": "100", "genre": genre})
+	if err != nil {
+		return err
+	}
+	if err := s.pm.LoadTracks(songs, false, ShuffleNone); err != nil {
+		return err
+	}
+	return s.pm.PlayFromBeginning()
+}
+
+func (s *Scheduler) playRadioStation(streamURL string) error {
+	stations, err := s.lm.GetRadioStations()
+	if err != nil {
+		return err
+	}
+	for _, st := range stations {
+		if st.StreamUrl == streamURL {
+			return s.pm.PlayRadioStation(st)
+		}
+	}
+	return errors.New("radio station not found on server")
+}
+
+// Saves the smart playlists of the connected server that are due to the
+// server in the background. Only one batch is saved at a time, since
+// evaluating the playlists may need to fetch the whole library.
+func (s *Scheduler) materializeDueSmartPlaylists(now time.Time) {
+	if s.materializing.Load() || s.sm.Server() == nil {
+		return
+	}
+	var due []*SmartPlaylist
+	for _, sp := range s.lm.SmartPlaylists() {
+		if sp.MaterializeDue(now) {
+			due = append(due, sp)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+	s.materializing.Store(true)
+	go func() {
+		for _, sp := range due {
+			if err := s.lm.MaterializeSmartPlaylist(sp); err != nil {
+				log.Printf("error saving smart playlist: %s", err.Error())
+				// retry at the next interval rather than on every check
+				s.lm.updateStoredSmartPlaylist(sp.ID, func(sp *SmartPlaylist) {
+					sp.LastMaterialized = now
+				})
+			}
+		}
+		s.materializing.Store(false)
+	}()
+}
+
+// Raises the volume from 0 to target over the given duration. Stops if the
+// volume is changed by the user in the meantime, and jumps to the target
+// if playback is paused or stopped, so it resumes at the target volume.
+func (s *Scheduler) startRamp(target int, dur time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.lock.Lock()
+	if s.rampCancel != nil {
+		s.rampCancel()
+	}
+	s.rampCancel = cancel
+	s.lock.Unlock()
+	start := s.now()
+	go func() {
+		t := time.NewTicker(s.rampInterval)
+		defer t.Stop()
+		lastSet := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if s.player.GetVolume() != lastSet {
+					return // changed by the user
+				}
+				frac := float64(s.now().Sub(start)) / float64(dur)
+				if frac >= 1 || s.player.GetStatus().State != player.Playing {
+					s.setVolume(target)
+					return
+				}
+				if vol := int(float64(target) * frac); vol != lastSet {
+					lastSet = vol
+					s.setVolume(vol)
+				}
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) cancelRamp() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.rampCancel != nil {
+		s.rampCancel()
+		s.rampCancel = nil
+	}
+}
+
+func (s *Scheduler) setVolume(vol int) {
+	s.player.SetVolume(vol)
+	for _, cb := range s.onVolumeChanged {
+		cb(vol)
+	}
+}
+
+// Reports whether the schedule should start at the given time (to the minute).
+func (sp *ScheduledPlayback) IsDue(t time.Time) bool {
+	if t.Hour() != sp.Hour || t.Minute() != sp.Minute {
+		return false
+	}
+	return sp.runsOn(t.Weekday())
+}
+
+func (sp *ScheduledPlayback) runsOn(day time.Weekday) bool {
+	for _, d := range sp.Weekdays {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the next time after t that the schedule will start,
+// or false if it does not run on any day.
+func (sp *ScheduledPlayback) NextRun(t time.Time) (time.Time, bool) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), sp.Hour, sp.Minute, 0, 0, t.Location())
+	for i := 0; i < 8; i++ {
+		next := day.AddDate(0, 0, i)
+		if next.After(t) && sp.runsOn(next.Weekday()) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns a short description of when the schedule runs, e.g. "07:30 Mon-Fri".
+func (sp *ScheduledPlayback) TimeDescription() string {
+	var days [7]bool
+	for _, d := range sp.Weekdays {
+		if d >= 0 && d < 7 {
+			days[d] = true
+		}
+	}
+	var dayDesc string
+	switch days {
+	case [7]bool{true, true, true, true, true, true, true}:
+		dayDesc = "every day"
+	case [7]bool{false, true, true, true, true, true, false}:
+		dayDesc = "Mon-Fri"
+	case [7]bool{true, false, false, false, false, false, true}:
+		dayDesc = "Sat-Sun"
+	default:
+		var names []string
+		// list starting from Monday
+		for i := 1; i <= 7; i++ {
+			if d := i % 7; days[d] {
+				names = append(names, time.Weekday(d).String()[:3])
+			}
+		}
+		dayDesc = strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%02d:%02d %s", sp.Hour, sp.Minute, dayDesc)
+}
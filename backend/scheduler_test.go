@@ -0,0 +1,180 @@
+package backend
+
+import (
+	"supersonic/player"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// Monday, 7:00
+var testScheduleTime = time.Date(2023, 6, 5, 7, 0, 0, 0, time.Local)
+
+func newTestScheduler(t *testing.T) (*Scheduler, *fakePlayer, *fakeServer, *fakeClock) {
+	t.Helper()
+	pm, p, srv := newFakePlayerPlaybackManager(t, ScrobbleConfig{})
+	lm := NewLibraryManager(pm.sm, &SmartPlaylistsConfig{})
+	s := NewScheduler(pm, pm.sm, lm, p, &SchedulerConfig{})
+	clock := &fakeClock{t: testScheduleTime}
+	s.now = clock.Now
+	s.rampInterval = time.Millisecond
+	t.Cleanup(s.cancelRamp)
+	return s, p, srv, clock
+}
+
+func testSchedule(albumID string) ScheduledPlayback {
+	return ScheduledPlayback{
+		Enabled:    true,
+		Hour:       7,
+		Weekdays:   []int{1, 2, 3, 4, 5},
+		SourceType: ScheduleSourceAlbum,
+		SourceID:   albumID,
+	}
+}
+
+// Waits for the ramp to set the player volume to want.
+func waitForVolume(t *testing.T, p *fakePlayer, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for p.GetVolume() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("volume = %d, want %d", p.GetVolume(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduleIsDue(t *testing.T) {
+	sched := testSchedule("al-1")
+	for _, tt := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{testScheduleTime, true},
+		{testScheduleTime.Add(45 * time.Second), true},
+		{testScheduleTime.Add(-time.Minute), false},
+		{testScheduleTime.Add(time.Minute), false},
+		{testScheduleTime.AddDate(0, 0, 5), false}, // Saturday
+	} {
+		if got := sched.IsDue(tt.t); got != tt.want {
+			t.Errorf("IsDue(%s) = %t, want %t", tt.t, got, tt.want)
+		}
+	}
+	if next, _ := sched.NextRun(testScheduleTime.AddDate(0, 0, 4)); !next.Equal(testScheduleTime.AddDate(0, 0, 7)) {
+		t.Errorf("next run after Friday's = %s, want the following Monday", next)
+	}
+}
+
+func TestSchedulerRunsDueSchedules(t *testing.T) {
+	s, p, _, _ := newTestScheduler(t)
+	s.AddSchedule(testSchedule("al-2"))
+	disabled := testSchedule("al-1")
+	disabled.Enabled = false
+	disabled.Hour = 6
+	s.AddSchedule(disabled)
+
+	s.runDueSchedules(testScheduleTime.Add(-time.Minute))
+	if len(s.pm.GetPlayQueue()) != 0 {
+		t.Fatal("schedule started before its time")
+	}
+	s.runDueSchedules(testScheduleTime.Add(10 * time.Second))
+	queue := s.pm.GetPlayQueue()
+	if len(queue) != 2 || queue[0].ID != "tr-3" {
+		t.Fatalf("got play queue %v, want the album's tracks", queue)
+	}
+	if p.GetStatus().State != player.Playing {
+		t.Error("scheduled playback not started")
+	}
+}
+
+func TestSchedulerOverlappingSchedules(t *testing.T) {
+	s, p, srv, _ := newTestScheduler(t)
+	first := testSchedule("al-1")
+	first.Volume = 40
+	second := testSchedule("al-2")
+	second.Volume = 60
+	s.AddSchedule(first)
+	s.AddSchedule(second)
+
+	// both start, in order, so the later schedule's source and volume win
+	s.runDueSchedules(testScheduleTime.Add(5 * time.Second))
+	if n := srv.Requests("getAlbum"); n != 2 {
+		t.Errorf("got %d getAlbum requests, want 2", n)
+	}
+	if queue := s.pm.GetPlayQueue(); len(queue) == 0 || queue[0].AlbumID != "al-2" {
+		t.Errorf("got play queue %v, want the second schedule's album", queue)
+	}
+	if vol := p.GetVolume(); vol != 60 {
+		t.Errorf("volume = %d, want 60", vol)
+	}
+
+	// each schedule runs only once per minute
+	s.runDueSchedules(testScheduleTime.Add(20 * time.Second))
+	if n := srv.Requests("getAlbum"); n != 2 {
+		t.Errorf("schedules started again in the same minute")
+	}
+}
+
+func TestSchedulerVolumeRamp(t *testing.T) {
+	s, p, _, clock := newTestScheduler(t)
+	sched := testSchedule("al-1")
+	sched.Volume = 80
+	sched.RampMinutes = 1
+	if err := s.Run(&sched); err != nil {
+		t.Fatalf("Run: %s", err.Error())
+	}
+	waitForVolume(t, p, 0)
+	clock.Advance(30 * time.Second)
+	waitForVolume(t, p, 40)
+	clock.Advance(30 * time.Second)
+	waitForVolume(t, p, 80)
+}
+
+func TestSchedulerVolumeRampInterrupted(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		do   func(p *fakePlayer)
+		want int
+	}{
+		{"paused", func(p *fakePlayer) { p.FadeOutAndPause(0) }, 80},
+		{"stopped", func(p *fakePlayer) { p.Stop() }, 80},
+		{"volume changed", func(p *fakePlayer) { p.SetVolume(55) }, 55},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, p, _, clock := newTestScheduler(t)
+			sched := testSchedule("al-1")
+			sched.Volume = 80
+			sched.RampMinutes = 1
+			if err := s.Run(&sched); err != nil {
+				t.Fatalf("Run: %s", err.Error())
+			}
+			clock.Advance(15 * time.Second)
+			waitForVolume(t, p, 20)
+			tt.do(p)
+			waitForVolume(t, p, tt.want)
+			// the ramp has ended
+			clock.Advance(15 * time.Second)
+			time.Sleep(20 * time.Millisecond)
+			if vol := p.GetVolume(); vol != tt.want {
+				t.Errorf("volume = %d after the ramp ended, want %d", vol, tt.want)
+			}
+		})
+	}
+}
@@ -12,22 +12,28 @@ type searchIterBase struct {
 	artistOffset int
 	albumOffset  int
 	songOffset   int
-	s            *subsonic.Client
+	sm           *ServerManager
 }
 
-func (s *searchIterBase) fetchResults() *subsonic.SearchResult3 {
+// Fetches the next page of search results, along with the client
+// they were fetched from, for any follow-up requests.
+func (s *searchIterBase) fetchResults() (*subsonic.SearchResult3, *subsonic.Client) {
+	cli := s.sm.Server()
+	if cli == nil {
+		return nil, nil
+	}
 	searchOpts := map[string]string{
 		"artistOffset": strconv.Itoa(s.artistOffset),
 		"albumOffset":  strconv.Itoa(s.albumOffset),
 		"songOffset":   strconv.Itoa(s.songOffset),
 	}
-	results, err := s.s.Search3(s.query, searchOpts)
+	results, err := cli.Search3(s.query, searchOpts)
 	if err != nil {
 		log.Println(err)
 		results = nil
 	}
 	if results == nil || len(results.Album)+len(results.Artist)+len(results.Song) == 0 {
-		return nil
+		return nil, nil
 	}
-	return results
+	return results, cli
 }
@@ -3,36 +3,65 @@ package backend
 import (
 	"context"
 	"errors"
-	"net/http"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/dweymouth/go-subsonic/subsonic"
 	"github.com/google/uuid"
-	"github.com/zalando/go-keyring"
 )
 
 type ServerManager struct {
-	ServerID uuid.UUID
-	Server   *subsonic.Client
+	appName     string
+	credentials CredentialStore
+	monitor     *connectionMonitor
+
+	// guards the connected client and the connection state below,
+	// which the connection monitor reads from its own goroutine
+	connLock   sync.RWMutex
+	server     *subsonic.Client
+	serverID   uuid.UUID
+	connection ServerConnection
+	password   string
+	serverInfo ServerInfo
 
-	appName           string
 	onServerConnected []func()
 	onLogout          []func()
+	onHostnameChanged []func()
 }
 
-var ErrUnreachable = errors.New("server is unreachable")
+var (
+	ErrUnreachable             = errors.New("server is unreachable")
+	ErrInvalidConnectionOption = errors.New("invalid connection option")
+)
 
-func NewServerManager(appName string) *ServerManager {
-	return &ServerManager{appName: appName}
+func NewServerManager(ctx context.Context, appName string) *ServerManager {
+	s := &ServerManager{appName: appName, credentials: NewKeyringCredentialStore(appName)}
+	s.monitor = newConnectionMonitor(s)
+	s.monitor.Start(ctx)
+	return s
 }
 
 func (s *ServerManager) ConnectToServer(conf *ServerConfig, password string) error {
-	cli, err := s.testConnectionAndCreateClient(conf.ServerConnection, password)
+	cli, info, err := s.testConnectionAndCreateClient(conf.ServerConnection, password)
 	if err != nil {
 		return err
 	}
-	s.Server = cli
-	s.ServerID = conf.ID
+	if info.OpenSubsonic {
+		log.Printf("Connected to OpenSubsonic server %s %s (API %s) with %d extensions",
+			info.Type, info.ServerVersion, info.APIVersion, len(info.Extensions))
+	}
+	cli.Client.Transport = s.monitor.WrapTransport(cli.Client.Transport)
+	s.connLock.Lock()
+	s.serverInfo = info
+	s.connection = conf.ServerConnection
+	s.password = password
+	s.server = cli
+	s.serverID = conf.ID
+	s.connLock.Unlock()
+	s.monitor.Reset()
 	for _, cb := range s.onServerConnected {
 		cb()
 	}
@@ -45,7 +74,7 @@ func (s *ServerManager) TestConnectionAndAuth(
 	err := ErrUnreachable
 	done := make(chan bool)
 	go func() {
-		_, err = s.testConnectionAndCreateClient(connection, password)
+		_, _, err = s.testConnectionAndCreateClient(connection, password)
 		close(done)
 	}()
 	t := time.NewTimer(timeout)
@@ -58,32 +87,30 @@ func (s *ServerManager) TestConnectionAndAuth(
 	}
 }
 
-func (s *ServerManager) testConnectionAndCreateClient(connection ServerConnection, password string) (*subsonic.Client, error) {
+func (s *ServerManager) testConnectionAndCreateClient(connection ServerConnection, password string) (*subsonic.Client, ServerInfo, error) {
 	cli, err := s.connect(connection, password)
 	if err != nil {
-		return nil, err
+		return nil, ServerInfo{}, err
 	}
 	if err := cli.Authenticate(password); err != nil {
-		return nil, err
+		return nil, ServerInfo{}, err
+	}
+	info, err := fetchServerInfo(cli)
+	if err != nil {
+		log.Printf("error fetching server info: %s", err.Error())
 	}
-	return cli, nil
+	if connection.APIKeyAuth && !info.HasExtension(ExtensionAPIKeyAuth) {
+		return nil, ServerInfo{}, ErrAPIKeyAuthUnsupported
+	}
+	return cli, info, nil
 }
 
 func (s *ServerManager) connect(connection ServerConnection, password string) (*subsonic.Client, error) {
-	cli := &subsonic.Client{
-		Client:       &http.Client{},
-		BaseUrl:      connection.Hostname,
-		User:         connection.Username,
-		PasswordAuth: connection.LegacyAuth,
-		ClientName:   "supersonic",
-	}
-	altCli := &subsonic.Client{
-		Client:       &http.Client{},
-		BaseUrl:      connection.AltHostname,
-		User:         connection.Username,
-		PasswordAuth: connection.LegacyAuth,
-		ClientName:   "supersonic",
+	cli, err := newSubsonicClient(connection, connection.Hostname, password)
+	if err != nil {
+		return nil, err
 	}
+	altCli, _ := newSubsonicClient(connection, connection.AltHostname, password)
 	pingChan := make(chan bool, 2) // false for primary hostname, true for alternate
 	pingFunc := func(delay time.Duration, cli *subsonic.Client, val bool) {
 		<-time.After(delay)
@@ -109,15 +136,115 @@ func (s *ServerManager) connect(connection ServerConnection, password string) (*
 	}
 }
 
+// Creates a (not yet authenticated) client for the given hostname of the server connection.
+func newSubsonicClient(connection ServerConnection, hostname, password string) (*subsonic.Client, error) {
+	httpCli, err := newHTTPClient(connection)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConnectionOption, err.Error())
+	}
+	if connection.APIKeyAuth {
+		httpCli.Transport = &apiKeyTransport{base: httpCli.Transport, apiKey: password}
+	}
+	return &subsonic.Client{
+		Client:       httpCli,
+		BaseUrl:      hostname,
+		User:         connection.Username,
+		PasswordAuth: connection.LegacyAuth,
+		ClientName:   "supersonic",
+	}, nil
+}
+
 func (s *ServerManager) Logout() {
-	if s.Server != nil {
-		keyring.Delete(s.appName, s.ServerID.String())
+	if cli, _, _ := s.connectionState(); cli != nil {
+		s.credentials.Delete(s.ServerID().String())
 		for _, cb := range s.onLogout {
 			cb()
 		}
-		s.Server = nil
-		s.ServerID = uuid.UUID{}
+		s.connLock.Lock()
+		defer s.connLock.Unlock()
+		s.server = nil
+		s.serverID = uuid.UUID{}
+		s.connection = ServerConnection{}
+		s.password = ""
+		s.serverInfo = ServerInfo{}
+	}
+}
+
+// Returns the client for the connected server, or nil if not connected.
+// The client is replaced when the connection switches hostnames,
+// so callers should fetch it for each request rather than keep it.
+func (s *ServerManager) Server() *subsonic.Client {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.server
+}
+
+// Returns the ID of the connected server's config.
+func (s *ServerManager) ServerID() uuid.UUID {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.serverID
+}
+
+// Returns the connected client and the options and password it was
+// connected with, or a nil client if not connected.
+func (s *ServerManager) connectionState() (*subsonic.Client, ServerConnection, string) {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.server, s.connection, s.password
+}
+
+// Replaces the client with one connected to the given base URL, unless
+// the connected client is no longer oldCli (e.g. after logging out).
+// The client is copied rather than modified, since it may be in use.
+// Returns true if the client was replaced.
+func (s *ServerManager) switchBaseURL(oldCli *subsonic.Client, baseURL string) bool {
+	s.connLock.Lock()
+	defer s.connLock.Unlock()
+	if s.server == nil || s.server != oldCli {
+		return false
 	}
+	cli := *oldCli
+	cli.BaseUrl = baseURL
+	s.server = &cli
+	return true
+}
+
+// Returns information about the connected server, including
+// whether it is an OpenSubsonic server and which API extensions it supports.
+func (s *ServerManager) ServerInfo() ServerInfo {
+	s.connLock.RLock()
+	defer s.connLock.RUnlock()
+	return s.serverInfo
+}
+
+// Returns true if the connected server advertises
+// support for the given OpenSubsonic extension.
+func (s *ServerManager) HasExtension(name string) bool {
+	return s.ServerInfo().HasExtension(name)
+}
+
+// Returns true if connected to the server via the alternate hostname.
+func (s *ServerManager) UsingAltHostname() bool {
+	cli, conn, _ := s.connectionState()
+	return cli != nil && conn.AltHostname != "" &&
+		cli.BaseUrl == conn.AltHostname && cli.BaseUrl != conn.Hostname
+}
+
+// Returns the URL to stream the given track from the connected server.
+func (s *ServerManager) StreamURL(trackID string, params map[string]string) (*url.URL, error) {
+	cli, conn, password := s.connectionState()
+	if cli == nil {
+		return nil, ErrUnreachable
+	}
+	u, err := cli.GetStreamURL(trackID, params)
+	if err != nil {
+		return nil, err
+	}
+	if conn.APIKeyAuth {
+		u.RawQuery = replaceAuthParamsWithAPIKey(u.Query(), password).Encode()
+	}
+	return u, nil
 }
 
 // Sets a callback that is invoked when a server is connected to.
@@ -130,10 +257,27 @@ func (s *ServerManager) OnLogout(cb func()) {
 	s.onLogout = append(s.onLogout, cb)
 }
 
+// Sets a callback that is invoked when the connection to the server has been
+// switched between the primary and alternate hostnames during a session.
+// It is invoked from the connection monitor's goroutine.
+func (s *ServerManager) OnHostnameChanged(cb func()) {
+	s.onHostnameChanged = append(s.onHostnameChanged, cb)
+}
+
+// Sets the backend used to store server passwords.
+func (s *ServerManager) SetCredentialStore(store CredentialStore) {
+	s.credentials = store
+}
+
+// Returns the backend used to store server passwords.
+func (s *ServerManager) CredentialStore() CredentialStore {
+	return s.credentials
+}
+
 func (s *ServerManager) GetServerPassword(server *ServerConfig) (string, error) {
-	return keyring.Get(s.appName, server.ID.String())
+	return s.credentials.Get(server.ID.String())
 }
 
 func (s *ServerManager) SetServerPassword(server *ServerConfig, password string) error {
-	return keyring.Set(s.appName, server.ID.String(), password)
+	return s.credentials.Set(server.ID.String(), password)
 }
@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestServerManager(t *testing.T) *ServerManager {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewServerManager(ctx, "supersonic-test")
+}
+
+// Returns the URL of a fake server that is up, or one that has been shut down.
+func testHostname(t *testing.T, up bool) string {
+	t.Helper()
+	srv := newFakeServer(t)
+	if !up {
+		srv.Close()
+	}
+	return srv.URL
+}
+
+func TestConnectToServerFailover(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		primary bool // whether the primary hostname is up
+		alt     bool // whether the alternate hostname is up
+		noAlt   bool // whether there is no alternate hostname
+		wantAlt bool
+	}{
+		{"primary only", true, false, true, false},
+		{"both up", true, true, false, false},
+		{"alternate down", true, false, false, false},
+		{"primary down", false, true, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := ServerConnection{
+				Hostname: testHostname(t, tt.primary),
+				Username: fakeServerUser,
+			}
+			if !tt.noAlt {
+				conn.AltHostname = testHostname(t, tt.alt)
+			}
+			sm := newTestServerManager(t)
+			if err := sm.ConnectToServer(&ServerConfig{ServerConnection: conn}, fakeServerPassword); err != nil {
+				t.Fatalf("ConnectToServer: %s", err.Error())
+			}
+			if got := sm.UsingAltHostname(); got != tt.wantAlt {
+				t.Errorf("using alternate hostname = %t, want %t", got, tt.wantAlt)
+			}
+		})
+	}
+}
+
+func TestConnectToServerWrongPassword(t *testing.T) {
+	sm := newTestServerManager(t)
+	conn := ServerConnection{Hostname: testHostname(t, true), Username: fakeServerUser}
+	if err := sm.ConnectToServer(&ServerConfig{ServerConnection: conn}, "wrong"); err == nil {
+		t.Error("ConnectToServer succeeded with the wrong password")
+	}
+	if sm.Server() != nil {
+		t.Error("server set after failed connection")
+	}
+}
+
+// Switches to the alternate hostname after repeated request failures.
+func TestConnectionMonitorFailover(t *testing.T) {
+	primary := newFakeServer(t)
+	conn := ServerConnection{
+		Hostname:    primary.URL,
+		AltHostname: testHostname(t, true),
+		Username:    fakeServerUser,
+	}
+	sm := newTestServerManager(t)
+	changed := make(chan struct{}, 1)
+	sm.OnHostnameChanged(func() { changed <- struct{}{} })
+	if err := sm.ConnectToServer(&ServerConfig{ServerConnection: conn}, fakeServerPassword); err != nil {
+		t.Fatalf("ConnectToServer: %s", err.Error())
+	}
+	if sm.UsingAltHostname() {
+		t.Fatal("connected via alternate hostname while primary is up")
+	}
+
+	oldCli := sm.Server()
+	primary.Close()
+	for i := 0; i < monitorFailureThreshold; i++ {
+		if sm.Server().Ping() {
+			t.Fatal("ping succeeded after primary hostname went down")
+		}
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hostname not switched after repeated failures")
+	}
+	if !sm.UsingAltHostname() {
+		t.Error("not using alternate hostname after failover")
+	}
+	if !sm.Server().Ping() {
+		t.Error("ping via alternate hostname failed")
+	}
+	if oldCli.BaseUrl != primary.URL {
+		t.Error("client in use was modified instead of replaced")
+	}
+}
+
+// A re-check that finishes after the client was replaced,
+// e.g. by logging out or connecting again, must not switch it.
+func TestSwitchBaseURLStaleClient(t *testing.T) {
+	sm := newTestServerManager(t)
+	conn := ServerConnection{Hostname: testHostname(t, true), Username: fakeServerUser}
+	if err := sm.ConnectToServer(&ServerConfig{ServerConnection: conn}, fakeServerPassword); err != nil {
+		t.Fatalf("ConnectToServer: %s", err.Error())
+	}
+	cli := sm.Server()
+	if !sm.switchBaseURL(cli, "http://alt.example") {
+		t.Fatal("client not switched")
+	}
+	if sm.Server().BaseUrl != "http://alt.example" || cli.BaseUrl != conn.Hostname {
+		t.Errorf("got base URLs %s (new) and %s (old)", sm.Server().BaseUrl, cli.BaseUrl)
+	}
+	if sm.switchBaseURL(cli, "http://other.example") {
+		t.Error("stale client switched")
+	}
+}
@@ -0,0 +1,141 @@
+package backend
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"supersonic/backend/util"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// Strategy for the order in which LoadTracks loads tracks into the play queue.
+type ShuffleMode int
+
+const (
+	// Keep the original order
+	ShuffleNone ShuffleMode = iota
+	// Uniformly random order
+	ShuffleRandom
+	// Random order favoring highly rated, starred and often played tracks
+	ShuffleWeighted
+	// Random order keeping tracks by the same artist or from the same album apart
+	ShuffleSpread
+	// Random order of albums, keeping the track order within each album
+	ShuffleAlbums
+)
+
+// Returns ShuffleRandom if shuffle is true, otherwise ShuffleNone.
+func ShuffleModeFromBool(shuffle bool) ShuffleMode {
+	if shuffle {
+		return ShuffleRandom
+	}
+	return ShuffleNone
+}
+
+// Returns the order in which to load the tracks, as indexes into tracks.
+func shuffleOrder(tracks []*subsonic.Child, mode ShuffleMode) []int {
+	order := util.Range(len(tracks))
+	switch mode {
+	case ShuffleRandom:
+		util.ShuffleSlice(order)
+	case ShuffleWeighted:
+		weightedShuffle(tracks, order)
+	case ShuffleSpread:
+		order = spreadShuffle(tracks)
+	case ShuffleAlbums:
+		order = albumShuffle(tracks)
+	}
+	return order
+}
+
+// Shuffles the order so that tracks with a higher weight are more likely
+// to come first, using weighted random sampling without replacement.
+func weightedShuffle(tracks []*subsonic.Child, order []int) {
+	keys := make([]float64, len(tracks))
+	for i, tr := range tracks {
+		keys[i] = math.Pow(rand.Float64(), 1/shuffleWeight(tr))
+	}
+	sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] > keys[order[j]] })
+}
+
+// Returns the weight of a track for ShuffleWeighted. Tracks played more
+// often are favored, with diminishing returns.
+func shuffleWeight(tr *subsonic.Child) float64 {
+	return userPreferenceWeight(tr) * (1 + math.Log1p(float64(tr.PlayCount))/2)
+}
+
+// Spreads the tracks of each artist evenly across the play order,
+// starting at a random offset, and alternates between each artist's albums.
+func spreadShuffle(tracks []*subsonic.Child) []int {
+	byArtist := groupTracks(tracks, func(tr *subsonic.Child) string {
+		if tr.ArtistID != "" {
+			return tr.ArtistID
+		}
+		return tr.Artist
+	})
+	pos := make([]float64, len(tracks))
+	for _, group := range byArtist {
+		byAlbum := groupTracks(tracks, func(tr *subsonic.Child) string { return tr.AlbumID }, group...)
+		rand.Shuffle(len(byAlbum), func(i, j int) { byAlbum[i], byAlbum[j] = byAlbum[j], byAlbum[i] })
+		for _, album := range byAlbum {
+			util.ShuffleSlice(album)
+		}
+		offset := rand.Float64() / float64(len(group))
+		for i, idx := range interleave(byAlbum) {
+			pos[idx] = offset + float64(i)/float64(len(group))
+		}
+	}
+	order := util.Range(len(tracks))
+	sort.SliceStable(order, func(i, j int) bool { return pos[order[i]] < pos[order[j]] })
+	return order
+}
+
+// Shuffles the order of the albums, keeping the order of each album's tracks.
+func albumShuffle(tracks []*subsonic.Child) []int {
+	byAlbum := groupTracks(tracks, func(tr *subsonic.Child) string { return tr.AlbumID })
+	rand.Shuffle(len(byAlbum), func(i, j int) { byAlbum[i], byAlbum[j] = byAlbum[j], byAlbum[i] })
+	order := make([]int, 0, len(tracks))
+	for _, album := range byAlbum {
+		order = append(order, album...)
+	}
+	return order
+}
+
+// Groups the indexes of the tracks by key, in order of first appearance.
+// If idxs is given, only those tracks are grouped.
+func groupTracks(tracks []*subsonic.Child, key func(*subsonic.Child) string, idxs ...int) [][]int {
+	if idxs == nil {
+		idxs = util.Range(len(tracks))
+	}
+	var groups [][]int
+	groupIdx := make(map[string]int)
+	for _, i := range idxs {
+		k := key(tracks[i])
+		g, ok := groupIdx[k]
+		if !ok {
+			g = len(groups)
+			groupIdx[k] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// Takes one item from each group in turn until all are exhausted.
+func interleave(groups [][]int) []int {
+	var result []int
+	for i := 0; ; i++ {
+		more := false
+		for _, g := range groups {
+			if i < len(g) {
+				result = append(result, g[i])
+				more = true
+			}
+		}
+		if !more {
+			return result
+		}
+	}
+}
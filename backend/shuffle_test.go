@@ -0,0 +1,95 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// Returns tracks of the given number of artists, with the given
+// number of albums per artist and tracks per album.
+func shuffleTestTracks(artists, albums, tracks int) []*subsonic.Child {
+	var result []*subsonic.Child
+	for ar := 0; ar < artists; ar++ {
+		for al := 0; al < albums; al++ {
+			for tr := 0; tr < tracks; tr++ {
+				result = append(result, &subsonic.Child{
+					ID:       fmt.Sprintf("%d-%d-%d", ar, al, tr),
+					ArtistID: fmt.Sprint(ar),
+					AlbumID:  fmt.Sprintf("%d-%d", ar, al),
+					Track:    tr + 1,
+				})
+			}
+		}
+	}
+	return result
+}
+
+func TestShuffleOrderIsPermutation(t *testing.T) {
+	tracks := shuffleTestTracks(3, 2, 4)
+	for _, mode := range []ShuffleMode{ShuffleNone, ShuffleRandom, ShuffleWeighted, ShuffleSpread, ShuffleAlbums} {
+		order := shuffleOrder(tracks, mode)
+		sorted := append([]int(nil), order...)
+		sort.Ints(sorted)
+		for i, idx := range sorted {
+			if idx != i {
+				t.Errorf("mode %d: order %v is not a permutation", mode, order)
+				break
+			}
+		}
+	}
+}
+
+func TestSpreadShuffle(t *testing.T) {
+	tracks := shuffleTestTracks(3, 2, 2)
+	for n := 0; n < 50; n++ {
+		order := shuffleOrder(tracks, ShuffleSpread)
+		for i := 1; i < len(order); i++ {
+			if a, b := tracks[order[i-1]], tracks[order[i]]; a.ArtistID == b.ArtistID {
+				t.Fatalf("tracks %s and %s by the same artist are adjacent", a.ID, b.ID)
+			}
+		}
+		// each artist's tracks alternate between the artist's albums
+		last := make(map[string]string)
+		for _, idx := range order {
+			tr := tracks[idx]
+			if last[tr.ArtistID] == tr.AlbumID {
+				t.Fatalf("consecutive tracks by artist %s are from the same album", tr.ArtistID)
+			}
+			last[tr.ArtistID] = tr.AlbumID
+		}
+	}
+}
+
+func TestAlbumShuffle(t *testing.T) {
+	tracks := shuffleTestTracks(2, 3, 4)
+	order := shuffleOrder(tracks, ShuffleAlbums)
+	for i := 0; i < len(order); i += 4 {
+		album := tracks[order[i]].AlbumID
+		for j := 0; j < 4; j++ {
+			if tr := tracks[order[i+j]]; tr.AlbumID != album || tr.Track != j+1 {
+				t.Fatalf("track %s at position %d, want track %d of album %s", tr.ID, i+j, j+1, album)
+			}
+		}
+	}
+}
+
+func TestWeightedShuffle(t *testing.T) {
+	tracks := []*subsonic.Child{
+		{ID: "disliked", UserRating: 1},
+		{ID: "loved", UserRating: 5, Starred: time.Now(), PlayCount: 20},
+	}
+	const runs = 1000
+	lovedFirst := 0
+	for n := 0; n < runs; n++ {
+		if order := shuffleOrder(tracks, ShuffleWeighted); order[0] == 1 {
+			lovedFirst++
+		}
+	}
+	if lovedFirst < runs*9/10 {
+		t.Errorf("loved track first in %d of %d shuffles, want at least 90%%", lovedFirst, runs)
+	}
+}
@@ -0,0 +1,175 @@
+package backend
+
+import (
+	"log"
+	"time"
+)
+
+type SleepTimerMode int
+
+const (
+	SleepTimerOff SleepTimerMode = iota
+	// Pause after a set duration, fading out the volume
+	SleepTimerDuration
+	// Stop at the end of the current track
+	SleepTimerEndOfTrack
+	// Stop at the end of the last consecutive track of the current album
+	SleepTimerEndOfAlbum
+	// Stop at the end of the play queue
+	SleepTimerEndOfQueue
+)
+
+// Duration of the volume fade-out at the end of a SleepTimerDuration timer.
+const sleepTimerFadeSeconds = 15
+
+// Sets the sleep timer. The duration is used only for SleepTimerDuration.
+func (p *PlaybackManager) SetSleepTimer(mode SleepTimerMode, duration time.Duration) {
+	p.lock()
+	defer p.unlock()
+	p.cancelSleepTimer()
+	p.sleepMode = mode
+	if mode == SleepTimerDuration {
+		p.sleepDeadline = time.Now().Add(duration)
+		fadeStart := duration - sleepTimerFadeSeconds*time.Second
+		if fadeStart < 0 {
+			fadeStart = 0
+		}
+		p.sleepTimer = time.AfterFunc(fadeStart, func() {
+			p.lock()
+			defer p.unlock()
+			fade := time.Until(p.sleepDeadline)
+			if err := p.player.FadeOutAndPause(fade.Seconds()); err != nil {
+				log.Printf("error pausing for sleep timer: %s", err.Error())
+			}
+			p.sleepTimer = time.AfterFunc(fade, func() {
+				p.lock()
+				defer p.unlock()
+				p.sleepMode = SleepTimerOff
+				p.updateStopAfterCurrent()
+				p.invokeOnSleepTimerChangedCallbacks()
+			})
+		})
+	}
+	p.updateStopAfterCurrent()
+	p.invokeOnSleepTimerChangedCallbacks()
+}
+
+func (p *PlaybackManager) SleepTimerMode() SleepTimerMode {
+	p.lock()
+	defer p.unlock()
+	return p.sleepMode
+}
+
+// Returns the wall-clock time until the sleep timer stops playback,
+// or false if the sleep timer is off or the time is unknown.
+// For the modes other than SleepTimerDuration, the time
+// is estimated assuming playback is not paused.
+func (p *PlaybackManager) SleepTimerRemaining() (time.Duration, bool) {
+	p.lock()
+	defer p.unlock()
+	if p.sleepMode == SleepTimerDuration {
+		return time.Until(p.sleepDeadline), true
+	}
+	if p.sleepMode == SleepTimerOff || p.nowPlaying() == nil {
+		return 0, false
+	}
+	status := p.player.GetStatus()
+	remaining := status.Duration - status.TimePos
+	last := p.nowPlayingIdx
+	switch p.sleepMode {
+	case SleepTimerEndOfAlbum:
+		last = p.lastTrackOfAlbumRun(p.nowPlayingIdx)
+	case SleepTimerEndOfQueue:
+		last = int64(len(p.playQueue)) - 1
+	}
+	for i := p.nowPlayingIdx + 1; i <= last; i++ {
+		remaining += float64(p.playQueue[i].Duration)
+	}
+	return time.Duration(remaining / p.player.GetSpeed() * float64(time.Second)), true
+}
+
+// Sets whether to stop playback at the end of the current track.
+// The setting is kept when skipping to other tracks, and is cleared
+// once playback has stopped.
+func (p *PlaybackManager) SetStopAfterCurrent(stop bool) {
+	p.lock()
+	defer p.unlock()
+	p.stopAfterCurrent = stop
+	p.updateStopAfterCurrent()
+	p.invokeOnSleepTimerChangedCallbacks()
+}
+
+func (p *PlaybackManager) StopAfterCurrent() bool {
+	p.lock()
+	defer p.unlock()
+	return p.stopAfterCurrent
+}
+
+// Registers a callback that is notified when the sleep timer
+// or stop after current track setting changes.
+func (p *PlaybackManager) OnSleepTimerChanged(cb func()) {
+	p.lock()
+	defer p.unlock()
+	p.onSleepTimerChanged = append(p.onSleepTimerChanged, cb)
+}
+
+func (p *PlaybackManager) cancelSleepTimer() {
+	if p.sleepTimer != nil {
+		p.sleepTimer.Stop()
+		p.sleepTimer = nil
+	}
+	// the timer may already have started fading out
+	p.player.CancelFadeOutAndPause()
+}
+
+// Arms the player to stop at the end of the current track if needed
+// for the stop after current setting or the sleep timer mode.
+// Must be called whenever the current track changes.
+func (p *PlaybackManager) updateStopAfterCurrent() {
+	stop := p.stopAfterCurrent || p.sleepMode == SleepTimerEndOfTrack
+	if p.sleepMode == SleepTimerEndOfAlbum && len(p.playQueue) > 0 {
+		stop = stop || p.lastTrackOfAlbumRun(p.nowPlayingIdx) == p.nowPlayingIdx
+	}
+	if stop != p.player.StopAfterCurrent() {
+		p.player.SetStopAfterCurrent(stop)
+	}
+}
+
+// Returns the index of the last track in the play queue in the run of
+// consecutive tracks from the same album as the track at idx.
+func (p *PlaybackManager) lastTrackOfAlbumRun(idx int64) int64 {
+	if idx < 0 || idx >= int64(len(p.playQueue)) {
+		return idx
+	}
+	albumID := p.playQueue[idx].AlbumID
+	for idx+1 < int64(len(p.playQueue)) && p.playQueue[idx+1].AlbumID == albumID {
+		idx++
+	}
+	return idx
+}
+
+// Invoked when the player has stopped at the end of a track.
+func (p *PlaybackManager) onStoppedAfterCurrent() {
+	p.stopAfterCurrent = false
+	if p.sleepMode == SleepTimerEndOfTrack || p.sleepMode == SleepTimerEndOfAlbum {
+		p.sleepMode = SleepTimerOff
+	}
+	p.invokeOnSleepTimerChangedCallbacks()
+}
+
+// Invoked when playback has stopped for any reason.
+func (p *PlaybackManager) clearEndOfQueueSleepTimer() {
+	if p.sleepMode == SleepTimerEndOfQueue {
+		p.sleepMode = SleepTimerOff
+		p.invokeOnSleepTimerChangedCallbacks()
+	}
+}
+
+func (p *PlaybackManager) invokeOnSleepTimerChangedCallbacks() {
+	if p.callbacksDisabled {
+		return
+	}
+	for _, cb := range p.onSleepTimerChanged {
+		p.queueCallback(cb)
+	}
+}
@@ -0,0 +1,352 @@
+package backend
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+	"github.com/google/uuid"
+)
+
+const (
+	SmartPlaylistSortRandom         = "Random"
+	SmartPlaylistSortTitle          = "Title"
+	SmartPlaylistSortArtist         = "Artist"
+	SmartPlaylistSortAlbum          = "Album"
+	SmartPlaylistSortNewest         = "Newest first"
+	SmartPlaylistSortOldest         = "Oldest first"
+	SmartPlaylistSortHighestRated   = "Highest rated"
+	SmartPlaylistSortMostPlayed     = "Most played"
+	SmartPlaylistSortLeastPlayed    = "Least played"
+	SmartPlaylistSortRecentlyPlayed = "Recently played"
+	SmartPlaylistSortRecentlyAdded  = "Recently added"
+)
+
+var SmartPlaylistSortOrders = []string{
+	SmartPlaylistSortRandom,
+	SmartPlaylistSortTitle,
+	SmartPlaylistSortArtist,
+	SmartPlaylistSortAlbum,
+	SmartPlaylistSortNewest,
+	SmartPlaylistSortOldest,
+	SmartPlaylistSortHighestRated,
+	SmartPlaylistSortMostPlayed,
+	SmartPlaylistSortLeastPlayed,
+	SmartPlaylistSortRecentlyPlayed,
+	SmartPlaylistSortRecentlyAdded,
+}
+
+// Returns a new smart playlist with the rules set to match any track.
+func NewSmartPlaylist(name string) SmartPlaylist {
+	return SmartPlaylist{
+		Name:             name,
+		SortOrder:        SmartPlaylistSortRandom,
+		Limit:            100,
+		MaterializeHours: 24,
+	}
+}
+
+// Reports whether the track satisfies all of the playlist's rules.
+// lastPlayed is the time the track was last played, or zero if never.
+func (sp *SmartPlaylist) Matches(tr *subsonic.Child, lastPlayed, now time.Time) bool {
+	if len(sp.Genres) > 0 && !containsFold(sp.Genres, tr.Genre) {
+		return false
+	}
+	if (sp.MinYear > 0 && tr.Year < sp.MinYear) || (sp.MaxYear > 0 && tr.Year > sp.MaxYear) {
+		return false
+	}
+	if tr.UserRating < sp.MinRating {
+		return false
+	}
+	if sp.StarredOnly && tr.Starred.IsZero() {
+		return false
+	}
+	if tr.PlayCount < int64(sp.MinPlayCount) || (sp.HasMaxPlayCount && tr.PlayCount > int64(sp.MaxPlayCount)) {
+		return false
+	}
+	if sp.NotPlayedForDays > 0 && lastPlayed.After(now.AddDate(0, 0, -sp.NotPlayedForDays)) {
+		return false
+	}
+	if sp.PlayedWithinDays > 0 && !lastPlayed.After(now.AddDate(0, 0, -sp.PlayedWithinDays)) {
+		return false
+	}
+	if sp.Artist != "" && !strings.Contains(strings.ToLower(tr.Artist), strings.ToLower(sp.Artist)) {
+		return false
+	}
+	if tr.BitRate < sp.MinBitRate {
+		return false
+	}
+	if len(sp.Suffixes) > 0 && !containsFold(sp.Suffixes, tr.Suffix) {
+		return false
+	}
+	if tr.Duration < sp.MinDuration || (sp.MaxDuration > 0 && tr.Duration > sp.MaxDuration) {
+		return false
+	}
+	return true
+}
+
+// Reports whether the playlist should be saved to the server at time t.
+func (sp *SmartPlaylist) MaterializeDue(t time.Time) bool {
+	if !sp.Materialize || sp.MaterializeHours <= 0 {
+		return false
+	}
+	return !t.Before(sp.LastMaterialized.Add(time.Duration(sp.MaterializeHours) * time.Hour))
+}
+
+// Returns the tracks matching the playlist's rules, in its sort order
+// and limited to its maximum number of tracks.
+func (sp *SmartPlaylist) Evaluate(tracks []*subsonic.Child, lastPlayed func(id string) time.Time, now time.Time) []*subsonic.Child {
+	var matched []*subsonic.Child
+	for _, tr := range tracks {
+		if sp.Matches(tr, lastPlayed(tr.ID), now) {
+			matched = append(matched, tr)
+		}
+	}
+	sortSmartPlaylist(matched, sp.SortOrder, lastPlayed)
+	if sp.Limit > 0 && len(matched) > sp.Limit {
+		matched = matched[:sp.Limit]
+	}
+	return matched
+}
+
+func sortSmartPlaylist(tracks []*subsonic.Child, order string, lastPlayed func(id string) time.Time) {
+	// album order: by album, then disc and track number
+	albumLess := func(a, b *subsonic.Child) bool {
+		if a.Album != b.Album {
+			return a.Album < b.Album
+		}
+		if a.DiscNumber != b.DiscNumber {
+			return a.DiscNumber < b.DiscNumber
+		}
+		return a.Track < b.Track
+	}
+	var less func(a, b *subsonic.Child) bool
+	switch order {
+	case SmartPlaylistSortTitle:
+		less = func(a, b *subsonic.Child) bool { return a.Title < b.Title }
+	case SmartPlaylistSortArtist:
+		less = func(a, b *subsonic.Child) bool {
+			if a.Artist != b.Artist {
+				return a.Artist < b.Artist
+			}
+			return albumLess(a, b)
+		}
+	case SmartPlaylistSortAlbum:
+		less = albumLess
+	case SmartPlaylistSortNewest:
+		less = func(a, b *subsonic.Child) bool { return a.Year > b.Year }
+	case SmartPlaylistSortOldest:
+		less = func(a, b *subsonic.Child) bool { return a.Year < b.Year }
+	case SmartPlaylistSortHighestRated:
+		less = func(a, b *subsonic.Child) bool { return a.UserRating > b.UserRating }
+	case SmartPlaylistSortMostPlayed:
+		less = func(a, b *subsonic.Child) bool { return a.PlayCount > b.PlayCount }
+	case SmartPlaylistSortLeastPlayed:
+		less = func(a, b *subsonic.Child) bool { return a.PlayCount < b.PlayCount }
+	case SmartPlaylistSortRecentlyPlayed:
+		less = func(a, b *subsonic.Child) bool { return lastPlayed(a.ID).After(lastPlayed(b.ID)) }
+	case SmartPlaylistSortRecentlyAdded:
+		less = func(a, b *subsonic.Child) bool { return a.Created.After(b.Created) }
+	default:
+		rand.Shuffle(len(tracks), func(i, j int) { tracks[i], tracks[j] = tracks[j], tracks[i] })
+		return
+	}
+	sort.SliceStable(tracks, func(i, j int) bool { return less(tracks[i], tracks[j]) })
+}
+
+// Returns a short description of the playlist's rules,
+// e.g. "Rock, Jazz; 2000-2009; rated 4+".
+func (sp *SmartPlaylist) RulesDescription() string {
+	var parts []string
+	add := func(format string, args ...any) { parts = append(parts, fmt.Sprintf(format, args...)) }
+	if len(sp.Genres) > 0 {
+		add("%s", strings.Join(sp.Genres, ", "))
+	}
+	if sp.Artist != "" {
+		add("artist %q", sp.Artist)
+	}
+	switch {
+	case sp.MinYear > 0 && sp.MaxYear > 0:
+		add("%d-%d", sp.MinYear, sp.MaxYear)
+	case sp.MinYear > 0:
+		add("%d or later", sp.MinYear)
+	case sp.MaxYear > 0:
+		add("%d or earlier", sp.MaxYear)
+	}
+	if sp.MinRating > 0 {
+		add("rated %d+", sp.MinRating)
+	}
+	if sp.StarredOnly {
+		add("favorites")
+	}
+	switch {
+	case sp.HasMaxPlayCount && sp.MaxPlayCount == 0:
+		add("never played")
+	case sp.HasMaxPlayCount:
+		add("%d-%d plays", sp.MinPlayCount, sp.MaxPlayCount)
+	case sp.MinPlayCount > 0:
+		add("%d+ plays", sp.MinPlayCount)
+	}
+	if sp.NotPlayedForDays > 0 {
+		add("not played in %d days", sp.NotPlayedForDays)
+	}
+	if sp.PlayedWithinDays > 0 {
+		add("played in the last %d days", sp.PlayedWithinDays)
+	}
+	if sp.MinBitRate > 0 {
+		add("%d+ kbps", sp.MinBitRate)
+	}
+	if len(sp.Suffixes) > 0 {
+		add("%s", strings.Join(sp.Suffixes, ", "))
+	}
+	if sp.MinDuration > 0 || sp.MaxDuration > 0 {
+		add("%s-%s long", durationDesc(sp.MinDuration), durationDesc(sp.MaxDuration))
+	}
+	if len(parts) == 0 {
+		return "All tracks"
+	}
+	return strings.Join(parts, "; ")
+}
+
+func durationDesc(secs int) string {
+	if secs <= 0 {
+		return "any"
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns copies of the smart playlists of the connected server.
+func (l *LibraryManager) SmartPlaylists() []*SmartPlaylist {
+	serverID := l.s.ServerID()
+	l.smartPlaylistsLock.Lock()
+	defer l.smartPlaylistsLock.Unlock()
+	var playlists []*SmartPlaylist
+	for _, sp := range l.smartPlaylists.Playlists {
+		if sp.ServerID == serverID {
+			sp := *sp
+			playlists = append(playlists, &sp)
+		}
+	}
+	return playlists
+}
+
+// Returns a copy of the smart playlist with the given ID, or nil if there is none.
+func (l *LibraryManager) SmartPlaylist(id uuid.UUID) *SmartPlaylist {
+	l.smartPlaylistsLock.Lock()
+	defer l.smartPlaylistsLock.Unlock()
+	for _, sp := range l.smartPlaylists.Playlists {
+		if sp.ID == id {
+			sp := *sp
+			return &sp
+		}
+	}
+	return nil
+}
+
+// Adds a new smart playlist with a new ID for the connected server to the config.
+// Sets the ID and server ID of sp; the config keeps a copy.
+func (l *LibraryManager) AddSmartPlaylist(sp *SmartPlaylist) {
+	sp.ID = uuid.New()
+	sp.ServerID = l.s.ServerID()
+	stored := *sp
+	l.smartPlaylistsLock.Lock()
+	l.smartPlaylists.Playlists = append(l.smartPlaylists.Playlists, &stored)
+	l.smartPlaylistsLock.Unlock()
+	l.notifyPlaylistsChanged()
+}
+
+// Replaces the rules and name of the smart playlist with those of updated.
+// The playlist it was saved to on the server is kept.
+func (l *LibraryManager) UpdateSmartPlaylist(sp *SmartPlaylist, updated SmartPlaylist) {
+	l.updateStoredSmartPlaylist(sp.ID, func(stored *SmartPlaylist) {
+		updated.ID = stored.ID
+		updated.ServerID = stored.ServerID
+		updated.MaterializedPlaylistID = stored.MaterializedPlaylistID
+		updated.LastMaterialized = stored.LastMaterialized
+		*stored = updated
+	})
+	l.notifyPlaylistsChanged()
+}
+
+func (l *LibraryManager) DeleteSmartPlaylist(id uuid.UUID) {
+	l.smartPlaylistsLock.Lock()
+	for i, sp := range l.smartPlaylists.Playlists {
+		if sp.ID == id {
+			l.smartPlaylists.Playlists = append(l.smartPlaylists.Playlists[:i], l.smartPlaylists.Playlists[i+1:]...)
+			l.smartPlaylistsLock.Unlock()
+			l.notifyPlaylistsChanged()
+			return
+		}
+	}
+	l.smartPlaylistsLock.Unlock()
+}
+
+// Invokes f with the smart playlist with the given ID as stored in the
+// config, if it still exists, while holding the smart playlists lock.
+func (l *LibraryManager) updateStoredSmartPlaylist(id uuid.UUID, f func(sp *SmartPlaylist)) {
+	l.smartPlaylistsLock.Lock()
+	defer l.smartPlaylistsLock.Unlock()
+	for _, sp := range l.smartPlaylists.Playlists {
+		if sp.ID == id {
+			f(sp)
+			return
+		}
+	}
+}
+
+// Returns the tracks of the smart playlist, evaluated against the
+// (cached) tracks of the library.
+func (l *LibraryManager) EvaluateSmartPlaylist(sp *SmartPlaylist) ([]*subsonic.Child, error) {
+	tracks, err := l.AllTracks()
+	if err != nil {
+		return nil, err
+	}
+	serverID := l.s.ServerID()
+	lastPlayed := func(id string) time.Time { return l.PlayHistory.LastPlayed(serverID, id) }
+	return sp.Evaluate(tracks, lastPlayed, time.Now()), nil
+}
+
+// Saves the current tracks of the smart playlist to a playlist
+// on the server, creating the playlist the first time. Updates the
+// saved playlist ID and time both in sp and in the config.
+func (l *LibraryManager) MaterializeSmartPlaylist(sp *SmartPlaylist) error {
+	l.materializeLock.Lock()
+	defer l.materializeLock.Unlock()
+	tracks, err := l.EvaluateSmartPlaylist(sp)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(tracks))
+	for i, tr := range tracks {
+		ids[i] = tr.ID
+	}
+	// sp may be a stale copy; the config has the latest saved playlist ID
+	playlistID := sp.MaterializedPlaylistID
+	l.updateStoredSmartPlaylist(sp.ID, func(stored *SmartPlaylist) {
+		playlistID = stored.MaterializedPlaylistID
+	})
+	id, err := l.ReplacePlaylistTracks(playlistID, sp.Name, ids)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	sp.MaterializedPlaylistID = id
+	sp.LastMaterialized = now
+	l.updateStoredSmartPlaylist(sp.ID, func(stored *SmartPlaylist) {
+		stored.MaterializedPlaylistID = id
+		stored.LastMaterialized = now
+	})
+	return nil
+}
@@ -0,0 +1,192 @@
+package backend
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-subsonic/subsonic"
+	"github.com/pelletier/go-toml"
+)
+
+func TestSmartPlaylistMatches(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	tr := &subsonic.Child{
+		ID:         "1",
+		Artist:     "Blue Harbor",
+		Genre:      "Rock",
+		Year:       2011,
+		UserRating: 4,
+		Starred:    now,
+		PlayCount:  3,
+		BitRate:    320,
+		Suffix:     "mp3",
+		Duration:   243,
+	}
+	lastPlayed := now.AddDate(0, 0, -10)
+	for _, tt := range []struct {
+		name string
+		edit func(sp *SmartPlaylist)
+		want bool
+	}{
+		{"any", func(sp *SmartPlaylist) {}, true},
+		{"genre", func(sp *SmartPlaylist) { sp.Genres = []string{"Jazz", "rock"} }, true},
+		{"other genre", func(sp *SmartPlaylist) { sp.Genres = []string{"Jazz"} }, false},
+		{"year range", func(sp *SmartPlaylist) { sp.MinYear, sp.MaxYear = 2010, 2011 }, true},
+		{"before year range", func(sp *SmartPlaylist) { sp.MinYear = 2012 }, false},
+		{"rating", func(sp *SmartPlaylist) { sp.MinRating = 4 }, true},
+		{"low rating", func(sp *SmartPlaylist) { sp.MinRating = 5 }, false},
+		{"starred", func(sp *SmartPlaylist) { sp.StarredOnly = true }, true},
+		{"play count", func(sp *SmartPlaylist) { sp.MinPlayCount, sp.MaxPlayCount, sp.HasMaxPlayCount = 1, 3, true }, true},
+		{"never played", func(sp *SmartPlaylist) { sp.MaxPlayCount, sp.HasMaxPlayCount = 0, true }, false},
+		{"not played for", func(sp *SmartPlaylist) { sp.NotPlayedForDays = 7 }, true},
+		{"not played for longer", func(sp *SmartPlaylist) { sp.NotPlayedForDays = 30 }, false},
+		{"played within", func(sp *SmartPlaylist) { sp.PlayedWithinDays = 30 }, true},
+		{"not played within", func(sp *SmartPlaylist) { sp.PlayedWithinDays = 7 }, false},
+		{"artist", func(sp *SmartPlaylist) { sp.Artist = "harbor" }, true},
+		{"other artist", func(sp *SmartPlaylist) { sp.Artist = "Delta" }, false},
+		{"bitrate", func(sp *SmartPlaylist) { sp.MinBitRate = 320 }, true},
+		{"low bitrate", func(sp *SmartPlaylist) { sp.MinBitRate = 500 }, false},
+		{"suffix", func(sp *SmartPlaylist) { sp.Suffixes = []string{"MP3"} }, true},
+		{"other suffix", func(sp *SmartPlaylist) { sp.Suffixes = []string{"flac"} }, false},
+		{"duration", func(sp *SmartPlaylist) { sp.MinDuration, sp.MaxDuration = 180, 300 }, true},
+		{"too long", func(sp *SmartPlaylist) { sp.MaxDuration = 240 }, false},
+	} {
+		sp := NewSmartPlaylist(tt.name)
+		tt.edit(&sp)
+		if got := sp.Matches(tr, lastPlayed, now); got != tt.want {
+			t.Errorf("%s: Matches = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	// a track that was never played
+	sp := NewSmartPlaylist("not played")
+	sp.NotPlayedForDays = 30
+	if !sp.Matches(tr, time.Time{}, now) {
+		t.Error("never played track does not match not played rule")
+	}
+
+	// a playlist decoded from a config without the play count keys
+	var decoded SmartPlaylist
+	if err := toml.Unmarshal([]byte(`Name = "Rock"`), &decoded); err != nil {
+		t.Fatalf("error decoding smart playlist: %s", err.Error())
+	}
+	if !decoded.Matches(tr, lastPlayed, now) {
+		t.Error("decoded playlist without a max play count does not match played track")
+	}
+}
+
+func TestEvaluateSmartPlaylist(t *testing.T) {
+	l, srv := newTestLibraryManager(t)
+	sp := NewSmartPlaylist("Recent rock")
+	sp.Genres = []string{"Rock"}
+	sp.MinYear = 2010
+	sp.SortOrder = SmartPlaylistSortNewest
+	sp.Limit = 3
+
+	tracks, err := l.EvaluateSmartPlaylist(&sp)
+	if err != nil {
+		t.Fatalf("error evaluating smart playlist: %s", err.Error())
+	}
+	if got, want := queueIDs(tracks), "tr-11 tr-12 tr-9"; got != want {
+		t.Errorf("got tracks %s, want %s", got, want)
+	}
+
+	// evaluated again from the cached library
+	albumRequests := srv.Requests("getAlbum")
+	sp.Limit = 0
+	if tracks, _ := l.EvaluateSmartPlaylist(&sp); len(tracks) != 4 {
+		t.Errorf("got %d tracks without limit, want 4", len(tracks))
+	}
+	if n := srv.Requests("getAlbum"); n != albumRequests {
+		t.Errorf("library fetched again: %d album requests, want %d", n, albumRequests)
+	}
+}
+
+func TestMaterializeSmartPlaylist(t *testing.T) {
+	l, srv := newTestLibraryManager(t)
+	sp := NewSmartPlaylist("Jazz")
+	sp.Genres = []string{"Jazz"}
+	sp.SortOrder = SmartPlaylistSortTitle
+
+	if err := l.MaterializeSmartPlaylist(&sp); err != nil {
+		t.Fatalf("error saving smart playlist: %s", err.Error())
+	}
+	if sp.MaterializedPlaylistID == "" || sp.LastMaterialized.IsZero() {
+		t.Fatal("materialized playlist not recorded")
+	}
+	pl, err := l.s.Server().GetPlaylist(sp.MaterializedPlaylistID)
+	if err != nil {
+		t.Fatalf("error getting playlist: %s", err.Error())
+	}
+	if pl.Name != "Jazz" || len(pl.Entry) != 6 {
+		t.Errorf("got playlist %q with %d tracks, want Jazz with 6", pl.Name, len(pl.Entry))
+	}
+
+	// saved again to the same playlist, replacing its tracks
+	id := sp.MaterializedPlaylistID
+	sp.Limit = 2
+	if err := l.MaterializeSmartPlaylist(&sp); err != nil {
+		t.Fatalf("error saving smart playlist: %s", err.Error())
+	}
+	if sp.MaterializedPlaylistID != id {
+		t.Errorf("saved to playlist %s, want %s", sp.MaterializedPlaylistID, id)
+	}
+	if pl, _ := l.s.Server().GetPlaylist(id); len(pl.Entry) != 2 {
+		t.Errorf("playlist has %d tracks, want 2", len(pl.Entry))
+	}
+	if n := srv.Requests("createPlaylist"); n != 2 {
+		t.Errorf("got %d create playlist requests, want 2", n)
+	}
+}
+
+func TestMaterializeDue(t *testing.T) {
+	now := time.Now()
+	sp := NewSmartPlaylist("Due")
+	if sp.MaterializeDue(now) {
+		t.Error("due without materialize enabled")
+	}
+	sp.Materialize = true
+	if !sp.MaterializeDue(now) {
+		t.Error("not due when never saved")
+	}
+	sp.LastMaterialized = now.Add(-time.Hour)
+	if sp.MaterializeDue(now) {
+		t.Error("due an hour after saving with a 24 hour interval")
+	}
+}
+
+// Saving stale copies of a smart playlist concurrently with
+// editing it must not create the server playlist twice.
+func TestMaterializeSmartPlaylistCopies(t *testing.T) {
+	l, srv := newTestLibraryManager(t)
+	sp := NewSmartPlaylist("Jazz")
+	sp.Genres = []string{"Jazz"}
+	l.AddSmartPlaylist(&sp)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		cp := l.SmartPlaylist(sp.ID)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := l.MaterializeSmartPlaylist(cp); err != nil {
+				t.Errorf("error saving smart playlist: %s", err.Error())
+			}
+		}()
+	}
+	updated := *l.SmartPlaylist(sp.ID)
+	updated.Name = "Jazz Tracks"
+	l.UpdateSmartPlaylist(&sp, updated)
+	wg.Wait()
+
+	stored := l.SmartPlaylist(sp.ID)
+	if stored.Name != "Jazz Tracks" || stored.MaterializedPlaylistID == "" {
+		t.Errorf("got smart playlist %q saved to %q", stored.Name, stored.MaterializedPlaylistID)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if n := len(srv.playlists); n != 1 {
+		t.Errorf("got %d playlists on the server, want 1", n)
+	}
+}
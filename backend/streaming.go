@@ -0,0 +1,81 @@
+package backend
+
+import "strconv"
+
+// Stream formats to request from the server (Subsonic "format" parameter).
+const (
+	StreamFormatRaw  = "raw" // no transcoding
+	StreamFormatOpus = "opus"
+	StreamFormatMP3  = "mp3"
+)
+
+// A StreamingProfile sets the transcoding parameters used to stream tracks.
+type StreamingProfile struct {
+	Name string
+	// Maximum bit rate in kbps. 0 means no limit.
+	MaxBitRateKbps int
+	// One of StreamFormatRaw, StreamFormatOpus, or StreamFormatMP3
+	Format string
+	// Ask the server to estimate the Content-Length of transcoded streams
+	// so that seeking works before the stream is fully downloaded
+	EstimateContentLength bool
+}
+
+func DefaultStreamingProfiles() []*StreamingProfile {
+	return []*StreamingProfile{
+		{Name: "Original", Format: StreamFormatRaw},
+		{Name: "High (320k MP3)", MaxBitRateKbps: 320, Format: StreamFormatMP3, EstimateContentLength: true},
+		{Name: "Medium (160k Opus)", MaxBitRateKbps: 160, Format: StreamFormatOpus, EstimateContentLength: true},
+		{Name: "Low (96k Opus)", MaxBitRateKbps: 96, Format: StreamFormatOpus, EstimateContentLength: true},
+	}
+}
+
+// Returns the parameters to pass to the Subsonic stream endpoint.
+func (s *StreamingProfile) Params() map[string]string {
+	params := make(map[string]string)
+	if s == nil {
+		return params
+	}
+	if s.Format != "" {
+		params["format"] = s.Format
+	}
+	if s.MaxBitRateKbps > 0 {
+		params["maxBitRate"] = strconv.Itoa(s.MaxBitRateKbps)
+	}
+	if s.EstimateContentLength {
+		params["estimateContentLength"] = "true"
+	}
+	return params
+}
+
+// Returns the file extension of files streamed with this profile,
+// or "" if the extension is that of the original file.
+func (s *StreamingProfile) FileExtension() string {
+	if s == nil || s.Format == "" || s.Format == StreamFormatRaw {
+		return ""
+	}
+	return s.Format
+}
+
+// Returns the profile with the given name, or the first profile if not found.
+// Returns nil only if there are no profiles.
+func (c *StreamingConfig) Profile(name string) *StreamingProfile {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	if len(c.Profiles) > 0 {
+		return c.Profiles[0]
+	}
+	return nil
+}
+
+// Returns the names of all streaming profiles.
+func (c *StreamingConfig) ProfileNames() []string {
+	names := make([]string, len(c.Profiles))
+	for i, p := range c.Profiles {
+		names[i] = p.Name
+	}
+	return names
+}
@@ -16,7 +16,7 @@ func (l *LibraryManager) AllTracksIterator() TrackIterator {
 func (l *LibraryManager) SearchTracksIterator(query string) TrackIterator {
 	return &searchTracksIterator{
 		searchIterBase: searchIterBase{
-			s:     l.s.Server,
+			sm:    l.s,
 			query: query,
 		},
 		trackIDset: make(map[string]bool),
@@ -43,9 +43,15 @@ func (a *allTracksIterator) Next() *subsonic.Child {
 			a.done = true
 			return nil
 		}
-		al, err := a.l.s.Server.GetAlbum(al.ID)
+		cli := a.l.s.Server()
+		if cli == nil {
+			a.done = true
+			return nil
+		}
+		al, err := cli.GetAlbum(al.ID)
 		if err != nil {
 			log.Printf("error fetching album: %s", err.Error())
+			return a.Next()
 		}
 		if len(al.Song) == 0 {
 			// in the unlikely case of an album with zero tracks,
@@ -77,7 +83,7 @@ func (s *searchTracksIterator) Next() *subsonic.Child {
 
 	// prefetch more search results from server
 	if len(s.prefetched) == 0 {
-		results := s.searchIterBase.fetchResults()
+		results, cli := s.searchIterBase.fetchResults()
 
 		if results != nil {
 			// add results from songs search
@@ -86,17 +92,17 @@ func (s *searchTracksIterator) Next() *subsonic.Child {
 
 			// add results from artists search
 			for _, artist := range results.Artist {
-				artist, err := s.s.GetArtist(artist.ID)
+				artist, err := cli.GetArtist(artist.ID)
 				if err != nil {
 					log.Printf("error fetching artist: %s", err.Error())
 				} else {
-					s.addNewTracksFromAlbums(artist.Album)
+					s.addNewTracksFromAlbums(cli, artist.Album)
 				}
 			}
 			s.artistOffset += len(results.Artist)
 
 			// add results from albums search
-			s.addNewTracksFromAlbums(results.Album)
+			s.addNewTracksFromAlbums(cli, results.Album)
 			s.albumOffset += len(results.Album)
 		}
 	}
@@ -129,9 +135,9 @@ func (s *searchTracksIterator) addNewTracks(tracks []*subsonic.Child) {
 	}
 }
 
-func (s *searchTracksIterator) addNewTracksFromAlbums(albums []*subsonic.AlbumID3) {
+func (s *searchTracksIterator) addNewTracksFromAlbums(cli *subsonic.Client, albums []*subsonic.AlbumID3) {
 	for _, al := range albums {
-		if album, err := s.s.GetAlbum(al.ID); err != nil {
+		if album, err := cli.GetAlbum(al.ID); err != nil {
 			log.Printf("error fetching album: %s", err.Error())
 		} else {
 			s.addNewTracks(album.Song)
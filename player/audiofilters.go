@@ -0,0 +1,150 @@
+package player
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// An AudioFilter is a filter in the player's audio filter chain
+// (argument to SetAudioFilters). Filters are applied in order,
+// after the equalizer.
+type AudioFilter interface {
+	// Returns the mpv audio filter implementing this filter,
+	// or "" if the filter has no effect with its current parameters.
+	mpvFilter() string
+}
+
+// One of "loudnorm" or "dynaudnorm"
+type NormalizationMode string
+
+const (
+	// EBU R128 loudness normalization
+	NormalizationEBUR128 NormalizationMode = "loudnorm"
+	// Dynamic normalization, which evens out the volume within a track
+	NormalizationDynamic NormalizationMode = "dynaudnorm"
+)
+
+// Normalizes the loudness of the audio.
+type LoudnessNormalization struct {
+	Mode NormalizationMode
+	// Integrated loudness target in LUFS, used by NormalizationEBUR128.
+	// 0 uses the default of -16 LUFS.
+	TargetLUFS float64
+}
+
+func (l LoudnessNormalization) mpvFilter() string {
+	switch l.Mode {
+	case NormalizationEBUR128:
+		target := l.TargetLUFS
+		if target == 0 {
+			target = -16
+		}
+		target = math.Max(-70, math.Min(-5, target))
+		return fmt.Sprintf("lavfi=[loudnorm=I=%g:TP=-1.5:LRA=11]", target)
+	case NormalizationDynamic:
+		return "lavfi=[dynaudnorm=f=500:g=31]"
+	}
+	return ""
+}
+
+// Mixes the left and right channels together into both channels.
+type MonoDownmix struct{}
+
+func (MonoDownmix) mpvFilter() string {
+	return "lavfi=[pan=stereo|c0=0.5*c0+0.5*c1|c1=0.5*c0+0.5*c1]"
+}
+
+// Adjusts the left/right balance.
+type Balance struct {
+	// -1 (left only) to 1 (right only). 0 is centered.
+	Balance float64
+}
+
+func (b Balance) mpvFilter() string {
+	bal := math.Max(-1, math.Min(1, b.Balance))
+	if bal == 0 {
+		return ""
+	}
+	left, right := math.Min(1, 1-bal), math.Min(1, 1+bal)
+	return fmt.Sprintf("lavfi=[pan=stereo|c0=%.2f*c0|c1=%.2f*c1]", left, right)
+}
+
+// Mixes a portion of each channel into the other to make
+// stereo recordings more natural to listen to on headphones.
+type Crossfeed struct {
+	// 0.1 (subtle) to 1 (strong). 0 uses the default of 0.2.
+	Strength float64
+}
+
+func (c Crossfeed) mpvFilter() string {
+	strength := c.Strength
+	if strength <= 0 {
+		strength = 0.2
+	}
+	return fmt.Sprintf("lavfi=[crossfeed=strength=%.2f]", math.Min(1, strength))
+}
+
+// Removes silence at the beginning of tracks
+// and long silences within tracks.
+type SilenceTrim struct {
+	// Audio below this level is considered silence.
+	// 0 uses the default of -60 dB.
+	ThresholdDB float64
+}
+
+func (s SilenceTrim) mpvFilter() string {
+	thresh := s.ThresholdDB
+	if thresh == 0 {
+		thresh = -60
+	}
+	return fmt.Sprintf("lavfi=[silenceremove=start_periods=1:start_threshold=%gdB:"+
+		"stop_periods=-1:stop_duration=2:stop_threshold=%gdB]", thresh, thresh)
+}
+
+// Sets the audio filter chain, which is applied
+// live without interrupting the current track.
+// Unlike most Player functions, SetAudioFilters can be called
+// before Init, to set the initial filters of the player on startup.
+func (p *Player) SetAudioFilters(filters []AudioFilter) error {
+	p.stateLock.Lock()
+	p.audioFilters = append([]AudioFilter{}, filters...)
+	p.stateLock.Unlock()
+	return p.applyAudioFilters()
+}
+
+// Returns the full mpv audio filter chain for the current options.
+// Must be called with the stateLock held, or from Init.
+func (p *Player) audioFilterChain() string {
+	var chain []string
+	if eq := p.equalizerFilter(); eq != "" {
+		chain = append(chain, "@eq:"+eq)
+	}
+	for _, f := range p.audioFilters {
+		if f == nil {
+			continue
+		}
+		if af := f.mpvFilter(); af != "" {
+			chain = append(chain, af)
+		}
+	}
+	return strings.Join(chain, ",")
+}
+
+// Sets the mpv audio filter chain, if it has changed.
+func (p *Player) applyAudioFilters() error {
+	if !p.initialized {
+		return nil
+	}
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+	af := p.audioFilterChain()
+	if af == p.curAudioFilters {
+		return nil
+	}
+	if err := p.mpv.SetPropertyString("af", af); err != nil {
+		return err
+	}
+	p.curAudioFilters = af
+	return nil
+}
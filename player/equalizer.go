@@ -0,0 +1,66 @@
+package player
+
+import (
+	"fmt"
+	"strings"
+)
+
+const NumEqualizerBands = 10
+
+// Center frequencies in Hz of the bands of the equalizer.
+var EqualizerBands = [NumEqualizerBands]float64{31, 62, 125, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// Maximum boost or cut in dB of each equalizer band and of the preamp.
+const MaxEqualizerGainDB = 12
+
+// Equalizer options (argument to SetEqualizer).
+type EqualizerOptions struct {
+	Enabled bool
+	// Gain applied before equalization, normally <= 0 to
+	// leave headroom for boosted bands.
+	PreampDB float64
+	// Gain of each band in EqualizerBands.
+	GainsDB [NumEqualizerBands]float64
+}
+
+// Sets the equalizer options.
+// Unlike most Player functions, SetEqualizer can be called
+// before Init, to set the initial equalizer of the player on startup.
+func (p *Player) SetEqualizer(options EqualizerOptions) error {
+	p.stateLock.Lock()
+	p.eqOpts = options
+	p.stateLock.Unlock()
+	return p.applyAudioFilters()
+}
+
+// Returns the lavfi filter implementing the equalizer,
+// or "" if the equalizer is disabled or flat.
+func (p *Player) equalizerFilter() string {
+	if !p.eqOpts.Enabled {
+		return ""
+	}
+	var graph []string
+	if pre := clampGain(p.eqOpts.PreampDB); pre != 0 {
+		graph = append(graph, fmt.Sprintf("volume=%.1fdB", pre))
+	}
+	for i, f := range EqualizerBands {
+		if g := clampGain(p.eqOpts.GainsDB[i]); g != 0 {
+			// one-octave-wide peaking filter centered on the band
+			graph = append(graph, fmt.Sprintf("equalizer=f=%g:t=o:w=1:g=%.1f", f, g))
+		}
+	}
+	if len(graph) == 0 {
+		return ""
+	}
+	return "lavfi=[" + strings.Join(graph, ",") + "]"
+}
+
+func clampGain(g float64) float64 {
+	if g > MaxEqualizerGainDB {
+		return MaxEqualizerGainDB
+	}
+	if g < -MaxEqualizerGainDB {
+		return -MaxEqualizerGainDB
+	}
+	return g
+}
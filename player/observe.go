@@ -0,0 +1,182 @@
+package player
+
+import (
+	"log"
+	"math"
+	"time"
+	"unsafe"
+
+	"github.com/dweymouth/go-mpv"
+)
+
+// Reply userdata IDs of the observed mpv properties.
+const (
+	propTimePos uint64 = iota + 1
+	propDuration
+	propPause
+	propPlaylistPos
+	propVolume
+	propAudioDeviceList
+	propEOFReached
+)
+
+const (
+	// Minimum change in time-pos that is published to OnTimeUpdate listeners.
+	timeUpdateGranularity = 0.2
+	// Time after the mpv volume is set for a fade during which volume changes
+	// reported by mpv are assumed to be from the fade.
+	fadeSettleTime = 250 * time.Millisecond
+)
+
+// Mirror of the C struct mpv_event_property, which is the data
+// of an EVENT_PROPERTY_CHANGE event.
+type mpvEventProperty struct {
+	name   *byte
+	format int32
+	data   unsafe.Pointer
+}
+
+// Registers a callback which is invoked when the playback time or the
+// duration of the current track changes. The callback is invoked
+// from the mpv event goroutine with the current time and duration.
+func (p *Player) OnTimeUpdate(cb func(pos, dur float64)) {
+	p.stateLock.Lock()
+	p.onTimeUpdate = append(p.onTimeUpdate, cb)
+	p.stateLock.Unlock()
+}
+
+// Registers a callback which is invoked when the volume is changed
+// other than through SetVolume, such as by the audio output.
+func (p *Player) OnVolumeChange(cb func(int)) {
+	p.stateLock.Lock()
+	p.onVolumeChange = append(p.onVolumeChange, cb)
+	p.stateLock.Unlock()
+}
+
+// Registers a callback which is invoked when audio devices are added or removed.
+func (p *Player) OnAudioDevicesChanged(cb func([]AudioDevice)) {
+	p.stateLock.Lock()
+	p.onAudioDevicesChanged = append(p.onAudioDevicesChanged, cb)
+	p.stateLock.Unlock()
+}
+
+func (p *Player) observeProperties() error {
+	props := []struct {
+		id     uint64
+		name   string
+		format mpv.Format
+	}{
+		{propTimePos, "time-pos", mpv.FORMAT_DOUBLE},
+		{propDuration, "duration", mpv.FORMAT_DOUBLE},
+		{propPause, "pause", mpv.FORMAT_FLAG},
+		{propPlaylistPos, "playlist-pos", mpv.FORMAT_INT64},
+		{propVolume, "volume", mpv.FORMAT_DOUBLE},
+		// the device list is re-queried when it changes
+		{propAudioDeviceList, "audio-device-list", mpv.FORMAT_NONE},
+		{propEOFReached, "eof-reached", mpv.FORMAT_FLAG},
+	}
+	for _, prop := range props {
+		if err := p.mpv.ObserveProperty(prop.id, prop.name, prop.format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Updates the state snapshot from an EVENT_PROPERTY_CHANGE event
+// and notifies listeners. Invoked from the mpv event goroutine.
+func (p *Player) handlePropertyChange(e *mpv.Event) {
+	prop := (*mpvEventProperty)(e.Data)
+	if prop == nil {
+		return
+	}
+	// data is nil (format NONE) if the property is currently unavailable,
+	// e.g. time-pos when no file is loaded
+	have := prop.data != nil && mpv.Format(prop.format) != mpv.FORMAT_NONE
+
+	switch e.Reply_Userdata {
+	case propTimePos, propDuration:
+		var val float64
+		if have {
+			val = *(*float64)(prop.data)
+		}
+		p.stateLock.Lock()
+		if e.Reply_Userdata == propTimePos {
+			p.status.TimePos = val
+		} else {
+			p.status.Duration = val
+		}
+		s := p.status
+		notify := e.Reply_Userdata == propDuration ||
+			math.Abs(s.TimePos-p.lastTimeUpdate) >= timeUpdateGranularity
+		if notify {
+			p.lastTimeUpdate = s.TimePos
+		}
+		callbacks := p.onTimeUpdate
+		p.stateLock.Unlock()
+		if e.Reply_Userdata == propTimePos {
+			p.checkCrossfade(s)
+		}
+		if notify {
+			for _, cb := range callbacks {
+				cb(s.TimePos, s.Duration)
+			}
+		}
+	case propPlaylistPos:
+		pos := int64(-1)
+		if have {
+			pos = *(*int64)(prop.data)
+		}
+		p.stateLock.Lock()
+		p.status.PlaylistPos = pos
+		p.stateLock.Unlock()
+	case propPause:
+		// the Player sets its state when it pauses or resumes playback itself;
+		// this catches pauses made by mpv, e.g. when the audio device is lost
+		paused := have && *(*int32)(prop.data) != 0
+		if paused && p.GetStatus().State == Playing {
+			p.setPrePausedState(Playing)
+			p.setState(Paused)
+		}
+	case propVolume:
+		if !have || p.isFading() {
+			return
+		}
+		vol := int(math.Round(*(*float64)(prop.data)))
+		p.stateLock.Lock()
+		changed := vol != p.vol
+		p.vol = vol
+		callbacks := p.onVolumeChange
+		p.stateLock.Unlock()
+		if changed {
+			for _, cb := range callbacks {
+				cb(vol)
+			}
+		}
+	case propAudioDeviceList:
+		devices, err := p.queryAudioDevices()
+		if err != nil {
+			log.Printf("error listing audio devices: %s", err.Error())
+			return
+		}
+		p.stateLock.Lock()
+		p.audioDevices = devices
+		callbacks := p.onAudioDevicesChanged
+		p.stateLock.Unlock()
+		for _, cb := range callbacks {
+			cb(devices)
+		}
+	case propEOFReached:
+		if have && *(*int32)(prop.data) != 0 {
+			p.checkStopAfterCurrent()
+		}
+	}
+}
+
+// Reports whether the mpv volume was recently set by a fade, in which case
+// volume changes reported by mpv do not reflect the user-set volume.
+func (p *Player) isFading() bool {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
+	return time.Since(p.lastFadeStep) < fadeSettleTime
+}
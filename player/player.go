@@ -6,10 +6,18 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/dweymouth/go-mpv"
 )
 
+// The range of playback speeds supported by SetSpeed.
+const (
+	MinSpeed = 0.25
+	MaxSpeed = 4.0
+)
+
 // Error returned by many Player functions if called before the player has not been initialized.
 var ErrUnitialized error = errors.New("mpv player uninitialized")
 
@@ -58,6 +66,21 @@ type ReplayGainOptions struct {
 	// Fallback gain intentionally omitted
 }
 
+// HTTP options used when mpv requests stream URLs
+// (argument to SetHTTPOptions).
+type HTTPOptions struct {
+	// Network timeout in seconds. <= 0 uses the mpv default.
+	TimeoutSeconds int
+	// HTTP proxy to use. mpv does not support SOCKS proxies.
+	ProxyURL       string
+	CACertFile     string
+	ClientCertFile string
+	ClientKeyFile  string
+	SkipTLSVerify  bool
+	// Extra headers to send with every request.
+	Headers map[string]string
+}
+
 // Information about a specific audio device.
 // Returned by ListAudioDevices.
 type AudioDevice struct {
@@ -70,22 +93,65 @@ type AudioDevice struct {
 	Description string
 }
 
+// The subset of the libmpv client API used by the Player.
+// Implemented by *mpv.Mpv, and by playertest.FakeMPV for tests.
+type MPV interface {
+	Initialize() error
+	Command(cmd []string) error
+	SetOption(name string, format mpv.Format, data interface{}) error
+	SetOptionString(name, value string) error
+	SetProperty(name string, format mpv.Format, data interface{}) error
+	SetPropertyString(name, value string) error
+	GetProperty(name string, format mpv.Format) (interface{}, error)
+	ObserveProperty(replyUserdata uint64, name string, format mpv.Format) error
+	WaitEvent(timeout float32) *mpv.Event
+	TerminateDestroy()
+}
+
 // Player encapsulates the mpv instance and provides functions
 // to control it and to check its status.
+//
+// Player functions are safe to call from any goroutine. Callbacks are
+// invoked without any Player locks held, either from the goroutine that
+// called the Player function causing the change, or from the mpv event
+// goroutine; they may call back into the Player.
 type Player struct {
-	mpv            *mpv.Mpv
-	initialized    bool
-	vol            int
-	replayGainOpts ReplayGainOptions
-	haveRGainOpts  bool
-	audioExclusive bool
-	status         Status
-	seeking        bool
+	// set in Init, before any background goroutines are started
+	mpv         MPV
+	newMPV      func() MPV
+	initialized bool
+	clientName  string
+	bgCancel    context.CancelFunc
+
+	// only accessed from the mpv event goroutine
 	curPlaylistPos int64
-	prePausedState State
-	clientName     string
 
-	bgCancel context.CancelFunc
+	// guards all fields below, which include a snapshot of the player
+	// state that is updated from observed mpv properties
+	stateLock       sync.RWMutex
+	status          Status
+	seeking         bool
+	vol             int
+	speed           float64
+	audioDevices    []AudioDevice
+	lastTimeUpdate  float64
+	lastFadeStep    time.Time
+	replayGainOpts  ReplayGainOptions
+	haveRGainOpts   bool
+	httpOpts        HTTPOptions
+	haveHTTPOpts    bool
+	audioExclusive  bool
+	transitions     TransitionOptions
+	eqOpts          EqualizerOptions
+	audioFilters    []AudioFilter
+	curAudioFilters string
+	rampLock        sync.Mutex // serializes volume ramp steps with canceling the ramp
+	rampCancel      context.CancelFunc
+	fadingOut       bool
+	pauseTimer      *time.Timer // pauses at the end of FadeOutAndPause
+	skipFading      bool
+	stopAfterCur    bool
+	prePausedState  State
 
 	// callbacks
 	onPaused      []func()
@@ -93,6 +159,11 @@ type Player struct {
 	onPlaying     []func()
 	onSeek        []func()
 	onTrackChange []func(int64)
+	onStopAfter   []func()
+
+	onTimeUpdate          []func(float64, float64)
+	onVolumeChange        []func(int)
+	onAudioDevicesChanged []func([]AudioDevice)
 }
 
 // Returns a new player.
@@ -104,17 +175,26 @@ func New() *Player {
 // Same as New, but sets the application name that mpv
 // reports to the system audio API.
 func NewWithClientName(c string) *Player {
+	return NewWithMPV(func() MPV { return mpv.Create() }, c)
+}
+
+// Same as NewWithClientName, but uses the given function to create
+// the mpv instance in Init. Intended for testing with a FakeMPV.
+func NewWithMPV(newMPV func() MPV, clientName string) *Player {
 	return &Player{
+		newMPV:     newMPV,
 		vol:        -1, // use 100 in Init
-		clientName: c,
+		speed:      1,
+		clientName: clientName,
 	}
 }
 
 // Initializes the Player and makes it ready for playback.
 // Most Player functions will return ErrUnitialized if called before Init.
+// Init must not be called concurrently with other Player functions.
 func (p *Player) Init(maxCacheMB int) error {
 	if !p.initialized {
-		m := mpv.Create()
+		m := p.newMPV()
 
 		m.SetOptionString("idle", "yes")
 		m.SetOptionString("video", "no")
@@ -131,20 +211,33 @@ func (p *Player) Init(maxCacheMB int) error {
 			p.vol = 100
 		}
 		m.SetOption("volume", mpv.FORMAT_INT64, p.vol)
+		m.SetOptionString("audio-pitch-correction", "yes")
+		m.SetOption("speed", mpv.FORMAT_DOUBLE, p.speed)
 
 		p.SetAudioExclusive(p.audioExclusive)
 		if p.haveRGainOpts {
 			p.SetReplayGainOptions(p.replayGainOpts)
 		}
+		if p.haveHTTPOpts {
+			p.SetHTTPOptions(p.httpOpts)
+		}
 
 		if p.clientName != "" {
 			m.SetOptionString("audio-client-name", p.clientName)
 		}
 
+		if af := p.audioFilterChain(); af != "" {
+			m.SetOptionString("af", af)
+			p.curAudioFilters = af
+		}
+
 		if err := m.Initialize(); err != nil {
 			return fmt.Errorf("error initializing mpv: %s", err.Error())
 		}
 		p.mpv = m
+		if err := p.observeProperties(); err != nil {
+			return fmt.Errorf("error observing mpv properties: %s", err.Error())
+		}
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	go p.eventHandler(ctx)
@@ -185,13 +278,24 @@ func (p *Player) RemoveTrackAt(idx int) error {
 	return p.mpv.Command([]string{"playlist-remove", strconv.Itoa(idx)})
 }
 
+// Moves the item at index from in the internal playqueue so that it
+// takes the place of the item at index to. The moved item ends up
+// at index to-1 if from is lower than to.
+func (p *Player) MoveTrack(from, to int) error {
+	if !p.initialized {
+		return ErrUnitialized
+	}
+	return p.mpv.Command([]string{"playlist-move", strconv.Itoa(from), strconv.Itoa(to)})
+}
+
 // Stops playback and clears the play queue.
 func (p *Player) Stop() error {
 	if !p.initialized {
 		return ErrUnitialized
 	}
 	var err error
-	if p.status.State == Stopped {
+	p.restoreVolume()
+	if p.GetStatus().State == Stopped {
 		err = p.mpv.Command([]string{"playlist-clear"})
 	} else {
 		if err = p.mpv.Command([]string{"stop"}); err == nil {
@@ -219,7 +323,10 @@ func (p *Player) Seek(target string, mode SeekMode) error {
 	if !p.initialized {
 		return ErrUnitialized
 	}
+	p.cancelCrossfade()
+	p.stateLock.Lock()
 	p.seeking = true
+	p.stateLock.Unlock()
 	err := p.mpv.Command([]string{"seek", target, mode.String()})
 	return err
 }
@@ -234,13 +341,10 @@ func (p *Player) SeekBackOrPrevious() error {
 		return ErrUnitialized
 	}
 
-	if pos, err := p.getInt64Property("time-pos"); err == nil && pos > 3 {
-		return p.Seek("0", SeekAbsolutePercent)
-	}
-	if pos, err := p.getInt64Property("playlist-pos"); err == nil && pos == 0 {
+	if s := p.GetStatus(); s.TimePos > 3 || s.PlaylistPos == 0 {
 		return p.Seek("0", SeekAbsolutePercent)
 	}
-	return p.mpv.Command([]string{"playlist-prev"})
+	return p.fadeOutAndRunCommand([]string{"playlist-prev"})
 }
 
 // Seeks to the next track in the play queue, if any.
@@ -248,7 +352,24 @@ func (p *Player) SeekNext() error {
 	if !p.initialized {
 		return ErrUnitialized
 	}
-	return p.mpv.Command([]string{"playlist-next"})
+	return p.fadeOutAndRunCommand([]string{"playlist-next"})
+}
+
+// Runs the command after fading out, if fading on pause/skip is enabled
+// and the player is playing. The command is run asynchronously in that case.
+func (p *Player) fadeOutAndRunCommand(cmd []string) error {
+	fade := p.transitionOptions().PauseFadeSeconds
+	if p.GetStatus().State != Playing || fade <= 0 {
+		return p.mpv.Command(cmd)
+	}
+	p.fadeOutThen(fade, func() {
+		p.setSkipFading(true)
+		if err := p.mpv.Command(cmd); err != nil {
+			p.setSkipFading(false)
+			p.restoreVolume()
+		}
+	})
+	return nil
 }
 
 // Sets the volume of the player (0-100).
@@ -261,22 +382,53 @@ func (p *Player) SetVolume(vol int) error {
 		vol = 0
 	}
 	if p.initialized {
-		err := p.mpv.SetProperty("volume", mpv.FORMAT_INT64, vol)
-		if err == nil {
-			p.vol = vol
+		p.cancelRamp()
+		if err := p.mpv.SetProperty("volume", mpv.FORMAT_INT64, vol); err != nil {
+			return err
 		}
-		return err
 	}
+	p.stateLock.Lock()
 	p.vol = vol
+	p.stateLock.Unlock()
+	return nil
+}
+
+// Sets the playback speed of the player (MinSpeed-MaxSpeed).
+// The pitch is corrected so that speech sounds natural at any speed.
+// Unlike most Player functions, SetSpeed can be called before Init,
+// to set the initial speed of the player on startup.
+func (p *Player) SetSpeed(speed float64) error {
+	if speed > MaxSpeed {
+		speed = MaxSpeed
+	} else if speed < MinSpeed {
+		speed = MinSpeed
+	}
+	if p.initialized {
+		if err := p.mpv.SetProperty("speed", mpv.FORMAT_DOUBLE, speed); err != nil {
+			return err
+		}
+	}
+	p.stateLock.Lock()
+	p.speed = speed
+	p.stateLock.Unlock()
 	return nil
 }
 
+// Gets the current playback speed of the player.
+func (p *Player) GetSpeed() float64 {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
+	return p.speed
+}
+
 // Sets the ReplayGain options of the player.
 // Unlike most Player functions, SetReplayGainOptions can be called
 // before Init, to set the initial replaygain options of the player on startup.
 func (p *Player) SetReplayGainOptions(options ReplayGainOptions) error {
+	p.stateLock.Lock()
 	p.replayGainOpts = options
 	p.haveRGainOpts = true
+	p.stateLock.Unlock()
 	if p.initialized {
 		if err := p.mpv.SetPropertyString("replaygain", string(options.Mode)); err != nil {
 			return err
@@ -295,11 +447,71 @@ func (p *Player) SetReplayGainOptions(options ReplayGainOptions) error {
 	return nil
 }
 
+// Sets the HTTP options used when fetching stream URLs.
+// Unlike most Player functions, SetHTTPOptions can be called
+// before Init, to set the initial options of the player on startup.
+func (p *Player) SetHTTPOptions(options HTTPOptions) error {
+	p.stateLock.Lock()
+	p.httpOpts = options
+	p.haveHTTPOpts = true
+	p.stateLock.Unlock()
+	if !p.initialized {
+		return nil
+	}
+
+	timeout := "60" // mpv default
+	if options.TimeoutSeconds > 0 {
+		timeout = strconv.Itoa(options.TimeoutSeconds)
+	}
+	if err := p.mpv.SetPropertyString("network-timeout", timeout); err != nil {
+		return err
+	}
+	if err := p.mpv.SetPropertyString("http-proxy", options.ProxyURL); err != nil {
+		return err
+	}
+	if err := p.mpv.SetPropertyString("tls-ca-file", options.CACertFile); err != nil {
+		return err
+	}
+	if err := p.mpv.SetPropertyString("tls-cert-file", options.ClientCertFile); err != nil {
+		return err
+	}
+	keyFile := options.ClientKeyFile
+	if keyFile == "" {
+		// key may be bundled in the same PEM file as the certificate
+		keyFile = options.ClientCertFile
+	}
+	if err := p.mpv.SetPropertyString("tls-key-file", keyFile); err != nil {
+		return err
+	}
+	// mpv does not verify certificates by default, but stream
+	// URLs carry credentials, so verify like the API client does
+	verify := "yes"
+	if options.SkipTLSVerify {
+		verify = "no"
+	}
+	if err := p.mpv.SetPropertyString("tls-verify", verify); err != nil {
+		return err
+	}
+
+	// append headers one at a time, since header values may contain commas
+	if err := p.mpv.Command([]string{"change-list", "http-header-fields", "clr", ""}); err != nil {
+		return err
+	}
+	for k, v := range options.Headers {
+		if err := p.mpv.Command([]string{"change-list", "http-header-fields", "append", k + ": " + v}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Sets the audio exclusive option of the player.
 // Unlike most Player functions, SetAudioExclusive can be called
 // before Init, to set the initial option of the player on startup.
 func (p *Player) SetAudioExclusive(tf bool) {
+	p.stateLock.Lock()
 	p.audioExclusive = tf
+	p.stateLock.Unlock()
 	if p.initialized {
 		val := "no"
 		if tf {
@@ -311,6 +523,8 @@ func (p *Player) SetAudioExclusive(tf bool) {
 
 // Gets the current volume of the player.
 func (p *Player) GetVolume() int {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
 	return p.vol
 }
 
@@ -342,7 +556,8 @@ func (p *Player) PlayPause() error {
 		return ErrUnitialized
 	}
 
-	switch p.status.State {
+	state := p.GetStatus().State
+	switch state {
 	case Stopped:
 		// check if we have anything to play
 		if c, err := p.getInt64Property("playlist-count"); err == nil && c > 0 {
@@ -354,16 +569,37 @@ func (p *Player) PlayPause() error {
 		}
 		return nil
 	case Playing:
+		p.cancelCrossfade()
+		if fade := p.transitionOptions().PauseFadeSeconds; fade > 0 {
+			p.setPrePausedState(state)
+			p.setState(Paused)
+			p.fadeOutThen(fade, func() {
+				p.setPaused(true)
+				p.setMPVVolume(float64(p.GetVolume()))
+			})
+			return nil
+		}
 		err := p.setPaused(true)
 		if err == nil {
-			p.prePausedState = p.status.State
+			p.setPrePausedState(state)
 			p.setState(Paused)
 		}
 		return err
 	case Paused:
+		fade := p.transitionOptions().PauseFadeSeconds
+		if fade > 0 {
+			p.cancelRamp()
+			p.setMPVVolume(0)
+		}
 		err := p.setPaused(false)
 		if err == nil {
-			p.setState(p.prePausedState)
+			p.stateLock.RLock()
+			prev := p.prePausedState
+			p.stateLock.RUnlock()
+			p.setState(prev)
+		}
+		if fade > 0 {
+			p.rampVolume(0, float64(p.GetVolume()), fade, nil)
 		}
 		return err
 	default:
@@ -372,27 +608,26 @@ func (p *Player) PlayPause() error {
 }
 
 // Get the current status of the player.
+// The status is a snapshot kept up to date from mpv events, so this
+// is cheap to call and safe to call from any goroutine.
 func (p *Player) GetStatus() Status {
-	if !p.initialized {
-		return p.status
-	}
-
-	pos, _ := p.mpv.GetProperty("playback-time", mpv.FORMAT_DOUBLE)
-	dur, _ := p.mpv.GetProperty("duration", mpv.FORMAT_DOUBLE)
-	if pos != nil {
-		p.status.TimePos = pos.(float64)
-	}
-	if dur != nil {
-		p.status.Duration = dur.(float64)
-	}
-	if playpos, err := p.getInt64Property("playlist-pos"); err == nil {
-		p.status.PlaylistPos = playpos
-	}
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
 	return p.status
 }
 
 // List available audio devices.
 func (p *Player) ListAudioDevices() ([]AudioDevice, error) {
+	p.stateLock.RLock()
+	devices := p.audioDevices
+	p.stateLock.RUnlock()
+	if devices != nil {
+		return devices, nil
+	}
+	return p.queryAudioDevices()
+}
+
+func (p *Player) queryAudioDevices() ([]AudioDevice, error) {
 	n, err := p.mpv.GetProperty("audio-device-list", mpv.FORMAT_NODE)
 	if err != nil {
 		return nil, err
@@ -426,34 +661,46 @@ func (p *Player) getInt64Property(propName string) (int64, error) {
 
 // Returns true if a seek is currently in progress.
 func (p *Player) IsSeeking() bool {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
 	return p.seeking && p.status.State == Playing
 }
 
 // Registers a callback which is invoked when the player transitions to the Paused state.
 func (p *Player) OnPaused(cb func()) {
+	p.stateLock.Lock()
 	p.onPaused = append(p.onPaused, cb)
+	p.stateLock.Unlock()
 }
 
 // Registers a callback which is invoked when the player transitions to the Stopped state.
 func (p *Player) OnStopped(cb func()) {
+	p.stateLock.Lock()
 	p.onStopped = append(p.onStopped, cb)
+	p.stateLock.Unlock()
 }
 
 // Registers a callback which is invoked when the player transitions to the Playing state.
 func (p *Player) OnPlaying(cb func()) {
+	p.stateLock.Lock()
 	p.onPlaying = append(p.onPlaying, cb)
+	p.stateLock.Unlock()
 }
 
 // Registers a callback which is invoked whenever a seek event occurs.
 func (p *Player) OnSeek(cb func()) {
+	p.stateLock.Lock()
 	p.onSeek = append(p.onSeek, cb)
+	p.stateLock.Unlock()
 }
 
 // Registers a callback which is invoked when the currently playing track changes,
 // or when playback begins at any time from the Stopped state.
 // Callback is invoked with the index of the currently playing track (zero-based).
 func (p *Player) OnTrackChange(cb func(int64)) {
+	p.stateLock.Lock()
 	p.onTrackChange = append(p.onTrackChange, cb)
+	p.stateLock.Unlock()
 }
 
 // Destroy the player.
@@ -470,27 +717,23 @@ func (p *Player) Destroy() {
 
 // sets the state and invokes callbacks, if triggered
 func (p *Player) setState(s State) {
+	p.stateLock.Lock()
+	prev := p.status.State
+	p.status.State = s
+	var callbacks []func()
 	switch {
-	case s == Playing && p.status.State != Playing:
-		defer func() {
-			for _, cb := range p.onPlaying {
-				cb()
-			}
-		}()
-	case s == Paused && p.status.State != Paused:
-		defer func() {
-			for _, cb := range p.onPaused {
-				cb()
-			}
-		}()
-	case s == Stopped && p.status.State != Stopped:
-		defer func() {
-			for _, cb := range p.onStopped {
-				cb()
-			}
-		}()
+	case s == Playing && prev != Playing:
+		callbacks = p.onPlaying
+	case s == Paused && prev != Paused:
+		callbacks = p.onPaused
+	case s == Stopped && prev != Stopped:
+		callbacks = p.onStopped
+	}
+	p.stateLock.Unlock()
+
+	for _, cb := range callbacks {
+		cb()
 	}
-	p.status.State = s
 }
 
 func (p *Player) eventHandler(ctx context.Context) {
@@ -505,36 +748,57 @@ func (p *Player) eventHandler(ctx context.Context) {
 			}
 			switch e.Event_Id {
 			case mpv.EVENT_PLAYBACK_RESTART:
-				if p.seeking {
-					p.seeking = false
-				}
+				p.stateLock.Lock()
+				p.seeking = false
+				p.stateLock.Unlock()
+			case mpv.EVENT_PROPERTY_CHANGE:
+				p.handlePropertyChange(e)
 			case mpv.EVENT_SEEK:
-				for _, cb := range p.onSeek {
-					cb()
-				}
+				p.invokeOnSeekCallbacks()
 			case mpv.EVENT_FILE_LOADED:
-				if p.status.State == Paused {
+				state := p.GetStatus().State
+				if state == Paused {
 					// seek while paused switches to a new file
 					// mpv does not fire seek event in this case
-					for _, cb := range p.onSeek {
-						cb()
-					}
+					p.invokeOnSeekCallbacks()
 				}
 				if pos, err := p.getInt64Property("playlist-pos"); err == nil {
+					prevPos := p.curPlaylistPos
 					p.curPlaylistPos = pos
-					for _, cb := range p.onTrackChange {
+					p.stateLock.RLock()
+					callbacks := p.onTrackChange
+					p.stateLock.RUnlock()
+					for _, cb := range callbacks {
 						cb(pos)
 					}
+					p.onFileLoadedTransition(prevPos, pos, p.GetStatus().State == Playing)
 				}
 			case mpv.EVENT_IDLE:
+				p.stateLock.Lock()
 				p.status.Duration = 0
 				p.status.TimePos = 0
+				p.stateLock.Unlock()
 				p.setState(Stopped)
 			}
 		}
 	}
 }
 
+func (p *Player) invokeOnSeekCallbacks() {
+	p.stateLock.RLock()
+	callbacks := p.onSeek
+	p.stateLock.RUnlock()
+	for _, cb := range callbacks {
+		cb()
+	}
+}
+
+func (p *Player) setPrePausedState(s State) {
+	p.stateLock.Lock()
+	p.prePausedState = s
+	p.stateLock.Unlock()
+}
+
 func (s SeekMode) String() string {
 	switch s {
 	case SeekAbsolute:
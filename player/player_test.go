@@ -0,0 +1,279 @@
+package player_test
+
+import (
+	"strconv"
+	"supersonic/player"
+	"supersonic/player/playertest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dweymouth/go-mpv"
+)
+
+func newTestPlayer(t *testing.T) (*player.Player, *playertest.FakeMPV) {
+	t.Helper()
+	fake := playertest.NewFakeMPV()
+	p := player.NewWithMPV(func() player.MPV { return fake }, "")
+	if err := p.Init(16); err != nil {
+		t.Fatalf("Init: %s", err.Error())
+	}
+	t.Cleanup(p.Destroy)
+	fake.Flush()
+	return p, fake
+}
+
+func appendFiles(t *testing.T, p *player.Player, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := p.AppendFile("track" + strconv.Itoa(i)); err != nil {
+			t.Fatalf("AppendFile: %s", err.Error())
+		}
+	}
+}
+
+func TestTrackChange(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	var mu sync.Mutex
+	var changes []int64
+	p.OnTrackChange(func(idx int64) {
+		mu.Lock()
+		changes = append(changes, idx)
+		mu.Unlock()
+	})
+
+	appendFiles(t, p, 3)
+	if err := p.PlayFromBeginning(); err != nil {
+		t.Fatalf("PlayFromBeginning: %s", err.Error())
+	}
+	fake.Flush()
+	fake.EndFile()
+	fake.Flush()
+	fake.EndFile()
+	fake.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []int64{0, 1, 2}
+	if len(changes) != len(want) {
+		t.Fatalf("track changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("track changes = %v, want %v", changes, want)
+		}
+	}
+	if s := p.GetStatus(); s.State != player.Playing || s.PlaylistPos != 2 {
+		t.Errorf("status = %+v, want playing track 2", s)
+	}
+
+	fake.EndFile()
+	fake.Flush()
+	if s := p.GetStatus(); s.State != player.Stopped {
+		t.Errorf("state at end of queue = %v, want Stopped", s.State)
+	}
+}
+
+func TestTimeUpdate(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	var mu sync.Mutex
+	var lastPos, lastDur float64
+	p.OnTimeUpdate(func(pos, dur float64) {
+		mu.Lock()
+		lastPos, lastDur = pos, dur
+		mu.Unlock()
+	})
+
+	appendFiles(t, p, 1)
+	p.PlayFromBeginning()
+	fake.SetTimePos(42)
+	fake.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if lastPos != 42 || lastDur != fake.Duration {
+		t.Errorf("time update = (%v, %v), want (42, %v)", lastPos, lastDur, fake.Duration)
+	}
+	if s := p.GetStatus(); s.TimePos != 42 || s.Duration != fake.Duration {
+		t.Errorf("status = %+v, want time 42 of %v", s, fake.Duration)
+	}
+}
+
+func TestStopAfterCurrent(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	stopped := make(chan struct{}, 1)
+	p.OnStopAfterCurrent(func() { stopped <- struct{}{} })
+
+	appendFiles(t, p, 2)
+	p.PlayFromBeginning()
+	fake.Flush()
+	p.SetStopAfterCurrent(true)
+	fake.EndFile()
+	fake.Flush()
+
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("OnStopAfterCurrent callback not invoked")
+	}
+	if s := p.GetStatus(); s.State != player.Paused || s.PlaylistPos != 1 {
+		t.Errorf("status = %+v, want paused on track 1", s)
+	}
+	if p.StopAfterCurrent() {
+		t.Error("stop after current not cleared")
+	}
+}
+
+// Exercises the Player from several goroutines at once
+// while mpv events are handled. Meant to be run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	p.OnTimeUpdate(func(_, _ float64) { p.GetStatus() })
+	p.OnTrackChange(func(_ int64) { p.GetVolume() })
+	appendFiles(t, p, 20)
+	p.PlayFromBeginning()
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 15; i++ {
+			fake.SetTimePos(float64(i))
+			fake.EndFile()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			p.SetVolume(i)
+			p.SetSpeed(1 + float64(i%4)/4)
+			p.SetStopAfterCurrent(false)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			p.GetStatus()
+			p.IsSeeking()
+			p.GetSpeed()
+			p.Seek("10", player.SeekAbsolute)
+		}
+	}()
+	wg.Wait()
+	fake.Flush()
+
+	if s := p.GetStatus(); s.PlaylistPos != fake.PlaylistPos() {
+		t.Errorf("status playlist pos = %d, want %d", s.PlaylistPos, fake.PlaylistPos())
+	}
+}
+
+func TestHTTPOptionsTLS(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	prop := func(name string) interface{} {
+		val, _ := fake.GetProperty(name, mpv.FORMAT_STRING)
+		return val
+	}
+	for _, tt := range []struct {
+		name       string
+		opts       player.HTTPOptions
+		wantVerify string
+		wantKey    string
+	}{
+		{"defaults", player.HTTPOptions{}, "yes", ""},
+		{"skip verify", player.HTTPOptions{SkipTLSVerify: true}, "no", ""},
+		{"separate key", player.HTTPOptions{ClientCertFile: "cert.pem", ClientKeyFile: "key.pem"}, "yes", "key.pem"},
+		{"bundled key", player.HTTPOptions{ClientCertFile: "cert.pem"}, "yes", "cert.pem"},
+	} {
+		if err := p.SetHTTPOptions(tt.opts); err != nil {
+			t.Fatalf("%s: SetHTTPOptions: %s", tt.name, err.Error())
+		}
+		if v := prop("tls-verify"); v != tt.wantVerify {
+			t.Errorf("%s: tls-verify = %v, want %s", tt.name, v, tt.wantVerify)
+		}
+		if k := prop("tls-key-file"); k != tt.wantKey {
+			t.Errorf("%s: tls-key-file = %v, want %q", tt.name, k, tt.wantKey)
+		}
+	}
+}
+
+// Seeking away from the end of a track that is being faded out
+// for a crossfade cancels the fade and restores the volume.
+func TestSeekCancelsCrossfade(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	p.SetTransitionOptions(player.TransitionOptions{CrossfadeSeconds: 5})
+	volume := func() float64 {
+		val, _ := fake.GetProperty("volume", mpv.FORMAT_DOUBLE)
+		switch v := val.(type) {
+		case float64:
+			return v
+		case int64:
+			return float64(v)
+		case int:
+			return float64(v)
+		}
+		return -1
+	}
+
+	appendFiles(t, p, 2)
+	p.PlayFromBeginning()
+	fake.Flush()
+	fake.SetTimePos(fake.Duration - 2)
+	fake.Flush()
+	time.Sleep(100 * time.Millisecond)
+	if v := volume(); v >= float64(p.GetVolume()) {
+		t.Fatalf("volume = %v near end of track, want fading out", v)
+	}
+
+	if err := p.Seek("10", player.SeekAbsolute); err != nil {
+		t.Fatalf("Seek: %s", err.Error())
+	}
+	fake.Flush()
+	time.Sleep(100 * time.Millisecond)
+	if v := volume(); v != float64(p.GetVolume()) {
+		t.Errorf("volume = %v after seek, want %d", v, p.GetVolume())
+	}
+}
+
+func TestCancelFadeOutAndPause(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	appendFiles(t, p, 1)
+	p.PlayFromBeginning()
+	fake.Flush()
+
+	if err := p.FadeOutAndPause(0.5); err != nil {
+		t.Fatalf("FadeOutAndPause: %s", err.Error())
+	}
+	time.Sleep(100 * time.Millisecond)
+	p.CancelFadeOutAndPause()
+	time.Sleep(600 * time.Millisecond)
+	fake.Flush()
+	if s := p.GetStatus(); s.State != player.Playing {
+		t.Errorf("state = %v after cancelling fade-out, want Playing", s.State)
+	}
+	if v, _ := fake.GetProperty("volume", mpv.FORMAT_DOUBLE); v != float64(p.GetVolume()) {
+		t.Errorf("volume = %v after cancelling fade-out, want %d", v, p.GetVolume())
+	}
+}
+
+// The fade-out and pause continue across a track change during the fade.
+func TestFadeOutAndPauseTrackChange(t *testing.T) {
+	p, fake := newTestPlayer(t)
+	p.SetTransitionOptions(player.TransitionOptions{CrossfadeSeconds: 5, PauseFadeSeconds: 1})
+	appendFiles(t, p, 3)
+	p.PlayFromBeginning()
+	fake.Flush()
+
+	if err := p.FadeOutAndPause(0.5); err != nil {
+		t.Fatalf("FadeOutAndPause: %s", err.Error())
+	}
+	fake.EndFile()
+	fake.Flush()
+	time.Sleep(700 * time.Millisecond)
+	fake.Flush()
+	if s := p.GetStatus(); s.State != player.Paused || s.PlaylistPos != 1 {
+		t.Errorf("status = %+v after fade-out, want paused on track 1", s)
+	}
+	if v, _ := fake.GetProperty("volume", mpv.FORMAT_DOUBLE); v != float64(p.GetVolume()) {
+		t.Errorf("volume = %v after fade-out, want %d", v, p.GetVolume())
+	}
+}
@@ -0,0 +1,429 @@
+// Package playertest provides a fake mpv instance for testing
+// code that uses the player package.
+package playertest
+
+import (
+	"strconv"
+	"supersonic/player"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/dweymouth/go-mpv"
+)
+
+// FakeMPV is an in-memory implementation of the player.MPV interface for tests.
+// It keeps a playlist, simulates the playback commands used by the Player,
+// and emits the events and property changes that mpv would. Playback time
+// does not advance on its own; tests drive it with SetTimePos and EndFile.
+type FakeMPV struct {
+	// Duration reported for every loaded file, in seconds.
+	Duration float64
+
+	mu        sync.Mutex
+	drained   *sync.Cond
+	props     map[string]interface{}
+	observed  map[string]fakeObservation
+	playlist  []string
+	pos       int64
+	timePos   float64
+	eof       bool
+	events    []*mpv.Event
+	handling  bool
+	wake      chan struct{}
+	destroyed bool
+}
+
+type fakeObservation struct {
+	id     uint64
+	format mpv.Format
+}
+
+// Mirror of the C struct mpv_event_property, which is the data
+// of an EVENT_PROPERTY_CHANGE event.
+type eventProperty struct {
+	name   *byte
+	format int32
+	data   unsafe.Pointer
+}
+
+var _ player.MPV = (*FakeMPV)(nil)
+
+func NewFakeMPV() *FakeMPV {
+	f := &FakeMPV{
+		Duration: 180,
+		props:    make(map[string]interface{}),
+		observed: make(map[string]fakeObservation),
+		pos:      -1,
+		wake:     make(chan struct{}, 1),
+	}
+	f.drained = sync.NewCond(&f.mu)
+	return f
+}
+
+// Returns the URLs in the playlist.
+func (f *FakeMPV) Playlist() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.playlist...)
+}
+
+// Returns the index of the current playlist entry, or -1 if idle.
+func (f *FakeMPV) PlaylistPos() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pos
+}
+
+// Sets the playback time of the current file.
+func (f *FakeMPV) SetTimePos(pos float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.timePos = pos
+	f.propertyChanged("time-pos", pos)
+}
+
+// Simulates reaching the end of the current file. Advances to the next
+// playlist entry, or becomes idle at the end of the playlist, unless
+// keep-open is set to always.
+func (f *FakeMPV) EndFile() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pos < 0 {
+		return
+	}
+	f.timePos = f.Duration
+	f.propertyChanged("time-pos", f.timePos)
+	if f.props["keep-open"] == "always" {
+		f.eof = true
+		f.propertyChanged("eof-reached", true)
+		return
+	}
+	if f.pos+1 < int64(len(f.playlist)) {
+		f.load(f.pos + 1)
+	} else {
+		f.idle()
+	}
+}
+
+// Waits until the Player has handled all events emitted so far.
+func (f *FakeMPV) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for !f.destroyed && (len(f.events) > 0 || f.handling) {
+		f.drained.Wait()
+	}
+}
+
+func (f *FakeMPV) Initialize() error {
+	return nil
+}
+
+func (f *FakeMPV) Command(cmd []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(cmd) == 0 {
+		return mpv.ERROR_INVALID_PARAMETER
+	}
+	arg := func(i int) string {
+		if i < len(cmd) {
+			return cmd[i]
+		}
+		return ""
+	}
+	switch cmd[0] {
+	case "loadfile":
+		if arg(2) == "append" {
+			f.playlist = append(f.playlist, arg(1))
+			return nil
+		}
+		f.playlist = []string{arg(1)}
+		f.load(0)
+	case "playlist-play-index":
+		idx, err := strconv.ParseInt(arg(1), 10, 64)
+		if err != nil || idx < 0 || idx >= int64(len(f.playlist)) {
+			return mpv.ERROR_COMMAND
+		}
+		f.load(idx)
+	case "playlist-next":
+		if f.pos < 0 || f.pos+1 >= int64(len(f.playlist)) {
+			return mpv.ERROR_COMMAND
+		}
+		f.load(f.pos + 1)
+	case "playlist-prev":
+		if f.pos <= 0 {
+			return mpv.ERROR_COMMAND
+		}
+		f.load(f.pos - 1)
+	case "playlist-remove":
+		idx, err := strconv.ParseInt(arg(1), 10, 64)
+		if err != nil || idx < 0 || idx >= int64(len(f.playlist)) {
+			return mpv.ERROR_COMMAND
+		}
+		f.playlist = append(f.playlist[:idx], f.playlist[idx+1:]...)
+		switch {
+		case idx < f.pos:
+			f.pos--
+			f.propertyChanged("playlist-pos", f.pos)
+		case idx == f.pos && idx < int64(len(f.playlist)):
+			// mpv plays the entry after the removed current one
+			f.load(idx)
+		case idx == f.pos:
+			f.idle()
+		}
+	case "playlist-move":
+		from, err1 := strconv.ParseInt(arg(1), 10, 64)
+		to, err2 := strconv.ParseInt(arg(2), 10, 64)
+		n := int64(len(f.playlist))
+		if err1 != nil || err2 != nil || from < 0 || from >= n || to < 0 || to > n {
+			return mpv.ERROR_COMMAND
+		}
+		if from == to {
+			return nil
+		}
+		entry := f.playlist[from]
+		f.playlist = append(f.playlist[:from], f.playlist[from+1:]...)
+		if from < to {
+			// the entry takes the place of the one at index to
+			to--
+		}
+		f.playlist = append(f.playlist[:to], append([]string{entry}, f.playlist[to:]...)...)
+		pos := f.pos
+		switch {
+		case from == f.pos:
+			pos = to
+		case from < f.pos && to >= f.pos:
+			pos--
+		case from > f.pos && to <= f.pos:
+			pos++
+		}
+		if pos != f.pos {
+			f.pos = pos
+			f.propertyChanged("playlist-pos", f.pos)
+		}
+	case "playlist-clear":
+		// mpv keeps the current entry
+		if f.pos >= 0 {
+			f.playlist = []string{f.playlist[f.pos]}
+			if f.pos != 0 {
+				f.pos = 0
+				f.propertyChanged("playlist-pos", f.pos)
+			}
+		} else {
+			f.playlist = nil
+		}
+	case "stop":
+		if arg(1) != "keep-playlist" {
+			f.playlist = nil
+		}
+		f.idle()
+	case "seek":
+		target, err := strconv.ParseFloat(arg(1), 64)
+		if err != nil || f.pos < 0 {
+			return mpv.ERROR_COMMAND
+		}
+		switch arg(2) {
+		case "relative":
+			target += f.timePos
+		case "absolute-percent":
+			target = target * f.Duration / 100
+		case "relative-percent":
+			target = f.timePos + target*f.Duration/100
+		}
+		f.timePos = target
+		f.push(&mpv.Event{Event_Id: mpv.EVENT_SEEK})
+		f.propertyChanged("time-pos", target)
+		f.push(&mpv.Event{Event_Id: mpv.EVENT_PLAYBACK_RESTART})
+	}
+	return nil
+}
+
+func (f *FakeMPV) SetOption(name string, format mpv.Format, data interface{}) error {
+	return f.SetProperty(name, format, data)
+}
+
+func (f *FakeMPV) SetOptionString(name, value string) error {
+	return f.SetPropertyString(name, value)
+}
+
+func (f *FakeMPV) SetProperty(name string, _ mpv.Format, data interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.props[name] != data {
+		f.props[name] = data
+		f.propertyChanged(name, data)
+	}
+	return nil
+}
+
+func (f *FakeMPV) SetPropertyString(name, value string) error {
+	return f.SetProperty(name, mpv.FORMAT_STRING, value)
+}
+
+func (f *FakeMPV) GetProperty(name string, _ mpv.Format) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if val := f.value(name); val != nil {
+		return val, nil
+	}
+	if name == "audio-device-list" {
+		return &mpv.Node{Format: mpv.FORMAT_NODE_ARRAY, Data: []*mpv.Node{}}, nil
+	}
+	return nil, mpv.ERROR_PROPERTY_UNAVAILABLE
+}
+
+func (f *FakeMPV) ObserveProperty(replyUserdata uint64, name string, format mpv.Format) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.observed[name] = fakeObservation{id: replyUserdata, format: format}
+	// mpv always reports the initial value of an observed property
+	f.propertyChanged(name, f.value(name))
+	return nil
+}
+
+func (f *FakeMPV) WaitEvent(timeout float32) *mpv.Event {
+	deadline := time.After(time.Duration(float64(timeout) * float64(time.Second)))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// the previous event, if any, has been handled
+	f.handling = false
+	f.drained.Broadcast()
+	for {
+		if f.destroyed {
+			return &mpv.Event{Event_Id: mpv.EVENT_SHUTDOWN}
+		}
+		if len(f.events) > 0 {
+			e := f.events[0]
+			f.events = f.events[1:]
+			f.handling = true
+			return e
+		}
+		f.mu.Unlock()
+		select {
+		case <-f.wake:
+			f.mu.Lock()
+		case <-deadline:
+			f.mu.Lock()
+			return &mpv.Event{Event_Id: mpv.EVENT_NONE}
+		}
+	}
+}
+
+func (f *FakeMPV) TerminateDestroy() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.destroyed = true
+	f.drained.Broadcast()
+	f.signal()
+}
+
+// Returns the current value of a property, or nil if unavailable.
+func (f *FakeMPV) value(name string) interface{} {
+	switch name {
+	case "playlist-count":
+		return int64(len(f.playlist))
+	case "playlist-pos":
+		return f.pos
+	case "eof-reached":
+		return f.eof
+	case "time-pos", "playback-time":
+		if f.pos < 0 {
+			return nil
+		}
+		return f.timePos
+	case "duration":
+		if f.pos < 0 {
+			return nil
+		}
+		return f.Duration
+	case "pause":
+		paused, _ := f.props[name].(bool)
+		return paused
+	}
+	return f.props[name]
+}
+
+func (f *FakeMPV) load(idx int64) {
+	f.pos = idx
+	f.timePos = 0
+	f.eof = false
+	f.push(&mpv.Event{Event_Id: mpv.EVENT_START_FILE})
+	f.propertyChanged("playlist-pos", f.pos)
+	f.propertyChanged("eof-reached", false)
+	f.push(&mpv.Event{Event_Id: mpv.EVENT_FILE_LOADED})
+	f.propertyChanged("duration", f.Duration)
+	f.propertyChanged("time-pos", f.timePos)
+}
+
+func (f *FakeMPV) idle() {
+	f.pos = -1
+	f.timePos = 0
+	f.eof = false
+	f.propertyChanged("playlist-pos", f.pos)
+	f.propertyChanged("time-pos", nil)
+	f.propertyChanged("duration", nil)
+	f.push(&mpv.Event{Event_Id: mpv.EVENT_IDLE})
+}
+
+// Emits a property change event if the property is observed.
+func (f *FakeMPV) propertyChanged(name string, val interface{}) {
+	obs, ok := f.observed[name]
+	if !ok {
+		return
+	}
+	prop := &eventProperty{format: int32(obs.format)}
+	switch obs.format {
+	case mpv.FORMAT_DOUBLE:
+		if v, ok := toFloat64(val); ok {
+			prop.data = unsafe.Pointer(&v)
+		}
+	case mpv.FORMAT_INT64:
+		if v, ok := toFloat64(val); ok {
+			i := int64(v)
+			prop.data = unsafe.Pointer(&i)
+		}
+	case mpv.FORMAT_FLAG:
+		if v, ok := val.(bool); ok {
+			var flag int32
+			if v {
+				flag = 1
+			}
+			prop.data = unsafe.Pointer(&flag)
+		}
+	}
+	if prop.data == nil {
+		prop.format = int32(mpv.FORMAT_NONE)
+	}
+	f.push(&mpv.Event{
+		Event_Id:       mpv.EVENT_PROPERTY_CHANGE,
+		Reply_Userdata: obs.id,
+		Data:           unsafe.Pointer(prop),
+	})
+}
+
+func (f *FakeMPV) push(e *mpv.Event) {
+	f.events = append(f.events, e)
+	f.signal()
+}
+
+func (f *FakeMPV) signal() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+func toFloat64(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,65 @@
+package player
+
+// Sets whether to stop playback at the end of the currently playing track.
+// The setting applies to whichever track is current, so skipping to another
+// track does not cancel it. Once playback has stopped, the setting is cleared,
+// the play queue is advanced to the next track in the Paused state, and
+// the OnStopAfterCurrent callbacks are invoked.
+func (p *Player) SetStopAfterCurrent(stop bool) error {
+	p.stateLock.Lock()
+	p.stopAfterCur = stop
+	p.stateLock.Unlock()
+	if !p.initialized {
+		return nil
+	}
+	// with keep-open=always, mpv holds the last frame at the end of
+	// each file rather than advancing to the next playlist entry
+	keepOpen := "no"
+	if stop {
+		keepOpen = "always"
+	}
+	return p.mpv.SetPropertyString("keep-open", keepOpen)
+}
+
+// Returns whether playback will stop at the end of the current track.
+func (p *Player) StopAfterCurrent() bool {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
+	return p.stopAfterCur
+}
+
+// Registers a callback which is invoked when playback has stopped
+// at the end of the track because of SetStopAfterCurrent.
+func (p *Player) OnStopAfterCurrent(cb func()) {
+	p.stateLock.Lock()
+	p.onStopAfter = append(p.onStopAfter, cb)
+	p.stateLock.Unlock()
+}
+
+// Invoked when mpv reaches the end of a file that is kept open.
+func (p *Player) checkStopAfterCurrent() {
+	if !p.StopAfterCurrent() {
+		return
+	}
+	p.SetStopAfterCurrent(false)
+	count, err := p.getInt64Property("playlist-count")
+	if err != nil || p.curPlaylistPos+1 >= count {
+		// end of the play queue; stop as when playback ends naturally
+		p.mpv.Command([]string{"stop", "keep-playlist"})
+		p.setPaused(false)
+		p.setState(Stopped)
+	} else {
+		// load the next track paused, so playback can be resumed from it
+		p.setPaused(true)
+		p.setPrePausedState(Playing)
+		p.setState(Paused)
+		p.mpv.Command([]string{"playlist-next"})
+	}
+	p.restoreVolume()
+	p.stateLock.RLock()
+	callbacks := p.onStopAfter
+	p.stateLock.RUnlock()
+	for _, cb := range callbacks {
+		cb()
+	}
+}
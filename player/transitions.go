@@ -0,0 +1,284 @@
+package player
+
+import (
+	"context"
+	"time"
+
+	"github.com/dweymouth/go-mpv"
+)
+
+// Options for volume fades between tracks and on pause, resume and stop
+// (argument to SetTransitionOptions).
+type TransitionOptions struct {
+	// Duration of the fade-out at the end of a track and the fade-in at
+	// the beginning of the next. 0 disables crossfading.
+	CrossfadeSeconds float64
+
+	// Duration of the fade on pause, resume, stop and skipping tracks.
+	// 0 disables fading.
+	PauseFadeSeconds float64
+
+	// Reports whether to crossfade between the tracks at the given play queue
+	// indexes. toIdx may be past the end of the play queue. If nil, all
+	// track transitions are crossfaded.
+	ShouldCrossfade func(fromIdx, toIdx int64) bool
+}
+
+const rampStepInterval = 25 * time.Millisecond
+
+// Sets the options for fading between tracks and on pause, resume and stop.
+// Unlike most Player functions, SetTransitionOptions can be called
+// before Init, to set the initial options of the player on startup.
+func (p *Player) SetTransitionOptions(options TransitionOptions) {
+	p.stateLock.Lock()
+	p.transitions = options
+	p.stateLock.Unlock()
+}
+
+func (p *Player) transitionOptions() TransitionOptions {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
+	return p.transitions
+}
+
+// Fades out the volume and stops playback.
+// Returns immediately; the player enters the Stopped state when the fade completes.
+func (p *Player) FadeOutAndStop() error {
+	if !p.initialized {
+		return ErrUnitialized
+	}
+	fade := p.transitionOptions().PauseFadeSeconds
+	if p.GetStatus().State != Playing || fade <= 0 {
+		return p.Stop()
+	}
+	p.fadeOutThen(fade, func() {
+		p.Stop()
+		p.restoreVolume()
+	})
+	return nil
+}
+
+// Fades out the volume over the given duration and pauses playback.
+// Returns immediately; the player enters the Paused state when the fade completes.
+func (p *Player) FadeOutAndPause(seconds float64) error {
+	if !p.initialized {
+		return ErrUnitialized
+	}
+	if p.GetStatus().State != Playing {
+		return nil
+	}
+	p.cancelCrossfade()
+	// pause on a timer of its own rather than when the ramp completes,
+	// since track changes during the fade may replace the ramp
+	p.stateLock.Lock()
+	if p.pauseTimer != nil {
+		p.pauseTimer.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(time.Duration(seconds*float64(time.Second)), func() {
+		p.stateLock.Lock()
+		if p.pauseTimer != t {
+			// canceled or replaced
+			p.stateLock.Unlock()
+			return
+		}
+		p.pauseTimer = nil
+		p.stateLock.Unlock()
+		if p.GetStatus().State == Playing {
+			if err := p.setPaused(true); err == nil {
+				p.setPrePausedState(Playing)
+				p.setState(Paused)
+			}
+		}
+		p.restoreVolume()
+	})
+	p.pauseTimer = t
+	p.stateLock.Unlock()
+	p.fadeOutThen(seconds, nil)
+	return nil
+}
+
+// Cancels a fade-out started by FadeOutAndPause, if still in progress,
+// and restores the volume. Playback continues.
+func (p *Player) CancelFadeOutAndPause() {
+	if !p.initialized {
+		return
+	}
+	p.stateLock.Lock()
+	pending := p.pauseTimer != nil
+	if pending {
+		p.pauseTimer.Stop()
+		p.pauseTimer = nil
+	}
+	p.stateLock.Unlock()
+	if pending {
+		p.restoreVolume()
+	}
+}
+
+// Reports whether a fade-out started by FadeOutAndPause is in progress.
+func (p *Player) fadingToPause() bool {
+	p.stateLock.RLock()
+	defer p.stateLock.RUnlock()
+	return p.pauseTimer != nil
+}
+
+func (p *Player) shouldCrossfade(fromIdx, toIdx int64) bool {
+	opts := p.transitionOptions()
+	if opts.CrossfadeSeconds <= 0 {
+		return false
+	}
+	if opts.ShouldCrossfade == nil {
+		return true
+	}
+	return opts.ShouldCrossfade(fromIdx, toIdx)
+}
+
+// Fades out the volume over the given duration and then invokes f.
+func (p *Player) fadeOutThen(seconds float64, f func()) {
+	p.rampVolume(float64(p.GetVolume()), 0, seconds, f)
+}
+
+// Fades the volume in from silence to the user-set volume.
+func (p *Player) fadeIn(seconds float64) {
+	p.setMPVVolume(0)
+	p.rampVolume(0, float64(p.GetVolume()), seconds, nil)
+}
+
+// Cancels any in-progress volume ramp and sets the volume
+// back to the user-set volume.
+func (p *Player) restoreVolume() {
+	p.cancelRamp()
+	p.setMPVVolume(float64(p.GetVolume()))
+}
+
+// Sets the mpv volume for a fade, without changing the user-set volume.
+func (p *Player) setMPVVolume(vol float64) error {
+	p.stateLock.Lock()
+	p.lastFadeStep = time.Now()
+	p.stateLock.Unlock()
+	return p.mpv.SetProperty("volume", mpv.FORMAT_DOUBLE, vol)
+}
+
+func (p *Player) cancelRamp() {
+	// once rampLock is acquired, the canceled ramp can't set the volume any more
+	p.rampLock.Lock()
+	defer p.rampLock.Unlock()
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+	if p.rampCancel != nil {
+		p.rampCancel()
+		p.rampCancel = nil
+	}
+}
+
+// Linearly ramps the mpv volume from `from` to `to` over the given duration
+// in the background, then invokes done (if non-nil). Cancels any previous ramp.
+// Ramping changes the mpv volume only, not the user-set volume returned by GetVolume.
+func (p *Player) rampVolume(from, to float64, seconds float64, done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.rampLock.Lock()
+	p.stateLock.Lock()
+	if p.rampCancel != nil {
+		p.rampCancel()
+	}
+	p.rampCancel = cancel
+	p.stateLock.Unlock()
+	p.rampLock.Unlock()
+
+	go func() {
+		dur := time.Duration(seconds * float64(time.Second))
+		start := time.Now()
+		t := time.NewTicker(rampStepInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				frac := float64(time.Since(start)) / float64(dur)
+				if frac >= 1 {
+					if p.rampStep(ctx, to) && done != nil {
+						done()
+					}
+					return
+				}
+				p.rampStep(ctx, from+(to-from)*frac)
+			}
+		}
+	}()
+}
+
+// Sets the volume for a step of the ramp with the given context,
+// unless the ramp has been canceled. Returns false if canceled.
+func (p *Player) rampStep(ctx context.Context, vol float64) bool {
+	p.rampLock.Lock()
+	defer p.rampLock.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	p.setMPVVolume(vol)
+	return true
+}
+
+// Starts fading out the current track if it is about to end and should
+// be crossfaded into the next. Invoked on every time-pos change.
+func (p *Player) checkCrossfade(s Status) {
+	xfade := p.transitionOptions().CrossfadeSeconds
+	if xfade <= 0 || s.State != Playing || p.IsSeeking() || s.Duration <= 0 || p.fadingToPause() {
+		return
+	}
+	// wall-clock time remaining, which differs from content time if speed != 1
+	remaining := (s.Duration - s.TimePos) / p.GetSpeed()
+	if remaining <= 0 || remaining > xfade {
+		return
+	}
+	p.stateLock.Lock()
+	fadingOut := p.fadingOut
+	p.stateLock.Unlock()
+	if !fadingOut && p.shouldCrossfade(p.curPlaylistPos, p.curPlaylistPos+1) {
+		p.stateLock.Lock()
+		p.fadingOut = true
+		p.stateLock.Unlock()
+		p.fadeOutThen(remaining, nil)
+	}
+}
+
+// Cancels the fade-out of the current track for a crossfade, if one
+// is in progress, and restores the volume. Called when seeking or pausing,
+// since the track will no longer end when the fade-out completes.
+func (p *Player) cancelCrossfade() {
+	p.stateLock.Lock()
+	fadingOut := p.fadingOut
+	p.fadingOut = false
+	p.stateLock.Unlock()
+	if fadingOut {
+		p.restoreVolume()
+	}
+}
+
+// Invoked when a new file begins playing, to fade it in if needed.
+func (p *Player) onFileLoadedTransition(prevPos, pos int64, wasPlaying bool) {
+	p.stateLock.Lock()
+	p.fadingOut = false
+	skipFading := p.skipFading
+	p.skipFading = false
+	opts := p.transitions
+	p.stateLock.Unlock()
+	switch {
+	case p.fadingToPause():
+		// keep fading out across the track change
+	case wasPlaying && prevPos != pos && p.shouldCrossfade(prevPos, pos):
+		p.fadeIn(opts.CrossfadeSeconds)
+	case skipFading:
+		p.fadeIn(opts.PauseFadeSeconds)
+	default:
+		p.restoreVolume()
+	}
+}
+
+func (p *Player) setSkipFading(skip bool) {
+	p.stateLock.Lock()
+	p.skipFading = skip
+	p.stateLock.Unlock()
+}
@@ -68,7 +68,13 @@ const (
 // Reorder tracks and return a new track slice.
 // idxToMove must contain only valid indexes into tracks, and no repeats
 func ReorderTracks(tracks []*subsonic.Child, idxToMove []int, op TrackReorderOp) []*subsonic.Child {
-	newTracks := make([]*subsonic.Child, len(tracks))
+	return ReorderItems(tracks, idxToMove, op)
+}
+
+// Reorder items and return a new slice.
+// idxToMove must contain only valid indexes into items, and no repeats
+func ReorderItems[T any](tracks []T, idxToMove []int, op TrackReorderOp) []T {
+	newTracks := make([]T, len(tracks))
 	switch op {
 	case MoveToTop:
 		topIdx := 0
@@ -117,6 +123,27 @@ func ReorderTracks(tracks []*subsonic.Child, idxToMove []int, op TrackReorderOp)
 	return newTracks
 }
 
+// Move the items at idxToMove, keeping their relative order, so that they are
+// inserted before the item at insertIdx, or at the end if insertIdx == len(items).
+// Returns a new slice. idxToMove must contain only valid indexes into items, and no repeats
+func MoveItemsTo[T any](items []T, idxToMove []int, insertIdx int) []T {
+	newItems := make([]T, 0, len(items))
+	for i, it := range items {
+		if i < insertIdx && !SliceContains(idxToMove, i) {
+			newItems = append(newItems, it)
+		}
+	}
+	for _, i := range idxToMove {
+		newItems = append(newItems, items[i])
+	}
+	for i, it := range items {
+		if i >= insertIdx && !SliceContains(idxToMove, i) {
+			newItems = append(newItems, it)
+		}
+	}
+	return newItems
+}
+
 func firstIdxCanMoveUp(idxs []int) int {
 	prevIdx := -1
 	sort.Ints(idxs)
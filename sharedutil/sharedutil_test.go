@@ -1,6 +1,7 @@
 package sharedutil
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/dweymouth/go-subsonic/subsonic"
@@ -77,6 +78,25 @@ func Test_ReorderTracks(t *testing.T) {
 	}
 }
 
+func Test_MoveItemsTo(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e", "f"}
+	for _, tt := range []struct {
+		idxToMove []int
+		insertIdx int
+		want      string
+	}{
+		{[]int{4, 5}, 1, "aefbcd"},
+		{[]int{0, 2}, 4, "bdacef"},
+		{[]int{1}, 6, "acdefb"},
+		{[]int{1, 2}, 2, "abcdef"},
+		{[]int{3}, 0, "dabcef"},
+	} {
+		if got := strings.Join(MoveItemsTo(items, tt.idxToMove, tt.insertIdx), ""); got != tt.want {
+			t.Errorf("MoveItemsTo(%v, %d) = %s, want %s", tt.idxToMove, tt.insertIdx, got, tt.want)
+		}
+	}
+}
+
 func tracklistsEqual(t *testing.T, a, b []*subsonic.Child) bool {
 	t.Helper()
 	if len(a) != len(b) {
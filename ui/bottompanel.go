@@ -82,6 +82,7 @@ func NewBottomPanel(p *player.Player, contr *controller.Controller) *BottomPanel
 	bp.AuxControls.VolumeControl.OnVolumeChanged = func(v int) {
 		_ = p.SetVolume(v)
 	}
+	p.OnVolumeChange(bp.AuxControls.VolumeControl.SetVolume)
 
 	bp.container = container.New(layouts.NewLeftMiddleRightLayout(500),
 		bp.NowPlaying, bp.Controls, bp.AuxControls)
@@ -96,6 +97,33 @@ func (bp *BottomPanel) SetPlaybackManager(pm *backend.PlaybackManager) {
 			bp.Controls.UpdatePlayTime(cur, total)
 		}
 	})
+	bp.AuxControls.SetStreamingProfiles(pm.StreamingProfileNames(), pm.ActiveStreamingProfile())
+	bp.AuxControls.OnStreamingProfileChanged = pm.SetActiveStreamingProfile
+	pm.OnStreamingProfileChanged(bp.AuxControls.SetStreamingProfile)
+	bp.AuxControls.SetPlaybackSpeed(pm.PlaybackSpeed())
+	bp.AuxControls.OnPlaybackSpeedChanged = pm.SetPlaybackSpeed
+	pm.OnPlaybackSpeedChanged(bp.AuxControls.SetPlaybackSpeed)
+	bp.AuxControls.OnSetSleepTimer = pm.SetSleepTimer
+	bp.AuxControls.OnSetStopAfterCurrent = pm.SetStopAfterCurrent
+	pm.OnSleepTimerChanged(bp.updateSleepTimer)
+	go bp.sleepTimerUpdater(pm)
+}
+
+func (bp *BottomPanel) updateSleepTimer() {
+	pm := bp.playbackManager
+	remaining, ok := pm.SleepTimerRemaining()
+	bp.AuxControls.SetSleepTimerStatus(pm.SleepTimerMode(), pm.StopAfterCurrent(), remaining, ok)
+}
+
+// updates the sleep timer remaining time once a second while it is active
+func (bp *BottomPanel) sleepTimerUpdater(pm *backend.PlaybackManager) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for range t.C {
+		if pm.SleepTimerMode() != backend.SleepTimerOff {
+			bp.updateSleepTimer()
+		}
+	}
 }
 
 func (bp *BottomPanel) onSongChange(song *subsonic.Child, _ *subsonic.Child) {
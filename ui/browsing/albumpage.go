@@ -128,10 +128,11 @@ func (a *AlbumPage) load() {
 type AlbumPageHeader struct {
 	widget.BaseWidget
 
-	albumID  string
-	coverID  string
-	artistID string
-	genre    string
+	albumID   string
+	albumName string
+	coverID   string
+	artistID  string
+	genre     string
 
 	page *AlbumPage
 
@@ -168,10 +169,10 @@ func NewAlbumPageHeader(page *AlbumPage) *AlbumPageHeader {
 	}
 	a.miscLabel = widget.NewLabel("")
 	playButton := widget.NewButtonWithIcon("Play", theme.MediaPlayIcon(), func() {
-		go page.pm.PlayAlbum(page.albumID, 0, false)
+		go page.pm.PlayAlbum(page.albumID, 0, backend.ShuffleNone)
 	})
-	shuffleBtn := widget.NewButtonWithIcon(" Shuffle", myTheme.ShuffleIcon, func() {
-		page.pm.LoadTracks(page.tracklist.Tracks, false, true)
+	shuffleBtn := widgets.NewShuffleButton(" Shuffle", myTheme.ShuffleIcon, func(mode backend.ShuffleMode) {
+		page.pm.LoadTracks(page.tracklist.Tracks, false, mode)
 		page.pm.PlayFromBeginning()
 	})
 	var pop *widget.PopUpMenu
@@ -180,11 +181,15 @@ func NewAlbumPageHeader(page *AlbumPage) *AlbumPageHeader {
 		if pop == nil {
 			menu := fyne.NewMenu("",
 				fyne.NewMenuItem("Add to queue", func() {
-					a.page.pm.LoadAlbum(a.albumID, true /*append*/, false /*shuffle*/)
+					a.page.pm.LoadAlbum(a.albumID, true /*append*/, backend.ShuffleNone)
 				}),
 				fyne.NewMenuItem("Add to playlist...", func() {
 					a.page.contr.DoAddTracksToPlaylistWorkflow(
 						sharedutil.TracksToIDs(a.page.tracklist.Tracks))
+				}),
+				fyne.NewMenuItemSeparator(),
+				fyne.NewMenuItem("Pin to sidebar", func() {
+					a.page.contr.App.PinnedItems.Pin(backend.PinnedAlbum, a.albumID, a.albumName)
 				}))
 			pop = widget.NewPopUpMenu(menu, fyne.CurrentApp().Driver().CanvasForObject(a))
 		}
@@ -215,6 +220,7 @@ func (a *AlbumPageHeader) CreateRenderer() fyne.WidgetRenderer {
 
 func (a *AlbumPageHeader) Update(album *subsonic.AlbumID3, im *backend.ImageManager) {
 	a.albumID = album.ID
+	a.albumName = album.Name
 	a.coverID = album.CoverArt
 	a.artistID = album.ArtistID
 	a.titleLabel.Segments[0].(*widget.TextSegment).Text = album.Name
@@ -237,9 +243,9 @@ func (a *AlbumPageHeader) Update(album *subsonic.AlbumID3, im *backend.ImageMana
 
 func (a *AlbumPageHeader) toggleFavorited() {
 	if a.toggleFavButton.IsFavorited {
-		a.page.sm.Server.Star(subsonic.StarParameters{AlbumIDs: []string{a.albumID}})
+		a.page.sm.Server().Star(subsonic.StarParameters{AlbumIDs: []string{a.albumID}})
 	} else {
-		a.page.sm.Server.Unstar(subsonic.StarParameters{AlbumIDs: []string{a.albumID}})
+		a.page.sm.Server().Unstar(subsonic.StarParameters{AlbumIDs: []string{a.albumID}})
 	}
 }
 
@@ -128,19 +128,41 @@ func (a *ArtistPage) OnSongChange(track *subsonic.Child, lastScrobbledIfAny *sub
 func (a *ArtistPage) playAllTracks() {
 	if a.artistInfo != nil { // page loaded
 		for i, album := range a.artistInfo.Album {
-			a.pm.LoadAlbum(album.ID, i > 0 /*append*/, false /*shuffle*/)
+			a.pm.LoadAlbum(album.ID, i > 0 /*append*/, backend.ShuffleNone)
 		}
 		a.pm.PlayFromBeginning()
 	}
 }
 
+// Shuffles all tracks of the discography together, so that the
+// shuffle mode applies across albums.
+func (a *ArtistPage) shuffleAllTracks(shuffle backend.ShuffleMode) {
+	if a.artistInfo == nil { // page not loaded
+		return
+	}
+	albums := a.artistInfo.Album
+	go func() {
+		var tracks []*subsonic.Child
+		for _, album := range albums {
+			al, err := a.sm.Server().GetAlbum(album.ID)
+			if err != nil {
+				log.Printf("error loading album: %s", err.Error())
+				return
+			}
+			tracks = append(tracks, al.Song...)
+		}
+		a.pm.LoadTracks(tracks, false, shuffle)
+		a.pm.PlayFromBeginning()
+	}()
+}
+
 func (a *ArtistPage) playArtistRadio() {
 	go a.pm.PlaySimilarSongs(a.artistID)
 }
 
 // should be called asynchronously
 func (a *ArtistPage) load() {
-	artist, err := a.sm.Server.GetArtist(a.artistID)
+	artist, err := a.sm.Server().GetArtist(a.artistID)
 	if err != nil {
 		log.Printf("Failed to get artist: %s", err.Error())
 		return
@@ -152,7 +174,7 @@ func (a *ArtistPage) load() {
 	} else {
 		a.showTopTracks()
 	}
-	info, err := a.sm.Server.GetArtistInfo2(a.artistID, nil)
+	info, err := a.sm.Server().GetArtistInfo2(a.artistID, nil)
 	if err != nil {
 		log.Printf("Failed to get artist info: %s", err.Error())
 	}
@@ -188,7 +210,7 @@ func (a *ArtistPage) showTopTracks() {
 			a.activeView = 1 // if page still loading, will show tracks view first
 			return
 		}
-		ts, err := a.sm.Server.GetTopSongs(a.artistInfo.Name, map[string]string{"count": "20"})
+		ts, err := a.sm.Server().GetTopSongs(a.artistInfo.Name, map[string]string{"count": "20"})
 		if err != nil {
 			log.Printf("error getting top songs: %s", err.Error())
 			return
@@ -238,6 +260,7 @@ type ArtistPageHeader struct {
 	widget.BaseWidget
 
 	artistID       string
+	artistName     string
 	artistPage     *ArtistPage
 	artistImage    *widgets.ImagePlaceholder
 	titleDisp      *widget.RichText
@@ -245,7 +268,9 @@ type ArtistPageHeader struct {
 	similarArtists *fyne.Container
 	favoriteBtn    *widgets.FavoriteButton
 	playBtn        *widget.Button
+	shuffleBtn     *widget.Button
 	playRadioBtn   *widget.Button
+	menuBtn        *widget.Button
 	container      *fyne.Container
 }
 
@@ -267,7 +292,21 @@ func NewArtistPageHeader(page *ArtistPage) *ArtistPageHeader {
 	}
 	a.favoriteBtn = widgets.NewFavoriteButton(func() { go a.toggleFavorited() })
 	a.playBtn = widget.NewButtonWithIcon("Play Discography", theme.MediaPlayIcon(), page.playAllTracks)
+	a.shuffleBtn = widgets.NewShuffleButton(" Shuffle", myTheme.ShuffleIcon, page.shuffleAllTracks)
 	a.playRadioBtn = widget.NewButtonWithIcon(" Play Artist Radio", myTheme.ShuffleIcon, page.playArtistRadio)
+	var pop *widget.PopUpMenu
+	a.menuBtn = widget.NewButtonWithIcon("", theme.MoreHorizontalIcon(), nil)
+	a.menuBtn.OnTapped = func() {
+		if pop == nil {
+			menu := fyne.NewMenu("",
+				fyne.NewMenuItem("Pin to sidebar", func() {
+					page.contr.App.PinnedItems.Pin(backend.PinnedArtist, a.artistID, a.artistName)
+				}))
+			pop = widget.NewPopUpMenu(menu, fyne.CurrentApp().Driver().CanvasForObject(a))
+		}
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(a.menuBtn)
+		pop.ShowAtPosition(fyne.NewPos(pos.X, pos.Y+a.menuBtn.Size().Height))
+	}
 	a.biographyDisp.Wrapping = fyne.TextWrapWord
 	a.ExtendBaseWidget(a)
 	a.createContainer()
@@ -281,6 +320,7 @@ func (a *ArtistPageHeader) Update(artist *subsonic.ArtistID3) {
 	a.favoriteBtn.IsFavorited = !artist.Starred.IsZero()
 	a.favoriteBtn.Refresh()
 	a.artistID = artist.ID
+	a.artistName = artist.Name
 	a.titleDisp.Segments[0].(*widget.TextSegment).Text = artist.Name
 	a.titleDisp.Refresh()
 }
@@ -332,9 +372,9 @@ func (a *ArtistPageHeader) UpdateInfo(info *subsonic.ArtistInfo2) {
 
 func (a *ArtistPageHeader) toggleFavorited() {
 	if a.favoriteBtn.IsFavorited {
-		a.artistPage.sm.Server.Star(subsonic.StarParameters{ArtistIDs: []string{a.artistID}})
+		a.artistPage.sm.Server().Star(subsonic.StarParameters{ArtistIDs: []string{a.artistID}})
 	} else {
-		a.artistPage.sm.Server.Unstar(subsonic.StarParameters{ArtistIDs: []string{a.artistID}})
+		a.artistPage.sm.Server().Unstar(subsonic.StarParameters{ArtistIDs: []string{a.artistID}})
 	}
 }
 
@@ -343,7 +383,7 @@ func (a *ArtistPageHeader) createContainer() {
 		container.NewVBox(
 			container.New(&layouts.VboxCustomPadding{ExtraPad: -10},
 				a.titleDisp, a.biographyDisp, a.similarArtists),
-			container.NewHBox(util.NewHSpace(2), a.favoriteBtn, a.playBtn, a.playRadioBtn)))
+			container.NewHBox(util.NewHSpace(2), a.favoriteBtn, a.playBtn, a.shuffleBtn, a.playRadioBtn, a.menuBtn)))
 }
 
 func (a *ArtistPageHeader) CreateRenderer() fyne.WidgetRenderer {
@@ -61,6 +61,13 @@ func newArtistsGenresPage(isGenresPage bool, contr *controller.Controller, sm *b
 			a.contr.NavigateTo(controller.ArtistRoute(id))
 		}
 	}
+	a.list.OnPinToSidebar = func(item widgets.ArtistGenreListItemModel) {
+		itemType := backend.PinnedArtist
+		if a.isGenresPage {
+			itemType = backend.PinnedGenre
+		}
+		a.contr.App.PinnedItems.Pin(itemType, item.ID, item.Name)
+	}
 	a.searcher = widgets.NewSearcher()
 	a.searcher.OnSearched = a.onSearched
 	a.searcher.Entry.Text = searchText
@@ -72,13 +79,13 @@ func newArtistsGenresPage(isGenresPage bool, contr *controller.Controller, sm *b
 // should be called asynchronously
 func (a *ArtistsGenresPage) load(searchOnLoad bool) {
 	if a.isGenresPage {
-		genres, err := a.sm.Server.GetGenres()
+		genres, err := a.sm.Server().GetGenres()
 		if err != nil {
 			log.Printf("error loading genres: %v", err.Error())
 		}
 		a.model = a.buildGenresListModel(genres)
 	} else {
-		artists, err := a.sm.Server.GetArtists(nil)
+		artists, err := a.sm.Server().GetArtists(nil)
 		if err != nil {
 			log.Printf("error loading artists: %v", err.Error())
 		}
@@ -39,11 +39,27 @@ type CanShowNowPlaying interface {
 	OnSongChange(song *subsonic.Child, lastScrobbledIfAny *subsonic.Child)
 }
 
+// Pages that display the play queue should implement this interface to be
+// notified when the play queue or the Auto-DJ setting changes.
+type CanShowPlayQueue interface {
+	OnPlayQueueChange()
+}
+
+// Pages that accept tracks or albums dragged within the window should implement this interface.
+type CanDropItems interface {
+	// Handles items dropped at the given absolute position.
+	// Returns false if the position is not over a drop target on the page.
+	DropItems(items controller.DragItems, pos fyne.Position) bool
+}
+
 type BrowsingPane struct {
 	widget.BaseWidget
 
 	app *backend.App
 
+	// Called when the button to show or hide the sidebar is tapped
+	OnToggleSidebar func()
+
 	curPage Page
 
 	forward    *widget.Button
@@ -52,6 +68,7 @@ type BrowsingPane struct {
 	history    []SavedPage
 	historyIdx int
 
+	sidebarBtn       *widget.Button
 	settingsBtn      *widget.Button
 	settingsMenu     *fyne.Menu
 	navBtnsContainer *fyne.Container
@@ -65,7 +82,14 @@ func NewBrowsingPane(app *backend.App) *BrowsingPane {
 	b.back = widget.NewButtonWithIcon("", theme.NavigateBackIcon(), b.GoBack)
 	b.forward = widget.NewButtonWithIcon("", theme.NavigateNextIcon(), b.GoForward)
 	b.reload = widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), b.Reload)
+	b.sidebarBtn = widget.NewButtonWithIcon("", theme.MenuIcon(), func() {
+		if b.OnToggleSidebar != nil {
+			b.OnToggleSidebar()
+		}
+	})
 	b.app.PlaybackManager.OnSongChange(b.onSongChange)
+	b.app.PlaybackManager.OnPlayQueueChanged(b.onPlayQueueChange)
+	b.app.PlaybackManager.OnAutoDJChanged(func(bool) { b.onPlayQueueChange() })
 	bkgrnd := myTheme.NewThemedRectangle(myTheme.ColorNamePageBackground)
 	b.pageContainer = container.NewMax(bkgrnd, layout.NewSpacer())
 	b.settingsBtn = widget.NewButtonWithIcon("", theme.SettingsIcon(), func() {
@@ -79,7 +103,7 @@ func NewBrowsingPane(app *backend.App) *BrowsingPane {
 	b.container = container.NewBorder(container.New(
 		&layouts.MaxPadLayout{PadLeft: -5, PadRight: -5},
 		container.New(layouts.NewLeftMiddleRightLayout(0),
-			container.NewHBox(b.back, b.forward, b.reload), b.navBtnsContainer,
+			container.NewHBox(b.sidebarBtn, b.back, b.forward, b.reload), b.navBtnsContainer,
 			container.NewHBox(layout.NewSpacer(), b.settingsBtn))),
 		nil, nil, nil, b.pageContainer)
 	return b
@@ -122,6 +146,13 @@ func (b *BrowsingPane) EnableNavigationButtons() {
 	}
 }
 
+// Hides the navigation buttons, e.g. while the sidebar lists the
+// navigation destinations. They can still be activated by shortcuts.
+func (b *BrowsingPane) SetNavigationButtonsVisible(visible bool) {
+	b.navBtnsContainer.Hidden = !visible
+	b.navBtnsContainer.Refresh()
+}
+
 func (b *BrowsingPane) ActivateNavigationButton(num int) {
 	if num < len(b.navBtnsContainer.Objects) {
 		btn := b.navBtnsContainer.Objects[num].(*widget.Button)
@@ -144,6 +175,15 @@ func (b *BrowsingPane) SelectAll() {
 	}
 }
 
+// Passes items dropped at the given absolute position to the current page.
+// Returns false if the page did not accept them.
+func (b *BrowsingPane) DropItems(items controller.DragItems, pos fyne.Position) bool {
+	if p, ok := b.curPage.(CanDropItems); ok {
+		return p.DropItems(items, pos)
+	}
+	return false
+}
+
 func (b *BrowsingPane) doSetPage(p Page) bool {
 	if b.curPage != nil && b.curPage.Route() == p.Route() {
 		return false
@@ -168,6 +208,12 @@ func (b *BrowsingPane) onSongChange(song *subsonic.Child, lastScrobbledIfAny *su
 	}
 }
 
+func (b *BrowsingPane) onPlayQueueChange() {
+	if p, ok := b.curPage.(CanShowPlayQueue); ok {
+		p.OnPlayQueueChange()
+	}
+}
+
 func (b *BrowsingPane) addPageToHistory(p Page, truncate bool) {
 	if truncate {
 		// allow garbage collection of pages that will be removed from the history
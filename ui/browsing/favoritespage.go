@@ -132,7 +132,7 @@ func (a *FavoritesPage) Reload() {
 	if a.tracklistCtr != nil || a.artistListCtr != nil {
 		go func() {
 			// re-fetch starred info from server
-			starred, err := a.sm.Server.GetStarred2(nil)
+			starred, err := a.sm.Server().GetStarred2(nil)
 			if err != nil {
 				log.Printf("error getting starred items: %s", err.Error())
 				return
@@ -245,7 +245,7 @@ func (a *FavoritesPage) onShowFavoriteArtists() {
 			a.createContainer(layout.NewSpacer())
 		}
 		go func() {
-			s, err := a.sm.Server.GetStarred2(nil)
+			s, err := a.sm.Server().GetStarred2(nil)
 			if err != nil {
 				log.Printf("error getting starred items: %s", err.Error())
 				return
@@ -256,6 +256,9 @@ func (a *FavoritesPage) onShowFavoriteArtists() {
 			artistList.OnNavTo = func(artistID string) {
 				a.contr.NavigateTo(controller.ArtistRoute(artistID))
 			}
+			artistList.OnPinToSidebar = func(item widgets.ArtistGenreListItemModel) {
+				a.contr.App.PinnedItems.Pin(backend.PinnedArtist, item.ID, item.Name)
+			}
 			a.artistListCtr = container.New(
 				&layouts.MaxPadLayout{PadLeft: 15, PadRight: 15, PadTop: 5, PadBottom: 15},
 				artistList)
@@ -293,7 +296,7 @@ func (a *FavoritesPage) onShowFavoriteSongs() {
 			a.createContainer(layout.NewSpacer())
 		}
 		go func() {
-			s, err := a.sm.Server.GetStarred2(nil)
+			s, err := a.sm.Server().GetStarred2(nil)
 			if err != nil {
 				log.Printf("error getting starred items: %s", err.Error())
 				return
@@ -30,6 +30,7 @@ type GenrePage struct {
 	searchText string
 	titleDisp  *widget.RichText
 	playRandom *widget.Button
+	pinBtn     *widget.Button
 
 	OnPlayAlbum func(string, int)
 
@@ -50,7 +51,8 @@ func NewGenrePage(genre string, contr *controller.Controller, pm *backend.Playba
 	g.titleDisp.Segments[0].(*widget.TextSegment).Style = widget.RichTextStyle{
 		SizeName: theme.SizeNameHeadingText,
 	}
-	g.playRandom = widget.NewButtonWithIcon(" Play random", myTheme.ShuffleIcon, g.playRandomSongs)
+	g.playRandom = widgets.NewShuffleButton(" Play random", myTheme.ShuffleIcon, g.playRandomSongs)
+	g.pinBtn = widget.NewButton("Pin to sidebar", g.pinToSidebar)
 	iter := g.lm.GenreIter(g.genre)
 	g.grid = widgets.NewGridView(widgets.NewGridViewAlbumIterator(iter), g.im)
 	g.contr.ConnectAlbumGridActions(g.grid)
@@ -67,7 +69,7 @@ func (g *GenrePage) createContainer(searchGrid bool) {
 	if searchGrid {
 		gr = g.searchGrid
 	}
-	playRandomVbox := container.NewVBox(layout.NewSpacer(), g.playRandom, layout.NewSpacer())
+	playRandomVbox := container.NewVBox(layout.NewSpacer(), container.NewHBox(g.playRandom, g.pinBtn), layout.NewSpacer())
 	g.container = container.NewBorder(
 		container.NewHBox(util.NewHSpace(6), g.titleDisp, playRandomVbox, layout.NewSpacer(), searchVbox, util.NewHSpace(15)),
 		nil,
@@ -91,7 +93,8 @@ func restoreGenrePage(saved *savedGenrePage) *GenrePage {
 	g.titleDisp.Segments[0].(*widget.TextSegment).Style = widget.RichTextStyle{
 		SizeName: theme.SizeNameHeadingText,
 	}
-	g.playRandom = widget.NewButtonWithIcon(" Play random", myTheme.ShuffleIcon, g.playRandomSongs)
+	g.playRandom = widgets.NewShuffleButton(" Play random", myTheme.ShuffleIcon, g.playRandomSongs)
+	g.pinBtn = widget.NewButton("Pin to sidebar", g.pinToSidebar)
 	g.grid = widgets.NewGridViewFromState(saved.gridState)
 	g.searcher = widgets.NewSearcher()
 	g.searcher.OnSearched = g.OnSearched
@@ -109,6 +112,10 @@ func (g *GenrePage) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(g.container)
 }
 
+func (g *GenrePage) pinToSidebar() {
+	g.contr.App.PinnedItems.Pin(backend.PinnedGenre, g.genre, g.genre)
+}
+
 func (a *GenrePage) Route() controller.Route {
 	return controller.GenreRoute(a.genre)
 }
@@ -171,8 +178,8 @@ func (g *GenrePage) doSearch(query string) {
 	g.Refresh()
 }
 
-func (g *GenrePage) playRandomSongs() {
-	go g.pm.PlayRandomSongs(g.genre)
+func (g *GenrePage) playRandomSongs(shuffle backend.ShuffleMode) {
+	go g.pm.PlayRandomSongs(g.genre, shuffle)
 }
 
 type savedGenrePage struct {
@@ -9,6 +9,7 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/dweymouth/go-subsonic/subsonic"
 )
@@ -19,6 +20,7 @@ type NowPlayingPage struct {
 	nowPlayingPageState
 
 	title        *widget.RichText
+	autoDJ       *widget.Check
 	tracklist    *widgets.Tracklist
 	nowPlayingID string
 	container    *fyne.Container
@@ -47,16 +49,46 @@ func NewNowPlayingPage(
 	}
 	a.tracklist.AutoNumber = true
 	a.tracklist.DisablePlaybackMenu = true
+	// tracks added by Auto-DJ are shown in italics
+	a.tracklist.IsSecondaryTrack = pm.IsAutoDJTrack
 	contr.ConnectTracklistActions(a.tracklist)
 	// override the default OnPlayTrackAt handler b/c we don't need to re-load the tracks into the queue
 	a.tracklist.OnPlayTrackAt = a.onPlayTrackAt
-	a.tracklist.AuxiliaryMenuItems = []*fyne.MenuItem{
+	reorderMenu := fyne.NewMenuItem("Reorder tracks", nil)
+	reorderMenu.ChildMenu = fyne.NewMenu("", []*fyne.MenuItem{
+		fyne.NewMenuItem("Move to top", func() { a.doReorderSelected(sharedutil.MoveToTop) }),
+		fyne.NewMenuItem("Move up", func() { a.doReorderSelected(sharedutil.MoveUp) }),
+		fyne.NewMenuItem("Move down", func() { a.doReorderSelected(sharedutil.MoveDown) }),
+		fyne.NewMenuItem("Move to bottom", func() { a.doReorderSelected(sharedutil.MoveToBottom) }),
+	}...)
+	a.tracklist.AuxiliaryMenuItems = []*fyne.MenuItem{reorderMenu,
 		fyne.NewMenuItem("Remove from queue", a.onRemoveSelectedFromQueue),
 	}
 	a.title = widget.NewRichTextWithText("Now Playing")
 	a.title.Segments[0].(*widget.TextSegment).Style.SizeName = widget.RichTextStyleHeading.SizeName
+	a.autoDJ = widget.NewCheck("Auto-DJ", func(enabled bool) {
+		if enabled != a.pm.AutoDJEnabled() {
+			a.pm.SetAutoDJEnabled(enabled)
+		}
+	})
+	var pop *widget.PopUpMenu
+	menuBtn := widget.NewButtonWithIcon("", theme.MoreHorizontalIcon(), nil)
+	menuBtn.OnTapped = func() {
+		if pop == nil {
+			menu := fyne.NewMenu("",
+				fyne.NewMenuItem("Save queue as playlist...", a.contr.DoSaveQueueAsPlaylistWorkflow),
+				fyne.NewMenuItem("Add selection to playlist...", a.onAddSelectedToPlaylist),
+				fyne.NewMenuItem("Export queue...", func() {
+					a.contr.DoExportPlaylistWorkflow("Play queue", a.pm.GetPlayQueue())
+				}))
+			pop = widget.NewPopUpMenu(menu, fyne.CurrentApp().Driver().CanvasForObject(a))
+		}
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(menuBtn)
+		pop.ShowAtPosition(fyne.NewPos(pos.X, pos.Y+menuBtn.Size().Height))
+	}
+	header := container.NewBorder(nil, nil, nil, container.NewHBox(container.NewCenter(a.autoDJ), container.NewCenter(menuBtn)), a.title)
 	a.container = container.New(&layouts.MaxPadLayout{PadLeft: 15, PadRight: 15, PadTop: 5, PadBottom: 15},
-		container.NewBorder(a.title, nil, nil, nil, a.tracklist))
+		container.NewBorder(header, nil, nil, nil, a.tracklist))
 	a.load(highlightedTrackID)
 	return a
 }
@@ -96,14 +128,48 @@ func (a *NowPlayingPage) Reload() {
 	a.load("")
 }
 
+func (a *NowPlayingPage) OnPlayQueueChange() {
+	a.load("")
+}
+
 func (a *NowPlayingPage) onPlayTrackAt(tracknum int) {
 	_ = a.pm.PlayTrackAt(tracknum)
 }
 
+func (a *NowPlayingPage) onAddSelectedToPlaylist() {
+	idxs := a.tracklist.SelectedTrackIndexes()
+	if len(idxs) == 0 {
+		return
+	}
+	ids := make([]string, len(idxs))
+	for i, idx := range idxs {
+		ids[i] = a.tracklist.Tracks[idx].ID
+	}
+	a.contr.DoAddTracksToPlaylistWorkflow(ids)
+}
+
 func (a *NowPlayingPage) onRemoveSelectedFromQueue() {
-	a.pm.RemoveTracksFromQueue(a.tracklist.SelectedTrackIndexes())
+	a.contr.RemoveTracksFromQueue(a.tracklist.SelectedTrackIndexes())
+	a.tracklist.UnselectAll()
+}
+
+func (a *NowPlayingPage) doReorderSelected(op sharedutil.TrackReorderOp) {
+	a.contr.ReorderQueueTracks(a.tracklist.SelectedTrackIndexes(), op)
+	a.tracklist.UnselectAll()
+}
+
+// Reorders the play queue when its tracks are dragged within the tracklist.
+func (a *NowPlayingPage) DropItems(items controller.DragItems, pos fyne.Position) bool {
+	if items.Source != a.tracklist {
+		return false
+	}
+	insertIdx := a.tracklist.DropIndexAt(pos)
+	if insertIdx < 0 {
+		return false
+	}
+	a.contr.MoveQueueTracks(items.Indexes, insertIdx)
 	a.tracklist.UnselectAll()
-	a.Reload()
+	return true
 }
 
 // does not make calls to server - can safely be run in UI callbacks
@@ -111,6 +177,7 @@ func (a *NowPlayingPage) load(highlightedTrackID string) {
 	queue := a.pm.GetPlayQueue()
 	a.tracklist.Tracks = queue
 	a.tracklist.SetNowPlaying(a.nowPlayingID)
+	a.autoDJ.SetChecked(a.pm.AutoDJEnabled())
 	if highlightedTrackID != "" {
 		a.tracklist.SelectAndScrollToTrack(highlightedTrackID)
 	}
@@ -112,7 +112,7 @@ func (a *PlaylistPage) SelectAll() {
 
 // should be called asynchronously
 func (a *PlaylistPage) load() {
-	playlist, err := a.sm.Server.GetPlaylist(a.playlistID)
+	playlist, err := a.sm.Server().GetPlaylist(a.playlistID)
 	if err != nil {
 		log.Printf("Failed to get playlist: %s", err.Error())
 		return
@@ -141,14 +141,25 @@ func (a *PlaylistPage) onMoveSelectedToBottom() {
 
 func (a *PlaylistPage) doSetNewTrackOrder(op sharedutil.TrackReorderOp) {
 	idxs := a.tracklist.SelectedTrackIndexes()
-	newTracks := sharedutil.ReorderTracks(a.tracklist.Tracks, idxs, op)
-	ids := make([]string, len(newTracks))
-	for i, tr := range newTracks {
-		ids[i] = tr.ID
+	newTracks, err := a.contr.ReorderPlaylistTracks(a.playlistID, a.header.playlistName(), a.tracklist.Tracks, idxs, op)
+	a.onTracksReordered(newTracks, err)
+}
+
+// Reorders the playlist when its tracks are dragged within the tracklist.
+func (a *PlaylistPage) DropItems(items controller.DragItems, pos fyne.Position) bool {
+	if items.Source != a.tracklist || !a.header.isOwnPlaylist() {
+		return false
 	}
-	err := a.sm.Server.CreatePlaylistWithTracks(ids, map[string]string{
-		"playlistId": a.playlistID,
-	})
+	insertIdx := a.tracklist.DropIndexAt(pos)
+	if insertIdx < 0 {
+		return false
+	}
+	newTracks, err := a.contr.MovePlaylistTracks(a.playlistID, a.header.playlistName(), a.tracklist.Tracks, items.Indexes, insertIdx)
+	a.onTracksReordered(newTracks, err)
+	return true
+}
+
+func (a *PlaylistPage) onTracksReordered(newTracks []*subsonic.Child, err error) {
 	if err != nil {
 		log.Printf("error updating playlist: %s", err.Error())
 	} else {
@@ -158,8 +169,17 @@ func (a *PlaylistPage) doSetNewTrackOrder(op sharedutil.TrackReorderOp) {
 	}
 }
 
+func (a *PlaylistPage) sortTracks(key string) {
+	newTracks, err := a.contr.SortPlaylistTracks(a.playlistID, a.header.playlistName(), a.tracklist.Tracks, key, false)
+	a.onTracksReordered(newTracks, err)
+}
+
 func (a *PlaylistPage) onRemoveSelectedFromPlaylist() {
-	a.sm.Server.UpdatePlaylistTracks(a.playlistID, nil, a.tracklist.SelectedTrackIndexes())
+	err := a.contr.RemoveTracksFromPlaylist(a.playlistID, a.header.playlistName(), a.tracklist.Tracks,
+		a.tracklist.SelectedTrackIndexes())
+	if err != nil {
+		log.Printf("error removing tracks from playlist: %s", err.Error())
+	}
 	a.tracklist.UnselectAll()
 	go a.Reload()
 }
@@ -178,6 +198,10 @@ type PlaylistPageHeader struct {
 	ownerLabel       *widget.Label
 	trackTimeLabel   *widget.Label
 
+	// playlist tools in the menu, disabled if the user isn't the owner
+	removeDuplicatesItem *fyne.MenuItem
+	sortItem             *fyne.MenuItem
+
 	container *fyne.Container
 }
 
@@ -202,28 +226,69 @@ func NewPlaylistPageHeader(page *PlaylistPage) *PlaylistPageHeader {
 	})
 	a.editButton.Hidden = true
 	playButton := widget.NewButtonWithIcon("Play", theme.MediaPlayIcon(), func() {
-		page.pm.LoadTracks(page.tracklist.Tracks, false, false)
+		page.pm.LoadTracks(page.tracklist.Tracks, false, backend.ShuffleNone)
 		page.pm.PlayFromBeginning()
 	})
 	// TODO: find way to pad shuffle svg rather than using a space in the label string
-	shuffleBtn := widget.NewButtonWithIcon(" Shuffle", myTheme.ShuffleIcon, func() {
-		page.pm.LoadTracks(page.tracklist.Tracks, false /*append*/, true /*shuffle*/)
+	shuffleBtn := widgets.NewShuffleButton(" Shuffle", myTheme.ShuffleIcon, func(mode backend.ShuffleMode) {
+		page.pm.LoadTracks(page.tracklist.Tracks, false /*append*/, mode)
 		page.pm.PlayFromBeginning()
 	})
+	a.removeDuplicatesItem = fyne.NewMenuItem("Remove duplicates", nil)
+	a.removeDuplicatesItem.ChildMenu = fyne.NewMenu("",
+		fyne.NewMenuItem("Same track", func() { a.removeDuplicates(false) }),
+		fyne.NewMenuItem("Same artist and title", func() { a.removeDuplicates(true) }))
+	a.sortItem = fyne.NewMenuItem("Sort by", nil)
+	a.sortItem.ChildMenu = fyne.NewMenu("")
+	for _, key := range backend.PlaylistSortKeys {
+		key := key
+		a.sortItem.ChildMenu.Items = append(a.sortItem.ChildMenu.Items,
+			fyne.NewMenuItem(key, func() { page.sortTracks(key) }))
+	}
 	var pop *widget.PopUpMenu
 	menuBtn := widget.NewButtonWithIcon("", theme.MoreHorizontalIcon(), nil)
 	menuBtn.OnTapped = func() {
 		if pop == nil {
 			menu := fyne.NewMenu("",
 				fyne.NewMenuItem("Add to queue", func() {
-					a.page.pm.LoadPlaylist(a.page.playlistID, true /*append*/, false /*shuffle*/)
+					a.page.pm.LoadPlaylist(a.page.playlistID, true /*append*/, backend.ShuffleNone)
+				}),
+				fyne.NewMenuItem("Instant mix", func() {
+					tracks := a.page.tracklist.Tracks
+					go func() {
+						if err := a.page.pm.PlayInstantMix(tracks); err != nil {
+							log.Printf("error playing instant mix: %s", err.Error())
+						}
+					}()
 				}),
 				fyne.NewMenuItem("Add to playlist...", func() {
 					a.page.contr.DoAddTracksToPlaylistWorkflow(
 						sharedutil.TracksToIDs(a.page.tracklist.Tracks))
-				}))
+				}),
+				fyne.NewMenuItem("Export...", func() {
+					if a.playlistInfo != nil {
+						a.page.contr.DoExportPlaylistWorkflow(a.playlistInfo.Name, a.page.tracklist.Tracks)
+					}
+				}),
+				fyne.NewMenuItem("Clone...", func() {
+					if a.playlistInfo != nil {
+						a.page.contr.DoClonePlaylistWorkflow(a.playlistInfo)
+					}
+				}),
+				fyne.NewMenuItem("Find missing tracks", func() {
+					if a.playlistInfo != nil {
+						a.page.contr.DoFindMissingPlaylistTracksWorkflow(a.playlistInfo,
+							a.page.tracklist.Tracks, a.isOwnPlaylist())
+					}
+				}),
+				fyne.NewMenuItemSeparator(),
+				a.removeDuplicatesItem,
+				a.sortItem)
 			pop = widget.NewPopUpMenu(menu, fyne.CurrentApp().Driver().CanvasForObject(a))
 		}
+		// only the owner can modify the playlist
+		a.removeDuplicatesItem.Disabled = !a.isOwnPlaylist()
+		a.sortItem.Disabled = !a.isOwnPlaylist()
 		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(menuBtn)
 		pop.ShowAtPosition(fyne.NewPos(pos.X, pos.Y+menuBtn.Size().Height))
 	}
@@ -242,9 +307,26 @@ func (a *PlaylistPageHeader) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(a.container)
 }
 
+func (a *PlaylistPageHeader) playlistName() string {
+	if a.playlistInfo == nil {
+		return ""
+	}
+	return a.playlistInfo.Name
+}
+
+func (a *PlaylistPageHeader) isOwnPlaylist() bool {
+	return a.playlistInfo != nil && a.playlistInfo.Owner == a.page.sm.Server().User
+}
+
+func (a *PlaylistPageHeader) removeDuplicates(byArtistTitle bool) {
+	if a.playlistInfo != nil {
+		a.page.contr.DoRemoveDuplicatePlaylistTracksWorkflow(a.playlistInfo, a.page.tracklist.Tracks, byArtistTitle)
+	}
+}
+
 func (a *PlaylistPageHeader) Update(playlist *subsonic.Playlist) {
 	a.playlistInfo = playlist
-	a.editButton.Hidden = playlist.Owner != a.page.sm.Server.User
+	a.editButton.Hidden = !a.isOwnPlaylist()
 	a.titleLabel.Segments[0].(*widget.TextSegment).Text = playlist.Name
 	a.descriptionLabel.SetText(playlist.Comment)
 	a.ownerLabel.SetText(a.formatPlaylistOwnerStr(playlist))
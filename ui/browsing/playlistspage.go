@@ -10,6 +10,7 @@ import (
 	"supersonic/sharedutil"
 	"supersonic/ui/controller"
 	"supersonic/ui/layouts"
+	"supersonic/ui/util"
 	"supersonic/ui/widgets"
 
 	"fyne.io/fyne/v2"
@@ -18,8 +19,16 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/dweymouth/go-subsonic/subsonic"
+	"github.com/google/uuid"
 )
 
+// Prefix of the IDs given to smart playlists in the playlist views,
+// to tell them apart from server playlists.
+const smartPlaylistIDPrefix = "smart:"
+
+// Owner shown for local smart playlists
+const smartPlaylistOwner = "Smart playlist"
+
 type PlaylistsPage struct {
 	widget.BaseWidget
 
@@ -29,12 +38,15 @@ type PlaylistsPage struct {
 	playlists         []*subsonic.Playlist
 	searchedPlaylists []*subsonic.Playlist
 
-	viewToggle *widgets.ToggleButtonGroup
-	searcher   *widgets.Searcher
-	titleDisp  *widget.RichText
-	container  *fyne.Container
-	listView   *PlaylistList
-	gridView   *widgets.GridView
+	viewToggle  *widgets.ToggleButtonGroup
+	newSmartBtn *widget.Button
+	importBtn   *widget.Button
+	mergeBtn    *widget.Button
+	searcher    *widgets.Searcher
+	titleDisp   *widget.RichText
+	container   *fyne.Container
+	listView    *PlaylistList
+	gridView    *widgets.GridView
 }
 
 func NewPlaylistsPage(contr *controller.Controller, cfg *backend.PlaylistsPageConfig, sm *backend.ServerManager) *PlaylistsPage {
@@ -61,6 +73,11 @@ func newPlaylistsPage(contr *controller.Controller, cfg *backend.PlaylistsPageCo
 		widget.NewButtonWithIcon("", theme.NewThemedResource(res.ResListSvg), a.showListView),
 		widget.NewButtonWithIcon("", theme.NewThemedResource(res.ResGridSvg), a.showGridView))
 	a.viewToggle.SetActivatedButton(activeView)
+	a.newSmartBtn = widget.NewButtonWithIcon("Smart playlist", theme.ContentAddIcon(), func() {
+		a.contr.DoEditSmartPlaylistWorkflow(nil)
+	})
+	a.importBtn = widget.NewButtonWithIcon("Import", theme.FolderOpenIcon(), a.contr.DoImportPlaylistWorkflow)
+	a.mergeBtn = widget.NewButtonWithIcon("Merge", theme.ContentAddIcon(), a.contr.DoMergePlaylistsWorkflow)
 	if activeView == 0 {
 		a.createListView()
 		a.buildContainer(a.listView)
@@ -74,10 +91,11 @@ func newPlaylistsPage(contr *controller.Controller, cfg *backend.PlaylistsPageCo
 }
 
 func (a *PlaylistsPage) load(searchOnLoad bool) {
-	playlists, err := a.sm.Server.GetPlaylists(nil)
+	playlists, err := a.sm.Server().GetPlaylists(nil)
 	if err != nil {
 		log.Printf("error loading playlists: %v", err.Error())
 	}
+	playlists = append(smartPlaylistsAsPlaylists(a.contr.App.LibraryManager.SmartPlaylists()), playlists...)
 	a.playlists = playlists
 	if searchOnLoad {
 		a.onSearched(a.searcher.Entry.Text)
@@ -94,23 +112,102 @@ func (a *PlaylistsPage) createListView() {
 func (a *PlaylistsPage) createGridView(playlists []*subsonic.Playlist) {
 	model := createPlaylistGridViewModel(playlists)
 	a.gridView = widgets.NewFixedGridView(model, a.contr.App.ImageManager)
+	pm := a.contr.App.PlaybackManager
 	a.gridView.OnPlay = func(id string, shuffle bool) {
-		go a.contr.App.PlaybackManager.PlayPlaylist(id, 0, shuffle)
+		go a.withSmartPlaylistTracks(id, func(tracks []*subsonic.Child) {
+			pm.LoadTracks(tracks, false, backend.ShuffleModeFromBool(shuffle))
+			pm.PlayFromBeginning()
+		}, func() {
+			pm.PlayPlaylist(id, 0, backend.ShuffleModeFromBool(shuffle))
+		})
 	}
 	a.gridView.OnAddToQueue = func(id string) {
-		go a.contr.App.PlaybackManager.LoadPlaylist(id, true, false)
+		go a.withSmartPlaylistTracks(id, func(tracks []*subsonic.Child) {
+			pm.LoadTracks(tracks, true, backend.ShuffleNone)
+		}, func() {
+			pm.LoadPlaylist(id, true, backend.ShuffleNone)
+		})
+	}
+	a.gridView.OnInstantMix = func(id string) {
+		go a.withSmartPlaylistTracks(id, func(tracks []*subsonic.Child) {
+			if err := pm.PlayInstantMix(tracks); err != nil {
+				log.Printf("error playing instant mix: %s", err.Error())
+			}
+		}, func() {
+			if err := pm.PlayPlaylistInstantMix(id); err != nil {
+				log.Printf("error playing instant mix: %s", err.Error())
+			}
+		})
 	}
 	a.gridView.OnShowItemPage = a.showPlaylistPage
 	a.gridView.OnAddToPlaylist = func(id string) {
-		go func() {
-			pl, err := a.contr.App.ServerManager.Server.GetPlaylist(id)
+		go a.withSmartPlaylistTracks(id, func(tracks []*subsonic.Child) {
+			a.contr.DoAddTracksToPlaylistWorkflow(sharedutil.TracksToIDs(tracks))
+		}, func() {
+			pl, err := a.contr.App.ServerManager.Server().GetPlaylist(id)
 			if err != nil {
 				log.Printf("error loading playlist: %s", err.Error())
 				return
 			}
 			a.contr.DoAddTracksToPlaylistWorkflow(sharedutil.TracksToIDs(pl.Entry))
-		}()
+		})
+	}
+}
+
+// Appends tracks or an album dropped onto one of the user's playlists to it.
+func (a *PlaylistsPage) DropItems(items controller.DragItems, pos fyne.Position) bool {
+	var id string
+	if a.listView != nil {
+		id = a.listView.PlaylistIDAt(pos)
+	}
+	if id == "" && a.gridView != nil {
+		id = a.gridView.ItemIDAt(pos)
+	}
+	for _, pl := range a.playlists {
+		// smart playlists can't be added to
+		if pl.ID == id && !strings.HasPrefix(id, smartPlaylistIDPrefix) && pl.Owner == a.sm.Server().User {
+			a.contr.DropOnPlaylist(pl, items)
+			return true
+		}
+	}
+	return false
+}
+
+// Calls onSmart with the evaluated tracks if id is the ID of a smart playlist,
+// otherwise calls onServer. Should be called asynchronously.
+func (a *PlaylistsPage) withSmartPlaylistTracks(id string, onSmart func([]*subsonic.Child), onServer func()) {
+	if !strings.HasPrefix(id, smartPlaylistIDPrefix) {
+		onServer()
+		return
+	}
+	smartID, err := uuid.Parse(strings.TrimPrefix(id, smartPlaylistIDPrefix))
+	if err != nil {
+		return
+	}
+	sp := a.contr.App.LibraryManager.SmartPlaylist(smartID)
+	if sp == nil {
+		return
 	}
+	tracks, err := a.contr.App.LibraryManager.EvaluateSmartPlaylist(sp)
+	if err != nil {
+		log.Printf("error evaluating smart playlist: %s", err.Error())
+		return
+	}
+	onSmart(tracks)
+}
+
+// Returns playlists standing in for the smart playlists in the playlist views.
+// The track count is not known until a smart playlist is evaluated.
+func smartPlaylistsAsPlaylists(smart []*backend.SmartPlaylist) []*subsonic.Playlist {
+	return sharedutil.MapSlice(smart, func(sp *backend.SmartPlaylist) *subsonic.Playlist {
+		return &subsonic.Playlist{
+			ID:        smartPlaylistIDPrefix + sp.ID.String(),
+			Name:      sp.Name,
+			Comment:   sp.RulesDescription(),
+			Owner:     smartPlaylistOwner,
+			SongCount: -1,
+		}
+	})
 }
 
 func (a *PlaylistsPage) showListView() {
@@ -146,16 +243,24 @@ func createPlaylistGridViewModel(playlists []*subsonic.Playlist) []widgets.GridV
 		if pl.SongCount == 1 {
 			tracks = "track"
 		}
+		secondary := fmt.Sprintf("%d %s", pl.SongCount, tracks)
+		if pl.SongCount < 0 {
+			secondary = smartPlaylistOwner
+		}
 		return widgets.GridViewItemModel{
 			Name:       pl.Name,
 			ID:         pl.ID,
 			CoverArtID: pl.CoverArt,
-			Secondary:  fmt.Sprintf("%d %s", pl.SongCount, tracks),
+			Secondary:  secondary,
 		}
 	})
 }
 
 func (a *PlaylistsPage) showPlaylistPage(id string) {
+	if strings.HasPrefix(id, smartPlaylistIDPrefix) {
+		a.contr.NavigateTo(controller.SmartPlaylistRoute(strings.TrimPrefix(id, smartPlaylistIDPrefix)))
+		return
+	}
 	a.contr.NavigateTo(controller.PlaylistRoute(id))
 }
 
@@ -234,7 +339,8 @@ func (a *PlaylistsPage) buildContainer(initialView fyne.CanvasObject) {
 	searchVbox := container.NewVBox(layout.NewSpacer(), a.searcher.Entry, layout.NewSpacer())
 	a.container = container.New(&layouts.MaxPadLayout{PadLeft: 15, PadRight: 15, PadTop: 5, PadBottom: 15},
 		container.NewBorder(
-			container.NewHBox(a.titleDisp, container.NewCenter(a.viewToggle), layout.NewSpacer(), searchVbox),
+			container.NewHBox(a.titleDisp, container.NewCenter(a.viewToggle), container.NewCenter(a.newSmartBtn),
+				container.NewCenter(a.importBtn), container.NewCenter(a.mergeBtn), layout.NewSpacer(), searchVbox),
 			nil, nil, nil, initialView))
 }
 
@@ -251,6 +357,7 @@ type PlaylistList struct {
 	columnsLayout *layouts.ColumnsLayout
 	header        *widgets.ListHeader
 	list          *widget.List
+	rows          []*PlaylistListRow
 	container     *fyne.Container
 }
 
@@ -266,6 +373,7 @@ func NewPlaylistList() *PlaylistList {
 		func() fyne.CanvasObject {
 			r := NewPlaylistListRow(a.columnsLayout)
 			r.OnTapped = func() { a.onRowTapped(r.ID) }
+			a.rows = append(a.rows, r)
 			return r
 		},
 		func(id widget.ListItemID, item fyne.CanvasObject) {
@@ -274,7 +382,10 @@ func NewPlaylistList() *PlaylistList {
 			row.nameLabel.Text = a.Playlists[id].Name
 			row.descrptionLabel.Text = a.Playlists[id].Comment
 			row.ownerLabel.Text = a.Playlists[id].Owner
-			row.trackCountLabel.Text = strconv.Itoa(a.Playlists[id].SongCount)
+			row.trackCountLabel.Text = ""
+			if count := a.Playlists[id].SongCount; count >= 0 {
+				row.trackCountLabel.Text = strconv.Itoa(count)
+			}
 			row.Refresh()
 		},
 	)
@@ -296,6 +407,16 @@ func (p *PlaylistList) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(p.container)
 }
 
+// Returns the ID of the playlist shown at the absolute position, or "" if none.
+func (p *PlaylistList) PlaylistIDAt(pos fyne.Position) string {
+	for _, r := range p.rows {
+		if util.IsPosInObject(r, pos) {
+			return r.ID
+		}
+	}
+	return ""
+}
+
 func (p *PlaylistList) onRowTapped(id string) {
 	if p.OnNavTo != nil {
 		p.OnNavTo(id)
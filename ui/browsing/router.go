@@ -45,6 +45,8 @@ func (r Router) CreatePage(rte controller.Route) Page {
 		return NewNowPlayingPage(rte.Arg, r.Controller, &r.App.Config.NowPlayingPage, r.App.ServerManager, r.App.PlaybackManager)
 	case controller.Playlist:
 		return NewPlaylistPage(rte.Arg, &r.App.Config.PlaylistPage, r.Controller, r.App.ServerManager, r.App.PlaybackManager, r.App.ImageManager)
+	case controller.SmartPlaylist:
+		return NewSmartPlaylistPage(rte.Arg, &r.App.Config.PlaylistPage, r.Controller, r.App.LibraryManager, r.App.PlaybackManager)
 	case controller.Playlists:
 		return NewPlaylistsPage(r.Controller, &r.App.Config.PlaylistsPage, r.App.ServerManager)
 	case controller.Tracks:
@@ -0,0 +1,266 @@
+package browsing
+
+import (
+	"fmt"
+	"log"
+	"supersonic/backend"
+	"supersonic/res"
+	"supersonic/sharedutil"
+	"supersonic/ui/controller"
+	"supersonic/ui/layouts"
+	myTheme "supersonic/ui/theme"
+	"supersonic/ui/util"
+	"supersonic/ui/widgets"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/go-subsonic/subsonic"
+	"github.com/google/uuid"
+)
+
+// Page showing the tracks of a local smart playlist, evaluated against the library.
+type SmartPlaylistPage struct {
+	widget.BaseWidget
+
+	smartPlaylistPageState
+
+	header       *SmartPlaylistPageHeader
+	tracklist    *widgets.Tracklist
+	nowPlayingID string
+	container    *fyne.Container
+}
+
+type smartPlaylistPageState struct {
+	playlistID string
+	conf       *backend.PlaylistPageConfig
+	contr      *controller.Controller
+	lm         *backend.LibraryManager
+	pm         *backend.PlaybackManager
+}
+
+func NewSmartPlaylistPage(
+	playlistID string,
+	conf *backend.PlaylistPageConfig,
+	contr *controller.Controller,
+	lm *backend.LibraryManager,
+	pm *backend.PlaybackManager,
+) *SmartPlaylistPage {
+	a := &SmartPlaylistPage{smartPlaylistPageState: smartPlaylistPageState{playlistID: playlistID, conf: conf, contr: contr, lm: lm, pm: pm}}
+	a.ExtendBaseWidget(a)
+	a.header = NewSmartPlaylistPageHeader(a)
+	a.tracklist = widgets.NewTracklist(nil)
+	a.tracklist.SetVisibleColumns(conf.TracklistColumns)
+	a.tracklist.OnVisibleColumnsChanged = func(cols []string) {
+		conf.TracklistColumns = cols
+	}
+	a.tracklist.AutoNumber = true
+	a.contr.ConnectTracklistActions(a.tracklist)
+
+	a.container = container.NewBorder(
+		container.New(&layouts.MaxPadLayout{PadLeft: 15, PadRight: 15, PadTop: 15, PadBottom: 10}, a.header),
+		nil, nil, nil, container.New(&layouts.MaxPadLayout{PadLeft: 15, PadRight: 15, PadBottom: 15}, a.tracklist))
+	go a.load()
+	return a
+}
+
+func (a *SmartPlaylistPage) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(a.container)
+}
+
+func (a *SmartPlaylistPage) Save() SavedPage {
+	s := a.smartPlaylistPageState
+	return &s
+}
+
+func (a *SmartPlaylistPage) Route() controller.Route {
+	return controller.SmartPlaylistRoute(a.playlistID)
+}
+
+func (a *SmartPlaylistPage) OnSongChange(song *subsonic.Child, lastScrobbledIfAny *subsonic.Child) {
+	a.nowPlayingID = sharedutil.TrackIDOrEmptyStr(song)
+	a.tracklist.SetNowPlaying(a.nowPlayingID)
+	a.tracklist.IncrementPlayCount(sharedutil.TrackIDOrEmptyStr(lastScrobbledIfAny))
+}
+
+func (a *SmartPlaylistPage) Reload() {
+	go a.load()
+}
+
+func (a *SmartPlaylistPage) Tapped(*fyne.PointEvent) {
+	a.tracklist.UnselectAll()
+}
+
+func (a *SmartPlaylistPage) SelectAll() {
+	a.tracklist.SelectAll()
+}
+
+func (a *SmartPlaylistPage) smartPlaylist() *backend.SmartPlaylist {
+	id, err := uuid.Parse(a.playlistID)
+	if err != nil {
+		return nil
+	}
+	return a.lm.SmartPlaylist(id)
+}
+
+// should be called asynchronously
+func (a *SmartPlaylistPage) load() {
+	sp := a.smartPlaylist()
+	if sp == nil {
+		log.Printf("smart playlist %s not found", a.playlistID)
+		return
+	}
+	a.header.Update(sp, nil)
+	tracks, err := a.lm.EvaluateSmartPlaylist(sp)
+	if err != nil {
+		log.Printf("error evaluating smart playlist: %s", err.Error())
+		return
+	}
+	a.tracklist.Tracks = tracks
+	a.tracklist.SetNowPlaying(a.nowPlayingID)
+	a.tracklist.Refresh()
+	a.header.Update(sp, tracks)
+}
+
+// Evaluates the playlist against a freshly fetched library.
+func (a *SmartPlaylistPage) refreshLibrary() {
+	a.lm.InvalidateLibraryCache()
+	a.Reload()
+}
+
+func (a *SmartPlaylistPage) saveToServer() {
+	sp := a.smartPlaylist()
+	if sp == nil {
+		return
+	}
+	go func() {
+		if err := a.lm.MaterializeSmartPlaylist(sp); err != nil {
+			log.Printf("error saving smart playlist: %s", err.Error())
+			return
+		}
+		a.header.Update(sp, a.tracklist.Tracks)
+	}()
+}
+
+type SmartPlaylistPageHeader struct {
+	widget.BaseWidget
+
+	page *SmartPlaylistPage
+
+	titleLabel     *widget.RichText
+	rulesLabel     *widget.Label
+	sortLabel      *widget.Label
+	trackTimeLabel *widget.Label
+	savedLabel     *widget.Label
+
+	container *fyne.Container
+}
+
+func NewSmartPlaylistPageHeader(page *SmartPlaylistPage) *SmartPlaylistPageHeader {
+	a := &SmartPlaylistPageHeader{page: page}
+	a.ExtendBaseWidget(a)
+
+	image := widgets.NewImagePlaceholder(res.ResPlaylistInvertPng, 225)
+	a.titleLabel = widget.NewRichTextWithText("")
+	a.titleLabel.Wrapping = fyne.TextTruncate
+	a.titleLabel.Segments[0].(*widget.TextSegment).Style = widget.RichTextStyle{
+		SizeName: theme.SizeNameHeadingText,
+	}
+	a.rulesLabel = widget.NewLabel("")
+	a.rulesLabel.Wrapping = fyne.TextTruncate
+	a.sortLabel = widget.NewLabel("")
+	a.trackTimeLabel = widget.NewLabel("")
+	a.savedLabel = widget.NewLabel("")
+
+	editButton := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), func() {
+		if sp := page.smartPlaylist(); sp != nil {
+			page.contr.DoEditSmartPlaylistWorkflow(sp)
+		}
+	})
+	playButton := widget.NewButtonWithIcon("Play", theme.MediaPlayIcon(), func() {
+		page.pm.LoadTracks(page.tracklist.Tracks, false, backend.ShuffleNone)
+		page.pm.PlayFromBeginning()
+	})
+	shuffleBtn := widgets.NewShuffleButton(" Shuffle", myTheme.ShuffleIcon, func(mode backend.ShuffleMode) {
+		page.pm.LoadTracks(page.tracklist.Tracks, false /*append*/, mode)
+		page.pm.PlayFromBeginning()
+	})
+	var pop *widget.PopUpMenu
+	menuBtn := widget.NewButtonWithIcon("", theme.MoreHorizontalIcon(), nil)
+	menuBtn.OnTapped = func() {
+		if pop == nil {
+			menu := fyne.NewMenu("",
+				fyne.NewMenuItem("Add to queue", func() {
+					page.pm.LoadTracks(page.tracklist.Tracks, true /*append*/, backend.ShuffleNone)
+				}),
+				fyne.NewMenuItem("Add to playlist...", func() {
+					page.contr.DoAddTracksToPlaylistWorkflow(
+						sharedutil.TracksToIDs(page.tracklist.Tracks))
+				}),
+				fyne.NewMenuItem("Export...", func() {
+					if sp := page.smartPlaylist(); sp != nil {
+						page.contr.DoExportPlaylistWorkflow(sp.Name, page.tracklist.Tracks)
+					}
+				}),
+				fyne.NewMenuItem("Save to server playlist now", page.saveToServer),
+				fyne.NewMenuItem("Refresh library", page.refreshLibrary))
+			pop = widget.NewPopUpMenu(menu, fyne.CurrentApp().Driver().CanvasForObject(a))
+		}
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(menuBtn)
+		pop.ShowAtPosition(fyne.NewPos(pos.X, pos.Y+menuBtn.Size().Height))
+	}
+
+	a.container = container.NewBorder(nil, nil, image, nil,
+		container.NewVBox(a.titleLabel, container.New(&layouts.VboxCustomPadding{ExtraPad: -10},
+			a.rulesLabel,
+			a.sortLabel,
+			a.trackTimeLabel,
+			a.savedLabel),
+			container.NewHBox(editButton, playButton, shuffleBtn, menuBtn),
+		))
+	return a
+}
+
+func (a *SmartPlaylistPageHeader) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(a.container)
+}
+
+// Updates the header for the smart playlist. tracks is nil while
+// the playlist is being evaluated.
+func (a *SmartPlaylistPageHeader) Update(sp *backend.SmartPlaylist, tracks []*subsonic.Child) {
+	a.titleLabel.Segments[0].(*widget.TextSegment).Text = sp.Name
+	a.rulesLabel.SetText(sp.RulesDescription())
+	sortDesc := "Smart playlist sorted by " + sp.SortOrder
+	if sp.Limit > 0 {
+		sortDesc += fmt.Sprintf(", up to %d tracks", sp.Limit)
+	}
+	a.sortLabel.SetText(sortDesc)
+	if tracks == nil {
+		a.trackTimeLabel.SetText("Evaluating rules...")
+	} else {
+		a.trackTimeLabel.SetText(formatTrackCountAndTime(tracks))
+	}
+	a.savedLabel.SetText("")
+	if !sp.LastMaterialized.IsZero() {
+		a.savedLabel.SetText("Saved to server " + sp.LastMaterialized.Format(time.RFC822))
+	}
+	a.Refresh()
+}
+
+func formatTrackCountAndTime(tracks []*subsonic.Child) string {
+	var dur int
+	for _, tr := range tracks {
+		dur += tr.Duration
+	}
+	label := "tracks"
+	if len(tracks) == 1 {
+		label = "track"
+	}
+	return fmt.Sprintf("%d %s, %s", len(tracks), label, util.SecondsToTimeString(float64(dur)))
+}
+
+func (s *smartPlaylistPageState) Restore() Page {
+	return NewSmartPlaylistPage(s.playlistID, s.conf, s.contr, s.lm, s.pm)
+}
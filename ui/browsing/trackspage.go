@@ -155,5 +155,5 @@ func (s *tracksPageState) Restore() Page {
 }
 
 func (t *TracksPage) playRandomSongs() {
-	t.contr.App.PlaybackManager.PlayRandomSongs("")
+	t.contr.App.PlaybackManager.PlayRandomSongs("", backend.ShuffleNone)
 }
@@ -1,10 +1,13 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
 	"log"
-	"math"
 	"strconv"
+	"strings"
 	"supersonic/backend"
 	"supersonic/player"
 	"supersonic/sharedutil"
@@ -16,8 +19,10 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
 	"github.com/dweymouth/go-subsonic/subsonic"
 )
@@ -35,10 +40,14 @@ type Controller struct {
 	NavHandler  NavigationHandler
 	CurPageFunc CurPageFunc
 	ReloadFunc  ReloadFunc
+	DropFunc    DropFunc
 
 	escapablePopUp   *widget.PopUp
 	haveModal        bool
 	runOnModalClosed func()
+	undoStack        undoStack
+	snackbar         *widget.PopUp
+	drag             dragState
 }
 
 func (m *Controller) NavigateTo(route Route) {
@@ -93,28 +102,40 @@ func (m *Controller) ShowPopUpImage(img image.Image) {
 func (m *Controller) ConnectTracklistActions(tracklist *widgets.Tracklist) {
 	tracklist.OnAddToPlaylist = m.DoAddTracksToPlaylistWorkflow
 	tracklist.OnAddToQueue = func(tracks []*subsonic.Child) {
-		m.App.PlaybackManager.LoadTracks(tracks, true, false)
+		m.App.PlaybackManager.LoadTracks(tracks, true, backend.ShuffleNone)
 	}
 	tracklist.OnPlayTrackAt = func(idx int) {
-		m.App.PlaybackManager.LoadTracks(tracklist.Tracks, false, false)
+		m.App.PlaybackManager.LoadTracks(tracklist.Tracks, false, backend.ShuffleNone)
 		m.App.PlaybackManager.PlayTrackAt(idx)
 	}
 	tracklist.OnPlaySelection = func(tracks []*subsonic.Child) {
-		m.App.PlaybackManager.LoadTracks(tracks, false, false)
+		m.App.PlaybackManager.LoadTracks(tracks, false, backend.ShuffleNone)
 		m.App.PlaybackManager.PlayFromBeginning()
 	}
+	tracklist.OnInstantMix = func(tracks []*subsonic.Child) {
+		go func() {
+			if err := m.App.PlaybackManager.PlayInstantMix(tracks); err != nil {
+				log.Printf("error playing instant mix: %s", err.Error())
+			}
+		}()
+	}
 	tracklist.OnSetFavorite = func(trackIDs []string, fav bool) {
-		s := m.App.ServerManager.Server
-		if fav {
-			go s.Star(subsonic.StarParameters{SongIDs: trackIDs})
-		} else {
-			go s.Unstar(subsonic.StarParameters{SongIDs: trackIDs})
-		}
-		for _, id := range trackIDs {
-			m.App.PlaybackManager.OnTrackFavoriteStatusChanged(id, fav)
-		}
+		go func() {
+			if err := m.setTracksFavorite(trackIDs, fav); err != nil {
+				log.Printf("error setting favorite: %s", err.Error())
+			}
+		}()
+		m.recordFavoriteEdit(trackIDs, fav)
 	}
-	tracklist.OnSetRating = m.setTrackRatings
+	tracklist.OnSetRating = func(trackIDs []string, rating int, prevRatings []int) {
+		go func() {
+			if err := m.setTrackRatings(trackIDs, rating); err != nil {
+				log.Printf("error setting rating: %s", err.Error())
+			}
+		}()
+		m.recordRatingEdit(trackIDs, rating, prevRatings)
+	}
+	tracklist.OnDownload = m.DoDownloadTracksWorkflow
 	tracklist.OnShowAlbumPage = func(albumID string) {
 		m.NavigateTo(AlbumRoute(albumID))
 	}
@@ -124,14 +145,22 @@ func (m *Controller) ConnectTracklistActions(tracklist *widgets.Tracklist) {
 	tracklist.OnColumnVisibilityMenuShown = func(pop *widget.PopUp) {
 		m.ClosePopUpOnEscape(pop)
 	}
+	m.connectTracklistDragging(tracklist)
 }
 
 func (m *Controller) ConnectAlbumGridActions(grid *widgets.GridView) {
 	grid.OnAddToQueue = func(albumID string) {
-		m.App.PlaybackManager.LoadAlbum(albumID, true, false)
+		m.App.PlaybackManager.LoadAlbum(albumID, true, backend.ShuffleNone)
 	}
 	grid.OnPlay = func(albumID string, shuffle bool) {
-		m.App.PlaybackManager.PlayAlbum(albumID, 0, shuffle)
+		m.App.PlaybackManager.PlayAlbum(albumID, 0, backend.ShuffleModeFromBool(shuffle))
+	}
+	grid.OnInstantMix = func(albumID string) {
+		go func() {
+			if err := m.App.PlaybackManager.PlayAlbumInstantMix(albumID); err != nil {
+				log.Printf("error playing instant mix: %s", err.Error())
+			}
+		}()
 	}
 	grid.OnShowItemPage = func(albumID string) {
 		m.NavigateTo(AlbumRoute(albumID))
@@ -140,18 +169,26 @@ func (m *Controller) ConnectAlbumGridActions(grid *widgets.GridView) {
 		m.NavigateTo(ArtistRoute(artistID))
 	}
 	grid.OnAddToPlaylist = func(albumID string) {
-		album, err := m.App.ServerManager.Server.GetAlbum(albumID)
+		album, err := m.App.ServerManager.Server().GetAlbum(albumID)
 		if err != nil {
 			log.Printf("error loading album: %s", err.Error())
 			return
 		}
 		m.DoAddTracksToPlaylistWorkflow(sharedutil.TracksToIDs(album.Song))
 	}
+	grid.OnPinToSidebar = func(albumID, name string) {
+		m.App.PinnedItems.Pin(backend.PinnedAlbum, albumID, name)
+	}
+	m.connectAlbumGridDragging(grid)
 }
 
 func (m *Controller) PromptForFirstServer() {
 	d := dialogs.NewAddEditServerDialog("Connect to Server", nil)
 	pop := widget.NewModalPopUp(d, m.MainWindow.Canvas())
+	d.OnLayoutChanged = pop.Refresh
+	d.OnShowDiagnostics = func() {
+		m.ShowConnectionDiagnostics(serverConnectionFromDialog(d), d.Password)
+	}
 	d.OnSubmit = func() {
 		d.DisableSubmit()
 		go func() {
@@ -159,18 +196,8 @@ func (m *Controller) PromptForFirstServer() {
 				// connection is good
 				pop.Hide()
 				m.doModalClosed()
-				conn := backend.ServerConnection{
-					Hostname:    d.Host,
-					AltHostname: d.AltHost,
-					Username:    d.Username,
-					LegacyAuth:  d.LegacyAuth,
-				}
-				server := m.App.Config.AddServer(d.Nickname, conn)
-				if err := m.App.ServerManager.SetServerPassword(server, d.Password); err != nil {
-					log.Printf("error setting keyring credentials: %v", err)
-					// TODO: handle?
-				}
-				m.DoConnectToServerWorkflow(server)
+				server := m.App.Config.AddServer(d.Nickname, serverConnectionFromDialog(d))
+				m.trySetPasswordAndConnectToServer(server, d.Password)
 			}
 			d.EnableSubmit()
 		}()
@@ -183,6 +210,23 @@ func (m *Controller) PromptForFirstServer() {
 // Depending on the results of that dialog, potentially create a new playlist
 // Add tracks to the user-specified playlist
 func (m *Controller) DoAddTracksToPlaylistWorkflow(trackIDs []string) {
+	m.doAddTracksToPlaylist("Add to Playlist", trackIDs, false)
+}
+
+// Show dialog to save the tracks of the play queue to a new playlist,
+// or to replace or append to the tracks of an existing playlist.
+func (m *Controller) DoSaveQueueAsPlaylistWorkflow() {
+	// radio stations in the queue can't be saved to a playlist
+	queue := sharedutil.FilterSlice(m.App.PlaybackManager.GetPlayQueue(), func(tr *subsonic.Child) bool {
+		return tr.ID != ""
+	})
+	if len(queue) == 0 {
+		return
+	}
+	m.doAddTracksToPlaylist("Save Queue as Playlist", sharedutil.TracksToIDs(queue), true)
+}
+
+func (m *Controller) doAddTracksToPlaylist(title string, trackIDs []string, allowReplace bool) {
 	pls, err := m.App.LibraryManager.GetUserOwnedPlaylists()
 	if err != nil {
 		// TODO: surface this error to user
@@ -194,25 +238,66 @@ func (m *Controller) DoAddTracksToPlaylistWorkflow(trackIDs []string) {
 		plNames = append(plNames, pl.Name)
 	}
 
-	dlg := dialogs.NewAddToPlaylistDialog("Add to Playlist", plNames)
+	dlg := dialogs.NewAddToPlaylistDialog(title, plNames)
+	if allowReplace {
+		dlg.ShowReplaceOption()
+	}
 	pop := widget.NewModalPopUp(dlg, m.MainWindow.Canvas())
 	m.ClosePopUpOnEscape(pop)
-	dlg.OnCanceled = pop.Hide
+	dlg.OnCanceled = func() {
+		pop.Hide()
+		m.doModalClosed()
+	}
 	dlg.OnSubmit = func(playlistChoice int, newPlaylistName string) {
 		pop.Hide()
 		m.doModalClosed()
-		if playlistChoice < 0 {
-			m.App.ServerManager.Server.CreatePlaylistWithTracks(
-				trackIDs, map[string]string{"name": newPlaylistName})
-		} else {
-			m.App.ServerManager.Server.UpdatePlaylistTracks(
-				pls[playlistChoice].ID, trackIDs, nil /*tracksToRemove*/)
-		}
+		lm := m.App.LibraryManager
+		go func() {
+			var err error
+			var playlistID string
+			// large track lists are sent in chunks to stay under URL length limits
+			if playlistChoice < 0 {
+				playlistID, err = lm.ReplacePlaylistTracks("", newPlaylistName, trackIDs)
+			} else {
+				playlistID = pls[playlistChoice].ID
+				err = m.addTracksToExistingPlaylist(pls[playlistChoice], trackIDs, dlg.ReplaceTracks)
+			}
+			if err != nil {
+				log.Printf("error saving tracks to playlist: %s", err.Error())
+			} else if playlistChoice < 0 && allowReplace {
+				// show the playlist the queue was saved as
+				m.NavigateTo(PlaylistRoute(playlistID))
+			} else if rte := m.CurPageFunc(); rte.Page == Playlist && rte.Arg == playlistID {
+				m.ReloadFunc()
+			}
+		}()
 	}
 	m.haveModal = true
 	pop.Show()
 }
 
+// Prompts for a destination folder and downloads the tracks into it
+// using the download streaming profile.
+func (m *Controller) DoDownloadTracksWorkflow(tracks []*subsonic.Child) {
+	dlg := dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+		m.doModalClosed()
+		if err != nil || dir == nil {
+			return
+		}
+		go func() {
+			err := m.App.DownloadManager.DownloadTracks(context.Background(), tracks, dir.Path(), nil)
+			msg := fmt.Sprintf("Downloaded %d tracks to %s", len(tracks), dir.Path())
+			if err != nil {
+				log.Printf("error downloading tracks: %s", err.Error())
+				msg = "Error downloading tracks: " + err.Error()
+			}
+			fyne.CurrentApp().SendNotification(fyne.NewNotification("Download finished", msg))
+		}()
+	}, m.MainWindow)
+	m.haveModal = true
+	dlg.Show()
+}
+
 func (m *Controller) DoEditPlaylistWorkflow(playlist *subsonic.Playlist) {
 	dlg := dialogs.NewEditPlaylistDialog(playlist)
 	pop := widget.NewModalPopUp(dlg, m.MainWindow.Canvas())
@@ -230,7 +315,7 @@ func (m *Controller) DoEditPlaylistWorkflow(playlist *subsonic.Playlist) {
 				} else {
 					m.doModalClosed()
 					go func() {
-						if err := m.App.ServerManager.Server.DeletePlaylist(playlist.ID); err != nil {
+						if err := m.App.LibraryManager.DeletePlaylist(playlist.ID); err != nil {
 							log.Printf("error deleting playlist: %s", err.Error())
 						} else if rte := m.CurPageFunc(); rte.Page == Playlist && rte.Arg == playlist.ID {
 							// navigate to playlists page if user is still on the page of the deleted playlist
@@ -244,7 +329,7 @@ func (m *Controller) DoEditPlaylistWorkflow(playlist *subsonic.Playlist) {
 		pop.Hide()
 		m.doModalClosed()
 		go func() {
-			err := m.App.ServerManager.Server.UpdatePlaylist(playlist.ID, map[string]string{
+			err := m.App.LibraryManager.UpdatePlaylist(playlist.ID, map[string]string{
 				"name":    dlg.Name,
 				"comment": dlg.Description,
 				"public":  strconv.FormatBool(dlg.IsPublic),
@@ -261,10 +346,331 @@ func (m *Controller) DoEditPlaylistWorkflow(playlist *subsonic.Playlist) {
 	pop.Show()
 }
 
+// Prompts for the file format and destination and exports
+// the tracks as a playlist file.
+func (m *Controller) DoExportPlaylistWorkflow(name string, tracks []*subsonic.Child) {
+	formats := make([]string, len(backend.PlaylistFormats))
+	for i, f := range backend.PlaylistFormats {
+		formats[i] = string(f)
+	}
+	formatSel := widget.NewSelect(formats, nil)
+	formatSel.SetSelectedIndex(0)
+	streamURLs := widget.NewCheck("Use stream URLs instead of file paths", nil)
+	note := widget.NewLabel("Stream URLs include your login credentials.")
+	note.Wrapping = fyne.TextWrapWord
+	items := []*widget.FormItem{
+		widget.NewFormItem("Format", formatSel),
+		widget.NewFormItem("", streamURLs),
+		widget.NewFormItem("", note),
+	}
+	dlg := dialog.NewForm("Export Playlist", "Export...", "Cancel", items, func(ok bool) {
+		if !ok {
+			m.doModalClosed()
+			return
+		}
+		format := backend.PlaylistFormats[formatSel.SelectedIndex()]
+		saveDlg := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			m.doModalClosed()
+			if err != nil || w == nil {
+				return
+			}
+			defer w.Close()
+			if err := m.App.LibraryManager.ExportPlaylist(w, format, name, tracks, streamURLs.Checked); err != nil {
+				log.Printf("error exporting playlist: %s", err.Error())
+				dialog.ShowError(err, m.MainWindow)
+			}
+		}, m.MainWindow)
+		saveDlg.SetFileName(name + format.Extension())
+		saveDlg.Show()
+	}, m.MainWindow)
+	dlg.Resize(fyne.NewSize(400, dlg.MinSize().Height))
+	m.haveModal = true
+	dlg.Show()
+}
+
+// Prompts for a playlist file, matches its entries to tracks in the library
+// and, after the user has reviewed the matches, creates a new playlist.
+func (m *Controller) DoImportPlaylistWorkflow() {
+	dlg := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil || r == nil {
+			m.doModalClosed()
+			return
+		}
+		defer r.Close()
+		format, err := backend.PlaylistFormatForFile(r.URI().Name())
+		var entries []backend.PlaylistFileEntry
+		var name string
+		if err == nil {
+			name, entries, err = backend.ReadPlaylistFile(r, format)
+		}
+		if err == nil && len(entries) == 0 {
+			err = errors.New("the playlist file has no entries")
+		}
+		if err != nil {
+			log.Printf("error reading playlist file: %s", err.Error())
+			m.doModalClosed()
+			dialog.ShowError(err, m.MainWindow)
+			return
+		}
+		if name == "" {
+			name = strings.TrimSuffix(r.URI().Name(), r.URI().Extension())
+		}
+		progress := dialog.NewProgress("Import Playlist", "Matching tracks...", m.MainWindow)
+		progress.Show()
+		go func() {
+			matches, err := m.App.LibraryManager.MatchPlaylistEntries(entries, func(done int) {
+				progress.SetValue(float64(done) / float64(len(entries)))
+			})
+			progress.Hide()
+			if err != nil {
+				log.Printf("error matching playlist entries: %s", err.Error())
+				m.doModalClosed()
+				dialog.ShowError(err, m.MainWindow)
+				return
+			}
+			m.showImportPlaylistDialog(name, matches)
+		}()
+	}, m.MainWindow)
+	exts := []string{".m3u"}
+	for _, f := range backend.PlaylistFormats {
+		exts = append(exts, f.Extension())
+	}
+	dlg.SetFilter(storage.NewExtensionFileFilter(exts))
+	m.haveModal = true
+	dlg.Show()
+}
+
+func (m *Controller) showImportPlaylistDialog(name string, matches []backend.PlaylistEntryMatch) {
+	dlg := dialogs.NewImportPlaylistDialog(name, matches)
+	pop := widget.NewModalPopUp(dlg, m.MainWindow.Canvas())
+	m.ClosePopUpOnEscape(pop)
+	dlg.OnCancel = func() {
+		pop.Hide()
+		m.doModalClosed()
+	}
+	dlg.OnSubmit = func() {
+		pop.Hide()
+		m.doModalClosed()
+		go func() {
+			id, err := m.App.LibraryManager.ReplacePlaylistTracks("", dlg.Name, dlg.TrackIDs())
+			if err != nil {
+				log.Printf("error creating playlist: %s", err.Error())
+				return
+			}
+			m.NavigateTo(PlaylistRoute(id))
+		}()
+	}
+	pop.Show()
+}
+
+// Shows the dialog to merge several playlists into a new one.
+func (m *Controller) DoMergePlaylistsWorkflow() {
+	pls, err := m.App.ServerManager.Server().GetPlaylists(nil)
+	if err != nil {
+		log.Printf("error getting playlists: %s", err.Error())
+		return
+	}
+	names := make([]string, len(pls))
+	for i, pl := range pls {
+		names[i] = pl.Name
+	}
+	dlg := dialogs.NewMergePlaylistsDialog(names)
+	pop := widget.NewModalPopUp(dlg, m.MainWindow.Canvas())
+	m.ClosePopUpOnEscape(pop)
+	dlg.OnCancel = func() {
+		pop.Hide()
+		m.doModalClosed()
+	}
+	dlg.OnSubmit = func() {
+		pop.Hide()
+		m.doModalClosed()
+		var ids []string
+		for _, idx := range dlg.SelectedIndexes() {
+			ids = append(ids, pls[idx].ID)
+		}
+		go func() {
+			id, err := m.App.LibraryManager.MergePlaylists(dlg.Name, ids, dlg.RemoveDuplicates)
+			if err != nil {
+				log.Printf("error merging playlists: %s", err.Error())
+				return
+			}
+			m.NavigateTo(PlaylistRoute(id))
+		}()
+	}
+	m.haveModal = true
+	pop.Show()
+}
+
+// Prompts for a name and creates a copy of the playlist owned by the user.
+func (m *Controller) DoClonePlaylistWorkflow(playlist *subsonic.Playlist) {
+	name := widget.NewEntry()
+	name.SetText("Copy of " + playlist.Name)
+	name.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return errors.New("name is required")
+		}
+		return nil
+	}
+	dlg := dialog.NewForm("Clone Playlist", "OK", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Name", name)}, func(ok bool) {
+			m.doModalClosed()
+			if !ok {
+				return
+			}
+			go func() {
+				id, err := m.App.LibraryManager.ClonePlaylist(playlist.ID, strings.TrimSpace(name.Text))
+				if err != nil {
+					log.Printf("error cloning playlist: %s", err.Error())
+					return
+				}
+				m.NavigateTo(PlaylistRoute(id))
+			}()
+		}, m.MainWindow)
+	dlg.Resize(fyne.NewSize(350, dlg.MinSize().Height))
+	m.haveModal = true
+	dlg.Show()
+}
+
+// Finds the duplicate tracks of the playlist, which has the given tracks,
+// and removes them after confirmation. Duplicates have the same ID or,
+// if byArtistTitle, the same artist and title.
+func (m *Controller) DoRemoveDuplicatePlaylistTracksWorkflow(playlist *subsonic.Playlist, tracks []*subsonic.Child, byArtistTitle bool) {
+	dupes := backend.DuplicateTrackIndexes(tracks, byArtistTitle)
+	if len(dupes) == 0 {
+		dialog.ShowInformation("Remove Duplicates", "The playlist has no duplicate tracks.", m.MainWindow)
+		return
+	}
+	msg := fmt.Sprintf("Remove %s that duplicate an earlier track?", tracksDescription(len(dupes)))
+	m.confirmRemovePlaylistTracks("Remove Duplicates", msg, playlist, tracks, dupes)
+}
+
+// Finds the entries of the playlist, which has the given tracks, whose tracks
+// no longer exist in the library, and offers to remove them if canEdit.
+func (m *Controller) DoFindMissingPlaylistTracksWorkflow(playlist *subsonic.Playlist, tracks []*subsonic.Child, canEdit bool) {
+	progress := dialog.NewProgressInfinite("Find Missing Tracks", "Checking tracks...", m.MainWindow)
+	progress.Show()
+	go func() {
+		missing, err := m.App.LibraryManager.MissingPlaylistTracks(tracks)
+		progress.Hide()
+		if err != nil {
+			log.Printf("error finding missing tracks: %s", err.Error())
+			dialog.ShowError(err, m.MainWindow)
+			return
+		}
+		if len(missing) == 0 {
+			dialog.ShowInformation("Find Missing Tracks", "All tracks of the playlist are in the library.", m.MainWindow)
+			return
+		}
+		list := container.NewVBox()
+		for _, idx := range missing {
+			list.Add(widget.NewLabel(fmt.Sprintf("%d. %s", idx+1, describeTrack(tracks[idx]))))
+		}
+		scroll := container.NewVScroll(list)
+		scroll.SetMinSize(fyne.NewSize(450, 200))
+		msg := fmt.Sprintf("%s no longer in the library:", tracksDescription(len(missing)))
+		if !canEdit {
+			dialog.ShowCustom("Find Missing Tracks", "Close",
+				container.NewBorder(widget.NewLabel(msg), nil, nil, nil, scroll), m.MainWindow)
+			return
+		}
+		m.confirmRemovePlaylistTracks("Find Missing Tracks", msg, playlist, tracks, missing, scroll)
+	}()
+}
+
+// Asks to confirm the removal of the tracks at the given indexes from the playlist
+// and removes them, optionally showing extra content below the message.
+func (m *Controller) confirmRemovePlaylistTracks(title, msg string, playlist *subsonic.Playlist, tracks []*subsonic.Child, idxs []int, extra ...fyne.CanvasObject) {
+	content := container.NewBorder(widget.NewLabel(msg), nil, nil, nil, extra...)
+	dlg := dialog.NewCustomConfirm(title, "Remove", "Cancel", content, func(ok bool) {
+		m.doModalClosed()
+		if !ok {
+			return
+		}
+		go func() {
+			if err := m.RemoveTracksFromPlaylist(playlist.ID, playlist.Name, tracks, idxs); err != nil {
+				log.Printf("error removing tracks from playlist: %s", err.Error())
+			}
+			m.ReloadFunc()
+		}()
+	}, m.MainWindow)
+	m.haveModal = true
+	dlg.Show()
+}
+
+func describeTrack(tr *subsonic.Child) string {
+	if tr.Artist == "" {
+		return tr.Title
+	}
+	return tr.Artist + " - " + tr.Title
+}
+
+// Shows the dialog to create a smart playlist if sp is nil,
+// or to edit or delete the given smart playlist.
+func (m *Controller) DoEditSmartPlaylistWorkflow(sp *backend.SmartPlaylist) {
+	lm := m.App.LibraryManager
+	title := "Edit Smart Playlist"
+	edited := backend.NewSmartPlaylist("")
+	if sp == nil {
+		title = "New Smart Playlist"
+	} else {
+		edited = *sp
+	}
+	dlg := dialogs.NewEditSmartPlaylistDialog(title, edited, sp == nil)
+	pop := widget.NewModalPopUp(dlg, m.MainWindow.Canvas())
+	m.ClosePopUpOnEscape(pop)
+	dlg.OnCancel = func() {
+		pop.Hide()
+		m.doModalClosed()
+	}
+	dlg.OnDelete = func() {
+		pop.Hide()
+		dialog.ShowCustomConfirm("Confirm Delete Smart Playlist", "OK", "Cancel", layout.NewSpacer(), /*custom content*/
+			func(ok bool) {
+				if !ok {
+					pop.Show()
+					return
+				}
+				m.doModalClosed()
+				lm.DeleteSmartPlaylist(sp.ID)
+				if rte := m.CurPageFunc(); rte.Page == SmartPlaylist && rte.Arg == sp.ID.String() {
+					m.NavigateTo(PlaylistsRoute())
+				} else if rte.Page == Playlists {
+					m.ReloadFunc()
+				}
+			}, m.MainWindow)
+	}
+	dlg.OnSubmit = func() {
+		pop.Hide()
+		m.doModalClosed()
+		if sp == nil {
+			newSp := dlg.Playlist
+			lm.AddSmartPlaylist(&newSp)
+			m.NavigateTo(SmartPlaylistRoute(newSp.ID.String()))
+			return
+		}
+		lm.UpdateSmartPlaylist(sp, dlg.Playlist)
+		if rte := m.CurPageFunc(); rte.Page == Playlists || (rte.Page == SmartPlaylist && rte.Arg == sp.ID.String()) {
+			m.ReloadFunc()
+		}
+	}
+	m.haveModal = true
+	pop.Show()
+}
+
 func (c *Controller) DoConnectToServerWorkflow(server *backend.ServerConfig) {
+	if c.App.CredentialStoreLocked() {
+		c.promptForMasterPassphrase(false, func(ok bool, _ string) {
+			if ok {
+				c.DoConnectToServerWorkflow(server)
+			} else {
+				c.PromptForLoginAndConnect()
+			}
+		})
+		return
+	}
 	pass, err := c.App.ServerManager.GetServerPassword(server)
 	if err != nil {
-		log.Printf("error getting password from keyring: %v", err)
+		log.Printf("error getting saved password: %v", err)
 		c.PromptForLoginAndConnect()
 	} else {
 		if err := c.tryConnectToServer(server, pass); err != nil {
@@ -304,17 +710,18 @@ func (m *Controller) PromptForLoginAndConnect() {
 		pop.Hide()
 		editD := dialogs.NewAddEditServerDialog("Edit server", server)
 		editPop := widget.NewModalPopUp(editD, m.MainWindow.Canvas())
+		editD.OnLayoutChanged = editPop.Refresh
+		editD.OnShowDiagnostics = func() {
+			m.ShowConnectionDiagnostics(serverConnectionFromDialog(editD), editD.Password)
+		}
 		editD.OnSubmit = func() {
 			d.DisableSubmit()
 			go func() {
 				if m.testConnectionAndUpdateDialogText(editD) {
 					// connection is good
 					editPop.Hide()
-					server.Hostname = editD.Host
-					server.AltHostname = editD.AltHost
+					server.ServerConnection = serverConnectionFromDialog(editD)
 					server.Nickname = editD.Nickname
-					server.Username = editD.Username
-					server.LegacyAuth = editD.LegacyAuth
 					m.trySetPasswordAndConnectToServer(server, editD.Password)
 					m.doModalClosed()
 				}
@@ -354,9 +761,24 @@ func (c *Controller) ShowSettingsDialog(themeUpdateCallbk func()) {
 		c.App.Player.SetAudioExclusive(c.App.Config.LocalPlayback.AudioExclusive)
 	}
 	dlg.OnAudioDeviceSettingChanged = func() {
-		c.App.Player.SetAudioDevice(c.App.Config.LocalPlayback.AudioDeviceName)
+		c.App.EqualizerManager.SetAudioDevice(c.App.Config.LocalPlayback.AudioDeviceName)
+	}
+	dlg.OnStreamingSettingsChanged = c.App.PlaybackManager.OnStreamingSettingsChanged
+	dlg.OnTransitionSettingsChanged = func() {
+		c.App.PlaybackManager.SetTransitionOptions(c.App.Config.LocalPlayback)
+	}
+	dlg.OnAutoDJSettingsChanged = func() {
+		c.App.PlaybackManager.OnAutoDJSettingsChanged()
+	}
+	dlg.OnAudioFiltersChanged = func() {
+		if err := c.App.Player.SetAudioFilters(c.App.Config.AudioFilters.PlayerFilters()); err != nil {
+			log.Printf("error setting audio filters: %s", err.Error())
+		}
 	}
 	dlg.OnThemeSettingChanged = themeUpdateCallbk
+	dlg.OnCredentialStoreSettingChanged = func(store string, usePassphrase bool) {
+		c.changeCredentialStore(store, usePassphrase, dlg)
+	}
 	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
 	dlg.OnDismiss = func() {
 		pop.Hide()
@@ -367,12 +789,164 @@ func (c *Controller) ShowSettingsDialog(themeUpdateCallbk func()) {
 	pop.Show()
 }
 
+func (c *Controller) ShowEqualizerDialog() {
+	dlg := dialogs.NewEqualizerDialog(&c.App.Config.Equalizer, c.MainWindow)
+	dlg.OnChanged = func() {
+		if err := c.App.EqualizerManager.Apply(); err != nil {
+			log.Printf("error setting equalizer: %s", err.Error())
+		}
+	}
+	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	dlg.OnDismiss = func() {
+		pop.Hide()
+		c.doModalClosed()
+	}
+	c.ClosePopUpOnEscape(pop)
+	c.haveModal = true
+	pop.Show()
+}
+
+// Shows the dialog to manage the scheduled playbacks (alarms).
+func (c *Controller) ShowSchedulesDialog() {
+	sched := c.App.Scheduler
+	dlg := dialogs.NewSchedulesDialog(sched.Schedules())
+	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	dlg.OnAdd = func() {
+		now := time.Now()
+		c.showEditScheduleDialog("Add Scheduled Playback", backend.ScheduledPlayback{
+			Enabled:    true,
+			Hour:       now.Hour(),
+			Minute:     now.Minute(),
+			Weekdays:   []int{1, 2, 3, 4, 5},
+			SourceType: backend.ScheduleSourcePlaylist,
+		}, func(s backend.ScheduledPlayback) {
+			s.ServerID = c.App.ServerManager.ServerID()
+			sched.AddSchedule(s)
+			dlg.SetSchedules(sched.Schedules())
+		})
+	}
+	dlg.OnEdit = func(sp *backend.ScheduledPlayback) {
+		c.showEditScheduleDialog("Edit Scheduled Playback", *sp, func(s backend.ScheduledPlayback) {
+			sched.UpdateSchedule(s)
+			dlg.SetSchedules(sched.Schedules())
+		})
+	}
+	dlg.OnDelete = func(sp *backend.ScheduledPlayback) {
+		sched.DeleteSchedule(sp.ID)
+		dlg.SetSchedules(sched.Schedules())
+	}
+	dlg.OnPlayNow = func(sp *backend.ScheduledPlayback) {
+		go func() {
+			if err := sched.Run(sp); err != nil {
+				log.Printf("error starting scheduled playback: %s", err.Error())
+			}
+		}()
+	}
+	dlg.OnDismiss = func() {
+		pop.Hide()
+		c.doModalClosed()
+	}
+	c.ClosePopUpOnEscape(pop)
+	c.haveModal = true
+	pop.Show()
+}
+
+// Shows the dialog to edit a schedule on top of the schedules dialog.
+func (c *Controller) showEditScheduleDialog(title string, sp backend.ScheduledPlayback, onSubmit func(backend.ScheduledPlayback)) {
+	dlg := dialogs.NewEditScheduleDialog(title, sp)
+	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	dlg.OnSourceTypeChanged = func(sourceType string) {
+		go c.loadScheduleSourceOptions(dlg, sourceType)
+	}
+	dlg.OnSearchAlbums = func(query string) {
+		go func() {
+			res, err := c.App.ServerManager.Server().Search3(query, map[string]string{
+				"albumCount": "50", "songCount": "0", "artistCount": "0"})
+			if err != nil {
+				log.Printf("error searching albums: %s", err.Error())
+				return
+			}
+			names := make([]string, len(res.Album))
+			ids := make([]string, len(res.Album))
+			for i, al := range res.Album {
+				names[i] = al.Name + " - " + al.Artist
+				ids[i] = al.ID
+			}
+			dlg.SetSourceOptions(names, ids)
+		}()
+	}
+	dlg.OnSubmit = func() {
+		pop.Hide()
+		onSubmit(dlg.Schedule)
+	}
+	dlg.OnCancel = pop.Hide
+	pop.Show()
+	if sp.SourceType != backend.ScheduleSourceAlbum && sp.SourceID == "" {
+		go c.loadScheduleSourceOptions(dlg, sp.SourceType)
+	}
+}
+
+func (c *Controller) loadScheduleSourceOptions(dlg *dialogs.EditScheduleDialog, sourceType string) {
+	var names, ids []string
+	switch sourceType {
+	case backend.ScheduleSourcePlaylist:
+		playlists, err := c.App.ServerManager.Server().GetPlaylists(nil)
+		if err != nil {
+			log.Printf("error loading playlists: %s", err.Error())
+			return
+		}
+		for _, pl := range playlists {
+			names = append(names, pl.Name)
+			ids = append(ids, pl.ID)
+		}
+	case backend.ScheduleSourceGenre:
+		genres, err := c.App.ServerManager.Server().GetGenres()
+		if err != nil {
+			log.Printf("error loading genres: %s", err.Error())
+			return
+		}
+		for _, g := range genres {
+			names = append(names, g.Name)
+			ids = append(ids, g.Name)
+		}
+	case backend.ScheduleSourceRadio:
+		stations, err := c.App.LibraryManager.GetRadioStations()
+		if err != nil {
+			log.Printf("error loading radio stations: %s", err.Error())
+			return
+		}
+		for _, st := range stations {
+			names = append(names, st.Name)
+			ids = append(ids, st.StreamUrl)
+		}
+	default:
+		return
+	}
+	dlg.SetSourceOptions(names, ids)
+}
+
+// Shows a dialog with a report of the connection diagnostics
+// for both the primary and alternate hostnames of the connection.
+// May be shown on top of another modal dialog.
+func (c *Controller) ShowConnectionDiagnostics(connection backend.ServerConnection, password string) {
+	dlg := dialogs.NewDiagnosticsDialog()
+	pop := widget.NewModalPopUp(dlg, c.MainWindow.Canvas())
+	dlg.OnCopyToClipboard = func(report string) {
+		c.MainWindow.Clipboard().SetContent(report)
+	}
+	dlg.OnDismiss = pop.Hide
+	pop.Show()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		dlg.SetReport(backend.RunConnectionDiagnostics(ctx, connection, password).String())
+	}()
+}
+
 func (c *Controller) trySetPasswordAndConnectToServer(server *backend.ServerConfig, password string) error {
 	if err := c.App.ServerManager.SetServerPassword(server, password); err != nil {
-		log.Printf("error setting keyring credentials: %v", err)
-		// TODO: how best to handle this unexpected codepath
-		// fall back to prompting for password on each run of the app?
-		return err
+		// password will be prompted for again on next startup
+		log.Printf("error saving password: %v", err)
 	}
 	return c.tryConnectToServer(server, password)
 }
@@ -390,15 +964,13 @@ func (c *Controller) tryConnectToServer(server *backend.ServerConfig, password s
 
 func (c *Controller) testConnectionAndUpdateDialogText(dlg *dialogs.AddEditServerDialog) bool {
 	dlg.SetInfoText("Testing connection...")
-	conn := backend.ServerConnection{
-		Hostname:    dlg.Host,
-		AltHostname: dlg.AltHost,
-		Username:    dlg.Username,
-		LegacyAuth:  dlg.LegacyAuth,
-	}
+	conn := serverConnectionFromDialog(dlg)
 	err := c.App.ServerManager.TestConnectionAndAuth(conn, dlg.Password, 5*time.Second)
 	if err == backend.ErrUnreachable {
-		dlg.SetErrorText("Could not reach server (wrong hostname?)")
+		dlg.SetErrorText("Could not reach server (see Diagnostics)")
+		return false
+	} else if errors.Is(err, backend.ErrInvalidConnectionOption) || err == backend.ErrAPIKeyAuthUnsupported {
+		dlg.SetErrorText(err.Error())
 		return false
 	} else if err != nil {
 		dlg.SetErrorText("Authentication failed (wrong username/password)")
@@ -407,6 +979,93 @@ func (c *Controller) testConnectionAndUpdateDialogText(dlg *dialogs.AddEditServe
 	return true
 }
 
+// Prompts the user for the master passphrase of the credential store.
+// If isNew, the passphrase is being set and must be entered twice.
+// For an existing passphrase, the store is unlocked before onDone is called.
+func (c *Controller) promptForMasterPassphrase(isNew bool, onDone func(ok bool, passphrase string)) {
+	pass := widget.NewPasswordEntry()
+	items := []*widget.FormItem{widget.NewFormItem("Passphrase", pass)}
+	confirm := widget.NewPasswordEntry()
+	title := "Unlock Saved Passwords"
+	if isNew {
+		title = "Set Master Passphrase"
+		items = append(items, widget.NewFormItem("Confirm", confirm))
+		confirm.Validator = func(s string) error {
+			if s != pass.Text {
+				return errors.New("passphrases do not match")
+			}
+			return nil
+		}
+	}
+	pass.Validator = func(s string) error {
+		if s == "" {
+			return errors.New("passphrase is required")
+		}
+		return nil
+	}
+	// may be shown on top of another modal, such as the settings dialog
+	hadModal := c.haveModal
+	dlg := dialog.NewForm(title, "OK", "Cancel", items, func(ok bool) {
+		if !hadModal {
+			c.doModalClosed()
+		}
+		if !ok || isNew {
+			onDone(ok, pass.Text)
+			return
+		}
+		if err := c.App.UnlockCredentialStore(pass.Text); err != nil {
+			log.Printf("error unlocking credential store: %s", err.Error())
+			errDlg := dialog.NewError(err, c.MainWindow)
+			errDlg.SetOnClosed(func() { c.promptForMasterPassphrase(isNew, onDone) })
+			c.haveModal = true
+			errDlg.Show()
+			return
+		}
+		onDone(true, pass.Text)
+	}, c.MainWindow)
+	dlg.Resize(fyne.NewSize(350, dlg.MinSize().Height))
+	c.haveModal = true
+	dlg.Show()
+}
+
+func (c *Controller) changeCredentialStore(store string, usePassphrase bool, settings *dialogs.SettingsDialog) {
+	doChange := func(passphrase string) {
+		if err := c.App.ChangeCredentialStore(store, usePassphrase, passphrase); err != nil {
+			log.Printf("error changing credential store: %s", err.Error())
+			dialog.ShowError(err, c.MainWindow)
+		}
+		settings.SetCredentialStoreSetting(c.App.Config.Credentials.Store, c.App.Config.Credentials.UseMasterPassphrase)
+	}
+	if store == backend.CredentialStoreFile && usePassphrase {
+		c.promptForMasterPassphrase(true, func(ok bool, passphrase string) {
+			if ok {
+				doChange(passphrase)
+			} else {
+				settings.SetCredentialStoreSetting(c.App.Config.Credentials.Store, c.App.Config.Credentials.UseMasterPassphrase)
+			}
+		})
+		return
+	}
+	doChange("")
+}
+
+func serverConnectionFromDialog(dlg *dialogs.AddEditServerDialog) backend.ServerConnection {
+	return backend.ServerConnection{
+		Hostname:              dlg.Host,
+		AltHostname:           dlg.AltHost,
+		Username:              dlg.Username,
+		LegacyAuth:            dlg.LegacyAuth,
+		APIKeyAuth:            dlg.APIKeyAuth,
+		RequestTimeoutSeconds: dlg.TimeoutSeconds,
+		ProxyURL:              dlg.ProxyURL,
+		CACertFile:            dlg.CACertFile,
+		ClientCertFile:        dlg.ClientCertFile,
+		ClientKeyFile:         dlg.ClientKeyFile,
+		SkipTLSVerify:         dlg.SkipTLSVerify,
+		ExtraHeaders:          dlg.ExtraHeaders,
+	}
+}
+
 func (c *Controller) doModalClosed() {
 	c.haveModal = false
 	if c.runOnModalClosed != nil {
@@ -415,42 +1074,35 @@ func (c *Controller) doModalClosed() {
 	}
 }
 
-func (c *Controller) setTrackRatings(trackIDs []string, rating int) {
+// Sets the rating of the tracks on the server.
+// Returns the first error, after attempting to rate all tracks.
+func (c *Controller) setTrackRatings(trackIDs []string, rating int) error {
+	// Notify PlaybackManager of rating change to update
+	// the in-memory track models
+	for _, id := range trackIDs {
+		c.App.PlaybackManager.OnTrackRatingChanged(id, rating)
+	}
 	// Subsonic doesn't allow bulk setting ratings.
 	// To not overwhelm the server with requests, set rating for
 	// only 5 tracks at a time concurrently
-	batchSize := 5
-	batchSetRating := func(offs int, wg *sync.WaitGroup) {
-		for i := 0; i < batchSize && offs+i < len(trackIDs); i++ {
-			if wg != nil {
-				wg.Add(1)
-			}
+	const batchSize = 5
+	s := c.App.ServerManager.Server()
+	errs := make([]error, len(trackIDs))
+	for offs := 0; offs < len(trackIDs); offs += batchSize {
+		var wg sync.WaitGroup
+		for i := offs; i < offs+batchSize && i < len(trackIDs); i++ {
+			wg.Add(1)
 			go func(idx int) {
-				c.App.ServerManager.Server.SetRating(trackIDs[idx], rating)
-				if wg != nil {
-					wg.Done()
-				}
-			}(offs + i)
+				defer wg.Done()
+				errs[idx] = s.SetRating(trackIDs[idx], rating)
+			}(i)
 		}
+		wg.Wait()
 	}
-
-	if len(trackIDs) <= 5 {
-		// one batch only - no need to use wait group
-		batchSetRating(0, nil)
-	} else {
-		go func() {
-			numBatches := int(math.Ceil(float64(len(trackIDs)) / float64(batchSize)))
-			for i := 0; i < numBatches; i++ {
-				var wg sync.WaitGroup
-				batchSetRating(i*batchSize, &wg)
-				wg.Wait()
-			}
-		}()
-	}
-
-	// Notify PlaybackManager of rating change to update
-	// the in-memory track models
-	for _, id := range trackIDs {
-		c.App.PlaybackManager.OnTrackRatingChanged(id, rating)
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
 	}
+	return nil
 }
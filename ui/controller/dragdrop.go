@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"supersonic/backend"
+	"supersonic/sharedutil"
+	"supersonic/ui/widgets"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+// Offset of the label shown next to the mouse pointer while dragging,
+// so that the label is not under the pointer when the items are dropped.
+const dragLabelOffset = 12
+
+// Tracks or an album being dragged within the main window. They can be
+// dropped onto the play queue or a playlist, or within the tracklist
+// they were dragged from to reorder it.
+type DragItems struct {
+	// The dragged tracks, if dragged from a tracklist
+	Tracks []*subsonic.Child
+	// The tracklist the tracks were dragged from, and their indexes in it
+	Source  *widgets.Tracklist
+	Indexes []int
+
+	// The ID of the dragged album, if dragged from an album grid
+	AlbumID string
+}
+
+// Handles items dropped at the given absolute position in the main window.
+// Returns false if the position is not over a drop target.
+type DropFunc func(items DragItems, pos fyne.Position) bool
+
+type dragState struct {
+	items *DragItems
+	label *widget.PopUp
+	pos   fyne.Position
+}
+
+// Starts dragging the items returned by getItems, or updates
+// the position of the ongoing drag.
+func (m *Controller) onDragged(e *fyne.DragEvent, getItems func() DragItems, description string) {
+	if m.drag.items == nil {
+		items := getItems()
+		m.drag.items = &items
+		m.drag.label = widget.NewPopUp(widget.NewLabel(description), m.MainWindow.Canvas())
+		m.drag.label.ShowAtPosition(e.AbsolutePosition.AddXY(dragLabelOffset, dragLabelOffset))
+	}
+	m.drag.pos = e.AbsolutePosition
+	m.drag.label.Move(e.AbsolutePosition.AddXY(dragLabelOffset, dragLabelOffset))
+}
+
+func (m *Controller) onDragEnd() {
+	d := m.drag
+	m.drag = dragState{}
+	if d.items == nil {
+		return
+	}
+	d.label.Hide()
+	if m.DropFunc != nil {
+		m.DropFunc(*d.items, d.pos)
+	}
+}
+
+func (m *Controller) connectTracklistDragging(tracklist *widgets.Tracklist) {
+	tracklist.OnDragged = func(e *fyne.DragEvent) {
+		idxs := tracklist.SelectedTrackIndexes()
+		m.onDragged(e, func() DragItems {
+			tracks := make([]*subsonic.Child, 0, len(idxs))
+			for _, idx := range idxs {
+				tracks = append(tracks, tracklist.TrackAt(idx))
+			}
+			return DragItems{Tracks: tracks, Source: tracklist, Indexes: idxs}
+		}, tracksDescription(len(idxs)))
+	}
+	tracklist.OnDragEnd = m.onDragEnd
+}
+
+func (m *Controller) connectAlbumGridDragging(grid *widgets.GridView) {
+	grid.OnDragged = func(albumID string, e *fyne.DragEvent) {
+		m.onDragged(e, func() DragItems {
+			return DragItems{AlbumID: albumID}
+		}, "1 album")
+	}
+	grid.OnDragEnd = m.onDragEnd
+}
+
+// Appends the dropped items to the play queue.
+func (m *Controller) DropOnQueue(items DragItems) {
+	pm := m.App.PlaybackManager
+	if items.AlbumID != "" {
+		pm.LoadAlbum(items.AlbumID, true /*append*/, backend.ShuffleNone)
+	} else {
+		pm.LoadTracks(items.Tracks, true /*append*/, backend.ShuffleNone)
+	}
+}
+
+// Appends the dropped items to the playlist, which must be owned by the user.
+func (m *Controller) DropOnPlaylist(playlist *subsonic.Playlist, items DragItems) {
+	go func() {
+		tracks := items.Tracks
+		if items.AlbumID != "" {
+			album, err := m.App.ServerManager.Server().GetAlbum(items.AlbumID)
+			if err != nil {
+				log.Printf("error loading album: %s", err.Error())
+				return
+			}
+			tracks = album.Song
+		}
+		if err := m.addTracksToExistingPlaylist(playlist, sharedutil.TracksToIDs(tracks), false); err != nil {
+			log.Printf("error adding tracks to playlist: %s", err.Error())
+			return
+		}
+		// there is no other feedback that the drop onto the playlist worked
+		m.showSnackbar(fmt.Sprintf("Added %s to %s", tracksDescription(len(tracks)), playlist.Name), "Undo", m.Undo)
+		// the track count on the playlists page or the tracks of the playlist page are out of date
+		if rte := m.CurPageFunc(); rte.Page == Playlists || (rte.Page == Playlist && rte.Arg == playlist.ID) {
+			m.ReloadFunc()
+		}
+	}()
+}
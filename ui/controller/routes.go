@@ -14,6 +14,7 @@ const (
 	NowPlaying
 	Playlist
 	Playlists
+	SmartPlaylist
 	Tracks
 )
 
@@ -49,6 +50,10 @@ func GenresRoute() Route {
 func PlaylistRoute(id string) Route {
 	return Route{Page: Playlist, Arg: id}
 }
+func SmartPlaylistRoute(id string) Route {
+	return Route{Page: SmartPlaylist, Arg: id}
+}
+
 func PlaylistsRoute() Route {
 	return Route{Page: Playlists}
 }
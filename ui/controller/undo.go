@@ -0,0 +1,387 @@
+package controller
+
+import (
+	"fmt"
+	"log"
+	"supersonic/backend"
+	"supersonic/sharedutil"
+	"supersonic/ui/widgets"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+const (
+	// Maximum number of edits that can be undone
+	maxUndoEdits = 50
+	// How long the "Undo" snackbar is shown after a destructive edit
+	snackbarDuration = 5 * time.Second
+)
+
+// An edit of the play queue, a playlist or track metadata that is
+// undone by issuing the inverse operation, and redone by issuing it again.
+type undoableEdit struct {
+	// assigned when the edit is recorded
+	id uint64
+	// e.g. "Removed 3 tracks from queue"
+	description string
+	undo        func() error
+	redo        func() error
+}
+
+type undoStack struct {
+	mu     sync.Mutex
+	undo   []undoableEdit
+	redo   []undoableEdit
+	nextID uint64
+	// held while an edit is being undone or redone,
+	// so that consecutive undos run in order
+	runMu sync.Mutex
+}
+
+// Pushes a newly done edit and returns the ID assigned to it.
+func (s *undoStack) push(edit undoableEdit) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	edit.id = s.nextID
+	s.undo = append(s.undo, edit)
+	if len(s.undo) > maxUndoEdits {
+		s.undo = s.undo[1:]
+	}
+	s.redo = nil
+	return edit.id
+}
+
+// Returns the last edit on the stack, or false if it is empty.
+func (s *undoStack) top(stack *[]undoableEdit) (undoableEdit, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(*stack) == 0 {
+		return undoableEdit{}, false
+	}
+	return (*stack)[len(*stack)-1], true
+}
+
+// Removes the edit with the given ID from the stack, if still there.
+// Edits may have been pushed on top while it was being undone or redone.
+func (s *undoStack) remove(stack *[]undoableEdit, id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, edit := range *stack {
+		if edit.id == id {
+			*stack = append((*stack)[:i], (*stack)[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *undoStack) pushUndone(edit undoableEdit, undone bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if undone {
+		s.redo = append(s.redo, edit)
+	} else {
+		s.undo = append(s.undo, edit)
+	}
+}
+
+// Undoes the last edit of the play queue, a playlist or track metadata.
+func (m *Controller) Undo() {
+	m.runUndoRedo(true, 0)
+}
+
+// Redoes the last undone edit.
+func (m *Controller) Redo() {
+	m.runUndoRedo(false, 0)
+}
+
+// Undoes or redoes the last edit on the respective stack. If onlyID is
+// not 0, does so only if that edit is the one with the given ID.
+// An edit that fails to be undone or redone stays on its stack.
+func (m *Controller) runUndoRedo(undo bool, onlyID uint64) {
+	s := &m.undoStack
+	go func() {
+		s.runMu.Lock()
+		defer s.runMu.Unlock()
+		stack, action, run := &s.redo, "redo", func(e undoableEdit) error { return e.redo() }
+		if undo {
+			stack, action, run = &s.undo, "undo", func(e undoableEdit) error { return e.undo() }
+		}
+		edit, ok := s.top(stack)
+		if !ok || (onlyID != 0 && edit.id != onlyID) {
+			return
+		}
+		if err := run(edit); err != nil {
+			log.Printf("error trying to %s %q: %s", action, edit.description, err.Error())
+			return
+		}
+		s.remove(stack, edit.id)
+		s.pushUndone(edit, undo)
+		// track models on the current page may be out of date
+		m.ReloadFunc()
+	}()
+}
+
+// Records an edit that has been done, so that it can be undone.
+// If destructive, a snackbar offering to undo it is shown.
+func (m *Controller) recordEdit(edit undoableEdit, destructive bool) {
+	id := m.undoStack.push(edit)
+	if destructive {
+		// undo only this edit, and only if no other edit was done since
+		m.showSnackbar(edit.description, "Undo", func() { m.runUndoRedo(true, id) })
+	}
+}
+
+// Shows a snackbar with the message at the bottom of the window
+// for a few seconds, replacing the previous one if still shown.
+func (m *Controller) showSnackbar(message, actionText string, onAction func()) {
+	if m.snackbar != nil {
+		m.snackbar.Hide()
+	}
+	sb := widgets.NewSnackbar(message, actionText)
+	pop := widget.NewPopUp(sb, m.MainWindow.Canvas())
+	sb.OnAction = func() {
+		pop.Hide()
+		onAction()
+	}
+	m.snackbar = pop
+	canvasSize := m.MainWindow.Canvas().Size()
+	size := pop.MinSize()
+	// above the bottom panel
+	pop.ShowAtPosition(fyne.NewPos((canvasSize.Width-size.Width)/2, canvasSize.Height-size.Height-120))
+	time.AfterFunc(snackbarDuration, pop.Hide)
+}
+
+// Removes the tracks at the given (sorted) indexes from the play queue.
+func (m *Controller) RemoveTracksFromQueue(idxs []int) {
+	pm := m.App.PlaybackManager
+	queue := pm.GetPlayQueue()
+	removed := make([]*subsonic.Child, 0, len(idxs))
+	for _, idx := range idxs {
+		if idx >= len(queue) {
+			return
+		}
+		removed = append(removed, queue[idx])
+	}
+	pm.RemoveTracksFromQueue(idxs)
+	m.recordEdit(undoableEdit{
+		description: fmt.Sprintf("Removed %s from queue", tracksDescription(len(idxs))),
+		undo:        func() error { return pm.InsertTracksIntoQueue(removed, idxs) },
+		redo: func() error {
+			queue := pm.GetPlayQueue()
+			for i, idx := range idxs {
+				if idx >= len(queue) || queue[idx].ID != removed[i].ID {
+					return backend.ErrQueueChanged
+				}
+			}
+			pm.RemoveTracksFromQueue(idxs)
+			return nil
+		},
+	}, true)
+}
+
+// Moves the tracks at the given indexes within the play queue.
+func (m *Controller) ReorderQueueTracks(idxs []int, op sharedutil.TrackReorderOp) {
+	m.reorderQueue(len(idxs), func(order []int) []int {
+		return sharedutil.ReorderItems(order, idxs, op)
+	})
+}
+
+// Moves the tracks at the given (sorted) indexes in the play queue
+// so that they are inserted before the track at insertIdx.
+func (m *Controller) MoveQueueTracks(idxs []int, insertIdx int) {
+	m.reorderQueue(len(idxs), func(order []int) []int {
+		return sharedutil.MoveItemsTo(order, idxs, insertIdx)
+	})
+}
+
+// Reorders the play queue by applying reorder to the identity order.
+func (m *Controller) reorderQueue(numMoved int, reorder func([]int) []int) {
+	pm := m.App.PlaybackManager
+	order := make([]int, len(pm.GetPlayQueue()))
+	for i := range order {
+		order[i] = i
+	}
+	order = reorder(order)
+	if err := pm.ReorderQueue(order); err != nil {
+		log.Printf("error reordering play queue: %s", err.Error())
+		return
+	}
+	inverse := make([]int, len(order))
+	for i, j := range order {
+		inverse[j] = i
+	}
+	m.recordEdit(undoableEdit{
+		description: fmt.Sprintf("Moved %s in queue", tracksDescription(numMoved)),
+		undo:        func() error { return pm.ReorderQueue(inverse) },
+		redo:        func() error { return pm.ReorderQueue(order) },
+	}, false)
+}
+
+// Removes the tracks at the given indexes from the playlist, which has the given tracks.
+func (m *Controller) RemoveTracksFromPlaylist(playlistID, name string, tracks []*subsonic.Child, idxs []int) error {
+	lm := m.App.LibraryManager
+	if err := lm.RemovePlaylistTracks(playlistID, idxs); err != nil {
+		return err
+	}
+	oldIDs := sharedutil.TracksToIDs(tracks)
+	m.recordEdit(undoableEdit{
+		description: fmt.Sprintf("Removed %s from %s", tracksDescription(len(idxs)), name),
+		undo: func() error {
+			_, err := lm.ReplacePlaylistTracks(playlistID, name, oldIDs)
+			return err
+		},
+		redo: func() error { return lm.RemovePlaylistTracks(playlistID, idxs) },
+	}, true)
+	return nil
+}
+
+// Moves the tracks at the given indexes within the playlist, which has the given tracks.
+// Returns the reordered tracks.
+func (m *Controller) ReorderPlaylistTracks(playlistID, name string, tracks []*subsonic.Child, idxs []int, op sharedutil.TrackReorderOp) ([]*subsonic.Child, error) {
+	newTracks := sharedutil.ReorderTracks(tracks, idxs, op)
+	desc := fmt.Sprintf("Moved %s in %s", tracksDescription(len(idxs)), name)
+	if err := m.setPlaylistTracks(playlistID, name, tracks, newTracks, desc, false); err != nil {
+		return nil, err
+	}
+	return newTracks, nil
+}
+
+// Moves the tracks at the given (sorted) indexes within the playlist, which has the given
+// tracks, so that they are inserted before the track at insertIdx. Returns the reordered tracks.
+func (m *Controller) MovePlaylistTracks(playlistID, name string, tracks []*subsonic.Child, idxs []int, insertIdx int) ([]*subsonic.Child, error) {
+	newTracks := sharedutil.MoveItemsTo(tracks, idxs, insertIdx)
+	desc := fmt.Sprintf("Moved %s in %s", tracksDescription(len(idxs)), name)
+	if err := m.setPlaylistTracks(playlistID, name, tracks, newTracks, desc, false); err != nil {
+		return nil, err
+	}
+	return newTracks, nil
+}
+
+// Sorts the playlist, which has the given tracks, by one of the backend.PlaylistSortKeys
+// and saves the new order. Returns the sorted tracks.
+func (m *Controller) SortPlaylistTracks(playlistID, name string, tracks []*subsonic.Child, key string, descending bool) ([]*subsonic.Child, error) {
+	newTracks := backend.SortTracks(tracks, key, descending)
+	desc := fmt.Sprintf("Sorted %s by %s", name, key)
+	if err := m.setPlaylistTracks(playlistID, name, tracks, newTracks, desc, false); err != nil {
+		return nil, err
+	}
+	return newTracks, nil
+}
+
+// Replaces the tracks of the playlist, recording the edit so it can be undone.
+func (m *Controller) setPlaylistTracks(playlistID, name string, oldTracks, newTracks []*subsonic.Child, description string, destructive bool) error {
+	lm := m.App.LibraryManager
+	oldIDs := sharedutil.TracksToIDs(oldTracks)
+	newIDs := sharedutil.TracksToIDs(newTracks)
+	if _, err := lm.ReplacePlaylistTracks(playlistID, name, newIDs); err != nil {
+		return err
+	}
+	m.recordEdit(undoableEdit{
+		description: description,
+		undo: func() error {
+			_, err := lm.ReplacePlaylistTracks(playlistID, name, oldIDs)
+			return err
+		},
+		redo: func() error {
+			_, err := lm.ReplacePlaylistTracks(playlistID, name, newIDs)
+			return err
+		},
+	}, destructive)
+	return nil
+}
+
+// Appends the tracks to the existing playlist, or replaces its tracks.
+func (m *Controller) addTracksToExistingPlaylist(pl *subsonic.Playlist, trackIDs []string, replace bool) error {
+	lm := m.App.LibraryManager
+	current, err := m.App.ServerManager.Server().GetPlaylist(pl.ID)
+	if err != nil {
+		return err
+	}
+	if replace {
+		oldIDs := sharedutil.TracksToIDs(current.Entry)
+		if _, err := lm.ReplacePlaylistTracks(pl.ID, pl.Name, trackIDs); err != nil {
+			return err
+		}
+		m.recordEdit(undoableEdit{
+			description: "Replaced the tracks of " + pl.Name,
+			undo: func() error {
+				_, err := lm.ReplacePlaylistTracks(pl.ID, pl.Name, oldIDs)
+				return err
+			},
+			redo: func() error {
+				_, err := lm.ReplacePlaylistTracks(pl.ID, pl.Name, trackIDs)
+				return err
+			},
+		}, true)
+		return nil
+	}
+	if err := lm.AppendPlaylistTracks(pl.ID, trackIDs); err != nil {
+		return err
+	}
+	added := make([]int, len(trackIDs))
+	for i := range added {
+		added[i] = len(current.Entry) + i
+	}
+	m.recordEdit(undoableEdit{
+		description: fmt.Sprintf("Added %s to %s", tracksDescription(len(trackIDs)), pl.Name),
+		undo:        func() error { return lm.RemovePlaylistTracks(pl.ID, added) },
+		redo:        func() error { return lm.AppendPlaylistTracks(pl.ID, trackIDs) },
+	}, false)
+	return nil
+}
+
+// Sets or unsets the tracks as favorites on the server.
+func (m *Controller) setTracksFavorite(trackIDs []string, fav bool) error {
+	for _, id := range trackIDs {
+		m.App.PlaybackManager.OnTrackFavoriteStatusChanged(id, fav)
+	}
+	s := m.App.ServerManager.Server()
+	if fav {
+		return s.Star(subsonic.StarParameters{SongIDs: trackIDs})
+	}
+	return s.Unstar(subsonic.StarParameters{SongIDs: trackIDs})
+}
+
+func (m *Controller) recordFavoriteEdit(trackIDs []string, fav bool) {
+	action := "Unset"
+	if fav {
+		action = "Set"
+	}
+	m.recordEdit(undoableEdit{
+		description: fmt.Sprintf("%s %s as favorite", action, tracksDescription(len(trackIDs))),
+		undo:        func() error { return m.setTracksFavorite(trackIDs, !fav) },
+		redo:        func() error { return m.setTracksFavorite(trackIDs, fav) },
+	}, false)
+}
+
+func (m *Controller) recordRatingEdit(trackIDs []string, rating int, prevRatings []int) {
+	m.recordEdit(undoableEdit{
+		description: fmt.Sprintf("Rated %s", tracksDescription(len(trackIDs))),
+		undo: func() error {
+			// restore the ratings in one batch per previous rating
+			byRating := make(map[int][]string)
+			for i, id := range trackIDs {
+				byRating[prevRatings[i]] = append(byRating[prevRatings[i]], id)
+			}
+			for r, ids := range byRating {
+				if err := m.setTrackRatings(ids, r); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		redo: func() error { return m.setTrackRatings(trackIDs, rating) },
+	}, false)
+}
+
+func tracksDescription(n int) string {
+	if n == 1 {
+		return "1 track"
+	}
+	return fmt.Sprintf("%d tracks", n)
+}
@@ -1,6 +1,9 @@
 package dialogs
 
 import (
+	"sort"
+	"strconv"
+	"strings"
 	"supersonic/backend"
 
 	"fyne.io/fyne/v2"
@@ -11,6 +14,12 @@ import (
 	"fyne.io/fyne/v2/widget"
 )
 
+const (
+	authModeToken  = "Token (default)"
+	authModeLegacy = "Legacy (plain password)"
+	authModeAPIKey = "API key (OpenSubsonic)"
+)
+
 type AddEditServerDialog struct {
 	widget.BaseWidget
 
@@ -20,7 +29,24 @@ type AddEditServerDialog struct {
 	Username   string
 	Password   string
 	LegacyAuth bool
-	OnSubmit   func()
+	APIKeyAuth bool
+
+	// Advanced HTTP options
+	TimeoutSeconds int
+	ProxyURL       string
+	CACertFile     string
+	ClientCertFile string
+	ClientKeyFile  string
+	SkipTLSVerify  bool
+	ExtraHeaders   map[string]string
+
+	OnSubmit func()
+	// Invoked when the user requests to run connection diagnostics
+	// with the currently entered settings
+	OnShowDiagnostics func()
+	// Invoked when the dialog's size changes, e.g. by
+	// showing or hiding the advanced options
+	OnLayoutChanged func()
 
 	submitBtn  *widget.Button
 	promptText *widget.RichText
@@ -38,6 +64,14 @@ func NewAddEditServerDialog(title string, prefillServer *backend.ServerConfig) *
 		a.AltHost = prefillServer.AltHostname
 		a.Username = prefillServer.Username
 		a.LegacyAuth = prefillServer.LegacyAuth
+		a.APIKeyAuth = prefillServer.APIKeyAuth
+		a.TimeoutSeconds = prefillServer.RequestTimeoutSeconds
+		a.ProxyURL = prefillServer.ProxyURL
+		a.CACertFile = prefillServer.CACertFile
+		a.ClientCertFile = prefillServer.ClientCertFile
+		a.ClientKeyFile = prefillServer.ClientKeyFile
+		a.SkipTLSVerify = prefillServer.SkipTLSVerify
+		a.ExtraHeaders = prefillServer.ExtraHeaders
 	}
 
 	titleLabel := widget.NewLabel(title)
@@ -50,8 +84,75 @@ func NewAddEditServerDialog(title string, prefillServer *backend.ServerConfig) *
 	altHostField.SetPlaceHolder("(optional) https://my-external-domain.net/music")
 	userField := widget.NewEntryWithData(binding.BindString(&a.Username))
 	passField := widget.NewPasswordEntry()
-	a.submitBtn = widget.NewButton("Enter", func() {
+	timeoutField := widget.NewEntry()
+	if a.TimeoutSeconds > 0 {
+		timeoutField.SetText(strconv.Itoa(a.TimeoutSeconds))
+	}
+	timeoutField.SetPlaceHolder("(optional) seconds")
+	proxyField := widget.NewEntryWithData(binding.BindString(&a.ProxyURL))
+	proxyField.SetPlaceHolder("(optional) socks5://localhost:1080")
+	caField := widget.NewEntryWithData(binding.BindString(&a.CACertFile))
+	caField.SetPlaceHolder("(optional) path to PEM file")
+	certField := widget.NewEntryWithData(binding.BindString(&a.ClientCertFile))
+	certField.SetPlaceHolder("(optional) path to PEM file")
+	keyField := widget.NewEntryWithData(binding.BindString(&a.ClientKeyFile))
+	keyField.SetPlaceHolder("(optional) path to PEM file")
+	headersField := widget.NewMultiLineEntry()
+	headersField.SetPlaceHolder("Header-Name: value\n(one per line)")
+	headersField.SetText(formatHeaders(a.ExtraHeaders))
+	headersField.SetMinRowsVisible(2)
+	tlsWarning := widget.NewRichText(&widget.TextSegment{
+		Text:  "Warning: disabling certificate verification makes the connection insecure",
+		Style: widget.RichTextStyle{ColorName: theme.ColorNameError, SizeName: theme.SizeNameCaptionText},
+	})
+	tlsWarning.Hidden = !a.SkipTLSVerify
+	skipVerifyCheck := widget.NewCheck("Skip TLS certificate verification", func(b bool) {
+		a.SkipTLSVerify = b
+		tlsWarning.Hidden = !b
+		tlsWarning.Refresh()
+		a.layoutChanged()
+	})
+	skipVerifyCheck.Checked = a.SkipTLSVerify
+
+	advanced := container.NewVBox(
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Timeout"),
+			timeoutField,
+			widget.NewLabel("Proxy"),
+			proxyField,
+			widget.NewLabel("CA bundle"),
+			caField,
+			widget.NewLabel("Client cert"),
+			certField,
+			widget.NewLabel("Client key"),
+			keyField,
+			widget.NewLabel("Extra headers"),
+			headersField,
+		),
+		container.NewHBox(layout.NewSpacer(), skipVerifyCheck),
+		container.NewHBox(layout.NewSpacer(), tlsWarning),
+	)
+	advanced.Hidden = !a.hasAdvancedOptions()
+	advancedCheck := widget.NewCheck("Show advanced options", func(b bool) {
+		advanced.Hidden = !b
+		advanced.Refresh()
+		a.layoutChanged()
+	})
+	advancedCheck.Checked = !advanced.Hidden
+
+	readFields := func() {
 		a.Password = passField.Text
+		a.TimeoutSeconds, _ = strconv.Atoi(strings.TrimSpace(timeoutField.Text))
+		a.ExtraHeaders = parseHeaders(headersField.Text)
+	}
+	diagnosticsBtn := widget.NewButton("Diagnostics...", func() {
+		readFields()
+		if a.OnShowDiagnostics != nil {
+			a.OnShowDiagnostics()
+		}
+	})
+	a.submitBtn = widget.NewButton("Enter", func() {
+		readFields()
 		if a.OnSubmit != nil {
 			a.OnSubmit()
 		}
@@ -59,7 +160,25 @@ func NewAddEditServerDialog(title string, prefillServer *backend.ServerConfig) *
 	a.promptText = widget.NewRichTextWithText("")
 	a.promptText.Hidden = true
 
-	legacyAuthCheck := widget.NewCheckWithData("Use legacy authentication", binding.BindBool(&a.LegacyAuth))
+	passLabel := widget.NewLabel("Password")
+	authOptions := []string{authModeToken, authModeLegacy, authModeAPIKey}
+	authSelect := widget.NewSelect(authOptions, func(mode string) {
+		a.LegacyAuth = mode == authModeLegacy
+		a.APIKeyAuth = mode == authModeAPIKey
+		if a.APIKeyAuth {
+			passLabel.SetText("API key")
+		} else {
+			passLabel.SetText("Password")
+		}
+	})
+	switch {
+	case a.APIKeyAuth:
+		authSelect.SetSelected(authModeAPIKey)
+	case a.LegacyAuth:
+		authSelect.SetSelected(authModeLegacy)
+	default:
+		authSelect.SetSelected(authModeToken)
+	}
 
 	a.container = container.NewVBox(
 		container.NewHBox(layout.NewSpacer(), titleLabel, layout.NewSpacer()),
@@ -72,14 +191,18 @@ func NewAddEditServerDialog(title string, prefillServer *backend.ServerConfig) *
 			altHostField,
 			widget.NewLabel("Username"),
 			userField,
-			widget.NewLabel("Password"),
+			passLabel,
 			passField,
+			widget.NewLabel("Authentication"),
+			authSelect,
 		),
-		container.NewHBox(layout.NewSpacer(), legacyAuthCheck),
+		container.NewHBox(advancedCheck, layout.NewSpacer()),
+		advanced,
 		widget.NewSeparator(),
 		container.NewHBox(
 			a.promptText,
 			layout.NewSpacer(),
+			diagnosticsBtn,
 			a.submitBtn),
 	)
 	return a
@@ -103,6 +226,50 @@ func (a *AddEditServerDialog) DisableSubmit() {
 	a.submitBtn.Refresh()
 }
 
+func (a *AddEditServerDialog) hasAdvancedOptions() bool {
+	return a.TimeoutSeconds > 0 || a.ProxyURL != "" || a.CACertFile != "" ||
+		a.ClientCertFile != "" || a.ClientKeyFile != "" || a.SkipTLSVerify || len(a.ExtraHeaders) > 0
+}
+
+func (a *AddEditServerDialog) layoutChanged() {
+	if a.OnLayoutChanged != nil {
+		a.OnLayoutChanged()
+	}
+}
+
+// parses "Name: value" lines into a header map, ignoring malformed lines
+func parseHeaders(text string) map[string]string {
+	var headers map[string]string
+	for _, line := range strings.Split(text, "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+func formatHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(name + ": " + headers[name])
+	}
+	return sb.String()
+}
+
 func (a *AddEditServerDialog) doSetPromptText(text string, color fyne.ThemeColorName) {
 	ts := a.promptText.Segments[0].(*widget.TextSegment)
 	ts.Text = text
@@ -13,7 +13,12 @@ type AddToPlaylistDialog struct {
 	OnCanceled func()
 	OnSubmit   func(playlistChoice int, newPlaylistName string)
 
+	// Whether the tracks of the chosen existing playlist should be replaced
+	// rather than appended to. Only settable if ShowReplaceOption was called.
+	ReplaceTracks bool
+
 	playlistSelect   *widget.Select
+	replaceCheck     *widget.Check
 	newPlaylistLabel *widget.Label
 	newPlaylistName  *widget.Entry
 	okBtn            *widget.Button
@@ -46,6 +51,11 @@ func NewAddToPlaylistDialog(title string, existingPlaylistNames []string) *AddTo
 	}
 	a.newPlaylistLabel = widget.NewLabel("Name")
 	a.newPlaylistLabel.Hidden = true
+	a.replaceCheck = widget.NewCheck("Replace existing tracks", func(checked bool) {
+		a.ReplaceTracks = checked
+	})
+	a.replaceCheck.Hidden = true
+	a.replaceCheck.Disable()
 
 	a.okBtn = widget.NewButton("OK", a.onOK)
 	a.okBtn.Disable()
@@ -58,12 +68,19 @@ func NewAddToPlaylistDialog(title string, existingPlaylistNames []string) *AddTo
 			a.playlistSelect,
 			a.newPlaylistLabel,
 			a.newPlaylistName),
+		a.replaceCheck,
 		widget.NewSeparator(),
 		container.NewHBox(layout.NewSpacer(), a.okBtn, cancelBtn))
 
 	return a
 }
 
+// Shows the option to replace the tracks of an existing playlist
+// instead of appending to it.
+func (a *AddToPlaylistDialog) ShowReplaceOption() {
+	a.replaceCheck.Show()
+}
+
 func (a *AddToPlaylistDialog) onOK() {
 	var newPlaylistName string
 	playlistChoice := -1
@@ -81,6 +98,7 @@ func (a *AddToPlaylistDialog) onSelectionChanged() {
 	if a.playlistSelect.SelectedIndex() == 0 {
 		a.newPlaylistName.Show()
 		a.newPlaylistLabel.Show()
+		a.replaceCheck.Disable()
 		if len(a.newPlaylistName.Text) == 0 {
 			a.okBtn.Disable()
 		} else {
@@ -89,6 +107,7 @@ func (a *AddToPlaylistDialog) onSelectionChanged() {
 	} else {
 		a.newPlaylistName.Hide()
 		a.newPlaylistLabel.Hide()
+		a.replaceCheck.Enable()
 		a.okBtn.Enable()
 	}
 }
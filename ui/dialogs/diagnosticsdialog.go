@@ -0,0 +1,67 @@
+package dialogs
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+type DiagnosticsDialog struct {
+	widget.BaseWidget
+
+	OnCopyToClipboard func(report string)
+	OnDismiss         func()
+
+	report    string
+	reportLbl *widget.Label
+	copyBtn   *widget.Button
+	container *fyne.Container
+}
+
+var _ fyne.Widget = (*DiagnosticsDialog)(nil)
+
+func NewDiagnosticsDialog() *DiagnosticsDialog {
+	d := &DiagnosticsDialog{}
+	d.ExtendBaseWidget(d)
+
+	title := widget.NewLabel("Connection Diagnostics")
+	title.TextStyle.Bold = true
+	d.reportLbl = widget.NewLabelWithStyle("Running diagnostics...", fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+	d.reportLbl.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(d.reportLbl)
+	scroll.SetMinSize(fyne.NewSize(600, 400))
+
+	d.copyBtn = widget.NewButtonWithIcon("Copy to Clipboard", theme.ContentCopyIcon(), func() {
+		if d.OnCopyToClipboard != nil {
+			d.OnCopyToClipboard(d.report)
+		}
+	})
+	d.copyBtn.Disable()
+	closeBtn := widget.NewButton("Close", func() {
+		if d.OnDismiss != nil {
+			d.OnDismiss()
+		}
+	})
+
+	d.container = container.NewBorder(
+		container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+		container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(layout.NewSpacer(), d.copyBtn, closeBtn),
+		),
+		nil, nil, scroll)
+	return d
+}
+
+// Sets the diagnostics report text to show, and enables copying it.
+func (d *DiagnosticsDialog) SetReport(report string) {
+	d.report = report
+	d.reportLbl.SetText(report)
+	d.copyBtn.Enable()
+}
+
+func (d *DiagnosticsDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
@@ -0,0 +1,223 @@
+package dialogs
+
+import (
+	"errors"
+	"strconv"
+	"supersonic/backend"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+var (
+	rampMinutes   = []int{0, 1, 2, 5, 10, 15, 30}
+	volumeOptions = []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+)
+
+// Dialog to add or edit a scheduled playback. The options for the
+// source are supplied by the handler of OnSourceTypeChanged and
+// OnSearchAlbums, through SetSourceOptions.
+type EditScheduleDialog struct {
+	widget.BaseWidget
+
+	// The schedule being edited. Updated when the dialog is submitted.
+	Schedule backend.ScheduledPlayback
+
+	OnSourceTypeChanged func(sourceType string)
+	OnSearchAlbums      func(query string)
+	OnSubmit            func()
+	OnCancel            func()
+
+	timeEntry    *widget.Entry
+	sourceSelect *widget.Select
+	albumSearch  *widget.Entry
+	submitBtn    *widget.Button
+	sourceIDs    []string
+	container    *fyne.Container
+}
+
+var _ fyne.Widget = (*EditScheduleDialog)(nil)
+
+func NewEditScheduleDialog(title string, sched backend.ScheduledPlayback) *EditScheduleDialog {
+	e := &EditScheduleDialog{Schedule: sched}
+	e.ExtendBaseWidget(e)
+
+	e.timeEntry = widget.NewEntry()
+	e.timeEntry.SetPlaceHolder("HH:MM")
+	e.timeEntry.Text = time.Date(0, 1, 1, sched.Hour, sched.Minute, 0, 0, time.Local).Format("15:04")
+	e.timeEntry.Validator = func(text string) error {
+		if _, err := time.Parse("15:04", text); err != nil {
+			return errors.New("time must be in 24-hour HH:MM format")
+		}
+		return nil
+	}
+	e.timeEntry.OnChanged = func(_ string) { e.updateSubmitEnabled() }
+
+	// Monday first
+	days := container.NewHBox()
+	for i := 1; i <= 7; i++ {
+		day := i % 7
+		check := widget.NewCheck(time.Weekday(day).String()[:3], func(checked bool) {
+			e.setWeekday(day, checked)
+		})
+		for _, d := range e.Schedule.Weekdays {
+			if d == day {
+				check.Checked = true
+			}
+		}
+		days.Add(check)
+	}
+
+	e.sourceSelect = widget.NewSelect(nil, func(_ string) {
+		if idx := e.sourceSelect.SelectedIndex(); idx >= 0 && idx < len(e.sourceIDs) {
+			e.Schedule.SourceID = e.sourceIDs[idx]
+			e.Schedule.SourceName = e.sourceSelect.Selected
+		}
+		e.updateSubmitEnabled()
+	})
+	if sched.SourceName != "" {
+		e.sourceSelect.Options = []string{sched.SourceName}
+		e.sourceIDs = []string{sched.SourceID}
+		e.sourceSelect.Selected = sched.SourceName
+	}
+	e.albumSearch = widget.NewEntry()
+	e.albumSearch.SetPlaceHolder("Search albums and press Enter")
+	e.albumSearch.OnSubmitted = func(query string) {
+		if e.OnSearchAlbums != nil {
+			e.OnSearchAlbums(query)
+		}
+	}
+	e.albumSearch.Hidden = sched.SourceType != backend.ScheduleSourceAlbum
+
+	sourceType := widget.NewSelect(backend.ScheduleSourceTypes, nil)
+	sourceType.Selected = sched.SourceType
+	sourceType.OnChanged = func(t string) {
+		e.Schedule.SourceType = t
+		e.Schedule.SourceID = ""
+		e.Schedule.SourceName = ""
+		e.SetSourceOptions(nil, nil)
+		e.albumSearch.Hidden = t != backend.ScheduleSourceAlbum
+		e.albumSearch.Refresh()
+		if e.OnSourceTypeChanged != nil {
+			e.OnSourceTypeChanged(t)
+		}
+	}
+
+	shuffle := widget.NewCheck("Shuffle", func(checked bool) {
+		e.Schedule.Shuffle = checked
+	})
+	shuffle.Checked = sched.Shuffle
+
+	rampOptions := make([]string, len(rampMinutes))
+	for i, m := range rampMinutes {
+		rampOptions[i] = strconv.Itoa(m) + " min"
+	}
+	rampOptions[0] = "Off"
+	ramp := widget.NewSelect(rampOptions, nil)
+	ramp.SetSelectedIndex(0)
+	for i, m := range rampMinutes {
+		if m == sched.RampMinutes {
+			ramp.SetSelectedIndex(i)
+		}
+	}
+	ramp.OnChanged = func(_ string) {
+		e.Schedule.RampMinutes = rampMinutes[ramp.SelectedIndex()]
+	}
+
+	volOptions := make([]string, len(volumeOptions))
+	for i, v := range volumeOptions {
+		volOptions[i] = strconv.Itoa(v) + "%"
+	}
+	volOptions[0] = "Current volume"
+	volume := widget.NewSelect(volOptions, nil)
+	volume.SetSelectedIndex(0)
+	for i, v := range volumeOptions {
+		if v == sched.Volume {
+			volume.SetSelectedIndex(i)
+		}
+	}
+	volume.OnChanged = func(_ string) {
+		e.Schedule.Volume = volumeOptions[volume.SelectedIndex()]
+	}
+
+	enabled := widget.NewCheck("Enabled", func(checked bool) {
+		e.Schedule.Enabled = checked
+	})
+	enabled.Checked = sched.Enabled
+
+	e.submitBtn = widget.NewButton("OK", func() {
+		t, _ := time.Parse("15:04", e.timeEntry.Text)
+		e.Schedule.Hour, e.Schedule.Minute = t.Hour(), t.Minute()
+		if e.OnSubmit != nil {
+			e.OnSubmit()
+		}
+	})
+	e.submitBtn.Importance = widget.HighImportance
+	cancelBtn := widget.NewButton("Cancel", func() {
+		if e.OnCancel != nil {
+			e.OnCancel()
+		}
+	})
+	e.updateSubmitEnabled()
+
+	titleLbl := widget.NewLabel(title)
+	titleLbl.TextStyle.Bold = true
+	e.container = container.NewVBox(
+		container.NewHBox(layout.NewSpacer(), titleLbl, layout.NewSpacer()),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Time"), e.timeEntry,
+			widget.NewLabel("Days"), days,
+			widget.NewLabel("Play"), sourceType,
+			layout.NewSpacer(), e.albumSearch,
+			layout.NewSpacer(), e.sourceSelect,
+			layout.NewSpacer(), shuffle,
+			widget.NewLabel("Volume"), volume,
+			widget.NewLabel("Fade in"), ramp,
+		),
+		enabled,
+		widget.NewSeparator(),
+		container.NewHBox(layout.NewSpacer(), cancelBtn, e.submitBtn),
+	)
+	return e
+}
+
+// Sets the sources to choose from for the selected source type.
+func (e *EditScheduleDialog) SetSourceOptions(names, ids []string) {
+	e.sourceIDs = ids
+	e.sourceSelect.Options = names
+	e.sourceSelect.ClearSelected()
+	e.sourceSelect.Refresh()
+}
+
+func (e *EditScheduleDialog) setWeekday(day int, checked bool) {
+	days := make([]int, 0, 7)
+	for _, d := range e.Schedule.Weekdays {
+		if d != day {
+			days = append(days, d)
+		}
+	}
+	if checked {
+		days = append(days, day)
+	}
+	e.Schedule.Weekdays = days
+	e.updateSubmitEnabled()
+}
+
+func (e *EditScheduleDialog) updateSubmitEnabled() {
+	if e.timeEntry.Validate() == nil && e.Schedule.SourceID != "" && len(e.Schedule.Weekdays) > 0 {
+		e.submitBtn.Enable()
+	} else {
+		e.submitBtn.Disable()
+	}
+}
+
+func (e *EditScheduleDialog) MinSize() fyne.Size {
+	return fyne.NewSize(450, e.BaseWidget.MinSize().Height)
+}
+
+func (e *EditScheduleDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(e.container)
+}
@@ -0,0 +1,245 @@
+package dialogs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"supersonic/backend"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+var (
+	ratingOptions           = []string{"Any", "1+", "2+", "3+", "4+", "5"}
+	materializeHours        = []int{1, 6, 12, 24, 168}
+	materializeHoursOptions = []string{"Every hour", "Every 6 hours", "Every 12 hours", "Every day", "Every week"}
+)
+
+// Dialog to create or edit the rules of a smart playlist.
+type EditSmartPlaylistDialog struct {
+	widget.BaseWidget
+
+	// The smart playlist being edited. Updated when the dialog is submitted.
+	Playlist backend.SmartPlaylist
+
+	OnSubmit func()
+	OnCancel func()
+	OnDelete func()
+
+	nameEntry     *widget.Entry
+	genresEntry   *widget.Entry
+	minYear       *widget.Entry
+	maxYear       *widget.Entry
+	minPlays      *widget.Entry
+	maxPlays      *widget.Entry
+	notPlayedFor  *widget.Entry
+	playedWithin  *widget.Entry
+	artistEntry   *widget.Entry
+	minBitRate    *widget.Entry
+	suffixesEntry *widget.Entry
+	minDuration   *widget.Entry
+	maxDuration   *widget.Entry
+	limitEntry    *widget.Entry
+	submitBtn     *widget.Button
+	container     *fyne.Container
+}
+
+var _ fyne.Widget = (*EditSmartPlaylistDialog)(nil)
+
+// Creates a dialog to edit the smart playlist. The delete button
+// is only shown if isNew is false.
+func NewEditSmartPlaylistDialog(title string, sp backend.SmartPlaylist, isNew bool) *EditSmartPlaylistDialog {
+	e := &EditSmartPlaylistDialog{Playlist: sp}
+	e.ExtendBaseWidget(e)
+
+	e.nameEntry = widget.NewEntry()
+	e.nameEntry.Text = sp.Name
+	e.nameEntry.OnChanged = func(_ string) { e.updateSubmitEnabled() }
+	e.genresEntry = widget.NewEntry()
+	e.genresEntry.SetPlaceHolder("Any (comma-separated)")
+	e.genresEntry.Text = strings.Join(sp.Genres, ", ")
+	e.artistEntry = widget.NewEntry()
+	e.artistEntry.SetPlaceHolder("Any")
+	e.artistEntry.Text = sp.Artist
+	e.suffixesEntry = widget.NewEntry()
+	e.suffixesEntry.SetPlaceHolder("Any, e.g. flac, mp3")
+	e.suffixesEntry.Text = strings.Join(sp.Suffixes, ", ")
+
+	e.minYear = e.newNumberEntry(sp.MinYear, 0, "From")
+	e.maxYear = e.newNumberEntry(sp.MaxYear, 0, "To")
+	e.minPlays = e.newNumberEntry(sp.MinPlayCount, 0, "Min")
+	e.maxPlays = e.newNumberEntry(sp.MaxPlayCount, 0, "Max")
+	if sp.HasMaxPlayCount {
+		e.maxPlays.Text = strconv.Itoa(sp.MaxPlayCount)
+	}
+	e.notPlayedFor = e.newNumberEntry(sp.NotPlayedForDays, 0, "Days")
+	e.playedWithin = e.newNumberEntry(sp.PlayedWithinDays, 0, "Days")
+	e.minBitRate = e.newNumberEntry(sp.MinBitRate, 0, "kbps")
+	e.minDuration = e.newNumberEntry(sp.MinDuration/60, 0, "Min")
+	e.maxDuration = e.newNumberEntry(sp.MaxDuration/60, 0, "Max")
+	e.limitEntry = e.newNumberEntry(sp.Limit, 0, "Unlimited")
+
+	rating := widget.NewSelect(ratingOptions, func(_ string) {})
+	rating.SetSelectedIndex(sp.MinRating)
+	rating.OnChanged = func(_ string) { e.Playlist.MinRating = rating.SelectedIndex() }
+	starred := widget.NewCheck("Favorites only", func(checked bool) { e.Playlist.StarredOnly = checked })
+	starred.Checked = sp.StarredOnly
+
+	sortOrder := widget.NewSelect(backend.SmartPlaylistSortOrders, func(s string) { e.Playlist.SortOrder = s })
+	sortOrder.Selected = sp.SortOrder
+
+	interval := widget.NewSelect(materializeHoursOptions, nil)
+	interval.SetSelectedIndex(3)
+	for i, h := range materializeHours {
+		if h == sp.MaterializeHours {
+			interval.SetSelectedIndex(i)
+		}
+	}
+	interval.OnChanged = func(_ string) {
+		e.Playlist.MaterializeHours = materializeHours[interval.SelectedIndex()]
+	}
+	if !sp.Materialize {
+		interval.Disable()
+	}
+	materialize := widget.NewCheck("Save to server playlist", func(checked bool) {
+		e.Playlist.Materialize = checked
+		if checked {
+			interval.Enable()
+		} else {
+			interval.Disable()
+		}
+	})
+	materialize.Checked = sp.Materialize
+
+	e.submitBtn = widget.NewButton("OK", func() {
+		e.updatePlaylist()
+		if e.OnSubmit != nil {
+			e.OnSubmit()
+		}
+	})
+	e.submitBtn.Importance = widget.HighImportance
+	cancelBtn := widget.NewButton("Cancel", func() {
+		if e.OnCancel != nil {
+			e.OnCancel()
+		}
+	})
+	deleteBtn := widget.NewButton("Delete Smart Playlist", func() {
+		if e.OnDelete != nil {
+			e.OnDelete()
+		}
+	})
+	deleteBtn.Hidden = isNew
+	e.updateSubmitEnabled()
+
+	titleLbl := widget.NewLabel(title)
+	titleLbl.TextStyle.Bold = true
+	e.container = container.NewVBox(
+		container.NewHBox(layout.NewSpacer(), titleLbl, layout.NewSpacer()),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Name"), e.nameEntry,
+			widget.NewLabel("Genres"), e.genresEntry,
+			widget.NewLabel("Artist"), e.artistEntry,
+			widget.NewLabel("Year"), container.NewGridWithColumns(2, e.minYear, e.maxYear),
+			widget.NewLabel("Rating"), container.NewGridWithColumns(2, rating, starred),
+			widget.NewLabel("Play count"), container.NewGridWithColumns(2, e.minPlays, e.maxPlays),
+			widget.NewLabel("Not played for"), e.notPlayedFor,
+			widget.NewLabel("Played within"), e.playedWithin,
+			widget.NewLabel("Minimum bitrate"), e.minBitRate,
+			widget.NewLabel("File types"), e.suffixesEntry,
+			widget.NewLabel("Duration (minutes)"), container.NewGridWithColumns(2, e.minDuration, e.maxDuration),
+			widget.NewLabel("Sort by"), sortOrder,
+			widget.NewLabel("Limit"), e.limitEntry,
+			materialize, interval,
+		),
+		container.NewHBox(layout.NewSpacer(), deleteBtn),
+		widget.NewSeparator(),
+		container.NewHBox(layout.NewSpacer(), cancelBtn, e.submitBtn),
+	)
+	return e
+}
+
+// Returns an entry for a non-negative number, which is left empty
+// if the value equals unset.
+func (e *EditSmartPlaylistDialog) newNumberEntry(value, unset int, placeholder string) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder(placeholder)
+	if value != unset {
+		entry.Text = strconv.Itoa(value)
+	}
+	entry.Validator = func(text string) error {
+		if text == "" {
+			return nil
+		}
+		if n, err := strconv.Atoi(text); err != nil || n < 0 {
+			return errors.New("must be a whole number")
+		}
+		return nil
+	}
+	entry.OnChanged = func(_ string) { e.updateSubmitEnabled() }
+	return entry
+}
+
+func (e *EditSmartPlaylistDialog) updatePlaylist() {
+	number := func(entry *widget.Entry, unset int) int {
+		if n, err := strconv.Atoi(entry.Text); err == nil {
+			return n
+		}
+		return unset
+	}
+	sp := &e.Playlist
+	sp.Name = strings.TrimSpace(e.nameEntry.Text)
+	sp.Genres = splitList(e.genresEntry.Text)
+	sp.Artist = strings.TrimSpace(e.artistEntry.Text)
+	sp.Suffixes = splitList(e.suffixesEntry.Text)
+	sp.MinYear = number(e.minYear, 0)
+	sp.MaxYear = number(e.maxYear, 0)
+	sp.MinPlayCount = number(e.minPlays, 0)
+	sp.HasMaxPlayCount = e.maxPlays.Text != ""
+	sp.MaxPlayCount = number(e.maxPlays, 0)
+	sp.NotPlayedForDays = number(e.notPlayedFor, 0)
+	sp.PlayedWithinDays = number(e.playedWithin, 0)
+	sp.MinBitRate = number(e.minBitRate, 0)
+	sp.MinDuration = number(e.minDuration, 0) * 60
+	sp.MaxDuration = number(e.maxDuration, 0) * 60
+	sp.Limit = number(e.limitEntry, 0)
+}
+
+// Splits a comma-separated list, dropping empty items.
+func splitList(text string) []string {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (e *EditSmartPlaylistDialog) updateSubmitEnabled() {
+	valid := strings.TrimSpace(e.nameEntry.Text) != ""
+	for _, entry := range []*widget.Entry{e.minYear, e.maxYear, e.minPlays, e.maxPlays, e.notPlayedFor,
+		e.playedWithin, e.minBitRate, e.minDuration, e.maxDuration, e.limitEntry} {
+		if entry != nil && entry.Validate() != nil {
+			valid = false
+		}
+	}
+	if e.submitBtn == nil {
+		return
+	}
+	if valid {
+		e.submitBtn.Enable()
+	} else {
+		e.submitBtn.Disable()
+	}
+}
+
+func (e *EditSmartPlaylistDialog) MinSize() fyne.Size {
+	return fyne.NewSize(500, e.BaseWidget.MinSize().Height)
+}
+
+func (e *EditSmartPlaylistDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(e.container)
+}
@@ -0,0 +1,239 @@
+package dialogs
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"supersonic/backend"
+	"supersonic/player"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// delay after the last slider movement before applying the equalizer,
+// since changing the mpv filter chain briefly interrupts the audio
+const eqApplyDelay = 150 * time.Millisecond
+
+type EqualizerDialog struct {
+	widget.BaseWidget
+
+	// Invoked when the equalizer settings in the config are changed
+	OnChanged func()
+	OnDismiss func()
+
+	config       *backend.EqualizerConfig
+	window       fyne.Window
+	presetSelect *widget.Select
+	deleteBtn    *widget.Button
+	preamp       *eqSlider
+	bands        []*eqSlider
+	updating     bool
+	applyTimer   *time.Timer
+
+	container *fyne.Container
+}
+
+var _ fyne.Widget = (*EqualizerDialog)(nil)
+
+func NewEqualizerDialog(config *backend.EqualizerConfig, window fyne.Window) *EqualizerDialog {
+	e := &EqualizerDialog{config: config, window: window}
+	e.ExtendBaseWidget(e)
+
+	enabled := widget.NewCheck("Enabled", func(checked bool) {
+		e.config.Enabled = checked
+		e.onChanged()
+	})
+	enabled.Checked = config.Enabled
+
+	e.presetSelect = widget.NewSelect(nil, func(name string) {
+		if e.updating {
+			return
+		}
+		if p := e.config.FindPreset(name); p != nil {
+			e.config.LoadPreset(p)
+			e.updateSliders()
+			e.onChanged()
+		}
+		e.updateDeleteButton()
+	})
+	e.presetSelect.PlaceHolder = "(Custom)"
+	saveBtn := widget.NewButton("Save...", e.doSavePreset)
+	e.deleteBtn = widget.NewButton("Delete", func() {
+		e.config.DeleteUserPreset(e.config.Preset)
+		e.updatePresetSelect()
+	})
+
+	perDevice := widget.NewCheck("Remember a separate curve for each audio device", func(checked bool) {
+		e.config.PerDevice = checked
+		e.onChanged()
+	})
+	perDevice.Checked = config.PerDevice
+
+	e.preamp = newEqSlider("Preamp", e.onSliderChanged)
+	sliders := container.NewGridWithColumns(player.NumEqualizerBands+1, e.preamp)
+	for _, f := range player.EqualizerBands {
+		s := newEqSlider(formatFrequency(f), e.onSliderChanged)
+		e.bands = append(e.bands, s)
+		sliders.Add(s)
+	}
+	e.updateSliders()
+	e.updatePresetSelect()
+
+	title := widget.NewLabel("Equalizer")
+	title.TextStyle.Bold = true
+	e.container = container.NewVBox(
+		container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+		container.NewHBox(enabled, layout.NewSpacer(), widget.NewLabel("Preset"),
+			container.NewGridWrap(fyne.NewSize(180, e.presetSelect.MinSize().Height), e.presetSelect),
+			saveBtn, e.deleteBtn),
+		sliders,
+		perDevice,
+		widget.NewSeparator(),
+		container.NewHBox(layout.NewSpacer(), widget.NewButton("Close", func() {
+			if e.OnDismiss != nil {
+				e.OnDismiss()
+			}
+		})),
+	)
+	return e
+}
+
+func (e *EqualizerDialog) doSavePreset() {
+	dialog.ShowEntryDialog("Save Preset", "Name", func(name string) {
+		if name == "" {
+			return
+		}
+		if err := e.config.SaveUserPreset(name); err != nil {
+			dialog.ShowError(err, e.window)
+			return
+		}
+		e.updatePresetSelect()
+		e.onChanged()
+	}, e.window)
+}
+
+func (e *EqualizerDialog) onSliderChanged() {
+	if e.updating {
+		return
+	}
+	e.config.PreampDB = e.preamp.Value()
+	e.config.GainsDB = make([]float64, len(e.bands))
+	for i, b := range e.bands {
+		e.config.GainsDB[i] = b.Value()
+	}
+	if e.config.Preset != "" {
+		e.config.Preset = ""
+		e.updatePresetSelect()
+	}
+	if e.applyTimer != nil {
+		e.applyTimer.Stop()
+	}
+	e.applyTimer = time.AfterFunc(eqApplyDelay, e.onChanged)
+}
+
+func (e *EqualizerDialog) updateSliders() {
+	e.updating = true
+	defer func() { e.updating = false }()
+	e.preamp.SetValue(e.config.PreampDB)
+	for i, b := range e.bands {
+		var g float64
+		if i < len(e.config.GainsDB) {
+			g = e.config.GainsDB[i]
+		}
+		b.SetValue(g)
+	}
+}
+
+func (e *EqualizerDialog) updatePresetSelect() {
+	e.updating = true
+	defer func() { e.updating = false }()
+	presets := e.config.Presets()
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		names[i] = p.Name
+	}
+	e.presetSelect.Options = names
+	if e.config.FindPreset(e.config.Preset) != nil {
+		e.presetSelect.SetSelected(e.config.Preset)
+	} else {
+		e.presetSelect.ClearSelected()
+	}
+	e.updateDeleteButton()
+}
+
+func (e *EqualizerDialog) updateDeleteButton() {
+	p := e.config.Preset
+	if p != "" && !e.config.IsBuiltinPreset(p) && e.config.FindPreset(p) != nil {
+		e.deleteBtn.Enable()
+	} else {
+		e.deleteBtn.Disable()
+	}
+}
+
+func (e *EqualizerDialog) onChanged() {
+	if e.OnChanged != nil {
+		e.OnChanged()
+	}
+}
+
+func (e *EqualizerDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(e.container)
+}
+
+func formatFrequency(f float64) string {
+	if f >= 1000 {
+		return fmt.Sprintf("%gk", f/1000)
+	}
+	return fmt.Sprintf("%g", f)
+}
+
+// A vertical gain slider with its current value and label.
+type eqSlider struct {
+	widget.BaseWidget
+
+	slider   *widget.Slider
+	valueLbl *widget.Label
+	content  *fyne.Container
+}
+
+func newEqSlider(label string, onChanged func()) *eqSlider {
+	s := &eqSlider{}
+	s.ExtendBaseWidget(s)
+	s.slider = widget.NewSlider(-player.MaxEqualizerGainDB, player.MaxEqualizerGainDB)
+	s.slider.Orientation = widget.Vertical
+	s.slider.Step = 0.5
+	s.valueLbl = widget.NewLabelWithStyle("0", fyne.TextAlignCenter, fyne.TextStyle{})
+	s.slider.OnChanged = func(v float64) {
+		s.updateLabel()
+		onChanged()
+	}
+	height := canvas.NewRectangle(color.Transparent)
+	height.SetMinSize(fyne.NewSize(1, 160))
+	s.content = container.NewBorder(s.valueLbl,
+		widget.NewLabelWithStyle(label, fyne.TextAlignCenter, fyne.TextStyle{}),
+		nil, nil, container.NewMax(height, s.slider))
+	return s
+}
+
+func (s *eqSlider) Value() float64 {
+	return s.slider.Value
+}
+
+func (s *eqSlider) SetValue(v float64) {
+	s.slider.SetValue(math.Max(-player.MaxEqualizerGainDB, math.Min(player.MaxEqualizerGainDB, v)))
+	s.updateLabel()
+}
+
+func (s *eqSlider) updateLabel() {
+	s.valueLbl.SetText(fmt.Sprintf("%+.1f", s.slider.Value))
+}
+
+func (s *eqSlider) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(s.content)
+}
@@ -0,0 +1,166 @@
+package dialogs
+
+import (
+	"fmt"
+	"strings"
+	"supersonic/backend"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+const skipTrackOption = "(Skip)"
+
+// Dialog to review how the entries of an imported playlist file were
+// matched to tracks in the library before creating the playlist.
+// Exactly matched entries are only counted; fuzzy matches can be
+// rejected and ambiguous matches resolved by choosing a candidate.
+type ImportPlaylistDialog struct {
+	widget.BaseWidget
+
+	// The name of the playlist to create. Updated when the dialog is submitted.
+	Name string
+
+	OnSubmit func()
+	OnCancel func()
+
+	matches []backend.PlaylistEntryMatch
+	// The chosen track for each match, or nil to skip the entry
+	chosen    []*subsonic.Child
+	nameEntry *widget.Entry
+	summary   *widget.Label
+	submitBtn *widget.Button
+	container *fyne.Container
+}
+
+var _ fyne.Widget = (*ImportPlaylistDialog)(nil)
+
+func NewImportPlaylistDialog(name string, matches []backend.PlaylistEntryMatch) *ImportPlaylistDialog {
+	d := &ImportPlaylistDialog{Name: name, matches: matches}
+	d.ExtendBaseWidget(d)
+
+	d.chosen = make([]*subsonic.Child, len(matches))
+	list := container.NewVBox()
+	for i, m := range matches {
+		switch m.Status {
+		case backend.PlaylistMatchExact, backend.PlaylistMatchFuzzy:
+			d.chosen[i] = m.Track
+		}
+		if row := d.newMatchRow(i); row != nil {
+			list.Add(row)
+		}
+	}
+	if len(list.Objects) == 0 {
+		list.Add(widget.NewLabel("All entries were matched exactly."))
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(600, 300))
+
+	d.nameEntry = widget.NewEntry()
+	d.nameEntry.Text = name
+	d.nameEntry.OnChanged = func(_ string) { d.updateSubmitEnabled() }
+	d.summary = widget.NewLabel("")
+
+	d.submitBtn = widget.NewButton("Create Playlist", func() {
+		d.Name = strings.TrimSpace(d.nameEntry.Text)
+		if d.OnSubmit != nil {
+			d.OnSubmit()
+		}
+	})
+	d.submitBtn.Importance = widget.HighImportance
+	cancelBtn := widget.NewButton("Cancel", func() {
+		if d.OnCancel != nil {
+			d.OnCancel()
+		}
+	})
+	d.updateSummary()
+
+	titleLbl := widget.NewLabel("Import Playlist")
+	titleLbl.TextStyle.Bold = true
+	d.container = container.NewBorder(
+		container.NewVBox(
+			container.NewHBox(layout.NewSpacer(), titleLbl, layout.NewSpacer()),
+			container.New(layout.NewFormLayout(), widget.NewLabel("Name"), d.nameEntry),
+			d.summary,
+		),
+		container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(layout.NewSpacer(), cancelBtn, d.submitBtn),
+		),
+		nil, nil, scroll)
+	return d
+}
+
+// Returns the IDs of the chosen tracks, in playlist order.
+func (d *ImportPlaylistDialog) TrackIDs() []string {
+	var ids []string
+	for _, tr := range d.chosen {
+		if tr != nil {
+			ids = append(ids, tr.ID)
+		}
+	}
+	return ids
+}
+
+// Returns a row to review the match, or nil for exact matches.
+func (d *ImportPlaylistDialog) newMatchRow(i int) fyne.CanvasObject {
+	m := d.matches[i]
+	entry := widget.NewLabel(m.Entry.String())
+	entry.Wrapping = fyne.TextTruncate
+	switch m.Status {
+	case backend.PlaylistMatchFuzzy:
+		check := widget.NewCheck(describeTrack(m.Track), func(checked bool) {
+			d.chosen[i] = nil
+			if checked {
+				d.chosen[i] = m.Track
+			}
+			d.updateSummary()
+		})
+		check.Checked = true
+		return container.NewGridWithColumns(2, entry, check)
+	case backend.PlaylistMatchAmbiguous:
+		options := make([]string, 0, len(m.Candidates)+1)
+		for _, tr := range m.Candidates {
+			options = append(options, describeTrack(tr))
+		}
+		options = append(options, skipTrackOption)
+		sel := widget.NewSelect(options, nil)
+		sel.PlaceHolder = "Choose a track"
+		sel.OnChanged = func(_ string) {
+			d.chosen[i] = nil
+			if idx := sel.SelectedIndex(); idx >= 0 && idx < len(m.Candidates) {
+				d.chosen[i] = m.Candidates[idx]
+			}
+			d.updateSummary()
+		}
+		return container.NewGridWithColumns(2, entry, sel)
+	case backend.PlaylistMatchNone:
+		return container.NewGridWithColumns(2, entry, widget.NewLabel("Not found, skipped"))
+	}
+	return nil
+}
+
+func describeTrack(tr *subsonic.Child) string {
+	return fmt.Sprintf("%s - %s (%s)", tr.Artist, tr.Title, tr.Album)
+}
+
+func (d *ImportPlaylistDialog) updateSummary() {
+	n := len(d.TrackIDs())
+	d.summary.SetText(fmt.Sprintf("%d of %d entries will be added to the playlist", n, len(d.matches)))
+	d.updateSubmitEnabled()
+}
+
+func (d *ImportPlaylistDialog) updateSubmitEnabled() {
+	if strings.TrimSpace(d.nameEntry.Text) != "" && len(d.TrackIDs()) > 0 {
+		d.submitBtn.Enable()
+	} else {
+		d.submitBtn.Disable()
+	}
+}
+
+func (d *ImportPlaylistDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
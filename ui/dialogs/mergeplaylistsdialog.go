@@ -0,0 +1,100 @@
+package dialogs
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Dialog to choose several playlists to merge into a new one.
+type MergePlaylistsDialog struct {
+	widget.BaseWidget
+
+	// The name of the playlist to create. Updated when the dialog is submitted.
+	Name string
+	// Whether tracks already in an earlier chosen playlist should be skipped
+	RemoveDuplicates bool
+
+	OnSubmit func()
+	OnCancel func()
+
+	checks    []*widget.Check
+	nameEntry *widget.Entry
+	submitBtn *widget.Button
+	container *fyne.Container
+}
+
+var _ fyne.Widget = (*MergePlaylistsDialog)(nil)
+
+func NewMergePlaylistsDialog(playlistNames []string) *MergePlaylistsDialog {
+	d := &MergePlaylistsDialog{RemoveDuplicates: true}
+	d.ExtendBaseWidget(d)
+
+	titleLabel := widget.NewLabel("Merge Playlists")
+	titleLabel.TextStyle.Bold = true
+	list := container.NewVBox()
+	for _, name := range playlistNames {
+		check := widget.NewCheck(name, func(_ bool) { d.updateSubmitEnabled() })
+		d.checks = append(d.checks, check)
+		list.Add(check)
+	}
+	scroll := container.NewVScroll(list)
+	scroll.SetMinSize(fyne.NewSize(350, 250))
+
+	d.nameEntry = widget.NewEntry()
+	d.nameEntry.OnChanged = func(_ string) { d.updateSubmitEnabled() }
+	dedupeCheck := widget.NewCheck("Remove duplicates", func(checked bool) {
+		d.RemoveDuplicates = checked
+	})
+	dedupeCheck.Checked = true
+
+	d.submitBtn = widget.NewButton("Merge", func() {
+		d.Name = strings.TrimSpace(d.nameEntry.Text)
+		if d.OnSubmit != nil {
+			d.OnSubmit()
+		}
+	})
+	d.submitBtn.Importance = widget.HighImportance
+	d.submitBtn.Disable()
+	cancelBtn := widget.NewButton("Cancel", func() {
+		if d.OnCancel != nil {
+			d.OnCancel()
+		}
+	})
+
+	d.container = container.NewVBox(
+		container.NewHBox(layout.NewSpacer(), titleLabel, layout.NewSpacer()),
+		widget.NewLabel("Playlists to merge"),
+		scroll,
+		container.New(layout.NewFormLayout(), widget.NewLabel("Name"), d.nameEntry),
+		dedupeCheck,
+		widget.NewSeparator(),
+		container.NewHBox(layout.NewSpacer(), d.submitBtn, cancelBtn))
+	return d
+}
+
+// Returns the indexes of the chosen playlists.
+func (d *MergePlaylistsDialog) SelectedIndexes() []int {
+	var idxs []int
+	for i, c := range d.checks {
+		if c.Checked {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+func (d *MergePlaylistsDialog) updateSubmitEnabled() {
+	if len(d.SelectedIndexes()) >= 2 && strings.TrimSpace(d.nameEntry.Text) != "" {
+		d.submitBtn.Enable()
+	} else {
+		d.submitBtn.Disable()
+	}
+}
+
+func (d *MergePlaylistsDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
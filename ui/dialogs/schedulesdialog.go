@@ -0,0 +1,105 @@
+package dialogs
+
+import (
+	"fmt"
+	"supersonic/backend"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Dialog listing the scheduled playbacks (alarms),
+// with buttons to add, edit, delete and test them.
+type SchedulesDialog struct {
+	widget.BaseWidget
+
+	OnAdd            func()
+	OnEdit           func(*backend.ScheduledPlayback)
+	OnDelete         func(*backend.ScheduledPlayback)
+	OnPlayNow        func(*backend.ScheduledPlayback)
+	OnEnabledChanged func(*backend.ScheduledPlayback)
+	OnDismiss        func()
+
+	list      *fyne.Container
+	container *fyne.Container
+}
+
+var _ fyne.Widget = (*SchedulesDialog)(nil)
+
+func NewSchedulesDialog(schedules []*backend.ScheduledPlayback) *SchedulesDialog {
+	s := &SchedulesDialog{}
+	s.ExtendBaseWidget(s)
+
+	title := widget.NewLabel("Scheduled Playback")
+	title.TextStyle.Bold = true
+	s.list = container.NewVBox()
+	scroll := container.NewVScroll(s.list)
+	scroll.SetMinSize(fyne.NewSize(550, 250))
+	addBtn := widget.NewButtonWithIcon("Add...", theme.ContentAddIcon(), func() {
+		if s.OnAdd != nil {
+			s.OnAdd()
+		}
+	})
+	closeBtn := widget.NewButton("Close", func() {
+		if s.OnDismiss != nil {
+			s.OnDismiss()
+		}
+	})
+	s.container = container.NewBorder(
+		container.NewHBox(layout.NewSpacer(), title, layout.NewSpacer()),
+		container.NewVBox(
+			widget.NewSeparator(),
+			container.NewHBox(addBtn, layout.NewSpacer(), closeBtn),
+		),
+		nil, nil, scroll)
+	s.SetSchedules(schedules)
+	return s
+}
+
+// Sets the schedules to display.
+func (s *SchedulesDialog) SetSchedules(schedules []*backend.ScheduledPlayback) {
+	s.list.RemoveAll()
+	if len(schedules) == 0 {
+		s.list.Add(widget.NewLabel("No scheduled playback. Use \"Add...\" to start music at a set time."))
+	}
+	for _, sched := range schedules {
+		s.list.Add(s.newScheduleRow(sched))
+	}
+	s.list.Refresh()
+}
+
+func (s *SchedulesDialog) newScheduleRow(sched *backend.ScheduledPlayback) fyne.CanvasObject {
+	enabled := widget.NewCheck("", func(checked bool) {
+		sched.Enabled = checked
+		if s.OnEnabledChanged != nil {
+			s.OnEnabledChanged(sched)
+		}
+	})
+	enabled.Checked = sched.Enabled
+	desc := widget.NewLabel(fmt.Sprintf("%s — %s: %s",
+		sched.TimeDescription(), sched.SourceType, sched.SourceName))
+	desc.Wrapping = fyne.TextTruncate
+	playBtn := widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() {
+		if s.OnPlayNow != nil {
+			s.OnPlayNow(sched)
+		}
+	})
+	editBtn := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+		if s.OnEdit != nil {
+			s.OnEdit(sched)
+		}
+	})
+	deleteBtn := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		if s.OnDelete != nil {
+			s.OnDelete(sched)
+		}
+	})
+	return container.NewBorder(nil, nil, enabled, container.NewHBox(playBtn, editBtn, deleteBtn), desc)
+}
+
+func (s *SchedulesDialog) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(s.container)
+}
@@ -12,6 +12,7 @@ import (
 	myTheme "supersonic/ui/theme"
 	"supersonic/ui/util"
 	"supersonic/ui/widgets"
+	"time"
 	"unicode"
 
 	"fyne.io/fyne/v2"
@@ -32,12 +33,24 @@ type SettingsDialog struct {
 	OnReplayGainSettingsChanged    func()
 	OnAudioExclusiveSettingChanged func()
 	OnAudioDeviceSettingChanged    func()
+	OnStreamingSettingsChanged     func()
+	OnTransitionSettingsChanged    func()
+	OnAutoDJSettingsChanged        func()
+	OnAudioFiltersChanged          func()
 	OnThemeSettingChanged          func()
-	OnDismiss                      func()
+	// Invoked when the user changes where server passwords are saved.
+	// The dialog does not update the config; the handler must call
+	// SetCredentialStoreSetting with the resulting setting.
+	OnCredentialStoreSettingChanged func(store string, usePassphrase bool)
+	OnDismiss                       func()
 
 	config       *backend.Config
+	credStore    *widget.Select
+	passphrase   *widget.Check
+	updatingCred bool
 	audioDevices []player.AudioDevice
 	promptText   *widget.RichText
+	filterTimer  *time.Timer
 
 	content fyne.CanvasObject
 }
@@ -50,6 +63,7 @@ func NewSettingsDialog(config *backend.Config, audioDeviceList []player.AudioDev
 	tabs := container.NewAppTabs(
 		s.createGeneralTab(),
 		s.createPlaybackTab(),
+		s.createAudioFiltersTab(),
 		s.createExperimentalTab(window),
 	)
 	// workaround issue where inactivated tabs don't fully update when theme setting is changed
@@ -181,6 +195,16 @@ func (s *SettingsDialog) createGeneralTab() *container.TabItem {
 	})
 	scrobbleEnabled.Checked = s.config.Scrobbling.Enabled
 
+	// Credential store settings
+
+	s.credStore = widget.NewSelect([]string{credStoreKeyring, credStoreFile}, func(_ string) {
+		s.onCredentialStoreSettingChanged()
+	})
+	s.passphrase = widget.NewCheck("Protect with master passphrase", func(_ bool) {
+		s.onCredentialStoreSettingChanged()
+	})
+	s.SetCredentialStoreSetting(s.config.Credentials.Store, s.config.Credentials.UseMasterPassphrase)
+
 	return container.NewTabItem("General", container.NewVBox(
 		container.New(layout.NewFormLayout(),
 			widget.NewLabel("Appearance"), container.NewGridWithColumns(2, themeSelect),
@@ -201,9 +225,46 @@ func (s *SettingsDialog) createGeneralTab() *container.TabItem {
 			durationEntry,
 			widget.NewLabel("minutes of track have been played"),
 		),
+		s.newSectionSeparator(),
+
+		widget.NewRichText(&widget.TextSegment{Text: "Saved Passwords", Style: boldStyle}),
+		container.NewHBox(widget.NewLabel("Save passwords in"), s.credStore, s.passphrase),
 	))
 }
 
+const (
+	credStoreKeyring = "System keyring"
+	credStoreFile    = "Encrypted file"
+)
+
+// Updates the credential store widgets to reflect the given setting.
+func (s *SettingsDialog) SetCredentialStoreSetting(store string, usePassphrase bool) {
+	s.updatingCred = true
+	defer func() { s.updatingCred = false }()
+	if store == backend.CredentialStoreFile {
+		s.credStore.SetSelected(credStoreFile)
+		s.passphrase.SetChecked(usePassphrase)
+		s.passphrase.Enable()
+	} else {
+		s.credStore.SetSelected(credStoreKeyring)
+		s.passphrase.SetChecked(false)
+		s.passphrase.Disable()
+	}
+}
+
+func (s *SettingsDialog) onCredentialStoreSettingChanged() {
+	if s.updatingCred {
+		return
+	}
+	store := backend.CredentialStoreKeyring
+	if s.credStore.Selected == credStoreFile {
+		store = backend.CredentialStoreFile
+	}
+	if s.OnCredentialStoreSettingChanged != nil {
+		s.OnCredentialStoreSettingChanged(store, s.passphrase.Checked)
+	}
+}
+
 func (s *SettingsDialog) createPlaybackTab() *container.TabItem {
 	deviceList := make([]string, len(s.audioDevices))
 	var selIndex int
@@ -278,11 +339,84 @@ func (s *SettingsDialog) createPlaybackTab() *container.TabItem {
 	})
 	audioExclusive.Checked = s.config.LocalPlayback.AudioExclusive
 
+	speedPerGenre := widget.NewCheck("Remember playback speed for each genre", func(checked bool) {
+		s.config.LocalPlayback.RememberSpeedPerGenre = checked
+	})
+	speedPerGenre.Checked = s.config.LocalPlayback.RememberSpeedPerGenre
+
+	// Crossfade and fade settings
+
+	crossfadeOptions := []string{"Off", "2 s", "4 s", "6 s", "8 s", "10 s", "12 s"}
+	crossfadeSelect := widget.NewSelect(crossfadeOptions, nil)
+	crossfadeSelect.SetSelectedIndex(clampInt(s.config.LocalPlayback.CrossfadeSeconds/2, 0, len(crossfadeOptions)-1))
+	crossfadeSelect.OnChanged = func(_ string) {
+		s.config.LocalPlayback.CrossfadeSeconds = crossfadeSelect.SelectedIndex() * 2
+		s.onTransitionSettingsChanged()
+	}
+	fadeOnPause := widget.NewCheck("", func(checked bool) {
+		s.config.LocalPlayback.FadeOnPause = checked
+		s.onTransitionSettingsChanged()
+	})
+	fadeOnPause.Checked = s.config.LocalPlayback.FadeOnPause
+
+	// Auto-DJ settings
+
+	autoDJ := widget.NewCheck("", func(checked bool) {
+		s.config.AutoDJ.Enabled = checked
+		s.onAutoDJSettingsChanged()
+	})
+	autoDJ.Checked = s.config.AutoDJ.Enabled
+	noRepeatHours := []int{0, 1, 2, 4, 8, 24}
+	noRepeatOptions := []string{"Off", "1 hour", "2 hours", "4 hours", "8 hours", "24 hours"}
+	noRepeatSelect := widget.NewSelect(noRepeatOptions, nil)
+	noRepeatSelect.SetSelectedIndex(len(noRepeatHours) - 1)
+	for i, h := range noRepeatHours {
+		if h >= s.config.AutoDJ.NoRepeatHours {
+			noRepeatSelect.SetSelectedIndex(i)
+			break
+		}
+	}
+	noRepeatSelect.OnChanged = func(_ string) {
+		s.config.AutoDJ.NoRepeatHours = noRepeatHours[noRepeatSelect.SelectedIndex()]
+		s.onAutoDJSettingsChanged()
+	}
+
+	// Streaming profile settings
+
+	profiles := s.config.Streaming.ProfileNames()
+	newProfileSelect := func(setting *string, notify bool) *widget.Select {
+		sel := widget.NewSelect(profiles, nil)
+		sel.SetSelected(s.config.Streaming.Profile(*setting).Name)
+		sel.OnChanged = func(name string) {
+			*setting = name
+			if notify && s.OnStreamingSettingsChanged != nil {
+				s.OnStreamingSettingsChanged()
+			}
+		}
+		return sel
+	}
+	var streamingSection fyne.CanvasObject = layout.NewSpacer()
+	if len(profiles) > 0 {
+		streamingSection = container.NewVBox(
+			s.newSectionSeparator(),
+			widget.NewRichText(&widget.TextSegment{Text: "Streaming Quality", Style: boldStyle}),
+			container.New(layout.NewFormLayout(),
+				widget.NewLabel("Primary hostname"), container.NewGridWithColumns(2,
+					newProfileSelect(&s.config.Streaming.PrimaryProfile, true)),
+				widget.NewLabel("Alt. hostname"), container.NewGridWithColumns(2,
+					newProfileSelect(&s.config.Streaming.AltProfile, true)),
+				widget.NewLabel("Downloads"), container.NewGridWithColumns(2,
+					newProfileSelect(&s.config.Streaming.DownloadProfile, false)),
+			),
+		)
+	}
+
 	return container.NewTabItem("Playback", container.NewVBox(
 		container.New(&layouts.MaxPadLayout{PadTop: 5},
 			container.New(layout.NewFormLayout(),
 				widget.NewLabel("Audio device"), container.NewBorder(nil, nil, nil, util.NewHSpace(70), deviceSelect),
 				layout.NewSpacer(), container.NewHBox(audioExclusive, layout.NewSpacer()),
+				layout.NewSpacer(), container.NewHBox(speedPerGenre, layout.NewSpacer()),
 			)),
 		s.newSectionSeparator(),
 
@@ -292,6 +426,120 @@ func (s *SettingsDialog) createPlaybackTab() *container.TabItem {
 			widget.NewLabel("ReplayGain preamp"), container.NewHBox(preampGain, widget.NewLabel("dB")),
 			widget.NewLabel("Prevent clipping"), container.NewHBox(preventClipping, layout.NewSpacer()),
 		),
+		s.newSectionSeparator(),
+
+		widget.NewRichText(&widget.TextSegment{Text: "Transitions", Style: boldStyle}),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Crossfade"), container.NewGridWithColumns(2, crossfadeSelect),
+			widget.NewLabel("Fade on pause and skip"), container.NewHBox(fadeOnPause, layout.NewSpacer()),
+		),
+		s.newSectionSeparator(),
+
+		widget.NewRichText(&widget.TextSegment{Text: "Auto-DJ", Style: boldStyle}),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Keep playing similar music"), container.NewHBox(autoDJ, layout.NewSpacer()),
+			widget.NewLabel("Don't repeat tracks within"), container.NewGridWithColumns(2, noRepeatSelect),
+		),
+		streamingSection,
+	))
+}
+
+func (s *SettingsDialog) createAudioFiltersTab() *container.TabItem {
+	cfg := &s.config.AudioFilters
+
+	normModes := []string{backend.NormalizationNone, backend.NormalizationEBUR128, backend.NormalizationDynamic}
+	targetLUFS := []float64{-14, -16, -18, -23}
+	targetOptions := make([]string, len(targetLUFS))
+	targetIdx := 1
+	for i, t := range targetLUFS {
+		targetOptions[i] = strconv.Itoa(int(t)) + " LUFS"
+		if t == cfg.NormalizationTargetLUFS {
+			targetIdx = i
+		}
+	}
+	targetSelect := widget.NewSelect(targetOptions, nil)
+	targetSelect.SetSelectedIndex(targetIdx)
+	targetSelect.OnChanged = func(_ string) {
+		cfg.NormalizationTargetLUFS = targetLUFS[targetSelect.SelectedIndex()]
+		s.onAudioFiltersChanged()
+	}
+
+	normSelect := widget.NewSelect([]string{"Off", "EBU R128 loudness", "Dynamic"}, nil)
+	normSelect.SetSelectedIndex(0)
+	for i, m := range normModes {
+		if m == cfg.Normalization {
+			normSelect.SetSelectedIndex(i)
+		}
+	}
+	updateTargetEnabled := func() {
+		if cfg.Normalization == backend.NormalizationEBUR128 {
+			targetSelect.Enable()
+		} else {
+			targetSelect.Disable()
+		}
+	}
+	updateTargetEnabled()
+	normSelect.OnChanged = func(_ string) {
+		cfg.Normalization = normModes[normSelect.SelectedIndex()]
+		updateTargetEnabled()
+		s.onAudioFiltersChanged()
+	}
+
+	newCheck := func(setting *bool) *widget.Check {
+		c := widget.NewCheck("", func(checked bool) {
+			*setting = checked
+			s.onAudioFiltersChanged()
+		})
+		c.Checked = *setting
+		return c
+	}
+
+	balanceLabel := widget.NewLabel("")
+	updateBalanceLabel := func() {
+		switch b := int(math.Round(cfg.Balance * 100)); {
+		case b < 0:
+			balanceLabel.SetText(strconv.Itoa(-b) + "% L")
+		case b > 0:
+			balanceLabel.SetText(strconv.Itoa(b) + "% R")
+		default:
+			balanceLabel.SetText("Center")
+		}
+	}
+	balance := widget.NewSlider(-1, 1)
+	balance.Step = 0.05
+	balance.Value = cfg.Balance
+	updateBalanceLabel()
+	balance.OnChanged = func(v float64) {
+		cfg.Balance = v
+		updateBalanceLabel()
+		// changing the filter chain briefly interrupts audio,
+		// so wait for the slider to stop moving
+		if s.filterTimer != nil {
+			s.filterTimer.Stop()
+		}
+		s.filterTimer = time.AfterFunc(200*time.Millisecond, s.onAudioFiltersChanged)
+	}
+
+	return container.NewTabItem("Audio Filters", container.NewVBox(
+		widget.NewRichText(&widget.TextSegment{Text: "Normalization", Style: boldStyle}),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Loudness normalization"), container.NewGridWithColumns(2, normSelect),
+			widget.NewLabel("Target loudness"), container.NewGridWithColumns(2, targetSelect),
+		),
+		s.newSectionSeparator(),
+
+		widget.NewRichText(&widget.TextSegment{Text: "Channels", Style: boldStyle}),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Mono downmix"), container.NewHBox(newCheck(&cfg.Mono), layout.NewSpacer()),
+			widget.NewLabel("Balance"), container.NewBorder(nil, nil, nil, balanceLabel, balance),
+			widget.NewLabel("Headphone crossfeed"), container.NewHBox(newCheck(&cfg.Crossfeed), layout.NewSpacer()),
+		),
+		s.newSectionSeparator(),
+
+		widget.NewRichText(&widget.TextSegment{Text: "Other", Style: boldStyle}),
+		container.New(layout.NewFormLayout(),
+			widget.NewLabel("Trim silence"), container.NewHBox(newCheck(&cfg.TrimSilence), layout.NewSpacer()),
+		),
 	))
 }
 
@@ -382,12 +630,40 @@ func (s *SettingsDialog) onReplayGainSettingsChanged() {
 	}
 }
 
+func (s *SettingsDialog) onTransitionSettingsChanged() {
+	if s.OnTransitionSettingsChanged != nil {
+		s.OnTransitionSettingsChanged()
+	}
+}
+
+func (s *SettingsDialog) onAutoDJSettingsChanged() {
+	if s.OnAutoDJSettingsChanged != nil {
+		s.OnAutoDJSettingsChanged()
+	}
+}
+
+func (s *SettingsDialog) onAudioFiltersChanged() {
+	if s.OnAudioFiltersChanged != nil {
+		s.OnAudioFiltersChanged()
+	}
+}
+
 func (s *SettingsDialog) onAudioExclusiveSettingsChanged() {
 	if s.OnAudioExclusiveSettingChanged != nil {
 		s.OnAudioExclusiveSettingChanged()
 	}
 }
 
+func clampInt(i, min, max int) int {
+	if i < min {
+		return min
+	}
+	if i > max {
+		return max
+	}
+	return i
+}
+
 func (s *SettingsDialog) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(s.content)
 }
@@ -2,12 +2,16 @@ package ui
 
 import (
 	"fmt"
+	"math"
 	"supersonic/backend"
 	"supersonic/res"
 	"supersonic/ui/browsing"
 	"supersonic/ui/controller"
 	"supersonic/ui/os"
 	"supersonic/ui/theme"
+	"supersonic/ui/util"
+	"supersonic/ui/widgets"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
@@ -21,6 +25,8 @@ var (
 	ShortcutReload      = desktop.CustomShortcut{KeyName: fyne.KeyR, Modifier: os.ControlModifier}
 	ShortcutSearch      = desktop.CustomShortcut{KeyName: fyne.KeyF, Modifier: os.ControlModifier}
 	ShortcutCloseWindow = desktop.CustomShortcut{KeyName: fyne.KeyW, Modifier: os.ControlModifier}
+	ShortcutUndo        = desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: os.ControlModifier}
+	ShortcutRedo        = desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: os.ControlModifier | fyne.KeyModifierShift}
 
 	ShortcutNavOne   = desktop.CustomShortcut{KeyName: fyne.Key1, Modifier: os.ControlModifier}
 	ShortcutNavTwo   = desktop.CustomShortcut{KeyName: fyne.Key2, Modifier: os.ControlModifier}
@@ -42,6 +48,7 @@ type MainWindow struct {
 	Controller   *controller.Controller
 	BrowsingPane *browsing.BrowsingPane
 	BottomPanel  *BottomPanel
+	Sidebar      *Sidebar
 
 	theme          *theme.MyTheme
 	haveSystemTray bool
@@ -77,7 +84,27 @@ func NewMainWindow(fyneApp fyne.App, appName, appVersion string, app *backend.Ap
 	m.BottomPanel = NewBottomPanel(app.Player, m.Controller)
 	m.BottomPanel.SetPlaybackManager(app.PlaybackManager)
 	m.BottomPanel.ImageManager = app.ImageManager
-	m.container = container.NewBorder(nil, m.BottomPanel, nil, nil, m.BrowsingPane)
+	app.Scheduler.OnVolumeChanged(m.BottomPanel.AuxControls.VolumeControl.SetVolume)
+	m.Sidebar = NewSidebar(app, m.Controller, m.navigationItems())
+	m.container = container.NewBorder(nil, m.BottomPanel, m.Sidebar, nil, m.BrowsingPane)
+	m.Sidebar.OnLayoutChanged = func() {
+		m.BrowsingPane.SetNavigationButtonsVisible(m.Sidebar.Collapsed())
+		m.container.Refresh()
+	}
+	m.BrowsingPane.SetNavigationButtonsVisible(m.Sidebar.Collapsed())
+	m.BrowsingPane.OnToggleSidebar = func() {
+		m.Sidebar.SetCollapsed(!m.Sidebar.Collapsed())
+	}
+	m.Controller.DropFunc = func(items controller.DragItems, pos fyne.Position) bool {
+		if util.IsPosInObject(m.BottomPanel, pos) {
+			m.Controller.DropOnQueue(items)
+			return true
+		}
+		if m.Sidebar.DropItems(items, pos) {
+			return true
+		}
+		return m.BrowsingPane.DropItems(items, pos)
+	}
 	m.Window.SetContent(m.container)
 	m.Window.Resize(size)
 	app.PlaybackManager.OnSongChange(func(song *subsonic.Child, _ *subsonic.Child) {
@@ -129,6 +156,8 @@ func NewMainWindow(fyneApp fyne.App, appName, appVersion string, app *backend.Ap
 			fyneApp.Settings().SetTheme(m.theme)
 		})
 	})
+	m.BrowsingPane.AddSettingsMenuItem("Equalizer...", m.Controller.ShowEqualizerDialog)
+	m.BrowsingPane.AddSettingsMenuItem("Scheduled Playback...", m.Controller.ShowSchedulesDialog)
 	m.BrowsingPane.AddSettingsMenuItem("About...", m.Controller.ShowAboutDialog)
 	m.addNavigationButtons()
 	m.BrowsingPane.DisableNavigationButtons()
@@ -149,6 +178,8 @@ func (m *MainWindow) StartupPage() controller.Route {
 
 func (m *MainWindow) SetupSystemTrayMenu(appName string, fyneApp fyne.App) {
 	if desk, ok := fyneApp.(desktop.App); ok {
+		sleepTimer := fyne.NewMenuItem("Sleep Timer", nil)
+		sleepTimer.ChildMenu = fyne.NewMenu("")
 		menu := fyne.NewMenu(appName,
 			fyne.NewMenuItem("Play/Pause", func() {
 				_ = m.App.Player.PlayPause()
@@ -172,9 +203,33 @@ func (m *MainWindow) SetupSystemTrayMenu(appName string, fyneApp fyne.App) {
 				m.BottomPanel.AuxControls.VolumeControl.SetVolume(vol)
 			}),
 			fyne.NewMenuItemSeparator(),
+			sleepTimer,
+			fyne.NewMenuItemSeparator(),
 			fyne.NewMenuItem("Show", m.Window.Show),
 			fyne.NewMenuItem("Hide", m.Window.Hide),
 		)
+		updateSleepTimer := func() {
+			pm := m.App.PlaybackManager
+			sleepTimer.Label = "Sleep Timer"
+			if remaining, ok := pm.SleepTimerRemaining(); ok {
+				sleepTimer.Label = fmt.Sprintf("Sleep Timer (%d min left)", int(math.Ceil(remaining.Minutes())))
+			} else if pm.SleepTimerMode() != backend.SleepTimerOff {
+				sleepTimer.Label = "Sleep Timer (on)"
+			}
+			sleepTimer.ChildMenu.Items = widgets.NewSleepTimerMenuItems(pm.SleepTimerMode(),
+				pm.StopAfterCurrent(), pm.SetSleepTimer, pm.SetStopAfterCurrent)
+			menu.Refresh()
+		}
+		updateSleepTimer()
+		m.App.PlaybackManager.OnSleepTimerChanged(updateSleepTimer)
+		// update the remaining time in the label
+		go func() {
+			for range time.Tick(time.Minute) {
+				if m.App.PlaybackManager.SleepTimerMode() != backend.SleepTimerOff {
+					updateSleepTimer()
+				}
+			}
+		}()
 		desk.SetSystemTrayMenu(menu)
 		desk.SetSystemTrayIcon(res.ResAppicon256Png)
 		m.haveSystemTray = true
@@ -200,28 +255,27 @@ func (m *MainWindow) ShowNewVersionDialog(appName, versionTag string) {
 	})
 }
 
+// The destinations of the navigation buttons and the sidebar, in order.
+// (The icons are only set once the theme has been created.)
+func (m *MainWindow) navigationItems() []navigationItem {
+	return []navigationItem{
+		{"Now Playing", theme.NowPlayingIcon, controller.NowPlayingRoute("")},
+		{"Favorites", theme.FavoriteIcon, controller.FavoritesRoute()},
+		{"Albums", theme.AlbumIcon, controller.AlbumsRoute()},
+		{"Artists", theme.ArtistIcon, controller.ArtistsRoute()},
+		{"Genres", theme.GenreIcon, controller.GenresRoute()},
+		{"Playlists", theme.PlaylistIcon, controller.PlaylistsRoute()},
+		{"Tracks", theme.TracksIcon, controller.TracksRoute()},
+	}
+}
+
 func (m *MainWindow) addNavigationButtons() {
-	m.BrowsingPane.AddNavigationButton(theme.NowPlayingIcon, func() {
-		m.Router.NavigateTo(controller.NowPlayingRoute(""))
-	})
-	m.BrowsingPane.AddNavigationButton(theme.FavoriteIcon, func() {
-		m.Router.NavigateTo(controller.FavoritesRoute())
-	})
-	m.BrowsingPane.AddNavigationButton(theme.AlbumIcon, func() {
-		m.Router.NavigateTo(controller.AlbumsRoute())
-	})
-	m.BrowsingPane.AddNavigationButton(theme.ArtistIcon, func() {
-		m.Router.NavigateTo(controller.ArtistsRoute())
-	})
-	m.BrowsingPane.AddNavigationButton(theme.GenreIcon, func() {
-		m.Router.NavigateTo(controller.GenresRoute())
-	})
-	m.BrowsingPane.AddNavigationButton(theme.PlaylistIcon, func() {
-		m.Router.NavigateTo(controller.PlaylistsRoute())
-	})
-	m.BrowsingPane.AddNavigationButton(theme.TracksIcon, func() {
-		m.Router.NavigateTo(controller.TracksRoute())
-	})
+	for _, item := range m.navigationItems() {
+		route := item.route
+		m.BrowsingPane.AddNavigationButton(item.icon, func() {
+			m.Router.NavigateTo(route)
+		})
+	}
 }
 
 func (m *MainWindow) addShortcuts() {
@@ -256,6 +310,13 @@ func (m *MainWindow) addShortcuts() {
 		}
 	})
 
+	m.Canvas().AddShortcut(&ShortcutUndo, func(_ fyne.Shortcut) {
+		m.Controller.Undo()
+	})
+	m.Canvas().AddShortcut(&ShortcutRedo, func(_ fyne.Shortcut) {
+		m.Controller.Redo()
+	})
+
 	for i, ns := range NavShortcuts {
 		m.Canvas().AddShortcut(&ns, func(i int) func(fyne.Shortcut) {
 			return func(fyne.Shortcut) {
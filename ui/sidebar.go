@@ -0,0 +1,315 @@
+package ui
+
+import (
+	"image/color"
+	"log"
+	"supersonic/backend"
+	"supersonic/ui/controller"
+	myTheme "supersonic/ui/theme"
+	"supersonic/ui/util"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/dweymouth/go-subsonic/subsonic"
+)
+
+const (
+	sidebarMinWidth = 150
+	sidebarMaxWidth = 450
+)
+
+// A destination shown both as a navigation button and in the sidebar.
+type navigationItem struct {
+	name  string
+	icon  fyne.Resource
+	route controller.Route
+}
+
+// Sidebar is the optional pane at the left of the window, listing
+// the navigation destinations, the user's playlists and pinned items.
+// It can be resized by dragging its right edge.
+type Sidebar struct {
+	widget.BaseWidget
+
+	// Called when the sidebar's width changes or it is shown or hidden,
+	// so that the containing layout can be updated.
+	OnLayoutChanged func()
+
+	app   *backend.App
+	contr *controller.Controller
+	conf  *backend.SidebarConfig
+	width float32
+
+	nowPlayingItem *sidebarItem
+	pinnedHeader   *widget.Label
+	pinned         *fyne.Container
+	playlistsLock  sync.Mutex
+	playlists      *fyne.Container
+	// the user's playlists, in the order of their rows after the smart playlists
+	userPlaylists []*subsonic.Playlist
+	container     *fyne.Container
+}
+
+func NewSidebar(app *backend.App, contr *controller.Controller, navItems []navigationItem) *Sidebar {
+	s := &Sidebar{
+		app:          app,
+		contr:        contr,
+		conf:         &app.Config.Sidebar,
+		pinnedHeader: newSidebarHeader("Pinned"),
+		pinned:       container.NewVBox(),
+		playlists:    container.NewVBox(),
+	}
+	s.ExtendBaseWidget(s)
+	s.setWidth(float32(s.conf.Width))
+	s.Hidden = s.conf.Collapsed
+
+	nav := container.NewVBox(newSidebarHeader("Library"))
+	for _, item := range navItems {
+		route := item.route
+		row := newSidebarItem(item.icon, item.name, func() { s.navigateTo(route) })
+		if route.Page == controller.NowPlaying {
+			s.nowPlayingItem = row
+		}
+		nav.Add(row)
+	}
+	s.pinnedHeader.Hide()
+
+	app.LibraryManager.OnPlaylistsChanged(func() { go s.loadPlaylists() })
+	app.PinnedItems.OnChanged(s.loadPinned)
+	app.ServerManager.OnServerConnected(func() {
+		s.loadPinned()
+		go s.loadPlaylists()
+	})
+	app.ServerManager.OnLogout(func() {
+		s.pinned.RemoveAll()
+		s.pinnedHeader.Hide()
+		s.setPlaylists(nil, nil)
+	})
+
+	list := container.NewVScroll(container.NewVBox(
+		nav, s.pinnedHeader, s.pinned, newSidebarHeader("Playlists"), s.playlists))
+	s.container = container.NewBorder(nil, nil, nil, newSidebarDivider(s), list)
+	return s
+}
+
+func (s *Sidebar) MinSize() fyne.Size {
+	s.ExtendBaseWidget(s)
+	return fyne.NewSize(s.width, s.BaseWidget.MinSize().Height)
+}
+
+// Shows or hides the sidebar, and saves the state in the config.
+func (s *Sidebar) SetCollapsed(collapsed bool) {
+	s.conf.Collapsed = collapsed
+	if collapsed {
+		s.Hide()
+	} else {
+		s.Show()
+	}
+	s.onLayoutChanged()
+}
+
+func (s *Sidebar) Collapsed() bool {
+	return s.conf.Collapsed
+}
+
+// Handles items dropped at the given absolute position onto Now Playing
+// or one of the user's playlists. Returns false if there is no target there.
+func (s *Sidebar) DropItems(items controller.DragItems, pos fyne.Position) bool {
+	if !s.Visible() || !util.IsPosInObject(s, pos) {
+		return false
+	}
+	if util.IsPosInObject(s.nowPlayingItem, pos) {
+		s.contr.DropOnQueue(items)
+		return true
+	}
+	s.playlistsLock.Lock()
+	defer s.playlistsLock.Unlock()
+	// the user's playlists are the last rows
+	rows := s.playlists.Objects[len(s.playlists.Objects)-len(s.userPlaylists):]
+	for i, row := range rows {
+		if util.IsPosInObject(row, pos) {
+			s.contr.DropOnPlaylist(s.userPlaylists[i], items)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sidebar) loadPinned() {
+	s.pinned.RemoveAll()
+	for _, item := range s.app.PinnedItems.Items() {
+		item := item
+		var icon fyne.Resource
+		var route controller.Route
+		switch item.Type {
+		case backend.PinnedAlbum:
+			icon, route = myTheme.AlbumIcon, controller.AlbumRoute(item.ID)
+		case backend.PinnedArtist:
+			icon, route = myTheme.ArtistIcon, controller.ArtistRoute(item.ID)
+		case backend.PinnedGenre:
+			icon, route = myTheme.GenreIcon, controller.GenreRoute(item.ID)
+		default:
+			continue
+		}
+		row := newSidebarItem(icon, item.Name, func() { s.navigateTo(route) })
+		row.Menu = fyne.NewMenu("", fyne.NewMenuItem("Unpin", func() {
+			s.app.PinnedItems.Unpin(item.Type, item.ID)
+		}))
+		s.pinned.Add(row)
+	}
+	if len(s.pinned.Objects) > 0 {
+		s.pinnedHeader.Show()
+	} else {
+		s.pinnedHeader.Hide()
+	}
+	s.pinned.Refresh()
+}
+
+// should be called asynchronously
+func (s *Sidebar) loadPlaylists() {
+	if s.app.ServerManager.Server() == nil {
+		return
+	}
+	playlists, err := s.app.LibraryManager.GetUserOwnedPlaylists()
+	if err != nil {
+		log.Printf("error loading playlists: %s", err.Error())
+		return
+	}
+	s.setPlaylists(s.app.LibraryManager.SmartPlaylists(), playlists)
+}
+
+func (s *Sidebar) setPlaylists(smartPlaylists []*backend.SmartPlaylist, playlists []*subsonic.Playlist) {
+	s.playlistsLock.Lock()
+	defer s.playlistsLock.Unlock()
+	s.playlists.RemoveAll()
+	for _, sp := range smartPlaylists {
+		route := controller.SmartPlaylistRoute(sp.ID.String())
+		s.playlists.Add(newSidebarItem(myTheme.PlaylistIcon, sp.Name,
+			func() { s.navigateTo(route) }))
+	}
+	for _, pl := range playlists {
+		route := controller.PlaylistRoute(pl.ID)
+		s.playlists.Add(newSidebarItem(myTheme.PlaylistIcon, pl.Name,
+			func() { s.navigateTo(route) }))
+	}
+	s.userPlaylists = playlists
+	s.playlists.Refresh()
+}
+
+// the navigation buttons are disabled until connected, but the sidebar stays shown
+func (s *Sidebar) navigateTo(route controller.Route) {
+	if s.app.ServerManager.Server() != nil {
+		s.contr.NavigateTo(route)
+	}
+}
+
+func (s *Sidebar) setWidth(width float32) {
+	s.width = fyne.Min(sidebarMaxWidth, fyne.Max(sidebarMinWidth, width))
+}
+
+func (s *Sidebar) onLayoutChanged() {
+	if s.OnLayoutChanged != nil {
+		s.OnLayoutChanged()
+	}
+}
+
+func (s *Sidebar) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(s.container)
+}
+
+func newSidebarHeader(text string) *widget.Label {
+	return widget.NewLabelWithStyle(text, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+}
+
+// A tappable row of the sidebar with an icon and a label,
+// and an optional context menu.
+type sidebarItem struct {
+	widget.BaseWidget
+
+	OnTapped func()
+	Menu     *fyne.Menu
+
+	background *canvas.Rectangle
+	container  *fyne.Container
+}
+
+func newSidebarItem(icon fyne.Resource, text string, onTapped func()) *sidebarItem {
+	s := &sidebarItem{
+		OnTapped:   onTapped,
+		background: canvas.NewRectangle(color.Transparent),
+	}
+	s.ExtendBaseWidget(s)
+	label := widget.NewLabel(text)
+	label.Wrapping = fyne.TextTruncate
+	s.container = container.NewMax(s.background,
+		container.NewBorder(nil, nil, widget.NewIcon(icon), nil, label))
+	return s
+}
+
+func (s *sidebarItem) Tapped(*fyne.PointEvent) {
+	if s.OnTapped != nil {
+		s.OnTapped()
+	}
+}
+
+func (s *sidebarItem) TappedSecondary(e *fyne.PointEvent) {
+	if s.Menu != nil {
+		widget.ShowPopUpMenuAtPosition(s.Menu, fyne.CurrentApp().Driver().CanvasForObject(s), e.AbsolutePosition)
+	}
+}
+
+func (s *sidebarItem) MouseIn(*desktop.MouseEvent) {
+	s.background.FillColor = theme.HoverColor()
+	s.background.Refresh()
+}
+
+func (s *sidebarItem) MouseMoved(*desktop.MouseEvent) {}
+
+func (s *sidebarItem) MouseOut() {
+	s.background.FillColor = color.Transparent
+	s.background.Refresh()
+}
+
+func (s *sidebarItem) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(s.container)
+}
+
+// The right edge of the sidebar, which can be dragged to resize it.
+type sidebarDivider struct {
+	widget.BaseWidget
+
+	sidebar   *Sidebar
+	container *fyne.Container
+}
+
+func newSidebarDivider(s *Sidebar) *sidebarDivider {
+	d := &sidebarDivider{
+		sidebar:   s,
+		container: container.NewHBox(layout.NewSpacer(), widget.NewSeparator(), layout.NewSpacer()),
+	}
+	d.ExtendBaseWidget(d)
+	return d
+}
+
+func (d *sidebarDivider) Dragged(e *fyne.DragEvent) {
+	d.sidebar.setWidth(d.sidebar.width + e.Dragged.DX)
+	d.sidebar.onLayoutChanged()
+}
+
+func (d *sidebarDivider) DragEnd() {
+	d.sidebar.conf.Width = int(d.sidebar.width)
+}
+
+func (d *sidebarDivider) Cursor() desktop.Cursor {
+	return desktop.HResizeCursor
+}
+
+func (d *sidebarDivider) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(d.container)
+}
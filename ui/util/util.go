@@ -87,6 +87,19 @@ func RichTextSegsFromHTMLString(s string) []widget.RichTextSegment {
 	return segs
 }
 
+// Returns whether the absolute position is within the bounds of the object,
+// which must currently be shown in the window.
+func IsPosInObject(obj fyne.CanvasObject, pos fyne.Position) bool {
+	objPos := fyne.CurrentApp().Driver().AbsolutePositionForObject(obj)
+	// the driver returns the zero position for objects that aren't shown
+	if objPos.IsZero() || !obj.Visible() {
+		return false
+	}
+	size := obj.Size()
+	return pos.X >= objPos.X && pos.Y >= objPos.Y &&
+		pos.X < objPos.X+size.Width && pos.Y < objPos.Y+size.Height
+}
+
 type HSpace struct {
 	widget.BaseWidget
 
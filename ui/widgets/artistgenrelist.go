@@ -24,7 +24,11 @@ type ArtistGenreList struct {
 	ShowAlbumCount bool
 	ShowTrackCount bool
 	OnNavTo        func(string)
+	// If set, items can be pinned to the sidebar from their context menu
+	OnPinToSidebar func(ArtistGenreListItemModel)
 
+	menu          *widget.PopUpMenu
+	menuItem      ArtistGenreListItemModel
 	columnsLayout *layouts.ColumnsLayout
 	hdr           *ListHeader
 	list          *widget.List
@@ -34,8 +38,9 @@ type ArtistGenreList struct {
 type ArtistGenreListRow struct {
 	widget.BaseWidget
 
-	Item     ArtistGenreListItemModel
-	OnTapped func()
+	Item              ArtistGenreListItemModel
+	OnTapped          func()
+	OnShowContextMenu func(fyne.Position)
 
 	nameLabel       *widget.Label
 	albumCountLabel *widget.Label
@@ -70,6 +75,7 @@ func NewArtistGenreList(items []ArtistGenreListItemModel) *ArtistGenreList {
 		func() fyne.CanvasObject {
 			r := NewArtistGenreListRow(a.columnsLayout)
 			r.OnTapped = func() { a.onRowDoubleTapped(r.Item) }
+			r.OnShowContextMenu = func(pos fyne.Position) { a.showContextMenu(r.Item, pos) }
 			return r
 		},
 		func(id widget.ListItemID, item fyne.CanvasObject) {
@@ -99,12 +105,31 @@ func (a *ArtistGenreList) onRowDoubleTapped(item ArtistGenreListItemModel) {
 	}
 }
 
+func (a *ArtistGenreList) showContextMenu(item ArtistGenreListItemModel, pos fyne.Position) {
+	if a.OnPinToSidebar == nil {
+		return
+	}
+	a.menuItem = item
+	if a.menu == nil {
+		a.menu = widget.NewPopUpMenu(fyne.NewMenu("",
+			fyne.NewMenuItem("Pin to sidebar", func() { a.OnPinToSidebar(a.menuItem) })),
+			fyne.CurrentApp().Driver().CanvasForObject(a))
+	}
+	a.menu.ShowAtPosition(pos)
+}
+
 func (a *ArtistGenreListRow) Tapped(*fyne.PointEvent) {
 	if a.OnTapped != nil {
 		a.OnTapped()
 	}
 }
 
+func (a *ArtistGenreListRow) TappedSecondary(e *fyne.PointEvent) {
+	if a.OnShowContextMenu != nil {
+		a.OnShowContextMenu(e.AbsolutePosition)
+	}
+}
+
 func (a *ArtistGenreList) CreateRenderer() fyne.WidgetRenderer {
 	return widget.NewSimpleRenderer(a.container)
 }
@@ -1,6 +1,11 @@
 package widgets
 
 import (
+	"strconv"
+	"supersonic/backend"
+	"supersonic/ui/util"
+	"time"
+
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/layout"
@@ -9,23 +14,143 @@ import (
 )
 
 // The "aux" controls for playback, positioned to the right
-// of the BottomPanel. Currently only volume control.
+// of the BottomPanel. Volume control, playback speed, sleep timer
+// and streaming profile quick switch.
 type AuxControls struct {
 	widget.BaseWidget
 
 	VolumeControl *VolumeControl
 
-	container *fyne.Container
+	OnStreamingProfileChanged func(profileName string)
+	OnPlaybackSpeedChanged    func(speed float64)
+	OnSetSleepTimer           func(mode backend.SleepTimerMode, duration time.Duration)
+	OnSetStopAfterCurrent     func(stop bool)
+
+	streamingProfiles []string
+	streamingProfile  *widget.Button
+	speed             float64
+	speedButton       *widget.Button
+	sleepMode         backend.SleepTimerMode
+	stopAfterCurrent  bool
+	sleepButton       *widget.Button
+	container         *fyne.Container
 }
 
 func NewAuxControls(initialVolume int) *AuxControls {
 	a := &AuxControls{
 		VolumeControl: NewVolumeControl(initialVolume),
 	}
-	a.container = container.NewHBox(layout.NewSpacer(), a.VolumeControl)
+	a.streamingProfile = widget.NewButton("", a.showStreamingProfileMenu)
+	a.streamingProfile.Importance = widget.LowImportance
+	a.streamingProfile.Hidden = true
+	a.speedButton = widget.NewButton("", a.showPlaybackSpeedMenu)
+	a.speedButton.Importance = widget.LowImportance
+	a.SetPlaybackSpeed(1)
+	a.sleepButton = widget.NewButton("Sleep", a.showSleepTimerMenu)
+	a.sleepButton.Importance = widget.LowImportance
+	a.container = container.NewHBox(layout.NewSpacer(),
+		container.NewCenter(a.streamingProfile), container.NewCenter(a.speedButton),
+		container.NewCenter(a.sleepButton), a.VolumeControl)
 	return a
 }
 
+var playbackSpeeds = []float64{0.75, 1, 1.25, 1.5, 1.75, 2}
+
+// Sets the displayed playback speed.
+func (a *AuxControls) SetPlaybackSpeed(speed float64) {
+	a.speed = speed
+	a.speedButton.SetText(formatSpeed(speed))
+}
+
+func (a *AuxControls) showPlaybackSpeedMenu() {
+	items := make([]*fyne.MenuItem, len(playbackSpeeds))
+	for i, speed := range playbackSpeeds {
+		_speed := speed
+		items[i] = fyne.NewMenuItem(formatSpeed(speed), func() {
+			a.SetPlaybackSpeed(_speed)
+			if a.OnPlaybackSpeedChanged != nil {
+				a.OnPlaybackSpeedChanged(_speed)
+			}
+		})
+		items[i].Checked = speed == a.speed
+	}
+	c := fyne.CurrentApp().Driver().CanvasForObject(a)
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(a.speedButton)
+	menu := widget.NewPopUpMenu(fyne.NewMenu("", items...), c)
+	menu.ShowAtPosition(pos.SubtractXY(0, menu.MinSize().Height))
+}
+
+// Sets the displayed sleep timer state. remaining is the time left until
+// playback stops, if known; it is ignored when haveRemaining is false.
+func (a *AuxControls) SetSleepTimerStatus(mode backend.SleepTimerMode, stopAfterCurrent bool, remaining time.Duration, haveRemaining bool) {
+	a.sleepMode = mode
+	a.stopAfterCurrent = stopAfterCurrent
+	text := "Sleep"
+	switch {
+	case mode != backend.SleepTimerOff && haveRemaining:
+		text = "Sleep " + util.SecondsToTimeString(remaining.Seconds())
+	case mode != backend.SleepTimerOff:
+		text = "Sleep on"
+	case stopAfterCurrent:
+		text = "Stop after track"
+	}
+	if a.sleepButton.Text != text {
+		a.sleepButton.SetText(text)
+	}
+}
+
+func (a *AuxControls) showSleepTimerMenu() {
+	items := NewSleepTimerMenuItems(a.sleepMode, a.stopAfterCurrent,
+		func(mode backend.SleepTimerMode, d time.Duration) {
+			if a.OnSetSleepTimer != nil {
+				a.OnSetSleepTimer(mode, d)
+			}
+		},
+		func(stop bool) {
+			if a.OnSetStopAfterCurrent != nil {
+				a.OnSetStopAfterCurrent(stop)
+			}
+		})
+	c := fyne.CurrentApp().Driver().CanvasForObject(a)
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(a.sleepButton)
+	menu := widget.NewPopUpMenu(fyne.NewMenu("", items...), c)
+	menu.ShowAtPosition(pos.SubtractXY(0, menu.MinSize().Height))
+}
+
+func formatSpeed(speed float64) string {
+	return strconv.FormatFloat(speed, 'f', -1, 64) + "×"
+}
+
+// Sets the names of the streaming profiles to choose from, and the selected profile.
+func (a *AuxControls) SetStreamingProfiles(names []string, selected string) {
+	a.streamingProfiles = names
+	a.streamingProfile.Hidden = len(names) < 2
+	a.SetStreamingProfile(selected)
+}
+
+// Sets the displayed name of the active streaming profile.
+func (a *AuxControls) SetStreamingProfile(name string) {
+	a.streamingProfile.SetText(name)
+}
+
+func (a *AuxControls) showStreamingProfileMenu() {
+	items := make([]*fyne.MenuItem, len(a.streamingProfiles))
+	for i, name := range a.streamingProfiles {
+		_name := name
+		items[i] = fyne.NewMenuItem(name, func() {
+			a.SetStreamingProfile(_name)
+			if a.OnStreamingProfileChanged != nil {
+				a.OnStreamingProfileChanged(_name)
+			}
+		})
+		items[i].Checked = name == a.streamingProfile.Text
+	}
+	c := fyne.CurrentApp().Driver().CanvasForObject(a)
+	pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(a.streamingProfile)
+	menu := widget.NewPopUpMenu(fyne.NewMenu("", items...), c)
+	menu.ShowAtPosition(pos.SubtractXY(0, menu.MinSize().Height))
+}
+
 func (a *AuxControls) CreateRenderer() fyne.WidgetRenderer {
 	a.ExtendBaseWidget(a)
 	return widget.NewSimpleRenderer(a.container)
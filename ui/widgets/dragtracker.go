@@ -0,0 +1,43 @@
+package widgets
+
+import (
+	"math"
+
+	"fyne.io/fyne/v2"
+)
+
+// How far the mouse must move before a press on a tappable, draggable widget
+// is treated as a drag. The driver starts a drag after a couple of pixels,
+// which would otherwise swallow clicks made with a slightly moving mouse.
+const dragThreshold = 8
+
+// Tells drags apart from taps on widgets that are both tappable and draggable.
+type dragTracker struct {
+	active   bool
+	dragging bool
+	// position of the mouse press, relative to the widget
+	start fyne.Position
+	moved fyne.Delta
+}
+
+// Called from Dragged. Returns true once the gesture is a drag.
+func (d *dragTracker) onDragged(e *fyne.DragEvent) bool {
+	if !d.active {
+		d.active = true
+		d.start = e.Position.Subtract(e.Dragged)
+	}
+	d.moved.DX += e.Dragged.DX
+	d.moved.DY += e.Dragged.DY
+	if !d.dragging && math.Hypot(float64(d.moved.DX), float64(d.moved.DY)) >= dragThreshold {
+		d.dragging = true
+	}
+	return d.dragging
+}
+
+// Called from DragEnd. Returns whether the gesture was a drag, or if it
+// should be handled as a tap instead, the position of the mouse press.
+func (d *dragTracker) onDragEnd() (wasDrag bool, tapPos fyne.Position) {
+	wasDrag, tapPos = d.dragging, d.start
+	*d = dragTracker{}
+	return wasDrag, tapPos
+}
@@ -7,6 +7,7 @@ import (
 	"supersonic/backend"
 	"supersonic/res"
 	"supersonic/sharedutil"
+	"supersonic/ui/util"
 	"sync"
 
 	"fyne.io/fyne/v2"
@@ -51,7 +52,8 @@ type GridView struct {
 
 	GridViewState
 
-	grid *GridWrap
+	grid  *GridWrap
+	cards []*GridViewItem
 }
 
 type GridViewState struct {
@@ -65,9 +67,17 @@ type GridViewState struct {
 
 	OnPlay              func(id string, shuffle bool)
 	OnAddToQueue        func(id string)
+	OnInstantMix        func(id string)
 	OnAddToPlaylist     func(id string)
 	OnShowItemPage      func(id string)
 	OnShowSecondaryPage func(id string)
+	// OnDragged, if set, is called as the cover of an item is dragged,
+	// and OnDragEnd when it is dropped.
+	OnDragged func(id string, e *fyne.DragEvent)
+	OnDragEnd func()
+	// If set, items can be pinned to the sidebar from their context menu.
+	// Must be set before the grid is first shown.
+	OnPinToSidebar func(id, name string)
 
 	scrollPos float32
 }
@@ -164,6 +174,11 @@ func (g *GridView) createGridWrap() {
 					g.OnAddToQueue(card.ItemID())
 				}
 			}
+			card.OnInstantMix = func() {
+				if g.OnInstantMix != nil {
+					g.OnInstantMix(card.ItemID())
+				}
+			}
 			card.OnShowSecondaryPage = func() {
 				if g.OnShowSecondaryPage != nil {
 					g.OnShowSecondaryPage(card.SecondaryID())
@@ -179,6 +194,22 @@ func (g *GridView) createGridWrap() {
 					g.OnAddToPlaylist(card.ItemID())
 				}
 			}
+			card.OnDragged = func(e *fyne.DragEvent) {
+				if g.OnDragged != nil {
+					g.OnDragged(card.ItemID(), e)
+				}
+			}
+			card.OnDragEnd = func() {
+				if g.OnDragEnd != nil {
+					g.OnDragEnd()
+				}
+			}
+			if g.OnPinToSidebar != nil {
+				card.OnPinToSidebar = func() {
+					g.OnPinToSidebar(card.ItemID(), card.ItemName())
+				}
+			}
+			g.cards = append(g.cards, card)
 			return card
 		},
 		// update func
@@ -235,6 +266,16 @@ func (g *GridView) doUpdateItemCard(itemIdx int, card *GridViewItem) {
 	}
 }
 
+// Returns the ID of the item shown at the absolute position, or "" if none.
+func (g *GridView) ItemIDAt(pos fyne.Position) string {
+	for _, card := range g.cards {
+		if util.IsPosInObject(card, pos) {
+			return card.ItemID()
+		}
+	}
+	return ""
+}
+
 func (g *GridView) lenItems() int {
 	g.itemsMutex.RLock()
 	defer g.itemsMutex.RUnlock()
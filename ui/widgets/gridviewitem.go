@@ -19,6 +19,7 @@ var _ fyne.Widget = (*GridViewItem)(nil)
 var _ fyne.Widget = (*coverImage)(nil)
 var _ fyne.Tappable = (*coverImage)(nil)
 var _ fyne.SecondaryTappable = (*coverImage)(nil)
+var _ fyne.Draggable = (*coverImage)(nil)
 
 type coverImage struct {
 	widget.BaseWidget
@@ -28,6 +29,10 @@ type coverImage struct {
 	OnPlay            func()
 	OnShowPage        func()
 	OnShowContextMenu func(fyne.Position)
+	OnDragged         func(*fyne.DragEvent)
+	OnDragEnd         func()
+
+	drag dragTracker
 }
 
 func newCoverImage() *coverImage {
@@ -69,6 +74,21 @@ func (c *coverImage) TappedSecondary(e *fyne.PointEvent) {
 	}
 }
 
+func (c *coverImage) Dragged(e *fyne.DragEvent) {
+	if c.drag.onDragged(e) && c.OnDragged != nil {
+		c.OnDragged(e)
+	}
+}
+
+func (c *coverImage) DragEnd() {
+	wasDrag, tapPos := c.drag.onDragEnd()
+	if !wasDrag {
+		c.Tapped(&fyne.PointEvent{Position: tapPos})
+	} else if c.OnDragEnd != nil {
+		c.OnDragEnd()
+	}
+}
+
 func (a *coverImage) MouseIn(*desktop.MouseEvent) {
 	a.playbtn.Hidden = false
 	a.Refresh()
@@ -121,6 +141,7 @@ type GridViewItem struct {
 	widget.BaseWidget
 
 	itemID        string
+	itemName      string
 	secondaryID   string
 	primaryText   *CustomHyperlink
 	secondaryText *CustomHyperlink
@@ -136,9 +157,14 @@ type GridViewItem struct {
 
 	OnPlay              func(shuffle bool)
 	OnAddToQueue        func()
+	OnInstantMix        func()
 	OnAddToPlaylist     func()
 	OnShowItemPage      func()
 	OnShowSecondaryPage func()
+	OnDragged           func(*fyne.DragEvent)
+	OnDragEnd           func()
+	// If set, a "Pin to sidebar" item is shown in the context menu
+	OnPinToSidebar func()
 }
 
 func NewGridViewItem() *GridViewItem {
@@ -156,6 +182,16 @@ func NewGridViewItem() *GridViewItem {
 		}
 	}
 	g.Cover.OnShowPage = showItemFn
+	g.Cover.OnDragged = func(e *fyne.DragEvent) {
+		if g.OnDragged != nil {
+			g.OnDragged(e)
+		}
+	}
+	g.Cover.OnDragEnd = func() {
+		if g.OnDragEnd != nil {
+			g.OnDragEnd()
+		}
+	}
 	g.primaryText.OnTapped = showItemFn
 	g.secondaryText.OnTapped = func() {
 		if g.OnShowSecondaryPage != nil {
@@ -176,18 +212,25 @@ func (g *GridViewItem) createContainer() {
 
 func (g *GridViewItem) showContextMenu(pos fyne.Position) {
 	if g.menu == nil {
-		g.menu = widget.NewPopUpMenu(fyne.NewMenu("",
+		menu := fyne.NewMenu("",
 			fyne.NewMenuItem("Play", func() { g.onPlay(false) }),
 			fyne.NewMenuItem("Shuffle", func() { g.onPlay(true) }),
 			fyne.NewMenuItem("Add to queue", g.onAddToQueue),
-			fyne.NewMenuItem("Add to playlist...", g.onAddToPlaylist)),
-			fyne.CurrentApp().Driver().CanvasForObject(g))
+			fyne.NewMenuItem("Instant mix", g.onInstantMix),
+			fyne.NewMenuItem("Add to playlist...", g.onAddToPlaylist))
+		if g.OnPinToSidebar != nil {
+			menu.Items = append(menu.Items,
+				fyne.NewMenuItemSeparator(),
+				fyne.NewMenuItem("Pin to sidebar", g.OnPinToSidebar))
+		}
+		g.menu = widget.NewPopUpMenu(menu, fyne.CurrentApp().Driver().CanvasForObject(g))
 	}
 	g.menu.ShowAtPosition(pos)
 }
 
 func (g *GridViewItem) Update(model GridViewItemModel) {
 	g.itemID = model.ID
+	g.itemName = model.Name
 	g.secondaryID = model.SecondaryID
 	g.primaryText.SetText(model.Name)
 	g.secondaryText.SetText(model.Secondary)
@@ -200,6 +243,10 @@ func (g *GridViewItem) ItemID() string {
 	return g.itemID
 }
 
+func (g *GridViewItem) ItemName() string {
+	return g.itemName
+}
+
 func (g *GridViewItem) SecondaryID() string {
 	return g.secondaryID
 }
@@ -220,6 +267,12 @@ func (g *GridViewItem) onAddToQueue() {
 	}
 }
 
+func (g *GridViewItem) onInstantMix() {
+	if g.OnInstantMix != nil {
+		g.OnInstantMix()
+	}
+}
+
 func (g *GridViewItem) onAddToPlaylist() {
 	if g.OnAddToPlaylist != nil {
 		g.OnAddToPlaylist()
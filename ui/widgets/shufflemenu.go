@@ -0,0 +1,36 @@
+package widgets
+
+import (
+	"supersonic/backend"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Returns menu items to choose the shuffle strategy.
+func NewShuffleMenuItems(onShuffle func(backend.ShuffleMode)) []*fyne.MenuItem {
+	newItem := func(label string, mode backend.ShuffleMode) *fyne.MenuItem {
+		return fyne.NewMenuItem(label, func() { onShuffle(mode) })
+	}
+	return []*fyne.MenuItem{
+		newItem("Shuffle", backend.ShuffleRandom),
+		newItem("Weighted by rating and plays", backend.ShuffleWeighted),
+		newItem("Spread artists and albums", backend.ShuffleSpread),
+		newItem("Shuffle albums", backend.ShuffleAlbums),
+	}
+}
+
+// Returns a button that shows a menu to choose the shuffle strategy when tapped.
+func NewShuffleButton(label string, icon fyne.Resource, onShuffle func(backend.ShuffleMode)) *widget.Button {
+	var pop *widget.PopUpMenu
+	btn := widget.NewButtonWithIcon(label, icon, nil)
+	btn.OnTapped = func() {
+		if pop == nil {
+			pop = widget.NewPopUpMenu(fyne.NewMenu("", NewShuffleMenuItems(onShuffle)...),
+				fyne.CurrentApp().Driver().CanvasForObject(btn))
+		}
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(btn)
+		pop.ShowAtPosition(fyne.NewPos(pos.X, pos.Y+btn.Size().Height))
+	}
+	return btn
+}
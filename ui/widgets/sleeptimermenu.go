@@ -0,0 +1,47 @@
+package widgets
+
+import (
+	"fmt"
+	"supersonic/backend"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+var sleepTimerMinutes = []int{15, 30, 45, 60, 90}
+
+// Returns menu items to set the sleep timer and the stop after current
+// track setting, reflecting their current state. Shared by the
+// AuxControls sleep timer menu and the system tray menu.
+func NewSleepTimerMenuItems(
+	mode backend.SleepTimerMode,
+	stopAfterCurrent bool,
+	onSetSleepTimer func(backend.SleepTimerMode, time.Duration),
+	onSetStopAfterCurrent func(bool),
+) []*fyne.MenuItem {
+	newItem := func(label string, m backend.SleepTimerMode, d time.Duration) *fyne.MenuItem {
+		item := fyne.NewMenuItem(label, func() {
+			onSetSleepTimer(m, d)
+		})
+		item.Checked = m == mode && m != backend.SleepTimerDuration
+		return item
+	}
+
+	items := []*fyne.MenuItem{newItem("Off", backend.SleepTimerOff, 0)}
+	for _, min := range sleepTimerMinutes {
+		items = append(items, newItem(fmt.Sprintf("%d minutes", min),
+			backend.SleepTimerDuration, time.Duration(min)*time.Minute))
+	}
+	items = append(items,
+		fyne.NewMenuItemSeparator(),
+		newItem("End of track", backend.SleepTimerEndOfTrack, 0),
+		newItem("End of album", backend.SleepTimerEndOfAlbum, 0),
+		newItem("End of queue", backend.SleepTimerEndOfQueue, 0),
+		fyne.NewMenuItemSeparator(),
+	)
+	stopAfter := fyne.NewMenuItem("Stop after current track", func() {
+		onSetStopAfterCurrent(!stopAfterCurrent)
+	})
+	stopAfter.Checked = stopAfterCurrent
+	return append(items, stopAfter)
+}
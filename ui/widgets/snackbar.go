@@ -0,0 +1,39 @@
+package widgets
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// A short message shown briefly at the bottom of the window,
+// with an optional action button such as "Undo".
+type Snackbar struct {
+	widget.BaseWidget
+
+	OnAction func()
+
+	container *fyne.Container
+}
+
+// Creates a snackbar with the message. The action button is
+// only shown if actionText is not empty.
+func NewSnackbar(message, actionText string) *Snackbar {
+	s := &Snackbar{}
+	s.ExtendBaseWidget(s)
+	s.container = container.NewHBox(widget.NewLabel(message))
+	if actionText != "" {
+		btn := widget.NewButton(actionText, func() {
+			if s.OnAction != nil {
+				s.OnAction()
+			}
+		})
+		btn.Importance = widget.LowImportance
+		s.container.Add(btn)
+	}
+	return s
+}
+
+func (s *Snackbar) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(s.container)
+}
@@ -58,17 +58,30 @@ type Tracklist struct {
 	// the tracklist context menu.
 	DisablePlaybackMenu bool
 
+	// IsSecondaryTrack, if set, reports whether the track at the given index
+	// should be rendered with an italic title, e.g. for tracks added automatically.
+	IsSecondaryTrack func(idx int) bool
+
 	// user action callbacks
 	OnPlayTrackAt   func(int)
 	OnPlaySelection func(tracks []*subsonic.Child)
 	OnAddToQueue    func(trackIDs []*subsonic.Child)
+	OnInstantMix    func(tracks []*subsonic.Child)
 	OnAddToPlaylist func(trackIDs []string)
+	OnDownload      func(tracks []*subsonic.Child)
 	OnSetFavorite   func(trackIDs []string, fav bool)
-	OnSetRating     func(trackIDs []string, rating int)
+	// prevRatings holds the ratings of the tracks before the change
+	OnSetRating func(trackIDs []string, rating int, prevRatings []int)
 
 	OnShowArtistPage func(artistID string)
 	OnShowAlbumPage  func(albumID string)
 
+	// OnDragged, if set, is called as the selected tracks are dragged
+	// (a row that isn't selected is selected when dragging starts),
+	// and OnDragEnd when they are dropped.
+	OnDragged func(e *fyne.DragEvent)
+	OnDragEnd func()
+
 	OnColumnVisibilityMenuShown func(*widget.PopUp)
 	OnVisibleColumnsChanged     func([]string)
 	OnTrackShown                func(tracknum int)
@@ -81,6 +94,7 @@ type Tracklist struct {
 	colLayout    *layouts.ColumnsLayout
 	hdr          *ListHeader
 	list         *widget.List
+	rows         []*TrackRow
 	ctxMenu      *fyne.Menu
 	container    *fyne.Container
 }
@@ -107,6 +121,7 @@ func NewTracklist(tracks []*subsonic.Child) *Tracklist {
 			tr.OnTapped = func() { t.onSelectTrack(tr.trackIdx) }
 			tr.OnTappedSecondary = t.onShowContextMenu
 			tr.OnDoubleTapped = func() { t.onPlayTrackAt(tr.trackIdx) }
+			t.rows = append(t.rows, tr)
 			return tr
 		},
 		func(itemID widget.ListItemID, item fyne.CanvasObject) {
@@ -274,6 +289,51 @@ func (t *Tracklist) onSelectTrack(idx int) {
 	t.list.Refresh()
 }
 
+// Returns the index at which tracks dropped at the absolute position would be
+// inserted, or -1 if the position is not over the tracklist.
+func (t *Tracklist) DropIndexAt(pos fyne.Position) int {
+	if !util.IsPosInObject(t.list, pos) {
+		return -1
+	}
+	d := fyne.CurrentApp().Driver()
+	idx, last := -1, -1
+	for _, r := range t.rows {
+		rowPos := d.AbsolutePositionForObject(r)
+		if rowPos.IsZero() || !r.Visible() {
+			continue // not currently shown by the list
+		}
+		if pos.Y < rowPos.Y+r.Size().Height/2 {
+			if idx < 0 || r.trackIdx < idx {
+				idx = r.trackIdx
+			}
+		} else if r.trackIdx > last {
+			last = r.trackIdx
+		}
+	}
+	if idx < 0 {
+		// below the last track
+		idx = last + 1
+	}
+	return idx
+}
+
+func (t *Tracklist) onDragged(idx int, e *fyne.DragEvent) {
+	if t.OnDragged == nil {
+		return
+	}
+	if !t.selectionMgr.IsSelected(idx) {
+		t.selectionMgr.Select(idx)
+		t.list.Refresh()
+	}
+	t.OnDragged(e)
+}
+
+func (t *Tracklist) onDragEnd() {
+	if t.OnDragEnd != nil {
+		t.OnDragEnd()
+	}
+}
+
 func (t *Tracklist) onShowContextMenu(e *fyne.PointEvent, trackIdx int) {
 	t.selectionMgr.Select(trackIdx)
 	t.list.Refresh()
@@ -292,6 +352,12 @@ func (t *Tracklist) onShowContextMenu(e *fyne.PointEvent, trackIdx int) {
 						t.OnAddToQueue(t.selectedTracks())
 					}
 				}))
+			t.ctxMenu.Items = append(t.ctxMenu.Items,
+				fyne.NewMenuItem("Instant mix", func() {
+					if t.OnInstantMix != nil {
+						t.OnInstantMix(t.selectedTracks())
+					}
+				}))
 		}
 		t.ctxMenu.Items = append(t.ctxMenu.Items,
 			fyne.NewMenuItem("Add to playlist...", func() {
@@ -299,6 +365,12 @@ func (t *Tracklist) onShowContextMenu(e *fyne.PointEvent, trackIdx int) {
 					t.OnAddToPlaylist(t.selectedTrackIDs())
 				}
 			}))
+		t.ctxMenu.Items = append(t.ctxMenu.Items,
+			fyne.NewMenuItem("Download...", func() {
+				if t.OnDownload != nil {
+					t.OnDownload(t.selectedTracks())
+				}
+			}))
 		t.ctxMenu.Items = append(t.ctxMenu.Items, fyne.NewMenuItemSeparator())
 		t.ctxMenu.Items = append(t.ctxMenu.Items,
 			fyne.NewMenuItem("Set favorite", func() {
@@ -344,6 +416,14 @@ func (t *Tracklist) onSetFavorite(trackID string, fav bool) {
 }
 
 func (t *Tracklist) onSetFavorites(tracks []*subsonic.Child, fav bool, needRefresh bool) {
+	// only notify about tracks whose status actually changes,
+	// so the change can be undone by inverting it
+	tracks = sharedutil.FilterSlice(tracks, func(tr *subsonic.Child) bool {
+		return tr.Starred.IsZero() == fav
+	})
+	if len(tracks) == 0 {
+		return
+	}
 	for _, tr := range tracks {
 		if fav {
 			tr.Starred = time.Now()
@@ -369,7 +449,9 @@ func (t *Tracklist) onSetRating(trackID string, rating int) {
 }
 
 func (t *Tracklist) onSetRatings(tracks []*subsonic.Child, rating int, needRefresh bool) {
-	for _, tr := range tracks {
+	prevRatings := make([]int, len(tracks))
+	for i, tr := range tracks {
+		prevRatings[i] = tr.UserRating
 		tr.UserRating = rating
 	}
 	if needRefresh {
@@ -377,7 +459,7 @@ func (t *Tracklist) onSetRatings(tracks []*subsonic.Child, rating int, needRefre
 	}
 	// notify listener
 	if t.OnSetRating != nil {
-		t.OnSetRating(sharedutil.TracksToIDs(tracks), rating)
+		t.OnSetRating(sharedutil.TracksToIDs(tracks), rating, prevRatings)
 	}
 }
 
@@ -486,15 +568,16 @@ type TrackRow struct {
 	ListRowBase
 
 	// internal state
-	tracklist  *Tracklist
-	trackIdx   int
-	trackNum   int
-	trackID    string
-	artistID   string
-	albumID    string
-	isPlaying  bool
-	isFavorite bool
-	playCount  int64
+	tracklist   *Tracklist
+	trackIdx    int
+	trackNum    int
+	trackID     string
+	artistID    string
+	albumID     string
+	isPlaying   bool
+	isSecondary bool
+	isFavorite  bool
+	playCount   int64
 
 	num      *widget.RichText
 	name     *widget.RichText
@@ -512,6 +595,7 @@ type TrackRow struct {
 	OnTappedSecondary func(e *fyne.PointEvent, trackIdx int)
 
 	playingIcon fyne.CanvasObject
+	drag        dragTracker
 }
 
 func NewTrackRow(tracklist *Tracklist, playingIcon fyne.CanvasObject) *TrackRow {
@@ -621,6 +705,13 @@ func (t *TrackRow) Update(tr *subsonic.Child, rowNum int) {
 		}
 	}
 
+	// Render whether track is secondary or not
+	isSecondary := t.tracklist.IsSecondaryTrack != nil && t.tracklist.IsSecondaryTrack(t.trackIdx)
+	if isSecondary != t.isSecondary {
+		t.isSecondary = isSecondary
+		t.name.Segments[0].(*widget.TextSegment).Style.TextStyle.Italic = isSecondary
+	}
+
 	// Render favorite column
 	if tr.Starred.IsZero() {
 		t.isFavorite = false
@@ -670,3 +761,17 @@ func (t *TrackRow) TappedSecondary(e *fyne.PointEvent) {
 		t.OnTappedSecondary(e, t.trackIdx)
 	}
 }
+
+func (t *TrackRow) Dragged(e *fyne.DragEvent) {
+	if t.drag.onDragged(e) {
+		t.tracklist.onDragged(t.trackIdx, e)
+	}
+}
+
+func (t *TrackRow) DragEnd() {
+	if wasDrag, _ := t.drag.onDragEnd(); wasDrag {
+		t.tracklist.onDragEnd()
+	} else {
+		t.Tapped(nil)
+	}
+}